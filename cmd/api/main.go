@@ -12,9 +12,14 @@ import (
 	"github.com/tuingking/supersvc/config"
 	"github.com/tuingking/supersvc/handler/api"
 	"github.com/tuingking/supersvc/handler/mux"
+	"github.com/tuingking/supersvc/pkg/cache"
 	"github.com/tuingking/supersvc/pkg/httpserver"
+	"github.com/tuingking/supersvc/pkg/idempotency"
 	"github.com/tuingking/supersvc/pkg/logger"
 	"github.com/tuingking/supersvc/pkg/mysql"
+	"github.com/tuingking/supersvc/pkg/notify"
+	"github.com/tuingking/supersvc/pkg/outbox"
+	"github.com/tuingking/supersvc/pkg/token"
 	"github.com/tuingking/supersvc/svc/user"
 )
 
@@ -30,13 +35,44 @@ func main() {
 	// infra
 	dbLocal := cfg.MySQL["localhost"]
 	db := mysql.NewMySQL(dbLocal)
+	userCache, err := cache.New(cfg.Cache)
+	if err != nil {
+		log.Fatal().Err(err).Msg("failed: cache.New")
+	}
 
 	// service
 	userRepo := user.NewRepository(cfg.User.Repository, db)
-	usersvc := user.NewService(cfg.User, userRepo)
+	if userCache != nil {
+		userRepo = user.NewCachedRepository(cfg.User.Cache, userRepo, userCache)
+	}
+	notifier, err := notify.New(cfg.Notify)
+	if err != nil {
+		log.Fatal().Err(err).Msg("failed: notify.New")
+	}
+	if cfg.Notify.Driver == notify.DriverLog {
+		log.Warn().Msg("notifier driver is log, verification code and password reset token are logged")
+	}
+	usersvc := user.NewService(cfg.User, userRepo, user.WithNotifier(notifier))
+
+	// background job
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go user.NewSweeper(cfg.User.Sweeper, usersvc).Run(ctx)
+	idempotencyStore := idempotency.NewMySQLStore(db)
+	go idempotency.NewSweeper(cfg.Idempotency.Sweeper, idempotencyStore).Run(ctx)
+	if cfg.Outbox.Webhook.URL != "" {
+		publisher := outbox.NewWebhookPublisher(cfg.Outbox.Webhook)
+		go outbox.NewRelay(cfg.Outbox.Relay, outbox.NewMySQLStore(db), publisher).Run(ctx)
+	} else {
+		log.Warn().Msg("outbox webhook url is not set, domain event is kept in outbox")
+	}
 
 	// handler
-	apiHandler := api.NewHandler(cfg, usersvc)
+	if cfg.Token.Secret == "" {
+		log.Warn().Msg("token secret is not set, login and authenticated endpoint are rejected")
+	}
+	signer := token.NewSigner(cfg.Token)
+	apiHandler := api.NewHandler(cfg, usersvc, idempotencyStore, signer)
 	httpHandler := mux.NewMux(apiHandler)
 
 	// server
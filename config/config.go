@@ -5,9 +5,15 @@ import (
 
 	"github.com/pkg/errors"
 	"github.com/spf13/viper"
+	"github.com/tuingking/supersvc/pkg/cache"
 	"github.com/tuingking/supersvc/pkg/httpserver"
+	"github.com/tuingking/supersvc/pkg/idempotency"
 	"github.com/tuingking/supersvc/pkg/logger"
 	"github.com/tuingking/supersvc/pkg/mysql"
+	"github.com/tuingking/supersvc/pkg/notify"
+	"github.com/tuingking/supersvc/pkg/outbox"
+	"github.com/tuingking/supersvc/pkg/tenant"
+	"github.com/tuingking/supersvc/pkg/token"
 	"github.com/tuingking/supersvc/svc/user"
 )
 
@@ -15,6 +21,13 @@ type Config struct {
 	HttpServer httpserver.Option
 	MySQL      map[string]mysql.Option
 	Logger     logger.Option
+	Cache      cache.Option
+
+	Idempotency idempotency.Option
+	Outbox      outbox.Option
+	Token       token.Option
+	Tenant      tenant.Option
+	Notify      notify.Option
 
 	// service config
 	User user.Option
@@ -1,6 +1,7 @@
 package entity
 
 import (
+	"errors"
 	"net/http"
 	"time"
 
@@ -17,13 +18,24 @@ type HttpResponse struct {
 }
 
 type Error struct {
-	Status bool   `json:"status" example:"false"` // true if we have error
-	Msg    string `json:"msg" example:" "`        // error message
-	Code   int    `json:"code" example:"0"`       // application error code for tracing
+	Status  bool        `json:"status" example:"false"` // true if we have error
+	Msg     string      `json:"msg" example:" "`        // error message
+	Code    int         `json:"code" example:"0"`       // application error code for tracing
+	Details interface{} `json:"details,omitempty"`      // additional info to resolve the error
 }
 
-// Render writes the http response to the client
+// ErrorCoder is implemented by error which has application error code
+type ErrorCoder interface {
+	ErrorCode() int
+}
+
+// Render writes the http response to the client.
+// when it is deferred by a panicking handler nothing is written, the panic is passed on to the recoverer middleware.
 func (res *HttpResponse) Render(w http.ResponseWriter, r *http.Request) {
+	if rec := recover(); rec != nil {
+		panic(rec)
+	}
+
 	if res.Code == 0 {
 		res.Code = http.StatusOK
 	}
@@ -37,7 +49,8 @@ func (res *HttpResponse) Render(w http.ResponseWriter, r *http.Request) {
 }
 
 // SetError set the response to return the given error.
-// code is http status code, http.StatusInternalServerError is the default value
+// code is http status code, http.StatusInternalServerError is the default value.
+// application error code is taken from the first ErrorCoder in the error chain.
 func (res *HttpResponse) SetError(err error, code ...int) {
 	if len(code) > 0 {
 		res.Code = code[0]
@@ -50,6 +63,10 @@ func (res *HttpResponse) SetError(err error, code ...int) {
 			Msg:    err.Error(),
 			Status: true,
 		}
-	}
 
+		var coder ErrorCoder
+		if errors.As(err, &coder) {
+			res.Error.Code = coder.ErrorCode()
+		}
+	}
 }
@@ -0,0 +1,223 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/tuingking/supersvc/entity"
+	"github.com/tuingking/supersvc/pkg/ctxkey"
+	"github.com/tuingking/supersvc/pkg/parser"
+	"github.com/tuingking/supersvc/pkg/tenant"
+	"github.com/tuingking/supersvc/svc/user"
+)
+
+const bearerPrefix = "Bearer "
+
+var (
+	errMissingToken = errors.New("missing bearer token")
+	errTokenTenant  = errors.New("token has no valid tenant")
+	errOtherTenant  = errors.New("token is issued for another tenant")
+)
+
+// GatewayTenant set X-Tenant-ID header as tenant of the request when it is sent by trusted gateway, see tenant.Option.
+// it must run before middleware.RealIP, so the peer address is checked instead of forwarded one.
+// the header sent by other client is ignored here and checked against the access token by Authenticate.
+func (h *Handler) GatewayTenant(next http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		if tenantID := r.Header.Get(ctxkey.XTenantID.String()); tenantID != "" && h.cfg.Tenant.IsTrustedGateway(r.RemoteAddr) {
+			r = r.WithContext(tenant.WithID(r.Context(), tenantID))
+		}
+		next.ServeHTTP(w, r)
+	}
+
+	return http.HandlerFunc(fn)
+}
+
+// GatewayActor set X-Actor header as actor of the request when it is sent by trusted gateway, see GatewayTenant.
+// actor of authenticated request is the subject of the access token, see Authenticate.
+func (h *Handler) GatewayActor(next http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		if actor := r.Header.Get(ctxkey.XActor.String()); actor != "" && h.cfg.Tenant.IsTrustedGateway(r.RemoteAddr) {
+			r = r.WithContext(context.WithValue(r.Context(), ctxkey.XActor, actor))
+		}
+		next.ServeHTTP(w, r)
+	}
+
+	return http.HandlerFunc(fn)
+}
+
+// Authenticate reject request without valid bearer access token with 401.
+// subject of the token is set as ctxkey.XUserID and ctxkey.XActor, and its tenant as ctxkey.XTenantID.
+// request with tenant header other than the tenant of the token is rejected too, so is token of user which is
+// no longer active or has changed password since the token is issued, see user.Service.VerifyAccess.
+func (h *Handler) Authenticate(next http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		var resp entity.HttpResponse
+
+		reject := func(err error, challenge string) {
+			w.Header().Set("WWW-Authenticate", challenge)
+			resp.SetError(err, http.StatusUnauthorized)
+			resp.Render(w, r)
+		}
+
+		auth := r.Header.Get("Authorization")
+		if !strings.HasPrefix(auth, bearerPrefix) {
+			reject(errMissingToken, "Bearer")
+			return
+		}
+
+		claims, err := h.token.Verify(strings.TrimPrefix(auth, bearerPrefix))
+		if err != nil {
+			reject(err, `Bearer error="invalid_token"`)
+			return
+		}
+		if tenant.Validate(claims.Tenant) != nil {
+			reject(errTokenTenant, `Bearer error="invalid_token"`)
+			return
+		}
+		if tenantID := r.Header.Get(ctxkey.XTenantID.String()); tenantID != "" && tenantID != claims.Tenant {
+			reject(errOtherTenant, `Bearer error="invalid_token"`)
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), ctxkey.XUserID, claims.Subject)
+		ctx = tenant.WithID(ctx, claims.Tenant)
+		ctx = context.WithValue(ctx, ctxkey.XActor, claims.Subject)
+
+		_, err = h.user.VerifyAccess(ctx, claims.Subject, time.Unix(claims.IssuedAt, 0))
+		if errors.Is(err, user.ErrAccessRevoked) {
+			reject(err, `Bearer error="invalid_token"`)
+			return
+		}
+		if err != nil {
+			setUserError(&resp, err)
+			resp.Render(w, r)
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(ctx))
+	}
+
+	return http.HandlerFunc(fn)
+}
+
+// Login authenticate email and password and issue access token
+func (h *Handler) Login(w http.ResponseWriter, r *http.Request) {
+	logger := r.Context().Value(ctxkey.ZeroLogSubLogger).(zerolog.Logger)
+
+	var resp entity.HttpResponse
+	defer resp.Render(w, r)
+
+	var req LoginRequest
+	if err := parser.Bind(r, &req); err != nil {
+		logger.Err(err).Msg("err: bind request")
+		resp.SetError(err, bindErrorCode(err))
+		return
+	}
+
+	// the user is looked up in the tenant set by the gateway, so the token is issued for it
+	tenantID, err := tenant.FromContext(r.Context())
+	if err != nil {
+		logger.Err(err).Msg("err: tenant")
+		resp.SetError(user.ErrInvalidTenant, userErrorCode(user.ErrInvalidTenant))
+		return
+	}
+
+	usr, err := h.user.Authenticate(r.Context(), req.Email, req.Password)
+	if err != nil {
+		logger.Err(err).Msg("err: authenticate")
+		resp.SetError(err, userErrorCode(err))
+		return
+	}
+
+	accessToken, claims, err := h.token.Sign(usr.ID, tenantID)
+	if err != nil {
+		logger.Err(err).Msg("err: sign token")
+		resp.SetError(err)
+		return
+	}
+
+	resp.Data = LoginResponse{
+		AccessToken: accessToken,
+		TokenType:   strings.TrimSpace(bearerPrefix),
+		ExpiresAt:   claims.ExpiresAt,
+		User:        usr,
+	}
+	resp.Message = "login success"
+}
+
+// ChangePassword change password of the authenticated user
+func (h *Handler) ChangePassword(w http.ResponseWriter, r *http.Request) {
+	logger := r.Context().Value(ctxkey.ZeroLogSubLogger).(zerolog.Logger)
+
+	var resp entity.HttpResponse
+	defer resp.Render(w, r)
+
+	var req ChangePasswordRequest
+	if err := parser.Bind(r, &req); err != nil {
+		logger.Err(err).Msg("err: bind request")
+		resp.SetError(err, bindErrorCode(err))
+		return
+	}
+
+	userID, _ := r.Context().Value(ctxkey.XUserID).(string)
+	if err := h.user.ChangePassword(r.Context(), userID, req.CurrentPassword, req.Password); err != nil {
+		logger.Err(err).Msg("err: change password")
+		setUserError(&resp, err)
+		return
+	}
+
+	resp.Message = "password changed"
+}
+
+// RequestPasswordReset send password reset token to the email, the token is never returned to the caller.
+// the response is 202 whether or not the email is registered.
+func (h *Handler) RequestPasswordReset(w http.ResponseWriter, r *http.Request) {
+	logger := r.Context().Value(ctxkey.ZeroLogSubLogger).(zerolog.Logger)
+
+	var resp entity.HttpResponse
+	defer resp.Render(w, r)
+
+	var req PasswordResetRequest
+	if err := parser.Bind(r, &req); err != nil {
+		logger.Err(err).Msg("err: bind request")
+		resp.SetError(err, bindErrorCode(err))
+		return
+	}
+
+	if err := h.user.RequestPasswordReset(r.Context(), req.Email); err != nil {
+		logger.Err(err).Msg("err: request password reset")
+		resp.SetError(err, userErrorCode(err))
+		return
+	}
+
+	resp.Code = http.StatusAccepted
+	resp.Message = "password reset token is sent to the email if it is registered"
+}
+
+// ResetPassword set password with password reset token
+func (h *Handler) ResetPassword(w http.ResponseWriter, r *http.Request) {
+	logger := r.Context().Value(ctxkey.ZeroLogSubLogger).(zerolog.Logger)
+
+	var resp entity.HttpResponse
+	defer resp.Render(w, r)
+
+	var req ResetPasswordRequest
+	if err := parser.Bind(r, &req); err != nil {
+		logger.Err(err).Msg("err: bind request")
+		resp.SetError(err, bindErrorCode(err))
+		return
+	}
+
+	if err := h.user.ResetPassword(r.Context(), req.Token, req.Password); err != nil {
+		logger.Err(err).Msg("err: reset password")
+		setUserError(&resp, err)
+		return
+	}
+
+	resp.Message = "password reset"
+}
@@ -0,0 +1,146 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/tuingking/supersvc/config"
+	"github.com/tuingking/supersvc/pkg/ctxkey"
+	"github.com/tuingking/supersvc/pkg/tenant"
+	"github.com/tuingking/supersvc/pkg/token"
+	"github.com/tuingking/supersvc/svc/user"
+	"github.com/tuingking/supersvc/svc/user/mocks"
+)
+
+func Test_Handler_Authenticate(t *testing.T) {
+	signer := token.NewSigner(token.Option{Secret: "secret"})
+	svc := mocks.NewService(t)
+	h := &Handler{token: signer, user: svc}
+
+	var userID, actor, tenantID interface{}
+	next := h.Authenticate(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userID, actor, tenantID = r.Context().Value(ctxkey.XUserID), r.Context().Value(ctxkey.XActor), r.Context().Value(ctxkey.XTenantID)
+	}))
+
+	do := func(auth string, headerTenant ...string) *httptest.ResponseRecorder {
+		userID, actor, tenantID = nil, nil, nil
+		r := httptest.NewRequest(http.MethodPut, "/api/v1/auth/password", nil)
+		if auth != "" {
+			r.Header.Set("Authorization", auth)
+		}
+		if len(headerTenant) > 0 {
+			r.Header.Set(ctxkey.XTenantID.String(), headerTenant[0])
+		}
+		w := httptest.NewRecorder()
+		next.ServeHTTP(w, r)
+		return w
+	}
+
+	t.Run("valid token", func(t *testing.T) {
+		accessToken, claims, err := signer.Sign("u1", "acme")
+		assert.Nil(t, err)
+		svc.On("VerifyAccess", mock.Anything, "u1", time.Unix(claims.IssuedAt, 0)).Return(user.User{ID: "u1"}, nil).Twice()
+
+		w := do("Bearer " + accessToken)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "u1", userID)
+		assert.Equal(t, "u1", actor)
+		assert.Equal(t, "acme", tenantID, "tenant is set from the token")
+
+		w = do("Bearer "+accessToken, "acme")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "acme", tenantID)
+	})
+
+	t.Run("other tenant", func(t *testing.T) {
+		accessToken, _, err := signer.Sign("u1", "acme")
+		assert.Nil(t, err)
+
+		w := do("Bearer "+accessToken, "other")
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Contains(t, w.Body.String(), errOtherTenant.Error())
+		assert.Nil(t, userID)
+	})
+
+	t.Run("revoked", func(t *testing.T) {
+		accessToken, _, err := signer.Sign("u2", "acme")
+		assert.Nil(t, err)
+		svc.On("VerifyAccess", mock.Anything, "u2", mock.Anything).Return(user.User{}, errors.Wrap(user.ErrAccessRevoked, "user is banned")).Once()
+
+		w := do("Bearer " + accessToken)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Contains(t, w.Header().Get("WWW-Authenticate"), "invalid_token")
+		assert.Nil(t, userID)
+	})
+
+	t.Run("rejected", func(t *testing.T) {
+		other, _, err := token.NewSigner(token.Option{Secret: "other"}).Sign("u1", "acme")
+		assert.Nil(t, err)
+		noTenant, _, err := signer.Sign("u1", "")
+		assert.Nil(t, err)
+
+		for _, auth := range []string{"", "Basic dTE6cGFzcw==", "Bearer invalid", "Bearer " + other, "Bearer " + noTenant} {
+			w := do(auth)
+			assert.Equal(t, http.StatusUnauthorized, w.Code, auth)
+			assert.NotEmpty(t, w.Header().Get("WWW-Authenticate"))
+			assert.Nil(t, userID)
+		}
+	})
+}
+
+func Test_Handler_GatewayTenant(t *testing.T) {
+	h := &Handler{cfg: &config.Config{Tenant: tenant.Option{TrustedGateways: []string{"10.0.0.0/8"}}}}
+
+	var tenantID string
+	var tenantErr error
+	next := h.GatewayTenant(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tenantID, tenantErr = tenant.FromContext(r.Context())
+	}))
+
+	do := func(remoteAddr, headerTenant string) {
+		r := httptest.NewRequest(http.MethodPost, "/api/v1/auth/login", nil)
+		r.RemoteAddr = remoteAddr
+		r.Header.Set(ctxkey.XTenantID.String(), headerTenant)
+		next.ServeHTTP(httptest.NewRecorder(), r)
+	}
+
+	do("10.1.2.3:8080", "acme")
+	assert.Nil(t, tenantErr)
+	assert.Equal(t, "acme", tenantID, "header of trusted gateway is the tenant")
+
+	do("192.0.2.1:1234", "acme")
+	assert.ErrorIs(t, tenantErr, tenant.ErrMissing, "header of other client is not trusted")
+
+	do("10.1.2.3:8080", "")
+	assert.ErrorIs(t, tenantErr, tenant.ErrMissing)
+}
+
+func Test_Handler_GatewayActor(t *testing.T) {
+	h := &Handler{cfg: &config.Config{Tenant: tenant.Option{TrustedGateways: []string{"10.0.0.0/8"}}}}
+
+	var actor interface{}
+	next := h.GatewayActor(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		actor = r.Context().Value(ctxkey.XActor)
+	}))
+
+	do := func(remoteAddr, headerActor string) {
+		r := httptest.NewRequest(http.MethodPost, "/api/v1/auth/password-reset", nil)
+		r.RemoteAddr = remoteAddr
+		r.Header.Set(ctxkey.XActor.String(), headerActor)
+		next.ServeHTTP(httptest.NewRecorder(), r)
+	}
+
+	do("10.1.2.3:8080", "support")
+	assert.Equal(t, "support", actor, "header of trusted gateway is the actor")
+
+	do("192.0.2.1:1234", "support")
+	assert.Nil(t, actor, "header of other client is not trusted")
+
+	do("10.1.2.3:8080", "")
+	assert.Nil(t, actor)
+}
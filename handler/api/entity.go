@@ -1 +1,125 @@
 package api
+
+import (
+	"time"
+
+	"github.com/tuingking/supersvc/svc/user"
+)
+
+type UserIDRequest struct {
+	ID string `path:"id"`
+}
+
+// TenantHeader document X-Tenant-ID header, see Handler.GatewayTenant
+type TenantHeader struct {
+	Tenant string `header:"X-Tenant-ID" description:"tenant of the request, trusted only from the gateway. with access token it must match the tenant of the token, user of other tenant is never visible"`
+}
+
+// IdempotencyHeader document Idempotency-Key header, see Handler.Idempotent
+type IdempotencyHeader struct {
+	Key string `header:"Idempotency-Key" description:"retry with the same key replay the original response, reusing the key for different request is rejected"`
+}
+
+type GetUserByIDRequest struct {
+	ID string `path:"id"`
+
+	// IncludeDeleted include soft deleted user, for admin
+	IncludeDeleted bool `param:"include_deleted"`
+}
+
+// IfMatchHeader carry ETag of the user for optimistic concurrency control
+type IfMatchHeader struct {
+	IfMatch string `header:"If-Match" json:"-" required:"true" description:"ETag of the user, request is rejected with 412 when the user has been modified"`
+}
+
+// CreateUserRequest only accept field written by the client, id, version and timestamps are owned by the server
+type CreateUserRequest struct {
+	user.CreateUser
+}
+
+type UpdateUserRequest struct {
+	ID string `path:"id" json:"-"`
+	IfMatchHeader
+	user.UpdateUser
+}
+
+type DeleteUserRequest struct {
+	ID string `path:"id"`
+	IfMatchHeader
+}
+
+type ChangeUserStatusRequest struct {
+	ID     string `path:"id" json:"-"`
+	Reason string `json:"reason"`
+}
+
+type SuspendUserRequest struct {
+	ID string `path:"id" json:"-"`
+
+	// Until is when suspension is lifted automatically, omit to suspend until lifted manually
+	Until  *time.Time `json:"until"`
+	Reason string     `json:"reason"`
+}
+
+type EraseUserRequest struct {
+	ID     string `path:"id" json:"-"`
+	Reason string `json:"reason"`
+}
+
+type MergeUserRequest struct {
+	ID string `path:"id" json:"-"`
+
+	// DuplicateID is merged into the user of the path
+	DuplicateID string `json:"duplicate_id"`
+	Reason      string `json:"reason"`
+}
+
+// AuthorizationHeader document access token of authenticated endpoint, see Handler.Authenticate
+type AuthorizationHeader struct {
+	Authorization string `header:"Authorization" required:"true" description:"Bearer access token issued by login"`
+}
+
+type LoginRequest struct {
+	Email    string `json:"email"`
+	Password string `json:"password"`
+}
+
+type LoginResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+
+	// ExpiresAt is unix second
+	ExpiresAt int64     `json:"expires_at"`
+	User      user.User `json:"user"`
+}
+
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password"`
+	Password        string `json:"password"`
+}
+
+type PasswordResetRequest struct {
+	Email string `json:"email"`
+}
+
+type ResetPasswordRequest struct {
+	Token    string `json:"token"`
+	Password string `json:"password"`
+}
+
+type VerifyContactRequest struct {
+	ID   string `path:"id" json:"-"`
+	Code string `json:"code"`
+}
+
+type GetUserHistoryRequest struct {
+	ID string `path:"id"`
+	user.GetAuditLogParam
+}
+
+// TransitionErrorDetails is error details for rejected user status transition
+type TransitionErrorDetails struct {
+	Status            user.Status   `json:"status"`
+	AllowedNextStates []user.Status `json:"allowed_next_states"`
+	ReasonRequired    bool          `json:"reason_required"`
+}
@@ -0,0 +1,54 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const (
+	headerETag    = "ETag"
+	headerIfMatch = "If-Match"
+)
+
+var (
+	errPreconditionRequired = errors.New("If-Match header is required, use ETag of the user")
+	errInvalidIfMatch       = errors.New("If-Match header should be ETag of the user")
+)
+
+// etag format version as ETag, e.g: "3"
+func etag(version int64) string {
+	return `"` + strconv.FormatInt(version, 10) + `"`
+}
+
+func setETag(w http.ResponseWriter, version int64) {
+	w.Header().Set(headerETag, etag(version))
+}
+
+// parseIfMatch return version of If-Match header, weak ETag is accepted
+func parseIfMatch(ifMatch string) (int64, error) {
+	ifMatch = strings.TrimSpace(ifMatch)
+	if ifMatch == "" {
+		return 0, errPreconditionRequired
+	}
+
+	tag := strings.TrimPrefix(ifMatch, "W/")
+	if len(tag) < 2 || tag[0] != '"' || tag[len(tag)-1] != '"' {
+		return 0, errInvalidIfMatch
+	}
+
+	version, err := strconv.ParseInt(tag[1:len(tag)-1], 10, 64)
+	if err != nil || version < 1 {
+		return 0, errInvalidIfMatch
+	}
+	return version, nil
+}
+
+// ifMatchErrorCode return http status code for parseIfMatch error
+func ifMatchErrorCode(err error) int {
+	if errors.Is(err, errPreconditionRequired) {
+		return http.StatusPreconditionRequired
+	}
+	return http.StatusBadRequest
+}
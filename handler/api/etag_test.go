@@ -0,0 +1,38 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_ParseIfMatch(t *testing.T) {
+	testCase := []struct {
+		in     string
+		exp    int64
+		expErr error
+	}{
+		{in: `"3"`, exp: 3},
+		{in: ` W/"12" `, exp: 12},
+		{in: "", expErr: errPreconditionRequired},
+		{in: "3", expErr: errInvalidIfMatch},
+		{in: `"abc"`, expErr: errInvalidIfMatch},
+		{in: `"0"`, expErr: errInvalidIfMatch},
+		{in: `*`, expErr: errInvalidIfMatch},
+		{in: `"1", "2"`, expErr: errInvalidIfMatch},
+	}
+
+	for _, tc := range testCase {
+		t.Run(tc.in, func(t *testing.T) {
+			got, err := parseIfMatch(tc.in)
+			if tc.expErr != nil {
+				assert.ErrorIs(t, err, tc.expErr)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, tc.exp, got)
+		})
+	}
+
+	assert.Equal(t, `"3"`, etag(3))
+}
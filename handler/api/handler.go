@@ -1,8 +1,14 @@
 package api
 
 import (
+	"errors"
+	"net/http"
+
 	"github.com/rs/zerolog/log"
 	"github.com/tuingking/supersvc/config"
+	"github.com/tuingking/supersvc/pkg/idempotency"
+	"github.com/tuingking/supersvc/pkg/parser"
+	"github.com/tuingking/supersvc/pkg/token"
 	"github.com/tuingking/supersvc/svc/user"
 )
 
@@ -11,14 +17,27 @@ type Handler struct {
 
 	// service
 	user user.Service
+
+	idempotency idempotency.Store
+	token       token.Signer
 }
 
-func NewHandler(cfg *config.Config, user user.Service) Handler {
+func NewHandler(cfg *config.Config, user user.Service, idempotency idempotency.Store, token token.Signer) Handler {
 	h := Handler{
-		cfg:  cfg,
-		user: user,
+		cfg:         cfg,
+		user:        user,
+		idempotency: idempotency,
+		token:       token,
 	}
 	log.Debug().Msg("api handler initalized")
 
 	return h
 }
+
+// bindErrorCode return http status code for parser.Bind error
+func bindErrorCode(err error) int {
+	if errors.Is(err, parser.ErrBodyTooLarge) {
+		return http.StatusRequestEntityTooLarge
+	}
+	return http.StatusBadRequest
+}
@@ -0,0 +1,133 @@
+package api
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/middleware"
+	"github.com/tuingking/supersvc/entity"
+	"github.com/tuingking/supersvc/pkg/idempotency"
+	"github.com/tuingking/supersvc/pkg/logger"
+	"github.com/tuingking/supersvc/pkg/parser"
+	"github.com/tuingking/supersvc/pkg/tenant"
+)
+
+const maxIdempotentBodySize = 1 << 20 // 1 MB
+
+var (
+	errInvalidIdempotencyKey    = errors.New("idempotency key should not exceed 255 characters")
+	errIdempotencyKeyReused     = errors.New("idempotency key is already used for a different request")
+	errIdempotencyKeyInProgress = errors.New("request with the same idempotency key is in progress, retry later")
+)
+
+// Idempotent replay the stored response and its ETag header when request is retried with the same Idempotency-Key header.
+// request without the header is passed through. response with 5xx status code is not stored,
+// so the request can be retried with the same key. key is scoped by tenant of the request.
+func (h *Handler) Idempotent(next http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(idempotency.HeaderKey)
+		if key == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		ctx := r.Context()
+		log := logger.Get(ctx)
+
+		var resp entity.HttpResponse
+		if len(key) > idempotency.MaxKeyLength {
+			resp.SetError(errInvalidIdempotencyKey, http.StatusBadRequest)
+			resp.Render(w, r)
+			return
+		}
+
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxIdempotentBodySize))
+		if err != nil {
+			var maxBytesErr *http.MaxBytesError
+			if errors.As(err, &maxBytesErr) {
+				resp.SetError(parser.ErrBodyTooLarge, http.StatusRequestEntityTooLarge)
+			} else {
+				resp.SetError(err, http.StatusBadRequest)
+			}
+			resp.Render(w, r)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		// request without valid tenant is rejected by the user service, its key is scoped to no tenant
+		tenantID, _ := tenant.FromContext(ctx)
+		key = idempotency.ScopedKey(tenantID, key)
+
+		rec := idempotency.Record{
+			Key:         key,
+			RequestHash: idempotency.Hash(r.Method, r.URL.Path, body),
+			ExpiresAt:   time.Now().Add(h.cfg.Idempotency.GetTTL()),
+		}
+
+		err = h.idempotency.Lock(ctx, rec)
+		if errors.Is(err, idempotency.ErrKeyExists) {
+			h.replay(w, r, rec)
+			return
+		}
+		if err != nil {
+			log.Err(err).Msg("failed: idempotency.Lock")
+			resp.SetError(err)
+			resp.Render(w, r)
+			return
+		}
+
+		var buf bytes.Buffer
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		ww.Tee(&buf)
+
+		defer func() {
+			if ww.Status() >= http.StatusInternalServerError || ww.Status() == 0 {
+				if err := h.idempotency.Unlock(ctx, key); err != nil {
+					log.Err(err).Msg("failed: idempotency.Unlock")
+				}
+				return
+			}
+			if err := h.idempotency.Save(ctx, key, ww.Status(), ww.Header().Get(headerETag), buf.Bytes()); err != nil {
+				log.Err(err).Msg("failed: idempotency.Save")
+			}
+		}()
+
+		next.ServeHTTP(ww, r)
+	}
+
+	return http.HandlerFunc(fn)
+}
+
+// replay write the stored response of the key
+func (h *Handler) replay(w http.ResponseWriter, r *http.Request, req idempotency.Record) {
+	log := logger.Get(r.Context())
+
+	var resp entity.HttpResponse
+
+	rec, err := h.idempotency.Get(r.Context(), req.Key)
+	switch {
+	case errors.Is(err, idempotency.ErrNotFound):
+		// unlocked or expired after Lock
+		resp.SetError(errIdempotencyKeyInProgress, http.StatusConflict)
+	case err != nil:
+		log.Err(err).Msg("failed: idempotency.Get")
+		resp.SetError(err)
+	case rec.RequestHash != req.RequestHash:
+		resp.SetError(errIdempotencyKeyReused, http.StatusUnprocessableEntity)
+	case !rec.Completed():
+		resp.SetError(errIdempotencyKeyInProgress, http.StatusConflict)
+	default:
+		w.Header().Set(idempotency.HeaderReplayed, "true")
+		if rec.ETag != "" {
+			w.Header().Set(headerETag, rec.ETag)
+		}
+		w.WriteHeader(rec.StatusCode)
+		w.Write(rec.Body)
+		return
+	}
+
+	resp.Render(w, r)
+}
@@ -0,0 +1,115 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tuingking/supersvc/config"
+	"github.com/tuingking/supersvc/pkg/idempotency"
+	"github.com/tuingking/supersvc/pkg/tenant"
+)
+
+func Test_Handler_Idempotent(t *testing.T) {
+	store := idempotency.NewMemoryStore()
+	h := &Handler{cfg: &config.Config{}, idempotency: store}
+
+	var calls int
+	status := http.StatusCreated
+	next := h.Idempotent(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		setETag(w, int64(calls))
+		w.WriteHeader(status)
+		w.Write([]byte(`{"call":` + strconv.Itoa(calls) + `}`))
+	}))
+
+	do := func(key, body string, tenantID ...string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, "/api/v1/users", strings.NewReader(body))
+		if key != "" {
+			r.Header.Set(idempotency.HeaderKey, key)
+		}
+		if len(tenantID) > 0 {
+			r = r.WithContext(tenant.WithID(r.Context(), tenantID[0]))
+		}
+		w := httptest.NewRecorder()
+		next.ServeHTTP(w, r)
+		return w
+	}
+
+	t.Run("without key", func(t *testing.T) {
+		calls = 0
+		do("", `{}`)
+		do("", `{}`)
+		assert.Equal(t, 2, calls)
+	})
+
+	t.Run("replay identical retry", func(t *testing.T) {
+		calls = 0
+		w := do("k1", `{"name":"foo"}`)
+		assert.Equal(t, http.StatusCreated, w.Code)
+		assert.Equal(t, `{"call":1}`, w.Body.String())
+
+		w = do("k1", `{"name":"foo"}`)
+		assert.Equal(t, 1, calls)
+		assert.Equal(t, http.StatusCreated, w.Code)
+		assert.Equal(t, `{"call":1}`, w.Body.String())
+		assert.Equal(t, "true", w.Header().Get(idempotency.HeaderReplayed))
+		assert.Equal(t, `"1"`, w.Header().Get(headerETag), "ETag of the stored response")
+	})
+
+	t.Run("key reused with different body", func(t *testing.T) {
+		calls = 0
+		do("k2", `{"name":"foo"}`)
+
+		w := do("k2", `{"name":"bar"}`)
+		assert.Equal(t, 1, calls)
+		assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	})
+
+	t.Run("server error is not stored", func(t *testing.T) {
+		calls, status = 0, http.StatusInternalServerError
+		defer func() { status = http.StatusCreated }()
+
+		do("k3", `{}`)
+		do("k3", `{}`)
+		assert.Equal(t, 2, calls)
+	})
+
+	t.Run("request in progress", func(t *testing.T) {
+		calls = 0
+		store.Lock(context.Background(), idempotency.Record{
+			Key:         idempotency.ScopedKey("", "k4"),
+			RequestHash: idempotency.Hash(http.MethodPost, "/api/v1/users", []byte(`{}`)),
+			ExpiresAt:   time.Now().Add(time.Hour),
+		})
+
+		w := do("k4", `{}`)
+		assert.Equal(t, 0, calls)
+		assert.Equal(t, http.StatusConflict, w.Code)
+	})
+
+	t.Run("key is scoped by tenant", func(t *testing.T) {
+		calls = 0
+		w := do("k5", `{}`, "acme")
+		assert.Equal(t, `{"call":1}`, w.Body.String())
+
+		w = do("k5", `{}`, "other")
+		assert.Equal(t, 2, calls, "the same key of other tenant is not replayed")
+		assert.Equal(t, `{"call":2}`, w.Body.String())
+		assert.Empty(t, w.Header().Get(idempotency.HeaderReplayed))
+
+		w = do("k5", `{}`, "acme")
+		assert.Equal(t, 2, calls)
+		assert.Equal(t, `{"call":1}`, w.Body.String())
+	})
+
+	t.Run("key too long", func(t *testing.T) {
+		w := do(strings.Repeat("k", idempotency.MaxKeyLength+1), `{}`)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
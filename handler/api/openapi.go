@@ -0,0 +1,150 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/rs/zerolog"
+	"github.com/tuingking/supersvc/pkg/ctxkey"
+	"github.com/tuingking/supersvc/pkg/openapi"
+	"github.com/tuingking/supersvc/svc/user"
+)
+
+// GetOpenAPI return OpenAPI document generated from param struct
+func (h *Handler) GetOpenAPI(w http.ResponseWriter, r *http.Request) {
+	logger := r.Context().Value(ctxkey.ZeroLogSubLogger).(zerolog.Logger)
+
+	doc, err := openAPIDocument()
+	if err != nil {
+		logger.Err(err).Msg("failed: generate openapi document")
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(doc)
+}
+
+func openAPIDocument() (openapi.Document, error) {
+	var err error
+	params := func(param interface{}) []openapi.Parameter {
+		if err != nil {
+			return nil
+		}
+		var p []openapi.Parameter
+		p, err = openapi.Parameters(param)
+		return p
+	}
+
+	userIDParams := params(UserIDRequest{})
+	ifMatchParams := params(DeleteUserRequest{})
+	doc := openapi.Document{
+		OpenAPI: openapi.Version,
+		Info:    openapi.Info{Title: "supersvc", Version: "v1"},
+		Paths: map[string]openapi.PathItem{
+			"/api/v1/auth/login": {
+				"post": {Summary: "authenticate email and password and issue bearer access token, user is locked after too many failed login"},
+			},
+			"/api/v1/auth/password-reset": {
+				"post": {Summary: "send single use password reset token to the email, response is 202 whether or not the email is registered"},
+			},
+			"/api/v1/auth/password-reset/confirm": {
+				"post": {Summary: "set password with password reset token"},
+			},
+			"/api/v1/auth/password": {
+				"put": {Summary: "change password of the authenticated user", Parameters: params(AuthorizationHeader{})},
+			},
+			"/api/v1/users": {
+				"get":  {Summary: "list user", Parameters: params(user.GetUserParam{})},
+				"post": {Summary: "create user", Parameters: params(IdempotencyHeader{})},
+			},
+			"/api/v1/users:import": {
+				"post": {
+					Summary: "bulk import user from CSV (text/csv) or NDJSON (application/x-ndjson) body",
+					Parameters: []openapi.Parameter{{
+						Name:        "dry_run",
+						In:          "query",
+						Description: "validate without storing",
+						Schema:      &openapi.Schema{Type: "boolean"},
+					}},
+				},
+			},
+			"/api/v1/users:export": {
+				"get": {
+					Summary:    "stream user matching the list filter as CSV (text/csv) or NDJSON (application/x-ndjson) chosen by Accept header, page and limit is ignored",
+					Parameters: params(user.GetUserParam{}),
+				},
+			},
+			"/api/v1/users:duplicates": {
+				"get": {Summary: "list group of user with the same phone and similar name, oldest first"},
+			},
+			"/api/v1/users/{id}": {
+				"get":    {Summary: "get user, merged user is resolved to the survivor", Parameters: params(GetUserByIDRequest{})},
+				"put":    {Summary: "replace user", Parameters: ifMatchParams},
+				"patch":  {Summary: "update user with JSON merge patch", Parameters: ifMatchParams},
+				"delete": {Summary: "soft delete user", Parameters: ifMatchParams},
+			},
+			"/api/v1/users/{id}/restore": {
+				"post": {Summary: "restore soft deleted user", Parameters: userIDParams},
+			},
+			"/api/v1/users/{id}/purge": {
+				"delete": {Summary: "permanently delete soft deleted user", Parameters: userIDParams},
+			},
+			"/api/v1/users/{id}/history": {
+				"get": {Summary: "list audit log of user mutation, newest first by default", Parameters: params(GetUserHistoryRequest{})},
+			},
+			"/api/v1/users/{id}/erase": {
+				"post": {Summary: "anonymize name, phone and email of user and redact them from audit log, erased user is returned unchanged", Parameters: userIDParams},
+			},
+			"/api/v1/users/{id}/data": {
+				"get": {Summary: "export user with its whole audit log, the export is audited", Parameters: userIDParams},
+			},
+			"/api/v1/users/{id}/verify-email": {
+				"post": {Summary: "send one-time code to email of the user, previous code is replaced", Parameters: userIDParams},
+			},
+			"/api/v1/users/{id}/verify-email/confirm": {
+				"post": {Summary: "verify email of the user with the code, the code is invalidated after too many attempt", Parameters: userIDParams},
+			},
+			"/api/v1/users/{id}/verify-phone": {
+				"post": {Summary: "send one-time code to phone of the user by SMS, previous code is replaced", Parameters: userIDParams},
+			},
+			"/api/v1/users/{id}/verify-phone/confirm": {
+				"post": {Summary: "verify phone of the user with the code, the code is invalidated after too many attempt", Parameters: userIDParams},
+			},
+			"/api/v1/users/{id}/merge": {
+				"post": {Summary: "merge duplicate_id into the user, the duplicate is soft deleted and its id is resolved to the user", Parameters: userIDParams},
+			},
+			"/api/v1/users/{id}/activate": {
+				"post": {Summary: "activate user, banned user require reason", Parameters: userIDParams},
+			},
+			"/api/v1/users/{id}/deactivate": {
+				"post": {Summary: "deactivate user", Parameters: userIDParams},
+			},
+			"/api/v1/users/{id}/suspend": {
+				"post": {Summary: "suspend user until the given time, suspended user is rescheduled", Parameters: userIDParams},
+			},
+			"/api/v1/users/{id}/suspension": {
+				"delete": {Summary: "cancel user suspension", Parameters: userIDParams},
+			},
+			"/api/v1/users/{id}/ban": {
+				"post": {Summary: "ban user", Parameters: userIDParams},
+			},
+		},
+	}
+
+	// every user and auth API is scoped by tenant, user API take it from the access token
+	tenantParams := params(TenantHeader{})
+	authParams := params(AuthorizationHeader{})
+	for path, item := range doc.Paths {
+		for method, op := range item {
+			p := append([]openapi.Parameter{}, tenantParams...)
+			if strings.HasPrefix(path, "/api/v1/users") {
+				p = append(p, authParams...)
+			}
+			op.Parameters = append(p, op.Parameters...)
+			item[method] = op
+		}
+	}
+
+	return doc, err
+}
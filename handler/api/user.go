@@ -1,10 +1,15 @@
 package api
 
 import (
-	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"mime"
 	"net/http"
+	"strconv"
+	"strings"
 
+	"github.com/go-chi/chi/middleware"
 	"github.com/rs/zerolog"
 	"github.com/tuingking/supersvc/entity"
 	"github.com/tuingking/supersvc/pkg/ctxkey"
@@ -12,6 +17,13 @@ import (
 	"github.com/tuingking/supersvc/svc/user"
 )
 
+const maxPatchSize = 1 << 20 // 1 MB
+
+var (
+	errUnsupportedImportType = errors.New("content type should be text/csv or application/x-ndjson")
+	errUnsupportedExportType = errors.New("accept should be text/csv or application/x-ndjson")
+)
+
 func (h *Handler) GetUser(w http.ResponseWriter, r *http.Request) {
 	logger := r.Context().Value(ctxkey.ZeroLogSubLogger).(zerolog.Logger)
 
@@ -19,18 +31,16 @@ func (h *Handler) GetUser(w http.ResponseWriter, r *http.Request) {
 	defer resp.Render(w, r)
 
 	var p user.GetUserParam
-	par := parser.InitParamParser()
-	err := par.Decode(&p, r.URL.Query())
-	if err != nil {
-		logger.Err(err).Msg("failed: parser.Decode param")
-		fmt.Fprintf(w, "err: decode param")
+	if err := parser.Bind(r, &p); err != nil {
+		logger.Err(err).Msg("failed: parser.Bind param")
+		resp.SetError(err, bindErrorCode(err))
 		return
 	}
 
 	user, pagination, err := h.user.GetUser(r.Context(), p)
 	if err != nil {
 		logger.Err(err).Msg("failed: user.GetUser")
-		fmt.Fprintf(w, "err: get user")
+		setUserError(&resp, err)
 		return
 	}
 
@@ -44,21 +54,638 @@ func (h *Handler) CreateUser(w http.ResponseWriter, r *http.Request) {
 	var resp entity.HttpResponse
 	defer resp.Render(w, r)
 
-	var req user.User
-	err := json.NewDecoder(r.Body).Decode(&req)
-	if err != nil {
-		logger.Err(err).Msg("err: decode request body")
-		fmt.Fprintf(w, "err: decode request body")
+	var req CreateUserRequest
+	if err := parser.Bind(r, &req); err != nil {
+		logger.Err(err).Msg("err: bind request body")
+		resp.SetError(err, bindErrorCode(err))
 		return
 	}
 
-	user, err := h.user.CreateUser(r.Context(), req)
+	usr := user.User{Name: req.Name, Phone: req.Phone, Email: req.Email, Status: req.Status}
+	user, err := h.user.CreateUser(r.Context(), usr)
 	if err != nil {
 		logger.Err(err).Msg("err: create user")
-		fmt.Fprintf(w, "err: create user")
+		setUserError(&resp, err)
 		return
 	}
 
+	setETag(w, user.Version)
 	resp.Data = user
 	resp.Message = "user created"
 }
+
+// ImportUsers accept CSV (text/csv) or NDJSON (application/x-ndjson) body, the body is streamed.
+// set dry_run=true query param to validate without storing. when the import is stopped by error,
+// report of the rows before the error is returned as data with the error.
+func (h *Handler) ImportUsers(w http.ResponseWriter, r *http.Request) {
+	logger := r.Context().Value(ctxkey.ZeroLogSubLogger).(zerolog.Logger)
+
+	var resp entity.HttpResponse
+	defer resp.Render(w, r)
+
+	var dryRun bool
+	if v := r.URL.Query().Get("dry_run"); v != "" {
+		var err error
+		if dryRun, err = strconv.ParseBool(v); err != nil {
+			resp.SetError(fmt.Errorf("invalid dry_run: %w", err), http.StatusBadRequest)
+			return
+		}
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+
+	var src user.ImportReader
+	switch mediaType {
+	case "text/csv":
+		var err error
+		if src, err = user.NewCSVImportReader(r.Body); err != nil {
+			resp.SetError(err, userErrorCode(err))
+			return
+		}
+	case "application/x-ndjson", "application/ndjson":
+		src = user.NewNDJSONImportReader(r.Body)
+	default:
+		resp.SetError(errUnsupportedImportType, http.StatusUnsupportedMediaType)
+		return
+	}
+
+	report, err := h.user.ImportUsers(r.Context(), src, dryRun)
+	if err != nil {
+		logger.Err(err).Msg("err: import users")
+		// rows before the error may be stored already, report them so the client can resume
+		resp.SetError(err, userErrorCode(err))
+		resp.Data = report
+		return
+	}
+
+	resp.Data = report
+	resp.Message = "users imported"
+}
+
+func (h *Handler) GetUserHistory(w http.ResponseWriter, r *http.Request) {
+	logger := r.Context().Value(ctxkey.ZeroLogSubLogger).(zerolog.Logger)
+
+	var resp entity.HttpResponse
+	defer resp.Render(w, r)
+
+	var req GetUserHistoryRequest
+	if err := parser.Bind(r, &req); err != nil {
+		logger.Err(err).Msg("failed: parser.Bind param")
+		resp.SetError(err, bindErrorCode(err))
+		return
+	}
+
+	logs, pagination, err := h.user.GetUserHistory(r.Context(), req.ID, req.GetAuditLogParam)
+	if err != nil {
+		logger.Err(err).Msg("err: get user history")
+		resp.SetError(err, userErrorCode(err))
+		return
+	}
+
+	resp.Data = logs
+	resp.Pagination = pagination
+}
+
+// ExportUsers stream users matching the GetUser filter as CSV or NDJSON chosen by Accept header, default to CSV.
+// error after the first byte is written cannot be reported, the response is truncated and the error is logged.
+func (h *Handler) ExportUsers(w http.ResponseWriter, r *http.Request) {
+	logger := r.Context().Value(ctxkey.ZeroLogSubLogger).(zerolog.Logger)
+
+	var resp entity.HttpResponse
+
+	var p user.GetUserParam
+	if err := parser.Bind(r, &p); err != nil {
+		logger.Err(err).Msg("failed: parser.Bind param")
+		resp.SetError(err, bindErrorCode(err))
+		resp.Render(w, r)
+		return
+	}
+
+	mediaType, ext := exportMediaType(r.Header.Get("Accept"))
+	if mediaType == "" {
+		resp.SetError(errUnsupportedExportType, http.StatusNotAcceptable)
+		resp.Render(w, r)
+		return
+	}
+
+	ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+
+	var dst user.ExportWriter
+	switch mediaType {
+	case "text/csv":
+		dst = user.NewCSVExportWriter(ww)
+	default:
+		dst = user.NewNDJSONExportWriter(ww)
+	}
+
+	w.Header().Set("Content-Type", mediaType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="users.%s"`, ext))
+
+	n, err := h.user.ExportUsers(r.Context(), p, dst)
+	if err != nil {
+		logger.Err(err).Int("exported", n).Msg("err: export users")
+		if ww.BytesWritten() == 0 {
+			w.Header().Del("Content-Disposition")
+			w.Header().Set("Content-Type", "application/json")
+			resp.SetError(err, userErrorCode(err))
+			resp.Render(w, r)
+		}
+	}
+}
+
+// exportMediaType return the first supported media type in Accept header and its file extension,
+// empty media type is returned when none is supported
+func exportMediaType(accept string) (mediaType, ext string) {
+	if strings.TrimSpace(accept) == "" {
+		return "text/csv", "csv"
+	}
+
+	for _, v := range strings.Split(accept, ",") {
+		mt, _, err := mime.ParseMediaType(v)
+		if err != nil {
+			continue
+		}
+		switch mt {
+		case "text/csv", "text/*", "*/*":
+			return "text/csv", "csv"
+		case "application/x-ndjson", "application/ndjson":
+			return mt, "ndjson"
+		}
+	}
+	return "", ""
+}
+
+func (h *Handler) GetUserByID(w http.ResponseWriter, r *http.Request) {
+	logger := r.Context().Value(ctxkey.ZeroLogSubLogger).(zerolog.Logger)
+
+	var resp entity.HttpResponse
+	defer resp.Render(w, r)
+
+	var req GetUserByIDRequest
+	if err := parser.Bind(r, &req); err != nil {
+		logger.Err(err).Msg("err: bind request")
+		resp.SetError(err, bindErrorCode(err))
+		return
+	}
+
+	var opts []user.FindOption
+	if req.IncludeDeleted {
+		opts = append(opts, user.WithDeleted())
+	}
+
+	usr, err := h.user.GetUserByID(r.Context(), req.ID, opts...)
+	if err != nil {
+		logger.Err(err).Msg("err: get user by id")
+		resp.SetError(err, userErrorCode(err))
+		return
+	}
+
+	setETag(w, usr.Version)
+	resp.Data = usr
+}
+
+func (h *Handler) UpdateUser(w http.ResponseWriter, r *http.Request) {
+	logger := r.Context().Value(ctxkey.ZeroLogSubLogger).(zerolog.Logger)
+
+	var resp entity.HttpResponse
+	defer resp.Render(w, r)
+
+	var req UpdateUserRequest
+	if err := parser.Bind(r, &req); err != nil {
+		logger.Err(err).Msg("err: bind request")
+		resp.SetError(err, bindErrorCode(err))
+		return
+	}
+
+	version, err := parseIfMatch(req.IfMatch)
+	if err != nil {
+		resp.SetError(err, ifMatchErrorCode(err))
+		return
+	}
+
+	usr, err := h.user.UpdateUser(r.Context(), req.ID, version, req.UpdateUser)
+	if err != nil {
+		logger.Err(err).Msg("err: update user")
+		setUserError(&resp, err)
+		return
+	}
+
+	setETag(w, usr.Version)
+	resp.Data = usr
+	resp.Message = "user updated"
+}
+
+// PatchUser accept JSON merge patch (RFC 7396) as request body
+func (h *Handler) PatchUser(w http.ResponseWriter, r *http.Request) {
+	logger := r.Context().Value(ctxkey.ZeroLogSubLogger).(zerolog.Logger)
+
+	var resp entity.HttpResponse
+	defer resp.Render(w, r)
+
+	version, err := parseIfMatch(r.Header.Get(headerIfMatch))
+	if err != nil {
+		resp.SetError(err, ifMatchErrorCode(err))
+		return
+	}
+
+	patch, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxPatchSize))
+	if err != nil {
+		logger.Err(err).Msg("err: read request body")
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			resp.SetError(parser.ErrBodyTooLarge, http.StatusRequestEntityTooLarge)
+			return
+		}
+		resp.SetError(err, http.StatusBadRequest)
+		return
+	}
+
+	// body is the merge patch which is already read, only the path is bound
+	r.Body = http.NoBody
+	var req UserIDRequest
+	if err := parser.Bind(r, &req); err != nil {
+		logger.Err(err).Msg("err: bind request")
+		resp.SetError(err, bindErrorCode(err))
+		return
+	}
+
+	usr, err := h.user.PatchUser(r.Context(), req.ID, version, patch)
+	if err != nil {
+		logger.Err(err).Msg("err: patch user")
+		setUserError(&resp, err)
+		return
+	}
+
+	setETag(w, usr.Version)
+	resp.Data = usr
+	resp.Message = "user updated"
+}
+
+func (h *Handler) DeleteUser(w http.ResponseWriter, r *http.Request) {
+	logger := r.Context().Value(ctxkey.ZeroLogSubLogger).(zerolog.Logger)
+
+	var resp entity.HttpResponse
+	defer resp.Render(w, r)
+
+	var req DeleteUserRequest
+	if err := parser.Bind(r, &req); err != nil {
+		logger.Err(err).Msg("err: bind request")
+		resp.SetError(err, bindErrorCode(err))
+		return
+	}
+
+	version, err := parseIfMatch(req.IfMatch)
+	if err != nil {
+		resp.SetError(err, ifMatchErrorCode(err))
+		return
+	}
+
+	if err := h.user.DeleteUser(r.Context(), req.ID, version); err != nil {
+		logger.Err(err).Msg("err: delete user")
+		resp.SetError(err, userErrorCode(err))
+		return
+	}
+
+	resp.Message = "user deleted"
+}
+
+func (h *Handler) RestoreUser(w http.ResponseWriter, r *http.Request) {
+	logger := r.Context().Value(ctxkey.ZeroLogSubLogger).(zerolog.Logger)
+
+	var resp entity.HttpResponse
+	defer resp.Render(w, r)
+
+	var req UserIDRequest
+	if err := parser.Bind(r, &req); err != nil {
+		logger.Err(err).Msg("err: bind request")
+		resp.SetError(err, bindErrorCode(err))
+		return
+	}
+
+	usr, err := h.user.RestoreUser(r.Context(), req.ID)
+	if err != nil {
+		logger.Err(err).Msg("err: restore user")
+		resp.SetError(err, userErrorCode(err))
+		return
+	}
+
+	setETag(w, usr.Version)
+	resp.Data = usr
+	resp.Message = "user restored"
+}
+
+func (h *Handler) PurgeUser(w http.ResponseWriter, r *http.Request) {
+	logger := r.Context().Value(ctxkey.ZeroLogSubLogger).(zerolog.Logger)
+
+	var resp entity.HttpResponse
+	defer resp.Render(w, r)
+
+	var req UserIDRequest
+	if err := parser.Bind(r, &req); err != nil {
+		logger.Err(err).Msg("err: bind request")
+		resp.SetError(err, bindErrorCode(err))
+		return
+	}
+
+	if err := h.user.PurgeUser(r.Context(), req.ID); err != nil {
+		logger.Err(err).Msg("err: purge user")
+		resp.SetError(err, userErrorCode(err))
+		return
+	}
+
+	resp.Message = "user purged"
+}
+
+// EraseUser anonymize personal data of the user for right to be forgotten request, it is safe to retry
+func (h *Handler) EraseUser(w http.ResponseWriter, r *http.Request) {
+	logger := r.Context().Value(ctxkey.ZeroLogSubLogger).(zerolog.Logger)
+
+	var resp entity.HttpResponse
+	defer resp.Render(w, r)
+
+	var req EraseUserRequest
+	if err := parser.Bind(r, &req); err != nil {
+		logger.Err(err).Msg("err: bind request")
+		resp.SetError(err, bindErrorCode(err))
+		return
+	}
+
+	usr, err := h.user.EraseUser(r.Context(), req.ID, req.Reason)
+	if err != nil {
+		logger.Err(err).Msg("err: erase user")
+		resp.SetError(err, userErrorCode(err))
+		return
+	}
+
+	setETag(w, usr.Version)
+	resp.Data = usr
+	resp.Message = "user erased"
+}
+
+// ExportUserData return everything stored about the user for data access request
+func (h *Handler) ExportUserData(w http.ResponseWriter, r *http.Request) {
+	logger := r.Context().Value(ctxkey.ZeroLogSubLogger).(zerolog.Logger)
+
+	var resp entity.HttpResponse
+	defer resp.Render(w, r)
+
+	var req UserIDRequest
+	if err := parser.Bind(r, &req); err != nil {
+		logger.Err(err).Msg("err: bind request")
+		resp.SetError(err, bindErrorCode(err))
+		return
+	}
+
+	data, err := h.user.ExportUserData(r.Context(), req.ID)
+	if err != nil {
+		logger.Err(err).Msg("err: export user data")
+		resp.SetError(err, userErrorCode(err))
+		return
+	}
+
+	resp.Data = data
+}
+
+// FindDuplicateUsers list group of user with the same phone and similar name, for admin
+func (h *Handler) FindDuplicateUsers(w http.ResponseWriter, r *http.Request) {
+	logger := r.Context().Value(ctxkey.ZeroLogSubLogger).(zerolog.Logger)
+
+	var resp entity.HttpResponse
+	defer resp.Render(w, r)
+
+	groups, err := h.user.FindDuplicateUsers(r.Context())
+	if err != nil {
+		logger.Err(err).Msg("err: find duplicate users")
+		resp.SetError(err, userErrorCode(err))
+		return
+	}
+
+	resp.Data = groups
+}
+
+// MergeUser merge the duplicate into the user of the path, the survivor is returned
+func (h *Handler) MergeUser(w http.ResponseWriter, r *http.Request) {
+	logger := r.Context().Value(ctxkey.ZeroLogSubLogger).(zerolog.Logger)
+
+	var resp entity.HttpResponse
+	defer resp.Render(w, r)
+
+	var req MergeUserRequest
+	if err := parser.Bind(r, &req); err != nil {
+		logger.Err(err).Msg("err: bind request")
+		resp.SetError(err, bindErrorCode(err))
+		return
+	}
+
+	usr, err := h.user.MergeUser(r.Context(), req.ID, req.DuplicateID, req.Reason)
+	if err != nil {
+		logger.Err(err).Msg("err: merge user")
+		resp.SetError(err, userErrorCode(err))
+		return
+	}
+
+	setETag(w, usr.Version)
+	resp.Data = usr
+	resp.Message = "user merged"
+}
+
+func (h *Handler) SendEmailVerification(w http.ResponseWriter, r *http.Request) {
+	h.sendVerification(w, r, user.VerificationChannelEmail)
+}
+
+func (h *Handler) SendPhoneVerification(w http.ResponseWriter, r *http.Request) {
+	h.sendVerification(w, r, user.VerificationChannelPhone)
+}
+
+// sendVerification deliver one-time code to the contact, the code is not returned
+func (h *Handler) sendVerification(w http.ResponseWriter, r *http.Request, channel user.VerificationChannel) {
+	logger := r.Context().Value(ctxkey.ZeroLogSubLogger).(zerolog.Logger)
+
+	var resp entity.HttpResponse
+	defer resp.Render(w, r)
+
+	var req UserIDRequest
+	if err := parser.Bind(r, &req); err != nil {
+		logger.Err(err).Msg("err: bind request")
+		resp.SetError(err, bindErrorCode(err))
+		return
+	}
+
+	sent, err := h.user.SendVerification(r.Context(), req.ID, channel)
+	if err != nil {
+		logger.Err(err).Msg("err: send verification")
+		resp.SetError(err, userErrorCode(err))
+		return
+	}
+
+	resp.Data = sent
+	resp.Message = "verification code sent"
+}
+
+func (h *Handler) VerifyEmail(w http.ResponseWriter, r *http.Request) {
+	h.verifyContact(w, r, user.VerificationChannelEmail)
+}
+
+func (h *Handler) VerifyPhone(w http.ResponseWriter, r *http.Request) {
+	h.verifyContact(w, r, user.VerificationChannelPhone)
+}
+
+func (h *Handler) verifyContact(w http.ResponseWriter, r *http.Request, channel user.VerificationChannel) {
+	logger := r.Context().Value(ctxkey.ZeroLogSubLogger).(zerolog.Logger)
+
+	var resp entity.HttpResponse
+	defer resp.Render(w, r)
+
+	var req VerifyContactRequest
+	if err := parser.Bind(r, &req); err != nil {
+		logger.Err(err).Msg("err: bind request")
+		resp.SetError(err, bindErrorCode(err))
+		return
+	}
+
+	usr, err := h.user.VerifyContact(r.Context(), req.ID, channel, req.Code)
+	if err != nil {
+		logger.Err(err).Msg("err: verify contact")
+		resp.SetError(err, userErrorCode(err))
+		return
+	}
+
+	setETag(w, usr.Version)
+	resp.Data = usr
+	resp.Message = string(channel) + " verified"
+}
+
+func (h *Handler) ActivateUser(w http.ResponseWriter, r *http.Request) {
+	h.changeUserStatus(w, r, user.UserStatusActive)
+}
+
+func (h *Handler) DeactivateUser(w http.ResponseWriter, r *http.Request) {
+	h.changeUserStatus(w, r, user.UserStatusInActive)
+}
+
+// SuspendUser suspend user, optionally until the given time. suspended user is rescheduled
+func (h *Handler) SuspendUser(w http.ResponseWriter, r *http.Request) {
+	logger := r.Context().Value(ctxkey.ZeroLogSubLogger).(zerolog.Logger)
+
+	var resp entity.HttpResponse
+	defer resp.Render(w, r)
+
+	var req SuspendUserRequest
+	if err := parser.Bind(r, &req); err != nil {
+		logger.Err(err).Msg("err: bind request")
+		resp.SetError(err, bindErrorCode(err))
+		return
+	}
+
+	usr, err := h.user.SuspendUser(r.Context(), req.ID, req.Until, req.Reason)
+	if err != nil {
+		logger.Err(err).Msg("err: suspend user")
+		setUserError(&resp, err)
+		return
+	}
+
+	setETag(w, usr.Version)
+	resp.Data = usr
+	resp.Message = "user suspended"
+}
+
+func (h *Handler) CancelSuspension(w http.ResponseWriter, r *http.Request) {
+	logger := r.Context().Value(ctxkey.ZeroLogSubLogger).(zerolog.Logger)
+
+	var resp entity.HttpResponse
+	defer resp.Render(w, r)
+
+	var req UserIDRequest
+	if err := parser.Bind(r, &req); err != nil {
+		logger.Err(err).Msg("err: bind request")
+		resp.SetError(err, bindErrorCode(err))
+		return
+	}
+
+	usr, err := h.user.CancelSuspension(r.Context(), req.ID)
+	if err != nil {
+		logger.Err(err).Msg("err: cancel suspension")
+		setUserError(&resp, err)
+		return
+	}
+
+	setETag(w, usr.Version)
+	resp.Data = usr
+	resp.Message = "user suspension canceled"
+}
+
+func (h *Handler) BanUser(w http.ResponseWriter, r *http.Request) {
+	h.changeUserStatus(w, r, user.UserStatusBanned)
+}
+
+func (h *Handler) changeUserStatus(w http.ResponseWriter, r *http.Request, to user.Status) {
+	logger := r.Context().Value(ctxkey.ZeroLogSubLogger).(zerolog.Logger)
+
+	var resp entity.HttpResponse
+	defer resp.Render(w, r)
+
+	var req ChangeUserStatusRequest
+	if err := parser.Bind(r, &req); err != nil {
+		logger.Err(err).Msg("err: bind request")
+		resp.SetError(err, bindErrorCode(err))
+		return
+	}
+
+	usr, err := h.user.ChangeStatus(r.Context(), req.ID, to, req.Reason)
+	if err != nil {
+		logger.Err(err).Msg("err: change user status")
+		setUserError(&resp, err)
+		return
+	}
+
+	setETag(w, usr.Version)
+	resp.Data = usr
+	resp.Message = "user " + to.String()
+}
+
+// setUserError set response error with details for user service error
+func setUserError(resp *entity.HttpResponse, err error) {
+	resp.SetError(err, userErrorCode(err))
+
+	var transitionErr *user.TransitionError
+	if errors.As(err, &transitionErr) {
+		resp.Error.Details = TransitionErrorDetails{
+			Status:            transitionErr.From,
+			AllowedNextStates: transitionErr.Allowed,
+			ReasonRequired:    transitionErr.ReasonRequired,
+		}
+	}
+
+	var validationErr *user.ValidationError
+	if errors.As(err, &validationErr) {
+		resp.Error.Details = validationErr.Fields
+	}
+}
+
+// userErrorCode return http status code for user service error
+func userErrorCode(err error) int {
+	switch {
+	case errors.Is(err, user.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, user.ErrImportLineTooLong):
+		return http.StatusRequestEntityTooLarge
+	case errors.Is(err, user.ErrVersionConflict):
+		return http.StatusPreconditionFailed
+	case errors.Is(err, user.ErrInvalidPatch), errors.Is(err, user.ErrInvalidStatus), errors.Is(err, user.ErrInvalidSuspension),
+		errors.Is(err, user.ErrInvalidMerge), errors.Is(err, user.ErrValidation), errors.Is(err, user.ErrInvalidResetToken),
+		errors.Is(err, user.ErrInvalidVerificationCode), errors.Is(err, user.ErrNoContact), errors.Is(err, user.ErrInvalidSortBy),
+		errors.Is(err, user.ErrInvalidTenant), errors.Is(err, user.ErrInvalidImport):
+		return http.StatusBadRequest
+	case errors.Is(err, user.ErrInvalidCredential), errors.Is(err, user.ErrAccessRevoked):
+		return http.StatusUnauthorized
+	case errors.Is(err, user.ErrAccountLocked):
+		return http.StatusLocked
+	case errors.Is(err, user.ErrVerificationCooldown):
+		return http.StatusTooManyRequests
+	case errors.Is(err, user.ErrDuplicateEmail), errors.Is(err, user.ErrInvalidTransition), errors.Is(err, user.ErrNotSuspended),
+		errors.Is(err, user.ErrContactVerified):
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
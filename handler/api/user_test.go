@@ -0,0 +1,58 @@
+package api
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/tuingking/supersvc/entity"
+	"github.com/tuingking/supersvc/svc/user"
+)
+
+func Test_ExportMediaType(t *testing.T) {
+	testCase := []struct {
+		accept  string
+		expType string
+		expExt  string
+	}{
+		{accept: "", expType: "text/csv", expExt: "csv"},
+		{accept: "*/*", expType: "text/csv", expExt: "csv"},
+		{accept: "text/csv; charset=utf-8", expType: "text/csv", expExt: "csv"},
+		{accept: "application/json, application/x-ndjson;q=0.9", expType: "application/x-ndjson", expExt: "ndjson"},
+		{accept: "application/ndjson", expType: "application/ndjson", expExt: "ndjson"},
+		{accept: "application/json", expType: "", expExt: ""},
+	}
+
+	for _, tc := range testCase {
+		t.Run(tc.accept, func(t *testing.T) {
+			mediaType, ext := exportMediaType(tc.accept)
+			assert.Equal(t, tc.expType, mediaType)
+			assert.Equal(t, tc.expExt, ext)
+		})
+	}
+}
+
+func Test_SetUserError_Validation(t *testing.T) {
+	fields := []user.FieldError{{Field: "phone", Message: "invalid phone"}}
+
+	testCase := []struct {
+		desc       string
+		err        error
+		expDetails interface{}
+	}{
+		{desc: "sentinel", err: user.ErrValidation},
+		{desc: "field error", err: &user.ValidationError{Fields: fields}, expDetails: fields},
+		{desc: "wrapped", err: errors.Wrap(&user.ValidationError{Fields: fields}, "update user"), expDetails: fields},
+	}
+
+	for _, tc := range testCase {
+		t.Run(tc.desc, func(t *testing.T) {
+			var resp entity.HttpResponse
+			setUserError(&resp, tc.err)
+			assert.Equal(t, http.StatusBadRequest, resp.Code)
+			assert.Equal(t, user.ErrValidation.Code, resp.Error.Code)
+			assert.Equal(t, tc.expDetails, resp.Error.Details)
+		})
+	}
+}
@@ -7,20 +7,32 @@ import (
 	"github.com/go-chi/chi/v5"
 	"github.com/rs/cors"
 	"github.com/tuingking/supersvc/handler/api"
+	"github.com/tuingking/supersvc/pkg/idempotency"
 	xmiddleware "github.com/tuingking/supersvc/pkg/middleware"
+	"github.com/tuingking/supersvc/pkg/parser"
 )
 
+// redactedRoutes carry password or token in the body, the body is not dumped to the log
+var redactedRoutes = []xmiddleware.Route{
+	{Method: http.MethodPost, Path: "/api/v1/auth/login"},
+	{Method: http.MethodPost, Path: "/api/v1/auth/password-reset/confirm"},
+	{Method: http.MethodPut, Path: "/api/v1/auth/password"},
+}
+
 func NewMux(h api.Handler) http.Handler {
 	r := chi.NewRouter()
 	r.Use(middleware.SetHeader("Content-Type", "application/json"))
-	r.Use(xmiddleware.Inbound)
+	r.Use(xmiddleware.InboundRedact(redactedRoutes...))
+	r.Use(h.GatewayTenant)
+	r.Use(h.GatewayActor)
 	r.Use(middleware.RealIP)
 	r.Use(middleware.Recoverer)
 
 	cors := cors.New(cors.Options{
 		AllowedOrigins: []string{"*"},
 		AllowedMethods: []string{"GET", "POST", "PUT", "DELETE", "PATCH"},
-		AllowedHeaders: []string{"Accept", "Authorization", "Content-Type"},
+		AllowedHeaders: []string{"Accept", "Authorization", "Content-Type", parser.HeaderTimezone, idempotency.HeaderKey, "If-Match"},
+		ExposedHeaders: []string{idempotency.HeaderReplayed, "ETag", "Content-Disposition"},
 	})
 	r.Use(cors.Handler)
 
@@ -28,10 +40,43 @@ func NewMux(h api.Handler) http.Handler {
 
 	r.Route("/api/v1", func(r chi.Router) {
 		r.Get("/configs", h.GetAppConfig)
+		r.Get("/openapi.json", h.GetOpenAPI)
+
+		// auth API, tenant is set by the gateway
+		r.Post("/auth/login", h.Login)
+		r.Post("/auth/password-reset", h.RequestPasswordReset)
+		r.Post("/auth/password-reset/confirm", h.ResetPassword)
+		r.With(h.Authenticate).Put("/auth/password", h.ChangePassword)
+
+		// user API, tenant is taken from the access token
+		r.Group(func(r chi.Router) {
+			r.Use(h.Authenticate)
 
-		// user API
-		r.Get("/users", h.GetUser)
-		r.Post("/users", h.CreateUser)
+			r.Get("/users", h.GetUser)
+			r.With(h.Idempotent).Post("/users", h.CreateUser)
+			r.Post("/users:import", h.ImportUsers)
+			r.Get("/users:export", h.ExportUsers)
+			r.Get("/users:duplicates", h.FindDuplicateUsers)
+			r.Get("/users/{id}", h.GetUserByID)
+			r.Put("/users/{id}", h.UpdateUser)
+			r.Patch("/users/{id}", h.PatchUser)
+			r.Delete("/users/{id}", h.DeleteUser)
+			r.Post("/users/{id}/restore", h.RestoreUser)
+			r.Delete("/users/{id}/purge", h.PurgeUser)
+			r.Get("/users/{id}/history", h.GetUserHistory)
+			r.Post("/users/{id}/erase", h.EraseUser)
+			r.Get("/users/{id}/data", h.ExportUserData)
+			r.Post("/users/{id}/merge", h.MergeUser)
+			r.Post("/users/{id}/verify-email", h.SendEmailVerification)
+			r.Post("/users/{id}/verify-email/confirm", h.VerifyEmail)
+			r.Post("/users/{id}/verify-phone", h.SendPhoneVerification)
+			r.Post("/users/{id}/verify-phone/confirm", h.VerifyPhone)
+			r.Post("/users/{id}/activate", h.ActivateUser)
+			r.Post("/users/{id}/deactivate", h.DeactivateUser)
+			r.Post("/users/{id}/suspend", h.SuspendUser)
+			r.Delete("/users/{id}/suspension", h.CancelSuspension)
+			r.Post("/users/{id}/ban", h.BanUser)
+		})
 	})
 
 	return r
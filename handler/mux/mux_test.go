@@ -0,0 +1,493 @@
+package mux
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/iotest"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/tuingking/supersvc/config"
+	"github.com/tuingking/supersvc/entity"
+	"github.com/tuingking/supersvc/handler/api"
+	"github.com/tuingking/supersvc/pkg/ctxkey"
+	"github.com/tuingking/supersvc/pkg/idempotency"
+	"github.com/tuingking/supersvc/pkg/notify"
+	"github.com/tuingking/supersvc/pkg/tenant"
+	"github.com/tuingking/supersvc/pkg/token"
+	"github.com/tuingking/supersvc/svc/user"
+	"github.com/tuingking/supersvc/svc/user/mocks"
+)
+
+var (
+	testCreatedAt = time.Date(2024, 01, 01, 0, 0, 0, 0, time.UTC)
+	testUser      = user.User{ID: "u1", Name: "foo", Phone: "+6281234567890", Email: "foo@bar.com", Status: user.UserStatusActive, CreatedAt: testCreatedAt, Version: 1}
+
+	// testUserJSON is testUser in response data
+	testUserJSON = `{"id":"u1","name":"foo","phone":"+6281234567890","email":"foo@bar.com","status":"active","created_at":"2024-01-01T00:00:00Z","version":1}`
+)
+
+var (
+	// testConfig trust X-Tenant-ID header of testGatewayAddr, default remote address of httptest is not trusted
+	testConfig      = &config.Config{Tenant: tenant.Option{TrustedGateways: []string{"10.0.0.0/8"}}}
+	testGatewayAddr = "10.0.0.1:1234"
+	testSigner      = token.NewSigner(token.Option{Secret: "secret"})
+)
+
+// newTestMux return mux of mock service, access token of authorize is accepted by the service
+func newTestMux(t *testing.T) (http.Handler, *mocks.Service) {
+	svc := mocks.NewService(t)
+	svc.On("VerifyAccess", mock.Anything, "admin", mock.Anything).Return(user.User{ID: "admin", Status: user.UserStatusActive}, nil).Maybe()
+	h := api.NewHandler(testConfig, svc, idempotency.NewMemoryStore(), testSigner)
+	return NewMux(h), svc
+}
+
+// authorize set access token of admin of tenant t1 to r
+func authorize(t *testing.T, r *http.Request) *http.Request {
+	t.Helper()
+
+	accessToken, _, err := testSigner.Sign("admin", "t1")
+	if err != nil {
+		t.Fatalf("sign token: %s", err)
+	}
+	r.Header.Set("Authorization", "Bearer "+accessToken)
+	return r
+}
+
+func serve(h http.Handler, r *http.Request) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+// decodeEnvelope decode the response as entity.HttpResponse, fields of the envelope is returned with their raw value
+func decodeEnvelope(t *testing.T, w *httptest.ResponseRecorder) map[string]json.RawMessage {
+	t.Helper()
+
+	mediaType, _, _ := mime.ParseMediaType(w.Header().Get("Content-Type"))
+	assert.Equal(t, "application/json", mediaType)
+	var envelope map[string]json.RawMessage
+	if err := json.Unmarshal(w.Body.Bytes(), &envelope); err != nil {
+		t.Fatalf("decode response %q: %s", w.Body.String(), err)
+	}
+	return envelope
+}
+
+func keys(m map[string]json.RawMessage) []string {
+	var v []string
+	for k := range m {
+		v = append(v, k)
+	}
+	return v
+}
+
+// assertError assert the envelope of error response
+func assertError(t *testing.T, w *httptest.ResponseRecorder, expStatus, expCode int) entity.Error {
+	t.Helper()
+
+	assert.Equal(t, expStatus, w.Code)
+	envelope := decodeEnvelope(t, w)
+	assert.ElementsMatch(t, []string{"code", "error", "message", "serverTime"}, keys(envelope))
+	assert.Equal(t, json.RawMessage(`""`), envelope["message"])
+
+	var code int
+	var errResp entity.Error
+	assert.Nil(t, json.Unmarshal(envelope["code"], &code))
+	assert.Nil(t, json.Unmarshal(envelope["error"], &errResp))
+	assert.Equal(t, expStatus, code)
+	assert.True(t, errResp.Status)
+	assert.NotEmpty(t, errResp.Msg)
+	assert.Equal(t, expCode, errResp.Code)
+	return errResp
+}
+
+func Test_Mux_GetUsers(t *testing.T) {
+	t.Run("ok", func(t *testing.T) {
+		h, svc := newTestMux(t)
+		svc.On("GetUser", mock.Anything, user.GetUserParam{Status: []user.Status{user.UserStatusActive}, Page: 2, Limit: 1, SortBy: []string{"-created_at"}}).
+			Return([]user.User{testUser}, entity.Pagination{Page: 2, Size: 1, Total: 3, HasNext: true}, nil).
+			Once()
+
+		w := serve(h, authorize(t, httptest.NewRequest(http.MethodGet, "/api/v1/users?status__in=active&page=2&limit=1&sortBy=-created_at", nil)))
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		envelope := decodeEnvelope(t, w)
+		assert.ElementsMatch(t, []string{"code", "data", "message", "serverTime", "pagination"}, keys(envelope))
+		assert.Equal(t, json.RawMessage(`200`), envelope["code"])
+		assert.JSONEq(t, "["+testUserJSON+"]", string(envelope["data"]))
+		assert.JSONEq(t, `{"page":2,"size":1,"total":3,"has_next":true}`, string(envelope["pagination"]))
+	})
+
+	t.Run("filter", func(t *testing.T) {
+		h, svc := newTestMux(t)
+		jakarta := time.FixedZone("+07:00", 7*60*60)
+		svc.On("GetUser", mock.Anything, user.GetUserParam{
+			Email:        sql.NullString{String: "foo@bar.com", Valid: true},
+			Status:       []user.Status{user.UserStatusInActive, user.UserStatusBanned},
+			CreatedAtGTE: sql.NullTime{Time: time.Date(2024, 01, 01, 0, 0, 0, 0, jakarta), Valid: true},
+		}).Return(nil, entity.Pagination{Page: 1}, nil).Once()
+
+		r := httptest.NewRequest(http.MethodGet, "/api/v1/users?filter[email][eq]=foo@bar.com&filter[status][in]=inactive,banned&created_at__gte=2024-01-01", nil)
+		r.Header.Set("X-Timezone", "+07:00")
+		w := serve(h, authorize(t, r))
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		envelope := decodeEnvelope(t, w)
+		assert.Equal(t, json.RawMessage(`null`), envelope["data"])
+		assert.JSONEq(t, `{"page":1,"size":0,"total":0,"has_next":false}`, string(envelope["pagination"]))
+	})
+
+	t.Run("invalid param", func(t *testing.T) {
+		h, _ := newTestMux(t)
+
+		for _, query := range []string{"status__in=unknown", "page=one", "created_at__gte=yesterday"} {
+			w := serve(h, authorize(t, httptest.NewRequest(http.MethodGet, "/api/v1/users?"+query, nil)))
+			assertError(t, w, http.StatusBadRequest, 0)
+		}
+	})
+
+	t.Run("invalid sort field", func(t *testing.T) {
+		h, svc := newTestMux(t)
+		svc.On("GetUser", mock.Anything, mock.Anything).Return(nil, entity.Pagination{}, user.ErrInvalidSortBy).Once()
+
+		w := serve(h, authorize(t, httptest.NewRequest(http.MethodGet, "/api/v1/users?sortBy=password", nil)))
+		assertError(t, w, http.StatusBadRequest, user.ErrInvalidSortBy.Code)
+	})
+
+	t.Run("service error", func(t *testing.T) {
+		h, svc := newTestMux(t)
+		svc.On("GetUser", mock.Anything, mock.Anything).Return(nil, entity.Pagination{}, errors.New("connection refused")).Once()
+
+		w := serve(h, authorize(t, httptest.NewRequest(http.MethodGet, "/api/v1/users", nil)))
+		errResp := assertError(t, w, http.StatusInternalServerError, 0)
+		assert.Equal(t, "connection refused", errResp.Msg)
+	})
+
+	t.Run("panic is recovered", func(t *testing.T) {
+		h, svc := newTestMux(t)
+		svc.On("GetUser", mock.Anything, mock.Anything).Panic("boom").Once()
+
+		w := serve(h, authorize(t, httptest.NewRequest(http.MethodGet, "/api/v1/users", nil)))
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+		assert.NotContains(t, w.Body.String(), "serverTime", "handler response is not rendered")
+	})
+}
+
+func Test_Mux_CreateUser(t *testing.T) {
+	const body = `{"name":"foo","phone":"+6281234567890","email":"foo@bar.com","status":"active"}`
+	req := user.User{Name: "foo", Phone: "+6281234567890", Email: "foo@bar.com", Status: user.UserStatusActive}
+
+	newRequest := func(body string) *http.Request {
+		r := httptest.NewRequest(http.MethodPost, "/api/v1/users", strings.NewReader(body))
+		r.Header.Set("Content-Type", "application/json")
+		return authorize(t, r)
+	}
+
+	t.Run("created", func(t *testing.T) {
+		h, svc := newTestMux(t)
+		actor := mock.MatchedBy(func(ctx context.Context) bool {
+			tenantID, _ := tenant.FromContext(ctx)
+			return ctx.Value(ctxkey.XActor) == "admin" && tenantID == "t1"
+		})
+		svc.On("CreateUser", actor, req).Return(testUser, nil).Once()
+
+		r := newRequest(body)
+		r.Header.Set(ctxkey.XActor.String(), "someone else")
+		w := serve(h, r)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, `"1"`, w.Header().Get("ETag"))
+
+		envelope := decodeEnvelope(t, w)
+		assert.ElementsMatch(t, []string{"code", "data", "message", "serverTime"}, keys(envelope))
+		assert.JSONEq(t, testUserJSON, string(envelope["data"]))
+		assert.Equal(t, json.RawMessage(`"user created"`), envelope["message"])
+	})
+
+	t.Run("idempotent retry", func(t *testing.T) {
+		h, svc := newTestMux(t)
+		svc.On("CreateUser", mock.Anything, req).Return(testUser, nil).Once()
+
+		var responses []*httptest.ResponseRecorder
+		for i := 0; i < 2; i++ {
+			r := newRequest(body)
+			r.Header.Set(idempotency.HeaderKey, "k1")
+			responses = append(responses, serve(h, r))
+		}
+
+		assert.Equal(t, http.StatusOK, responses[1].Code)
+		assert.Equal(t, "true", responses[1].Header().Get(idempotency.HeaderReplayed))
+		assert.Equal(t, responses[0].Body.String(), responses[1].Body.String())
+
+		r := newRequest(`{"name":"bar"}`)
+		r.Header.Set(idempotency.HeaderKey, "k1")
+		assertError(t, serve(h, r), http.StatusUnprocessableEntity, 0)
+	})
+
+	t.Run("invalid body", func(t *testing.T) {
+		h, _ := newTestMux(t)
+
+		for _, body := range []string{`{"name":`, `{"status":"unknown"}`, `[]`} {
+			assertError(t, serve(h, newRequest(body)), http.StatusBadRequest, 0)
+		}
+	})
+
+	t.Run("server owned field is rejected", func(t *testing.T) {
+		h, _ := newTestMux(t)
+
+		for _, field := range []string{`"id":"u9"`, `"version":9`, `"created_at":"2020-01-01T00:00:00Z"`, `"deleted_at":"2020-01-01T00:00:00Z"`, `"email_verified_at":"2020-01-01T00:00:00Z"`, `"merged_into":"u2"`} {
+			body := `{"name":"foo","email":"foo@bar.com",` + field + `}`
+			errResp := assertError(t, serve(h, newRequest(body)), http.StatusBadRequest, 0)
+			assert.Contains(t, errResp.Msg, "unknown field", field)
+		}
+	})
+
+	t.Run("validation error", func(t *testing.T) {
+		h, svc := newTestMux(t)
+		svc.On("CreateUser", mock.Anything, mock.Anything).
+			Return(user.User{}, &user.ValidationError{Fields: []user.FieldError{{Field: "email", Message: "invalid email"}}}).
+			Once()
+
+		w := serve(h, newRequest(`{"name":"foo","email":"foo"}`))
+		errResp := assertError(t, w, http.StatusBadRequest, user.ErrValidation.Code)
+		details, _ := json.Marshal(errResp.Details)
+		assert.JSONEq(t, `[{"field":"email","message":"invalid email"}]`, string(details))
+	})
+
+	t.Run("duplicate email", func(t *testing.T) {
+		h, svc := newTestMux(t)
+		svc.On("CreateUser", mock.Anything, mock.Anything).Return(user.User{}, user.ErrDuplicateEmail).Once()
+
+		w := serve(h, newRequest(body))
+		errResp := assertError(t, w, http.StatusConflict, user.ErrDuplicateEmail.Code)
+		assert.Equal(t, user.ErrDuplicateEmail.Msg, errResp.Msg)
+	})
+
+	t.Run("cors preflight", func(t *testing.T) {
+		h, _ := newTestMux(t)
+
+		r := httptest.NewRequest(http.MethodOptions, "/api/v1/users", nil)
+		r.Header.Set("Origin", "https://admin.example.com")
+		r.Header.Set("Access-Control-Request-Method", http.MethodPost)
+		r.Header.Set("Access-Control-Request-Headers", idempotency.HeaderKey)
+		w := serve(h, r)
+
+		assert.Equal(t, "*", w.Header().Get("Access-Control-Allow-Origin"))
+		assert.Contains(t, w.Header().Get("Access-Control-Allow-Methods"), http.MethodPost)
+	})
+}
+
+func Test_Mux_ImportUsers(t *testing.T) {
+	h, svc := newTestMux(t)
+	report := user.ImportReport{Created: 1, Rows: []user.ImportRowResult{{Line: 2, Status: user.ImportStatusCreated, ID: "u1", Email: "foo@bar.com"}}}
+	svc.On("ImportUsers", mock.Anything, mock.Anything, false).Return(report, errors.New("connection refused")).Once()
+
+	r := httptest.NewRequest(http.MethodPost, "/api/v1/users:import", strings.NewReader("name,email\nfoo,foo@bar.com\n"))
+	r.Header.Set("Content-Type", "text/csv")
+	w := serve(h, authorize(t, r))
+
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	envelope := decodeEnvelope(t, w)
+	assert.ElementsMatch(t, []string{"code", "data", "error", "message", "serverTime"}, keys(envelope), "report is returned with the error")
+	assert.JSONEq(t, `{"dry_run":false,"created":1,"skipped":0,"failed":0,"rows":[{"line":2,"status":"created","id":"u1","email":"foo@bar.com"}]}`, string(envelope["data"]))
+}
+
+func Test_Mux_ImportUsers_SourceError(t *testing.T) {
+	repo := user.NewMemoryRepository(nil)
+	createAdmin(t, repo)
+	h := NewMux(api.NewHandler(testConfig, user.NewService(user.Option{}, repo), idempotency.NewMemoryStore(), testSigner))
+
+	importUsers := func(contentType string, body io.Reader) (*httptest.ResponseRecorder, entity.Error) {
+		r := httptest.NewRequest(http.MethodPost, "/api/v1/users:import", body)
+		r.Header.Set("Content-Type", contentType)
+		w := serve(h, authorize(t, r))
+
+		var errResp entity.Error
+		assert.Nil(t, json.Unmarshal(decodeEnvelope(t, w)["error"], &errResp))
+		return w, errResp
+	}
+
+	t.Run("ndjson line too long", func(t *testing.T) {
+		body := strings.NewReader(`{"name":"foo","email":"foo@bar.com"}` + "\n" + strings.Repeat("x", 64<<10+1))
+		w, errResp := importUsers("application/x-ndjson", body)
+		assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+		assert.Equal(t, user.ErrImportLineTooLong.Code, errResp.Code)
+		assert.Contains(t, keys(decodeEnvelope(t, w)), "data", "report is returned with the error")
+	})
+
+	t.Run("csv read failed", func(t *testing.T) {
+		body := io.MultiReader(strings.NewReader("name,email\nfoo,foo@bar.com\n"), iotest.ErrReader(errors.New("connection reset")))
+		w, errResp := importUsers("text/csv", body)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Equal(t, user.ErrInvalidImport.Code, errResp.Code)
+	})
+}
+
+// createAdmin add active user with password as the subject of authorize, so its access token is accepted
+func createAdmin(t *testing.T, repo user.Repository) {
+	t.Helper()
+
+	ctx := tenant.WithID(context.Background(), "t1")
+	admin := user.User{ID: "admin", Name: "admin", Email: "admin@bar.com", Status: user.UserStatusActive, CreatedAt: testCreatedAt, Version: 1}
+	assert.Nil(t, repo.Create(ctx, admin))
+	assert.Nil(t, repo.SavePassword(ctx, admin.ID, "hash", testCreatedAt))
+}
+
+func Test_Mux_PatchUser(t *testing.T) {
+	repo := user.NewMemoryRepository(nil)
+	createAdmin(t, repo)
+	svc := user.NewService(user.Option{}, repo)
+	h := NewMux(api.NewHandler(testConfig, svc, idempotency.NewMemoryStore(), testSigner))
+
+	usr, err := svc.CreateUser(tenant.WithID(context.Background(), "t1"), user.User{Name: "foo", Email: "foo@bar.com"})
+	assert.Nil(t, err)
+
+	patch := func(body string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPatch, "/api/v1/users/"+usr.ID, strings.NewReader(body))
+		r.Header.Set("Content-Type", "application/merge-patch+json")
+		r.Header.Set("If-Match", `"1"`)
+		return serve(h, authorize(t, r))
+	}
+
+	t.Run("required field cannot be null", func(t *testing.T) {
+		for _, body := range []string{`{"name":null}`, `{"email":null}`} {
+			errResp := assertError(t, patch(body), http.StatusBadRequest, user.ErrInvalidPatch.Code)
+			assert.Contains(t, errResp.Msg, "cannot be null", body)
+		}
+	})
+
+	t.Run("patched", func(t *testing.T) {
+		w := patch(`{"name":"bar","phone":null}`)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, `"2"`, w.Header().Get("ETag"))
+
+		var data user.User
+		assert.Nil(t, json.Unmarshal(decodeEnvelope(t, w)["data"], &data))
+		assert.Equal(t, usr.ID, data.ID, "id is bound from the path")
+		assert.Equal(t, "bar", data.Name)
+	})
+}
+
+func Test_Mux_RequestPasswordReset(t *testing.T) {
+	var sent []notify.Message
+	notifier := notify.NotifierFunc(func(ctx context.Context, msg notify.Message) error {
+		sent = append(sent, msg)
+		return nil
+	})
+	svc := user.NewService(user.Option{}, user.NewMemoryRepository(nil), user.WithNotifier(notifier))
+	h := NewMux(api.NewHandler(testConfig, svc, idempotency.NewMemoryStore(), testSigner))
+
+	ctx := tenant.WithID(context.Background(), "t1")
+	_, err := svc.CreateUser(ctx, user.User{Name: "foo", Email: "foo@bar.com", Status: user.UserStatusActive})
+	assert.Nil(t, err)
+
+	request := func(email string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, "/api/v1/auth/password-reset", strings.NewReader(`{"email":"`+email+`"}`))
+		r.Header.Set("Content-Type", "application/json")
+		r.Header.Set(ctxkey.XTenantID.String(), "t1")
+		r.RemoteAddr = testGatewayAddr
+		return serve(h, r)
+	}
+
+	registered := request("foo@bar.com")
+	assert.Equal(t, http.StatusAccepted, registered.Code)
+	assert.Len(t, sent, 1, "token is sent to the email")
+	assert.Equal(t, "foo@bar.com", sent[0].To)
+
+	envelope := decodeEnvelope(t, registered)
+	assert.ElementsMatch(t, []string{"code", "message", "serverTime"}, keys(envelope), "token is not in the response")
+	token := strings.TrimSuffix(strings.Fields(strings.SplitN(sent[0].Body, ": ", 2)[1])[0], ".")
+	assert.NotEmpty(t, token)
+	assert.NotContains(t, registered.Body.String(), token)
+
+	unknown := request("unknown@bar.com")
+	assert.Equal(t, http.StatusAccepted, unknown.Code, "unknown email cannot be told apart")
+	assert.Len(t, sent, 1)
+	assert.Equal(t, envelope["message"], decodeEnvelope(t, unknown)["message"])
+}
+
+func Test_Mux_RedactedBody(t *testing.T) {
+	var logs bytes.Buffer
+	defaultLogger := log.Logger
+	log.Logger = zerolog.New(&logs)
+	t.Cleanup(func() { log.Logger = defaultLogger })
+
+	h, svc := newTestMux(t)
+	svc.On("Authenticate", mock.Anything, "foo@bar.com", "s3cret pass").Return(testUser, nil).Once()
+
+	r := httptest.NewRequest(http.MethodPost, "/api/v1/auth/login", strings.NewReader(`{"email":"foo@bar.com","password":"s3cret pass"}`))
+	r.Header.Set("Content-Type", "application/json")
+	r.Header.Set(ctxkey.XTenantID.String(), "t1")
+	r.RemoteAddr = testGatewayAddr
+	assert.Equal(t, http.StatusOK, serve(h, r).Code)
+
+	assert.Contains(t, logs.String(), "POST /api/v1/auth/login", "request is dumped without body")
+	assert.NotContains(t, logs.String(), "s3cret pass")
+}
+
+func Test_Mux_Tenant(t *testing.T) {
+	login := func(remoteAddr string) *http.Request {
+		r := httptest.NewRequest(http.MethodPost, "/api/v1/auth/login", strings.NewReader(`{"email":"foo@bar.com","password":"s3cret pass"}`))
+		r.Header.Set("Content-Type", "application/json")
+		r.Header.Set(ctxkey.XTenantID.String(), "t1")
+		r.RemoteAddr = remoteAddr
+		return r
+	}
+
+	t.Run("login in tenant of gateway", func(t *testing.T) {
+		h, svc := newTestMux(t)
+		inTenant := mock.MatchedBy(func(ctx context.Context) bool {
+			tenantID, _ := tenant.FromContext(ctx)
+			return tenantID == "t1"
+		})
+		svc.On("Authenticate", inTenant, "foo@bar.com", "s3cret pass").Return(testUser, nil).Once()
+
+		w := serve(h, login(testGatewayAddr))
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var data api.LoginResponse
+		assert.Nil(t, json.Unmarshal(decodeEnvelope(t, w)["data"], &data))
+		claims, err := testSigner.Verify(data.AccessToken)
+		assert.Nil(t, err)
+		assert.Equal(t, "t1", claims.Tenant, "token is issued for the tenant")
+	})
+
+	t.Run("tenant header of client is not trusted", func(t *testing.T) {
+		h, _ := newTestMux(t)
+
+		r := login("192.0.2.1:1234")
+		r.Header.Set("X-Forwarded-For", "10.0.0.1")
+		assertError(t, serve(h, r), http.StatusBadRequest, user.ErrInvalidTenant.Code)
+	})
+
+	t.Run("user API require access token", func(t *testing.T) {
+		h, _ := newTestMux(t)
+
+		r := httptest.NewRequest(http.MethodGet, "/api/v1/users/u1", nil)
+		r.Header.Set(ctxkey.XTenantID.String(), "t1")
+		r.RemoteAddr = testGatewayAddr
+		assertError(t, serve(h, r), http.StatusUnauthorized, 0)
+	})
+
+	t.Run("tenant header must match the token", func(t *testing.T) {
+		h, _ := newTestMux(t)
+
+		for _, remoteAddr := range []string{"192.0.2.1:1234", testGatewayAddr} {
+			r := authorize(t, httptest.NewRequest(http.MethodGet, "/api/v1/users/u1", nil))
+			r.Header.Set(ctxkey.XTenantID.String(), "t2")
+			r.RemoteAddr = remoteAddr
+			assertError(t, serve(h, r), http.StatusUnauthorized, 0)
+		}
+	})
+}
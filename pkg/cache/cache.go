@@ -0,0 +1,51 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+const (
+	DriverLRU   = "lru"
+	DriverRedis = "redis"
+)
+
+var (
+	// ErrMiss is returned by Get when the key is not cached or expired
+	ErrMiss = errors.New("cache miss")
+)
+
+// Cache keep value by key until it expires.
+// cache is best effort, caller should fall back to the source of truth on error.
+type Cache interface {
+	// Get return the value of the key, ErrMiss is returned when the key is not cached
+	Get(ctx context.Context, key string) ([]byte, error)
+
+	// Set store the value for ttl, the value never expires when ttl is 0
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+
+	// Delete remove the keys, key which is not cached is ignored
+	Delete(ctx context.Context, keys ...string) error
+}
+
+type Option struct {
+	// Driver is lru | redis, cache is disabled when it is empty
+	Driver string
+	LRU    LRUOption
+	Redis  RedisOption
+}
+
+// New return cache of the driver, nil cache is returned when the driver is empty
+func New(opt Option) (Cache, error) {
+	switch opt.Driver {
+	case "":
+		return nil, nil
+	case DriverLRU:
+		return NewLRU(opt.LRU), nil
+	case DriverRedis:
+		return NewRedis(opt.Redis), nil
+	}
+	return nil, fmt.Errorf("unknown cache driver %q", opt.Driver)
+}
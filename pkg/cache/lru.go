@@ -0,0 +1,107 @@
+package cache
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+)
+
+const DefaultLRUSize = 10000
+
+type LRUOption struct {
+	// Size is max number of key, least recently used key is evicted when it is full. default to 10000
+	Size int
+}
+
+func (o LRUOption) GetSize() int {
+	if o.Size <= 0 {
+		return DefaultLRUSize
+	}
+	return o.Size
+}
+
+type lruEntry struct {
+	key   string
+	value []byte
+
+	// expiresAt is zero when the value never expires
+	expiresAt time.Time
+}
+
+// lru keep value in process memory, it is not shared between replica.
+// it is meant for test and single instance deployment, or short ttl where stale value across replica is acceptable.
+type lru struct {
+	mu      sync.Mutex
+	size    int
+	entries map[string]*list.Element
+	order   *list.List // front is the most recently used
+	now     func() time.Time
+}
+
+func NewLRU(opt LRUOption) Cache {
+	return &lru{
+		size:    opt.GetSize(),
+		entries: make(map[string]*list.Element),
+		order:   list.New(),
+		now:     time.Now,
+	}
+}
+
+func (c *lru) Get(ctx context.Context, key string) ([]byte, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.entries[key]
+	if !ok {
+		return nil, ErrMiss
+	}
+
+	entry := el.Value.(*lruEntry)
+	if !entry.expiresAt.IsZero() && !c.now().Before(entry.expiresAt) {
+		c.remove(el)
+		return nil, ErrMiss
+	}
+
+	c.order.MoveToFront(el)
+	return append([]byte(nil), entry.value...), nil
+}
+
+func (c *lru) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry := &lruEntry{key: key, value: append([]byte(nil), value...)}
+	if ttl > 0 {
+		entry.expiresAt = c.now().Add(ttl)
+	}
+
+	if el, ok := c.entries[key]; ok {
+		el.Value = entry
+		c.order.MoveToFront(el)
+		return nil
+	}
+
+	c.entries[key] = c.order.PushFront(entry)
+	for c.order.Len() > c.size {
+		c.remove(c.order.Back())
+	}
+	return nil
+}
+
+func (c *lru) Delete(ctx context.Context, keys ...string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, key := range keys {
+		if el, ok := c.entries[key]; ok {
+			c.remove(el)
+		}
+	}
+	return nil
+}
+
+func (c *lru) remove(el *list.Element) {
+	c.order.Remove(el)
+	delete(c.entries, el.Value.(*lruEntry).key)
+}
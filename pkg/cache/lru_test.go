@@ -0,0 +1,68 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_LRU(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2024, 01, 01, 0, 0, 0, 0, time.UTC)
+
+	c := NewLRU(LRUOption{Size: 2}).(*lru)
+	c.now = func() time.Time { return now }
+
+	_, err := c.Get(ctx, "k1")
+	assert.ErrorIs(t, err, ErrMiss)
+
+	value := []byte("v1")
+	assert.Nil(t, c.Set(ctx, "k1", value, time.Minute))
+	value[0] = 'x'
+	got, err := c.Get(ctx, "k1")
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("v1"), got, "stored value is not modified through the argument")
+
+	// k1 is used more recently than k2, so k2 is evicted
+	assert.Nil(t, c.Set(ctx, "k2", []byte("v2"), 0))
+	_, _ = c.Get(ctx, "k1")
+	assert.Nil(t, c.Set(ctx, "k3", []byte("v3"), 0))
+	_, err = c.Get(ctx, "k2")
+	assert.ErrorIs(t, err, ErrMiss)
+
+	// set existing key replace the value and ttl
+	assert.Nil(t, c.Set(ctx, "k3", []byte("v3.1"), time.Second))
+	got, err = c.Get(ctx, "k3")
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("v3.1"), got)
+
+	now = now.Add(time.Second)
+	_, err = c.Get(ctx, "k3")
+	assert.ErrorIs(t, err, ErrMiss, "expired")
+	_, err = c.Get(ctx, "k1")
+	assert.Nil(t, err)
+
+	assert.Nil(t, c.Delete(ctx, "k1", "unknown"))
+	_, err = c.Get(ctx, "k1")
+	assert.ErrorIs(t, err, ErrMiss)
+	assert.Equal(t, 0, c.order.Len())
+}
+
+func Test_New(t *testing.T) {
+	c, err := New(Option{})
+	assert.Nil(t, err)
+	assert.Nil(t, c, "cache is disabled")
+
+	c, err = New(Option{Driver: DriverLRU})
+	assert.Nil(t, err)
+	assert.IsType(t, &lru{}, c)
+
+	c, err = New(Option{Driver: DriverRedis, Redis: RedisOption{Addr: "localhost:6379"}})
+	assert.Nil(t, err)
+	assert.IsType(t, &redis{}, c)
+
+	_, err = New(Option{Driver: "memcached"})
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,282 @@
+package cache
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultRedisPoolSize    = 10
+	DefaultRedisDialTimeout = 5 * time.Second
+	DefaultRedisTimeout     = 3 * time.Second
+)
+
+type RedisOption struct {
+	// Addr is host:port of the server, e.g: localhost:6379
+	Addr string
+
+	// Username and Password is sent with AUTH when password is not empty, username is optional
+	Username string
+	Password string
+
+	// DB is selected after connected
+	DB int
+
+	// PoolSize is max idle connection kept for reuse, default to 10
+	PoolSize int
+
+	// DialTimeout default to 5 second
+	DialTimeout time.Duration
+
+	// Timeout is deadline to write a command and read its reply, default to 3 second
+	Timeout time.Duration
+}
+
+func (o RedisOption) GetPoolSize() int {
+	if o.PoolSize <= 0 {
+		return DefaultRedisPoolSize
+	}
+	return o.PoolSize
+}
+
+func (o RedisOption) GetDialTimeout() time.Duration {
+	if o.DialTimeout <= 0 {
+		return DefaultRedisDialTimeout
+	}
+	return o.DialTimeout
+}
+
+func (o RedisOption) GetTimeout() time.Duration {
+	if o.Timeout <= 0 {
+		return DefaultRedisTimeout
+	}
+	return o.Timeout
+}
+
+// redisError is error reply of the server, the connection can still be reused
+type redisError string
+
+func (e redisError) Error() string {
+	return "redis: " + string(e)
+}
+
+// redis is client of server speaking the redis protocol (RESP2), e.g: redis, valkey, keydb.
+// only the command needed by Cache is supported, connection is dialed on demand and kept in a pool when idle.
+type redis struct {
+	opt  RedisOption
+	idle chan *redisConn
+}
+
+func NewRedis(opt RedisOption) Cache {
+	return &redis{
+		opt:  opt,
+		idle: make(chan *redisConn, opt.GetPoolSize()),
+	}
+}
+
+func (c *redis) Get(ctx context.Context, key string) ([]byte, error) {
+	reply, err := c.do(ctx, "GET", key)
+	if err != nil {
+		return nil, err
+	}
+
+	switch v := reply.(type) {
+	case nil:
+		return nil, ErrMiss
+	case []byte:
+		return v, nil
+	}
+	return nil, fmt.Errorf("redis: unexpected GET reply %T", reply)
+}
+
+func (c *redis) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	args := []string{"SET", key, string(value)}
+	if ttl > 0 {
+		args = append(args, "PX", strconv.FormatInt(max(ttl.Milliseconds(), 1), 10))
+	}
+
+	_, err := c.do(ctx, args...)
+	return err
+}
+
+func (c *redis) Delete(ctx context.Context, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+
+	_, err := c.do(ctx, append([]string{"DEL"}, keys...)...)
+	return err
+}
+
+// do send the command and return its reply.
+// pooled connection may be closed by the server while idle, the command is retried once on new connection,
+// it is safe because every supported command is idempotent.
+func (c *redis) do(ctx context.Context, args ...string) (interface{}, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	select {
+	case cn := <-c.idle:
+		reply, err := c.doConn(ctx, cn, args...)
+		if err == nil || isRedisError(err) || ctx.Err() != nil {
+			return reply, err
+		}
+	default:
+	}
+
+	cn, err := c.dial(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return c.doConn(ctx, cn, args...)
+}
+
+// doConn send the command through cn, cn is returned to the pool unless it is broken
+func (c *redis) doConn(ctx context.Context, cn *redisConn, args ...string) (interface{}, error) {
+	reply, err := cn.do(ctx, c.opt.GetTimeout(), args...)
+	if err != nil && !isRedisError(err) {
+		cn.conn.Close()
+		return nil, err
+	}
+
+	select {
+	case c.idle <- cn:
+	default:
+		cn.conn.Close()
+	}
+	return reply, err
+}
+
+func (c *redis) dial(ctx context.Context) (*redisConn, error) {
+	d := net.Dialer{Timeout: c.opt.GetDialTimeout()}
+	conn, err := d.DialContext(ctx, "tcp", c.opt.Addr)
+	if err != nil {
+		return nil, err
+	}
+
+	cn := &redisConn{conn: conn, r: bufio.NewReader(conn), w: bufio.NewWriter(conn)}
+	var setup [][]string
+	if c.opt.Password != "" {
+		if c.opt.Username != "" {
+			setup = append(setup, []string{"AUTH", c.opt.Username, c.opt.Password})
+		} else {
+			setup = append(setup, []string{"AUTH", c.opt.Password})
+		}
+	}
+	if c.opt.DB != 0 {
+		setup = append(setup, []string{"SELECT", strconv.Itoa(c.opt.DB)})
+	}
+
+	for _, args := range setup {
+		if _, err := cn.do(ctx, c.opt.GetTimeout(), args...); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("redis %s: %w", strings.ToLower(args[0]), err)
+		}
+	}
+	return cn, nil
+}
+
+func isRedisError(err error) bool {
+	var rerr redisError
+	return errors.As(err, &rerr)
+}
+
+type redisConn struct {
+	conn net.Conn
+	r    *bufio.Reader
+	w    *bufio.Writer
+}
+
+// do write the command and read its reply before timeout or the ctx deadline, whichever is earlier
+func (cn *redisConn) do(ctx context.Context, timeout time.Duration, args ...string) (interface{}, error) {
+	deadline := time.Now().Add(timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	if err := cn.conn.SetDeadline(deadline); err != nil {
+		return nil, err
+	}
+
+	if err := cn.write(args); err != nil {
+		return nil, err
+	}
+	return cn.read()
+}
+
+// write encode the command as array of bulk string
+func (cn *redisConn) write(args []string) error {
+	buf := make([]byte, 0, 64)
+	buf = append(buf, '*')
+	buf = strconv.AppendInt(buf, int64(len(args)), 10)
+	buf = append(buf, '\r', '\n')
+	for _, arg := range args {
+		buf = append(buf, '$')
+		buf = strconv.AppendInt(buf, int64(len(arg)), 10)
+		buf = append(buf, '\r', '\n')
+		buf = append(buf, arg...)
+		buf = append(buf, '\r', '\n')
+	}
+
+	if _, err := cn.w.Write(buf); err != nil {
+		return err
+	}
+	return cn.w.Flush()
+}
+
+// read decode a reply, simple string is string, integer is int64, bulk string is []byte,
+// array is []interface{} and null is nil. error reply is returned as redisError.
+func (cn *redisConn) read() (interface{}, error) {
+	line, err := cn.r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if len(line) < 3 || !strings.HasSuffix(line, "\r\n") {
+		return nil, fmt.Errorf("redis: invalid reply %q", line)
+	}
+	kind, line := line[0], line[1:len(line)-2]
+
+	switch kind {
+	case '+':
+		return line, nil
+	case '-':
+		return nil, redisError(line)
+	case ':':
+		return strconv.ParseInt(line, 10, 64)
+	case '$':
+		n, err := strconv.Atoi(line)
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(cn.r, buf); err != nil {
+			return nil, err
+		}
+		return buf[:n], nil
+	case '*':
+		n, err := strconv.Atoi(line)
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		values := make([]interface{}, n)
+		for i := range values {
+			v, err := cn.read()
+			if err != nil && !isRedisError(err) {
+				return nil, err
+			}
+			values[i] = v
+			if err != nil {
+				values[i] = err
+			}
+		}
+		return values, nil
+	}
+	return nil, fmt.Errorf("redis: invalid reply %q", string(kind)+line)
+}
@@ -0,0 +1,296 @@
+package cache
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeRedis is in process server speaking enough of the redis protocol to test the client
+type fakeRedis struct {
+	ln       net.Listener
+	password string
+
+	mu    sync.Mutex
+	data  map[int]map[string]fakeValue // by db
+	conns []net.Conn
+	dials int
+	delay time.Duration // before every reply
+}
+
+type fakeValue struct {
+	value     []byte
+	expiresAt time.Time
+}
+
+func newFakeRedis(t *testing.T, password string) *fakeRedis {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %s", err)
+	}
+
+	s := &fakeRedis{ln: ln, password: password, data: make(map[int]map[string]fakeValue)}
+	t.Cleanup(func() {
+		ln.Close()
+		s.closeConns()
+	})
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			s.mu.Lock()
+			s.conns = append(s.conns, conn)
+			s.dials++
+			s.mu.Unlock()
+			go s.handle(conn)
+		}
+	}()
+	return s
+}
+
+func (s *fakeRedis) addr() string {
+	return s.ln.Addr().String()
+}
+
+// closeConns close every client connection, like idle timeout or restart of the server
+func (s *fakeRedis) closeConns() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, conn := range s.conns {
+		conn.Close()
+	}
+	s.conns = nil
+}
+
+func (s *fakeRedis) dialCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.dials
+}
+
+func (s *fakeRedis) handle(conn net.Conn) {
+	defer conn.Close()
+
+	r := bufio.NewReader(conn)
+	authed, db := s.password == "", 0
+	for {
+		args, err := readFakeCommand(r)
+		if err != nil {
+			return
+		}
+
+		s.mu.Lock()
+		reply, delay := s.exec(args, &authed, &db), s.delay
+		s.mu.Unlock()
+
+		time.Sleep(delay)
+		if _, err := io.WriteString(conn, reply); err != nil {
+			return
+		}
+	}
+}
+
+func (s *fakeRedis) exec(args []string, authed *bool, db *int) string {
+	cmd := strings.ToUpper(args[0])
+	if cmd == "AUTH" {
+		if args[len(args)-1] != s.password {
+			return "-WRONGPASS invalid username-password pair\r\n"
+		}
+		*authed = true
+		return "+OK\r\n"
+	}
+	if !*authed {
+		return "-NOAUTH Authentication required.\r\n"
+	}
+
+	if s.data[*db] == nil {
+		s.data[*db] = make(map[string]fakeValue)
+	}
+	data := s.data[*db]
+
+	switch cmd {
+	case "SELECT":
+		*db, _ = strconv.Atoi(args[1])
+		return "+OK\r\n"
+	case "GET":
+		v, ok := data[args[1]]
+		if !ok || (!v.expiresAt.IsZero() && !time.Now().Before(v.expiresAt)) {
+			return "$-1\r\n"
+		}
+		return fmt.Sprintf("$%d\r\n%s\r\n", len(v.value), v.value)
+	case "SET":
+		v := fakeValue{value: []byte(args[2])}
+		if len(args) == 5 && strings.ToUpper(args[3]) == "PX" {
+			ms, err := strconv.Atoi(args[4])
+			if err != nil || ms <= 0 {
+				return "-ERR invalid expire time in 'set' command\r\n"
+			}
+			v.expiresAt = time.Now().Add(time.Duration(ms) * time.Millisecond)
+		}
+		data[args[1]] = v
+		return "+OK\r\n"
+	case "DEL":
+		var n int
+		for _, key := range args[1:] {
+			if _, ok := data[key]; ok {
+				delete(data, key)
+				n++
+			}
+		}
+		return fmt.Sprintf(":%d\r\n", n)
+	}
+	return fmt.Sprintf("-ERR unknown command '%s'\r\n", args[0])
+}
+
+// readFakeCommand read array of bulk string
+func readFakeCommand(r *bufio.Reader) ([]string, error) {
+	readLine := func(prefix byte) (int, error) {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return 0, err
+		}
+		if line[0] != prefix {
+			return 0, fmt.Errorf("unexpected %q", line)
+		}
+		return strconv.Atoi(strings.TrimSuffix(line[1:], "\r\n"))
+	}
+
+	n, err := readLine('*')
+	if err != nil {
+		return nil, err
+	}
+	args := make([]string, n)
+	for i := range args {
+		size, err := readLine('$')
+		if err != nil {
+			return nil, err
+		}
+		buf := make([]byte, size+2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		args[i] = string(buf[:size])
+	}
+	return args, nil
+}
+
+func Test_Redis(t *testing.T) {
+	ctx := context.Background()
+	s := newFakeRedis(t, "")
+	c := NewRedis(RedisOption{Addr: s.addr()})
+
+	_, err := c.Get(ctx, "k1")
+	assert.ErrorIs(t, err, ErrMiss)
+
+	value := []byte("line 1\r\nline 2\x00")
+	assert.Nil(t, c.Set(ctx, "k1", value, 0))
+	got, err := c.Get(ctx, "k1")
+	assert.Nil(t, err)
+	assert.Equal(t, value, got, "value is binary safe")
+
+	assert.Nil(t, c.Set(ctx, "k2", []byte("v2"), 20*time.Millisecond))
+	_, err = c.Get(ctx, "k2")
+	assert.Nil(t, err)
+	time.Sleep(30 * time.Millisecond)
+	_, err = c.Get(ctx, "k2")
+	assert.ErrorIs(t, err, ErrMiss, "expired")
+
+	assert.Nil(t, c.Set(ctx, "k3", []byte("v3"), time.Microsecond), "ttl is rounded up to 1ms")
+	assert.Nil(t, c.Delete(ctx, "k1", "k3", "unknown"))
+	assert.Nil(t, c.Delete(ctx))
+	_, err = c.Get(ctx, "k1")
+	assert.ErrorIs(t, err, ErrMiss)
+
+	assert.Equal(t, 1, s.dialCount(), "connection is reused")
+}
+
+func Test_Redis_Auth(t *testing.T) {
+	ctx := context.Background()
+	s := newFakeRedis(t, "secret")
+
+	c := NewRedis(RedisOption{Addr: s.addr(), Password: "wrong"})
+	assert.ErrorContains(t, c.Set(ctx, "k1", []byte("v1"), 0), "WRONGPASS")
+
+	c = NewRedis(RedisOption{Addr: s.addr(), Username: "default", Password: "secret", DB: 1})
+	assert.Nil(t, c.Set(ctx, "k1", []byte("v1"), 0))
+	got, err := c.Get(ctx, "k1")
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("v1"), got)
+
+	c = NewRedis(RedisOption{Addr: s.addr(), Password: "secret"})
+	_, err = c.Get(ctx, "k1")
+	assert.ErrorIs(t, err, ErrMiss, "key is stored in the selected db")
+}
+
+func Test_Redis_Reconnect(t *testing.T) {
+	ctx := context.Background()
+	s := newFakeRedis(t, "")
+	c := NewRedis(RedisOption{Addr: s.addr()})
+
+	assert.Nil(t, c.Set(ctx, "k1", []byte("v1"), 0))
+	s.closeConns()
+
+	got, err := c.Get(ctx, "k1")
+	assert.Nil(t, err, "closed pooled connection is retried on new connection")
+	assert.Equal(t, []byte("v1"), got)
+	assert.Equal(t, 2, s.dialCount())
+
+	s.ln.Close()
+	s.closeConns()
+	_, err = c.Get(ctx, "k1")
+	assert.NotNil(t, err)
+}
+
+func Test_Redis_Timeout(t *testing.T) {
+	s := newFakeRedis(t, "")
+	s.mu.Lock()
+	s.delay = 100 * time.Millisecond
+	s.mu.Unlock()
+
+	c := NewRedis(RedisOption{Addr: s.addr(), Timeout: 20 * time.Millisecond})
+	err := c.Set(context.Background(), "k1", []byte("v1"), 0)
+	assert.ErrorIs(t, err, os.ErrDeadlineExceeded)
+
+	c = NewRedis(RedisOption{Addr: s.addr()})
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err = c.Get(ctx, "k1")
+	assert.NotNil(t, err)
+	assert.Less(t, time.Since(start), 100*time.Millisecond, "ctx deadline is used when it is earlier")
+}
+
+func Test_Redis_Concurrent(t *testing.T) {
+	ctx := context.Background()
+	s := newFakeRedis(t, "")
+	c := NewRedis(RedisOption{Addr: s.addr(), PoolSize: 2})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			key, value := fmt.Sprintf("k%d", i), []byte(fmt.Sprintf("v%d", i))
+			assert.Nil(t, c.Set(ctx, key, value, 0))
+			got, err := c.Get(ctx, key)
+			assert.Nil(t, err)
+			assert.Equal(t, value, got, "reply is not mixed between connection")
+		}(i)
+	}
+	wg.Wait()
+
+	assert.LessOrEqual(t, len(c.(*redis).idle), 2, "idle connection is capped by pool size")
+}
@@ -10,6 +10,9 @@ const (
 
 	// x field
 	XRequestID CtxKey = "x-request-id"
+	XActor     CtxKey = "x-actor"     // who make the request, set by trusted gateway
+	XUserID    CtxKey = "x-user-id"   // subject of verified access token
+	XTenantID  CtxKey = "x-tenant-id" // tenant of the request, set by trusted gateway or verified access token
 
 	// custom
 	ZeroLogSubLogger    CtxKey = "x-zerolog"     // type: zerolog.Logger
@@ -0,0 +1,94 @@
+package idempotency
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"time"
+)
+
+const (
+	// HeaderKey is request header which carry the idempotency key
+	HeaderKey = "Idempotency-Key"
+
+	// HeaderReplayed is set to "true" on replayed response
+	HeaderReplayed = "Idempotent-Replayed"
+
+	// MaxKeyLength is max length of idempotency key
+	MaxKeyLength = 255
+
+	DefaultTTL = 24 * time.Hour
+)
+
+var (
+	ErrKeyExists = errors.New("idempotency key already exists")
+	ErrNotFound  = errors.New("idempotency key not found")
+)
+
+type Option struct {
+	// TTL is how long response is kept for replay, default to 24 hour
+	TTL time.Duration
+
+	// Sweeper purge expired record
+	Sweeper SweeperOption
+}
+
+func (o Option) GetTTL() time.Duration {
+	if o.TTL <= 0 {
+		return DefaultTTL
+	}
+	return o.TTL
+}
+
+// Record is request identified by idempotency key and its response
+type Record struct {
+	Key         string
+	RequestHash string
+
+	// StatusCode is 0 while the request is still in progress
+	StatusCode int
+	ETag       string
+	Body       []byte
+	ExpiresAt  time.Time
+}
+
+// Completed report whether the response is already stored
+func (r Record) Completed() bool {
+	return r.StatusCode != 0
+}
+
+// Store keep idempotency record, expired record is treated as not exists
+type Store interface {
+	// Lock store in progress record, ErrKeyExists is returned when the key is already stored
+	Lock(ctx context.Context, rec Record) error
+
+	// Get return record of the key, ErrNotFound is returned when the key is not stored
+	Get(ctx context.Context, key string) (Record, error)
+
+	// Save store the response of the locked key, etag is empty when the response has no ETag header
+	Save(ctx context.Context, key string, statusCode int, etag string, body []byte) error
+
+	// Unlock remove in progress record so the request can be retried with the same key
+	Unlock(ctx context.Context, key string) error
+
+	// Purge remove at most limit record expired at before, it return number of removed record
+	Purge(ctx context.Context, before time.Time, limit int) (int, error)
+}
+
+// ScopedKey return key of the record in the scope, such as tenant.
+// the same key sent in different scope is a different record.
+func ScopedKey(scope, key string) string {
+	return scope + ":" + key
+}
+
+// Hash return fingerprint of request, it is used to detect key reused for different request
+func Hash(method, path string, body []byte) string {
+	h := sha256.New()
+	h.Write([]byte(method))
+	h.Write([]byte{0})
+	h.Write([]byte(path))
+	h.Write([]byte{0})
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
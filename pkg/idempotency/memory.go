@@ -0,0 +1,99 @@
+package idempotency
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// memoryStore keep record in memory, it is meant for test and single instance deployment
+type memoryStore struct {
+	mu      sync.Mutex
+	records map[string]Record
+	now     func() time.Time
+}
+
+func NewMemoryStore() Store {
+	return &memoryStore{
+		records: make(map[string]Record),
+		now:     time.Now,
+	}
+}
+
+func (s *memoryStore) Lock(ctx context.Context, rec Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.get(rec.Key); ok {
+		return ErrKeyExists
+	}
+
+	rec.StatusCode, rec.ETag, rec.Body = 0, "", nil
+	s.records[rec.Key] = rec
+	return nil
+}
+
+func (s *memoryStore) Get(ctx context.Context, key string) (Record, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rec, ok := s.get(key)
+	if !ok {
+		return Record{}, ErrNotFound
+	}
+	return rec, nil
+}
+
+func (s *memoryStore) Save(ctx context.Context, key string, statusCode int, etag string, body []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rec, ok := s.get(key)
+	if !ok {
+		return ErrNotFound
+	}
+
+	rec.StatusCode, rec.ETag, rec.Body = statusCode, etag, append([]byte(nil), body...)
+	s.records[key] = rec
+	return nil
+}
+
+func (s *memoryStore) Unlock(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if rec, ok := s.get(key); ok && !rec.Completed() {
+		delete(s.records, key)
+	}
+	return nil
+}
+
+func (s *memoryStore) Purge(ctx context.Context, before time.Time, limit int) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var n int
+	for key, rec := range s.records {
+		if n == limit {
+			break
+		}
+		if !rec.ExpiresAt.After(before) {
+			delete(s.records, key)
+			n++
+		}
+	}
+	return n, nil
+}
+
+// get return unexpired record, expired record is removed. caller should hold the lock
+func (s *memoryStore) get(key string) (Record, bool) {
+	rec, ok := s.records[key]
+	if !ok {
+		return rec, false
+	}
+	if !rec.ExpiresAt.After(s.now()) {
+		delete(s.records, key)
+		return Record{}, false
+	}
+	return rec, true
+}
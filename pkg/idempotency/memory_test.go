@@ -0,0 +1,72 @@
+package idempotency
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_MemoryStore(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2024, 01, 01, 0, 0, 0, 0, time.UTC)
+
+	s := NewMemoryStore().(*memoryStore)
+	s.now = func() time.Time { return now }
+
+	rec := Record{Key: "k1", RequestHash: "h1", ExpiresAt: now.Add(time.Hour)}
+	assert.Nil(t, s.Lock(ctx, rec))
+	assert.ErrorIs(t, s.Lock(ctx, rec), ErrKeyExists)
+
+	got, err := s.Get(ctx, "k1")
+	assert.Nil(t, err)
+	assert.False(t, got.Completed())
+
+	assert.Nil(t, s.Save(ctx, "k1", 200, `"1"`, []byte(`{}`)))
+	got, err = s.Get(ctx, "k1")
+	assert.Nil(t, err)
+	assert.Equal(t, Record{Key: "k1", RequestHash: "h1", StatusCode: 200, ETag: `"1"`, Body: []byte(`{}`), ExpiresAt: rec.ExpiresAt}, got)
+
+	// completed record is not removed by unlock
+	assert.Nil(t, s.Unlock(ctx, "k1"))
+	_, err = s.Get(ctx, "k1")
+	assert.Nil(t, err)
+
+	// expired key can be reused
+	now = now.Add(time.Hour)
+	_, err = s.Get(ctx, "k1")
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.Nil(t, s.Lock(ctx, Record{Key: "k1", RequestHash: "h2", ExpiresAt: now.Add(time.Hour)}))
+
+	// in progress record is removed by unlock
+	assert.Nil(t, s.Unlock(ctx, "k1"))
+	_, err = s.Get(ctx, "k1")
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.ErrorIs(t, s.Save(ctx, "k1", 200, "", nil), ErrNotFound)
+}
+
+func Test_MemoryStore_Purge(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2024, 01, 01, 0, 0, 0, 0, time.UTC)
+
+	s := NewMemoryStore().(*memoryStore)
+	s.now = func() time.Time { return now.Add(-time.Hour) }
+	for _, rec := range []Record{
+		{Key: "k1", ExpiresAt: now.Add(-time.Minute)},
+		{Key: "k2", ExpiresAt: now},
+		{Key: "k3", ExpiresAt: now.Add(time.Minute)},
+	} {
+		assert.Nil(t, s.Lock(ctx, rec))
+	}
+
+	n, err := s.Purge(ctx, now, 1)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, n, "limited")
+
+	n, err = s.Purge(ctx, now, 10)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, n)
+	assert.Len(t, s.records, 1)
+	assert.Contains(t, s.records, "k3")
+}
@@ -0,0 +1,100 @@
+package idempotency
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	gomysql "github.com/go-sql-driver/mysql"
+	"github.com/tuingking/supersvc/pkg/mysql"
+)
+
+const (
+	deleteExpiredKeyQuery = "DELETE FROM idempotency_key WHERE idempotency_key = ? AND expires_at <= ?"
+	lockKeyQuery          = "INSERT INTO idempotency_key(idempotency_key, request_hash, expires_at, created_at) VALUES (?, ?, ?, ?)"
+	getKeyQuery           = "SELECT idempotency_key, request_hash, status_code, etag, response_body, expires_at FROM idempotency_key WHERE idempotency_key = ? AND expires_at > ?"
+	saveKeyQuery          = "UPDATE idempotency_key SET status_code = ?, etag = ?, response_body = ? WHERE idempotency_key = ? AND expires_at > ?"
+	unlockKeyQuery        = "DELETE FROM idempotency_key WHERE idempotency_key = ? AND status_code = 0"
+	purgeKeyQuery         = "DELETE FROM idempotency_key WHERE expires_at <= ? ORDER BY expires_at LIMIT ?"
+)
+
+// mysqlErrDuplicateEntry is mysql error number for duplicate entry of unique key
+const mysqlErrDuplicateEntry = 1062
+
+// mysqlStore keep record in idempotency_key table, see scripts/migration
+type mysqlStore struct {
+	db  mysql.MySQL
+	now func() time.Time
+}
+
+func NewMySQLStore(db mysql.MySQL) Store {
+	return &mysqlStore{
+		db:  db,
+		now: time.Now,
+	}
+}
+
+func (s *mysqlStore) Lock(ctx context.Context, rec Record) error {
+	now := s.now()
+
+	// expired key can be reused
+	if _, err := s.db.Get().ExecContext(ctx, deleteExpiredKeyQuery, rec.Key, now); err != nil {
+		return err
+	}
+
+	_, err := s.db.Get().ExecContext(ctx, lockKeyQuery, rec.Key, rec.RequestHash, rec.ExpiresAt, now)
+	var mysqlErr *gomysql.MySQLError
+	if errors.As(err, &mysqlErr) && mysqlErr.Number == mysqlErrDuplicateEntry {
+		return ErrKeyExists
+	}
+	return err
+}
+
+func (s *mysqlStore) Get(ctx context.Context, key string) (Record, error) {
+	var rec Record
+	err := s.db.Get().QueryRowContext(ctx, getKeyQuery, key, s.now()).Scan(
+		&rec.Key,
+		&rec.RequestHash,
+		&rec.StatusCode,
+		&rec.ETag,
+		&rec.Body,
+		&rec.ExpiresAt,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return rec, ErrNotFound
+	}
+	return rec, err
+}
+
+func (s *mysqlStore) Save(ctx context.Context, key string, statusCode int, etag string, body []byte) error {
+	res, err := s.db.Get().ExecContext(ctx, saveKeyQuery, statusCode, etag, body, key, s.now())
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *mysqlStore) Unlock(ctx context.Context, key string) error {
+	_, err := s.db.Get().ExecContext(ctx, unlockKeyQuery, key)
+	return err
+}
+
+// Purge delete expired row using idempotency_key_expires_at_ix, oldest first
+func (s *mysqlStore) Purge(ctx context.Context, before time.Time, limit int) (int, error) {
+	res, err := s.db.Get().ExecContext(ctx, purgeKeyQuery, before, limit)
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := res.RowsAffected()
+	return int(n), err
+}
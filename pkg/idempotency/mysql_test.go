@@ -0,0 +1,125 @@
+package idempotency
+
+import (
+	"context"
+	"database/sql"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	gomysql "github.com/go-sql-driver/mysql"
+	"github.com/stretchr/testify/assert"
+)
+
+type mockDB struct {
+	db *sql.DB
+}
+
+func (m mockDB) Stop() error {
+	return m.db.Close()
+}
+
+func (m mockDB) Get() *sql.DB {
+	return m.db
+}
+
+func newMockStore(t *testing.T, now time.Time) (*mysqlStore, sqlmock.Sqlmock) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New: %s", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	return &mysqlStore{db: mockDB{db: db}, now: func() time.Time { return now }}, mock
+}
+
+func Test_MySQLStore_Lock(t *testing.T) {
+	now := time.Date(2024, 01, 01, 0, 0, 0, 0, time.UTC)
+	rec := Record{Key: "k1", RequestHash: "h1", ExpiresAt: now.Add(time.Hour)}
+
+	t.Run("locked", func(t *testing.T) {
+		s, mock := newMockStore(t, now)
+		mock.ExpectExec(regexp.QuoteMeta(deleteExpiredKeyQuery)).
+			WithArgs("k1", now).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec(regexp.QuoteMeta(lockKeyQuery)).
+			WithArgs("k1", "h1", rec.ExpiresAt, now).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		assert.Nil(t, s.Lock(context.Background(), rec))
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("key exists", func(t *testing.T) {
+		s, mock := newMockStore(t, now)
+		mock.ExpectExec(regexp.QuoteMeta(deleteExpiredKeyQuery)).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec(regexp.QuoteMeta(lockKeyQuery)).
+			WillReturnError(&gomysql.MySQLError{Number: 1062, Message: "Duplicate entry 'k1' for key 'idempotency_key.PRIMARY'"})
+
+		assert.ErrorIs(t, s.Lock(context.Background(), rec), ErrKeyExists)
+	})
+}
+
+func Test_MySQLStore_Get(t *testing.T) {
+	now := time.Date(2024, 01, 01, 0, 0, 0, 0, time.UTC)
+	columns := []string{"idempotency_key", "request_hash", "status_code", "etag", "response_body", "expires_at"}
+
+	t.Run("found", func(t *testing.T) {
+		s, mock := newMockStore(t, now)
+		mock.ExpectQuery(regexp.QuoteMeta(getKeyQuery)).
+			WithArgs("k1", now).
+			WillReturnRows(sqlmock.NewRows(columns).AddRow("k1", "h1", 201, `"1"`, []byte(`{}`), now.Add(time.Hour)))
+
+		rec, err := s.Get(context.Background(), "k1")
+		assert.Nil(t, err)
+		assert.Equal(t, Record{Key: "k1", RequestHash: "h1", StatusCode: 201, ETag: `"1"`, Body: []byte(`{}`), ExpiresAt: now.Add(time.Hour)}, rec)
+	})
+
+	t.Run("not found or expired", func(t *testing.T) {
+		s, mock := newMockStore(t, now)
+		mock.ExpectQuery(regexp.QuoteMeta(getKeyQuery)).
+			WithArgs("k1", now).
+			WillReturnRows(sqlmock.NewRows(columns))
+
+		_, err := s.Get(context.Background(), "k1")
+		assert.ErrorIs(t, err, ErrNotFound)
+	})
+}
+
+func Test_MySQLStore_Save(t *testing.T) {
+	now := time.Date(2024, 01, 01, 0, 0, 0, 0, time.UTC)
+
+	t.Run("saved", func(t *testing.T) {
+		s, mock := newMockStore(t, now)
+		mock.ExpectExec(regexp.QuoteMeta(saveKeyQuery)).
+			WithArgs(201, `"1"`, []byte(`{}`), "k1", now).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		assert.Nil(t, s.Save(context.Background(), "k1", 201, `"1"`, []byte(`{}`)))
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("unlocked or expired", func(t *testing.T) {
+		s, mock := newMockStore(t, now)
+		mock.ExpectExec(regexp.QuoteMeta(saveKeyQuery)).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+
+		assert.ErrorIs(t, s.Save(context.Background(), "k1", 201, "", nil), ErrNotFound)
+	})
+}
+
+func Test_MySQLStore_Purge(t *testing.T) {
+	now := time.Date(2024, 01, 01, 0, 0, 0, 0, time.UTC)
+
+	s, mock := newMockStore(t, now)
+	mock.ExpectExec(regexp.QuoteMeta(purgeKeyQuery)).
+		WithArgs(now, 100).
+		WillReturnResult(sqlmock.NewResult(0, 42))
+
+	n, err := s.Purge(context.Background(), now, 100)
+	assert.Nil(t, err)
+	assert.Equal(t, 42, n)
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
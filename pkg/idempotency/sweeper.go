@@ -0,0 +1,92 @@
+package idempotency
+
+import (
+	"context"
+	"time"
+
+	"github.com/tuingking/supersvc/pkg/logger"
+)
+
+const (
+	defaultSweepInterval  = time.Hour
+	defaultSweepBatchSize = 1000
+)
+
+// SweeperOption configure background job which purge expired record
+type SweeperOption struct {
+	// Interval between sweep, default to 1 hour
+	Interval time.Duration
+
+	// BatchSize is max record purged at once, default to 1000
+	BatchSize int
+}
+
+func (o SweeperOption) interval() time.Duration {
+	if o.Interval <= 0 {
+		return defaultSweepInterval
+	}
+	return o.Interval
+}
+
+func (o SweeperOption) batchSize() int {
+	if o.BatchSize <= 0 {
+		return defaultSweepBatchSize
+	}
+	return o.BatchSize
+}
+
+// Sweeper periodically purge expired record, otherwise record is only removed when its key is reused.
+// purge is idempotent, so it is safe to run sweeper in every replica.
+type Sweeper interface {
+	// Run block until ctx is canceled
+	Run(ctx context.Context)
+}
+
+type sweeper struct {
+	opt   SweeperOption
+	store Store
+	now   func() time.Time
+}
+
+func NewSweeper(opt SweeperOption, store Store) Sweeper {
+	return &sweeper{
+		opt:   opt,
+		store: store,
+		now:   time.Now,
+	}
+}
+
+func (s *sweeper) Run(ctx context.Context) {
+	log := logger.Get(ctx)
+
+	ticker := time.NewTicker(s.opt.interval())
+	defer ticker.Stop()
+
+	log.Info().Dur("interval", s.opt.interval()).Msg("idempotency sweeper started")
+	for {
+		select {
+		case <-ctx.Done():
+			log.Info().Msg("idempotency sweeper stopped")
+			return
+		case <-ticker.C:
+			s.sweep(ctx)
+		}
+	}
+}
+
+// sweep purge expired record batch by batch until no full batch is left
+func (s *sweeper) sweep(ctx context.Context) {
+	log := logger.Get(ctx)
+
+	now := s.now()
+	for ctx.Err() == nil {
+		n, err := s.store.Purge(ctx, now, s.opt.batchSize())
+		if err != nil {
+			log.Err(err).Msg("failed: purge expired idempotency key")
+			return
+		}
+		if n < s.opt.batchSize() {
+			return
+		}
+	}
+}
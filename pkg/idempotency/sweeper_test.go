@@ -0,0 +1,54 @@
+package idempotency
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// countingStore count Purge call of the memory store
+type countingStore struct {
+	Store
+	purges int
+	err    error
+}
+
+func (s *countingStore) Purge(ctx context.Context, before time.Time, limit int) (int, error) {
+	s.purges++
+	if s.err != nil {
+		return 0, s.err
+	}
+	return s.Store.Purge(ctx, before, limit)
+}
+
+func Test_Sweeper_Sweep(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2024, 01, 01, 0, 0, 0, 0, time.UTC)
+
+	mem := NewMemoryStore().(*memoryStore)
+	mem.now = func() time.Time { return now.Add(-time.Hour) }
+	for _, key := range []string{"k1", "k2", "k3"} {
+		assert.Nil(t, mem.Lock(ctx, Record{Key: key, ExpiresAt: now}))
+	}
+	assert.Nil(t, mem.Lock(ctx, Record{Key: "k4", ExpiresAt: now.Add(time.Minute)}))
+
+	store := &countingStore{Store: mem}
+	s := NewSweeper(SweeperOption{BatchSize: 2}, store).(*sweeper)
+	s.now = func() time.Time { return now }
+
+	s.sweep(ctx)
+	assert.Equal(t, 2, store.purges, "until no full batch is left")
+	assert.Len(t, mem.records, 1)
+	assert.Contains(t, mem.records, "k4", "unexpired record is kept")
+
+	t.Run("failed purge", func(t *testing.T) {
+		store := &countingStore{Store: mem, err: errors.New("connection refused")}
+		s := NewSweeper(SweeperOption{BatchSize: 2}, store).(*sweeper)
+
+		s.sweep(ctx)
+		assert.Equal(t, 1, store.purges)
+	})
+}
@@ -0,0 +1,48 @@
+// Package jsonmerge implement JSON merge patch, see RFC 7396
+package jsonmerge
+
+import (
+	"encoding/json"
+
+	"github.com/pkg/errors"
+)
+
+// Merge apply patch to doc.
+// member with null value in patch is removed from doc, object is merged recursively,
+// any other value replace the value in doc.
+func Merge(doc, patch []byte) ([]byte, error) {
+	var target, p interface{}
+
+	if len(doc) > 0 {
+		if err := json.Unmarshal(doc, &target); err != nil {
+			return nil, errors.Wrap(err, "unmarshal doc")
+		}
+	}
+	if err := json.Unmarshal(patch, &p); err != nil {
+		return nil, errors.Wrap(err, "unmarshal patch")
+	}
+
+	return json.Marshal(merge(target, p))
+}
+
+func merge(target, patch interface{}) interface{} {
+	patchObj, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+
+	targetObj, ok := target.(map[string]interface{})
+	if !ok {
+		targetObj = make(map[string]interface{})
+	}
+
+	for key, value := range patchObj {
+		if value == nil {
+			delete(targetObj, key)
+			continue
+		}
+		targetObj[key] = merge(targetObj[key], value)
+	}
+
+	return targetObj
+}
@@ -0,0 +1,46 @@
+package jsonmerge
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// test case from RFC 7396 appendix A
+func Test_Merge(t *testing.T) {
+	testCase := []struct {
+		doc   string
+		patch string
+		exp   string
+	}{
+		{`{"a":"b"}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"b"}`, `{"b":"c"}`, `{"a":"b","b":"c"}`},
+		{`{"a":"b"}`, `{"a":null}`, `{}`},
+		{`{"a":"b","b":"c"}`, `{"a":null}`, `{"b":"c"}`},
+		{`{"a":["b"]}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"c"}`, `{"a":["b"]}`, `{"a":["b"]}`},
+		{`{"a":{"b":"c"}}`, `{"a":{"b":"d","c":null}}`, `{"a":{"b":"d"}}`},
+		{`{"a":[{"b":"c"}]}`, `{"a":[1]}`, `{"a":[1]}`},
+		{`["a","b"]`, `["c","d"]`, `["c","d"]`},
+		{`{"a":"b"}`, `["c"]`, `["c"]`},
+		{`{"a":"foo"}`, `null`, `null`},
+		{`{"a":"foo"}`, `"bar"`, `"bar"`},
+		{`{"e":null}`, `{"a":1}`, `{"a":1,"e":null}`},
+		{`[1,2]`, `{"a":"b","c":null}`, `{"a":"b"}`},
+		{`{}`, `{"a":{"bb":{"ccc":null}}}`, `{"a":{"bb":{}}}`},
+	}
+
+	for _, tc := range testCase {
+		result, err := Merge([]byte(tc.doc), []byte(tc.patch))
+		assert.Nil(t, err)
+		assert.JSONEq(t, tc.exp, string(result))
+	}
+}
+
+func Test_Merge_InvalidJSON(t *testing.T) {
+	_, err := Merge([]byte(`{"a":"b"}`), []byte(`{"a":`))
+	assert.NotNil(t, err)
+
+	_, err = Merge([]byte(`{"a":`), []byte(`{"a":"b"}`))
+	assert.NotNil(t, err)
+}
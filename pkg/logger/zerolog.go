@@ -10,6 +10,7 @@ import (
 
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
+	"github.com/tuingking/supersvc/pkg/ctxkey"
 )
 
 type ZeroLogKey int
@@ -60,6 +61,10 @@ func Setup(opt Option, svcname string) {
 	log.Debug().Msg("zerolog initialized")
 }
 
+// Get return sub logger set by middleware.Inbound, global logger is returned if not found
 func Get(ctx context.Context) zerolog.Logger {
-	return ctx.Value(ZeroLogSubLogger).(zerolog.Logger)
+	if l, ok := ctx.Value(ctxkey.ZeroLogSubLogger).(zerolog.Logger); ok {
+		return l
+	}
+	return log.Logger
 }
@@ -13,9 +13,31 @@ import (
 	"github.com/tuingking/supersvc/pkg/ctxkey"
 )
 
+// Route is method and path of request, e.g: POST /api/v1/auth/login
+type Route struct {
+	Method string
+	Path   string
+}
+
 // NOTE: this package using zerolog package
 // set "x-request-id" to context and zerolog
 func Inbound(next http.Handler) http.Handler {
+	return InboundRedact()(next)
+}
+
+// InboundRedact is Inbound which doesn't dump the body of request to the routes, e.g: body carrying password
+func InboundRedact(routes ...Route) func(http.Handler) http.Handler {
+	redacted := make(map[Route]bool, len(routes))
+	for _, route := range routes {
+		redacted[route] = true
+	}
+
+	return func(next http.Handler) http.Handler {
+		return inbound(next, redacted)
+	}
+}
+
+func inbound(next http.Handler, redacted map[Route]bool) http.Handler {
 	fn := func(w http.ResponseWriter, r *http.Request) {
 		ctx := r.Context()
 		requestId := r.Header.Get(ctxkey.XRequestID.String())
@@ -26,7 +48,8 @@ func Inbound(next http.Handler) http.Handler {
 		// create sub logger with requestId
 		subLogger := log.With().Str(ctxkey.XRequestID.String(), requestId).Logger()
 
-		dump, err := httputil.DumpRequest(r, true)
+		dumpBody := !redacted[Route{Method: r.Method, Path: r.URL.Path}]
+		dump, err := httputil.DumpRequest(r, dumpBody)
 		if err != nil {
 			subLogger.Err(err).Msg("err: httputil.DumpRequest")
 		}
@@ -0,0 +1,84 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/tuingking/supersvc/pkg/logger"
+)
+
+const (
+	DriverLog = "log"
+)
+
+var (
+	// ErrNotConfigured is returned by notifier of the service which is created without notifier
+	ErrNotConfigured = errors.New("notifier is not configured")
+)
+
+type Channel string
+
+const (
+	ChannelEmail Channel = "email"
+	ChannelSMS   Channel = "sms"
+)
+
+// Message is delivered to the recipient through the channel
+type Message struct {
+	Channel Channel
+
+	// To is email address for ChannelEmail and E.164 phone for ChannelSMS
+	To      string
+	Subject string
+	Body    string
+}
+
+// Notifier deliver message to the recipient, e.g: email or SMS provider
+type Notifier interface {
+	Notify(ctx context.Context, msg Message) error
+}
+
+type Option struct {
+	// Driver is log, it is required since the message carry secret such as verification code.
+	// log write the secret to the log, it is meant for local development only
+	Driver string
+}
+
+// New return notifier of the driver, error is returned when the driver is empty or unknown
+func New(opt Option) (Notifier, error) {
+	switch opt.Driver {
+	case "":
+		return nil, errors.New("notifier driver is not set")
+	case DriverLog:
+		return NewLogNotifier(), nil
+	}
+	return nil, fmt.Errorf("unknown notifier driver %q", opt.Driver)
+}
+
+// NotifierFunc adapt function as Notifier
+type NotifierFunc func(ctx context.Context, msg Message) error
+
+func (f NotifierFunc) Notify(ctx context.Context, msg Message) error {
+	return f(ctx, msg)
+}
+
+// LogNotifier write the message to the log instead of delivering it, it is meant for test and local development.
+// the body is logged as is, don't use it in production.
+type LogNotifier struct{}
+
+func NewLogNotifier() LogNotifier {
+	return LogNotifier{}
+}
+
+func (LogNotifier) Notify(ctx context.Context, msg Message) error {
+	log := logger.Get(ctx)
+
+	log.Info().
+		Str("channel", string(msg.Channel)).
+		Str("to", msg.To).
+		Str("subject", msg.Subject).
+		Str("body", msg.Body).
+		Msg("notification")
+	return nil
+}
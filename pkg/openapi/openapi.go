@@ -0,0 +1,342 @@
+package openapi
+
+import (
+	"database/sql"
+	"encoding"
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const Version = "3.0.3"
+
+// Document is minimal OpenAPI 3 document, only parameters are generated.
+type Document struct {
+	OpenAPI string              `json:"openapi"`
+	Info    Info                `json:"info"`
+	Paths   map[string]PathItem `json:"paths"`
+}
+
+type Info struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+// PathItem is map of lower case http method to operation
+type PathItem map[string]Operation
+
+type Operation struct {
+	Summary    string      `json:"summary,omitempty"`
+	Parameters []Parameter `json:"parameters,omitempty"`
+}
+
+type Parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Style       string  `json:"style,omitempty"`
+	Explode     *bool   `json:"explode,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+type Schema struct {
+	Type    string        `json:"type"`
+	Format  string        `json:"format,omitempty"`
+	Items   *Schema       `json:"items,omitempty"`
+	Enum    []interface{} `json:"enum,omitempty"`
+	Default interface{}   `json:"default,omitempty"`
+	Minimum *float64      `json:"minimum,omitempty"`
+	Maximum *float64      `json:"maximum,omitempty"`
+	Pattern string        `json:"pattern,omitempty"`
+}
+
+// regexFilterField match field accepted by parser in filter[field], see parser.regexFilterKey
+var regexFilterField = regexp.MustCompile(`^[A-Za-z0-9_.]+$`)
+
+// operatorDescriptions is operator suffix understood by qbuilder
+var operatorDescriptions = []struct {
+	suffix string
+	desc   string
+}{
+	{"__gte", "greater than or equal to"},
+	{"__gt", "greater than"},
+	{"__lte", "less than or equal to"},
+	{"__lt", "less than"},
+	{"__neq", "not equal to"},
+	{"__nin", "not in"},
+	{"__in", "in"},
+}
+
+var (
+	typeTime        = reflect.TypeOf(time.Time{})
+	typeNullString  = reflect.TypeOf(sql.NullString{})
+	typeNullBool    = reflect.TypeOf(sql.NullBool{})
+	typeNullInt64   = reflect.TypeOf(sql.NullInt64{})
+	typeNullInt32   = reflect.TypeOf(sql.NullInt32{})
+	typeNullInt16   = reflect.TypeOf(sql.NullInt16{})
+	typeNullByte    = reflect.TypeOf(sql.NullByte{})
+	typeNullFloat64 = reflect.TypeOf(sql.NullFloat64{})
+	typeNullTime    = reflect.TypeOf(sql.NullTime{})
+
+	typeTextUnmarshaler = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// Parameters generate OpenAPI parameter from param struct.
+//
+// Field is documented based on the following tag:
+//
+//	param:"created_at__gte"  query parameter, operator suffix is described
+//	path:"id"                path parameter, always required
+//	header:"X-Timezone"      header parameter
+//	required:"true"          parameter is required
+//	description:"..."        parameter description
+//	enum:"a,b,c"             allowed values
+//	minimum:"1"              minimum numeric value
+//	maximum:"100"            maximum numeric value
+//	pattern:"^[a-z]+$"       string pattern
+//
+// page, limit and sortBy param are described using qbuilder default.
+func Parameters(param interface{}) ([]Parameter, error) {
+	t := reflect.TypeOf(param)
+	if t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return nil, errors.New("openapi: param should be a struct or pointer to struct")
+	}
+
+	return parameters(t)
+}
+
+func parameters(t reflect.Type) ([]Parameter, error) {
+	var params []Parameter
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+
+		ft := field.Type
+		if ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+		if field.Anonymous && ft.Kind() == reflect.Struct {
+			embedded, err := parameters(ft)
+			if err != nil {
+				return nil, err
+			}
+			params = append(params, embedded...)
+			continue
+		}
+
+		if !field.IsExported() {
+			continue
+		}
+
+		p, ok, err := parameter(field)
+		if err != nil {
+			return nil, fmt.Errorf("openapi: field %s: %w", field.Name, err)
+		}
+		if ok {
+			params = append(params, p)
+		}
+	}
+
+	return params, nil
+}
+
+func parameter(field reflect.StructField) (p Parameter, ok bool, err error) {
+	switch {
+	case validTag(field.Tag.Get("path")):
+		p = Parameter{Name: field.Tag.Get("path"), In: "path", Required: true}
+	case validTag(field.Tag.Get("header")):
+		p = Parameter{Name: field.Tag.Get("header"), In: "header"}
+	case validTag(field.Tag.Get("param")):
+		p = Parameter{Name: field.Tag.Get("param"), In: "query"}
+	default:
+		return p, false, nil
+	}
+
+	p.Schema, err = schemaOf(field.Type)
+	if err != nil {
+		return p, false, err
+	}
+
+	if p.Schema.Type == "array" && p.In == "query" {
+		explode := true
+		p.Style, p.Explode = "form", &explode
+	}
+
+	if err = applyConstraint(p.Schema, field.Tag); err != nil {
+		return p, false, err
+	}
+
+	p.Required = p.Required || field.Tag.Get("required") == "true"
+	p.Description = field.Tag.Get("description")
+	if p.In == "query" {
+		describeQuery(&p, field.Tag.Get("db"))
+	}
+
+	return p, true, nil
+}
+
+func validTag(tag string) bool {
+	return tag != "" && tag != "-"
+}
+
+func schemaOf(t reflect.Type) (*Schema, error) {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch t {
+	case typeTime, typeNullTime:
+		return &Schema{Type: "string", Format: "date-time"}, nil
+	case typeNullString:
+		return &Schema{Type: "string"}, nil
+	case typeNullBool:
+		return &Schema{Type: "boolean"}, nil
+	case typeNullInt64:
+		return &Schema{Type: "integer", Format: "int64"}, nil
+	case typeNullInt32, typeNullInt16, typeNullByte:
+		return &Schema{Type: "integer", Format: "int32"}, nil
+	case typeNullFloat64:
+		return &Schema{Type: "number", Format: "double"}, nil
+	}
+
+	// generic sql.Null[T]
+	if t.Kind() == reflect.Struct && strings.HasPrefix(t.String(), "sql.Null[") {
+		if v, ok := t.FieldByName("V"); ok {
+			return schemaOf(v.Type)
+		}
+	}
+
+	// named type with text representation, e.g: enum
+	if t.Kind() != reflect.Slice && reflect.PointerTo(t).Implements(typeTextUnmarshaler) {
+		return &Schema{Type: "string"}, nil
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return &Schema{Type: "string"}, nil
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}, nil
+	case reflect.Int, reflect.Int64, reflect.Uint, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer", Format: "int64"}, nil
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint8, reflect.Uint16:
+		return &Schema{Type: "integer", Format: "int32"}, nil
+	case reflect.Float32:
+		return &Schema{Type: "number", Format: "float"}, nil
+	case reflect.Float64:
+		return &Schema{Type: "number", Format: "double"}, nil
+	case reflect.Slice, reflect.Array:
+		items, err := schemaOf(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+		return &Schema{Type: "array", Items: items}, nil
+	}
+
+	return nil, fmt.Errorf("unsupported type %s", t)
+}
+
+// applyConstraint set enum, minimum, maximum and pattern.
+// for array, the constraint is applied to the items.
+func applyConstraint(s *Schema, tag reflect.StructTag) error {
+	if s.Type == "array" {
+		s = s.Items
+	}
+
+	if enum := tag.Get("enum"); enum != "" {
+		for _, e := range strings.Split(enum, ",") {
+			v, err := typedValue(s, strings.TrimSpace(e))
+			if err != nil {
+				return fmt.Errorf("enum %q: %w", e, err)
+			}
+			s.Enum = append(s.Enum, v)
+		}
+	}
+
+	for key, dest := range map[string]**float64{"minimum": &s.Minimum, "maximum": &s.Maximum} {
+		if raw := tag.Get(key); raw != "" {
+			n, err := strconv.ParseFloat(raw, 64)
+			if err != nil {
+				return fmt.Errorf("%s %q: %w", key, raw, err)
+			}
+			*dest = &n
+		}
+	}
+
+	s.Pattern = tag.Get("pattern")
+
+	return nil
+}
+
+func typedValue(s *Schema, raw string) (interface{}, error) {
+	switch s.Type {
+	case "integer":
+		return strconv.ParseInt(raw, 10, 64)
+	case "number":
+		return strconv.ParseFloat(raw, 64)
+	case "boolean":
+		return strconv.ParseBool(raw)
+	}
+	return raw, nil
+}
+
+// describeQuery describe pagination and operator param the way qbuilder treat them
+func describeQuery(p *Parameter, db string) {
+	one := float64(1)
+
+	switch p.Name {
+	case "page":
+		p.Schema.Default, p.Schema.Minimum = 1, &one
+		appendDescription(p, "page number, start from 1")
+		return
+	case "limit":
+		p.Schema.Default, p.Schema.Minimum = 10, &one
+		appendDescription(p, "number of rows per page")
+		return
+	case "sortBy":
+		appendDescription(p, "sort by column, prefix with - for descending. e.g: -created_at")
+		return
+	}
+
+	// not a filter, see qbuilder
+	if !validTag(db) {
+		return
+	}
+
+	for _, op := range operatorDescriptions {
+		if field := strings.TrimSuffix(p.Name, op.suffix); field != p.Name {
+			appendDescription(p, fmt.Sprintf("filter %s %s the given value", field, op.desc)+
+				filterHint(field, "["+strings.TrimPrefix(op.suffix, "__")+"]"))
+			return
+		}
+	}
+
+	// filter[field][in] is bound to field__in, so param without suffix only accept filter[field] repeated for each value
+	if p.Schema.Type == "array" {
+		appendDescription(p, fmt.Sprintf("filter %s in the given values", p.Name)+filterHint(p.Name, ""))
+		return
+	}
+	appendDescription(p, fmt.Sprintf("filter %s equal to the given value", p.Name)+filterHint(p.Name, "[eq]"))
+}
+
+// filterHint return the JSON:API style key of the filter, empty when the field can't be written in the key.
+// it follows parser.expandFilter which bind filter[field][op] to the param field__op.
+func filterHint(field, op string) string {
+	if !regexFilterField.MatchString(field) {
+		return ""
+	}
+	return fmt.Sprintf(", also accepted as filter[%s]%s", field, op)
+}
+
+func appendDescription(p *Parameter, desc string) {
+	if p.Description != "" {
+		p.Description += ". "
+	}
+	p.Description += desc
+}
@@ -0,0 +1,163 @@
+package openapi
+
+import (
+	"database/sql"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type ParamUser struct {
+	ID           string         `path:"id"`
+	Timezone     string         `header:"X-Timezone" description:"IANA time zone"`
+	Email        sql.NullString `param:"email" db:"email" pattern:"^.+@.+$"`
+	Status       []int          `param:"status__in" db:"status" enum:"0,1,2"`
+	Score        float32        `param:"score__gte" db:"score" minimum:"0" maximum:"100"`
+	CreatedAtGTE sql.NullTime   `param:"created_at__gte" db:"created_at"`
+	Verbose      bool           `param:"verbose"`
+	Skip         string         `param:"-" db:"skip"`
+	Untagged     string
+
+	Page   int64    `param:"page"`
+	Limit  int64    `param:"limit"`
+	SortBy []string `param:"sortBy"`
+}
+
+type Color int
+
+func (c *Color) UnmarshalText(text []byte) error {
+	return nil
+}
+
+type ParamTextUnmarshaler struct {
+	Colors []Color `param:"color__in" db:"color" enum:"red,green"`
+}
+
+type ParamUnsupported struct {
+	Meta map[string]string `param:"meta"`
+}
+
+type ParamFilter struct {
+	Tags []string `param:"tags" db:"tags"`
+	Kind string   `param:"kind-of" db:"kind"`
+}
+
+type ParamGeneric struct {
+	Age  sql.Null[int32]     `param:"age" db:"age"`
+	Time sql.Null[time.Time] `param:"time" db:"time"`
+}
+
+func Test_Parameters(t *testing.T) {
+	explode := true
+	zero, one, hundred := float64(0), float64(1), float64(100)
+
+	exp := []Parameter{
+		{Name: "id", In: "path", Required: true, Schema: &Schema{Type: "string"}},
+		{Name: "X-Timezone", In: "header", Description: "IANA time zone", Schema: &Schema{Type: "string"}},
+		{
+			Name:        "email",
+			In:          "query",
+			Description: "filter email equal to the given value, also accepted as filter[email][eq]",
+			Schema:      &Schema{Type: "string", Pattern: "^.+@.+$"},
+		},
+		{
+			Name:        "status__in",
+			In:          "query",
+			Description: "filter status in the given value, also accepted as filter[status][in]",
+			Style:       "form",
+			Explode:     &explode,
+			Schema: &Schema{Type: "array", Items: &Schema{
+				Type:   "integer",
+				Format: "int64",
+				Enum:   []interface{}{int64(0), int64(1), int64(2)},
+			}},
+		},
+		{
+			Name:        "score__gte",
+			In:          "query",
+			Description: "filter score greater than or equal to the given value, also accepted as filter[score][gte]",
+			Schema:      &Schema{Type: "number", Format: "float", Minimum: &zero, Maximum: &hundred},
+		},
+		{
+			Name:        "created_at__gte",
+			In:          "query",
+			Description: "filter created_at greater than or equal to the given value, also accepted as filter[created_at][gte]",
+			Schema:      &Schema{Type: "string", Format: "date-time"},
+		},
+		{Name: "verbose", In: "query", Schema: &Schema{Type: "boolean"}},
+		{
+			Name:        "page",
+			In:          "query",
+			Description: "page number, start from 1",
+			Schema:      &Schema{Type: "integer", Format: "int64", Default: 1, Minimum: &one},
+		},
+		{
+			Name:        "limit",
+			In:          "query",
+			Description: "number of rows per page",
+			Schema:      &Schema{Type: "integer", Format: "int64", Default: 10, Minimum: &one},
+		},
+		{
+			Name:        "sortBy",
+			In:          "query",
+			Description: "sort by column, prefix with - for descending. e.g: -created_at",
+			Style:       "form",
+			Explode:     &explode,
+			Schema:      &Schema{Type: "array", Items: &Schema{Type: "string"}},
+		},
+	}
+
+	params, err := Parameters(&ParamUser{})
+	assert.Nil(t, err)
+	assert.Equal(t, exp, params)
+
+	_, err = json.Marshal(params)
+	assert.Nil(t, err)
+}
+
+func Test_Parameters_Filter(t *testing.T) {
+	params, err := Parameters(&ParamFilter{})
+	assert.Nil(t, err)
+	assert.Len(t, params, 2)
+
+	// filter[tags][in] is bound to tags__in which is not a param
+	assert.Equal(t, "filter tags in the given values, also accepted as filter[tags]", params[0].Description)
+	assert.Equal(t, "filter kind-of equal to the given value", params[1].Description, "filter key doesn't accept -")
+}
+
+func Test_Parameters_Generic(t *testing.T) {
+	params, err := Parameters(ParamGeneric{})
+	assert.Nil(t, err)
+	assert.Equal(t, &Schema{Type: "integer", Format: "int32"}, params[0].Schema)
+	assert.Equal(t, &Schema{Type: "string", Format: "date-time"}, params[1].Schema)
+}
+
+func Test_Parameters_TextUnmarshaler(t *testing.T) {
+	params, err := Parameters(ParamTextUnmarshaler{})
+	assert.Nil(t, err)
+	assert.Equal(t, &Schema{Type: "array", Items: &Schema{Type: "string", Enum: []interface{}{"red", "green"}}}, params[0].Schema)
+}
+
+func Test_Parameters_Required(t *testing.T) {
+	params, err := Parameters(struct {
+		IfMatch string `header:"If-Match" required:"true"`
+		Limit   int64  `param:"limit" required:"false"`
+	}{})
+	assert.Nil(t, err)
+	assert.True(t, params[0].Required)
+	assert.False(t, params[1].Required)
+}
+
+func Test_Parameters_Error(t *testing.T) {
+	t.Run("not a struct", func(t *testing.T) {
+		_, err := Parameters("hoho")
+		assert.NotNil(t, err)
+	})
+
+	t.Run("unsupported type", func(t *testing.T) {
+		_, err := Parameters(ParamUnsupported{})
+		assert.NotNil(t, err)
+	})
+}
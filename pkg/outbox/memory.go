@@ -0,0 +1,98 @@
+package outbox
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+type memoryMessage struct {
+	Message
+	nextAttemptAt time.Time
+	lastError     string
+}
+
+// MemoryStore keep message in memory, it is meant for test and single instance deployment
+type MemoryStore struct {
+	mu   sync.Mutex
+	msgs []memoryMessage
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{}
+}
+
+// Add store messages, they are due immediately
+func (s *MemoryStore) Add(msgs ...Message) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, m := range msgs {
+		s.msgs = append(s.msgs, memoryMessage{Message: m, nextAttemptAt: m.CreatedAt})
+	}
+}
+
+// Pending return unpublished message in insertion order
+func (s *MemoryStore) Pending() []Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	msgs := make([]Message, len(s.msgs))
+	for i, m := range s.msgs {
+		msgs[i] = m.Message
+	}
+	return msgs
+}
+
+func (s *MemoryStore) Claim(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]Message, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var msgs []Message
+	seen := make(map[string]bool)
+	for i := range s.msgs {
+		if len(msgs) == limit {
+			break
+		}
+
+		m := &s.msgs[i]
+		if seen[m.Key] {
+			continue
+		}
+		seen[m.Key] = true
+
+		if m.nextAttemptAt.After(now) {
+			continue
+		}
+		m.Attempts++
+		m.nextAttemptAt = now.Add(lease)
+		msgs = append(msgs, m.Message)
+	}
+	return msgs, nil
+}
+
+func (s *MemoryStore) Ack(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, m := range s.msgs {
+		if m.ID == id {
+			s.msgs = append(s.msgs[:i], s.msgs[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+func (s *MemoryStore) Retry(ctx context.Context, id string, at time.Time, cause error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := range s.msgs {
+		if s.msgs[i].ID == id {
+			s.msgs[i].nextAttemptAt, s.msgs[i].lastError = at, cause.Error()
+			break
+		}
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_MemoryStore(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2024, 01, 01, 0, 0, 0, 0, time.UTC)
+
+	ids := func(msgs []Message) []string {
+		var v []string
+		for _, m := range msgs {
+			v = append(v, m.ID)
+		}
+		return v
+	}
+
+	s := NewMemoryStore()
+	s.Add(
+		Message{ID: "m1", Key: "u1", CreatedAt: now},
+		Message{ID: "m2", Key: "u1", CreatedAt: now},
+		Message{ID: "m3", Key: "u2", CreatedAt: now},
+	)
+
+	// only the oldest message of each key is due
+	msgs, err := s.Claim(ctx, now, time.Minute, 10)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"m1", "m3"}, ids(msgs))
+	assert.Equal(t, 1, msgs[0].Attempts)
+
+	// claimed message is leased
+	msgs, err = s.Claim(ctx, now, time.Minute, 10)
+	assert.Nil(t, err)
+	assert.Empty(t, msgs)
+
+	assert.Nil(t, s.Ack(ctx, "m1"))
+	assert.Nil(t, s.Retry(ctx, "m3", now.Add(time.Hour), errors.New("unavailable")))
+
+	msgs, err = s.Claim(ctx, now, time.Minute, 10)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"m2"}, ids(msgs))
+
+	// unacked message is claimed again after the lease
+	msgs, err = s.Claim(ctx, now.Add(time.Hour), time.Minute, 10)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"m2", "m3"}, ids(msgs))
+	assert.Equal(t, []int{2, 2}, []int{msgs[0].Attempts, msgs[1].Attempts})
+
+	assert.Nil(t, s.Ack(ctx, "m2"))
+	assert.Equal(t, []string{"m3"}, ids(s.Pending()))
+}
@@ -0,0 +1,102 @@
+package outbox
+
+import (
+	"context"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/tuingking/supersvc/pkg/mysql"
+)
+
+const (
+	// multi rows insert is insertMessageQuery followed by comma separated insertMessageValues
+	insertMessageQuery  = "INSERT INTO outbox(id, message_type, message_key, payload, created_at, next_attempt_at) VALUES "
+	insertMessageValues = "(?, ?, ?, ?, ?, ?)"
+
+	// claim the oldest message of each key, locked row is skipped so multiple relay don't pick the same message
+	claimMessageQuery = "SELECT o.seq, o.id, o.message_type, o.message_key, o.payload, o.created_at, o.attempts FROM outbox o " +
+		"WHERE o.next_attempt_at <= ? AND NOT EXISTS (SELECT 1 FROM outbox p WHERE p.message_key = o.message_key AND p.seq < o.seq) " +
+		"ORDER BY o.seq LIMIT ? FOR UPDATE SKIP LOCKED"
+	leaseMessageQuery = "UPDATE outbox SET attempts = attempts + 1, next_attempt_at = ? WHERE seq IN (?)"
+	ackMessageQuery   = "DELETE FROM outbox WHERE id = ?"
+	retryMessageQuery = "UPDATE outbox SET next_attempt_at = ?, last_error = ? WHERE id = ?"
+
+	// maxErrorLength follow varchar(1000) column of outbox table
+	maxErrorLength = 1000
+)
+
+// mysqlStore keep message in outbox table, see scripts/migration
+type mysqlStore struct {
+	db mysql.MySQL
+}
+
+func NewMySQLStore(db mysql.MySQL) Store {
+	return &mysqlStore{db: db}
+}
+
+func (s *mysqlStore) Claim(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]Message, error) {
+	tx, err := s.db.Get().BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, claimMessageQuery, now, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var (
+		msgs []Message
+		seqs []int64
+	)
+	for rows.Next() {
+		var (
+			m   Message
+			seq int64
+		)
+		if err := rows.Scan(&seq, &m.ID, &m.Type, &m.Key, &m.Payload, &m.CreatedAt, &m.Attempts); err != nil {
+			return nil, err
+		}
+		m.Attempts++
+		msgs = append(msgs, m)
+		seqs = append(seqs, seq)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	if len(msgs) == 0 {
+		return nil, nil
+	}
+
+	query, args, err := sqlx.In(leaseMessageQuery, now.Add(lease), seqs)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := tx.ExecContext(ctx, query, args...); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return msgs, nil
+}
+
+func (s *mysqlStore) Ack(ctx context.Context, id string) error {
+	_, err := s.db.Get().ExecContext(ctx, ackMessageQuery, id)
+	return err
+}
+
+func (s *mysqlStore) Retry(ctx context.Context, id string, at time.Time, cause error) error {
+	msg := cause.Error()
+	if len(msg) > maxErrorLength {
+		msg = msg[:maxErrorLength]
+	}
+	_, err := s.db.Get().ExecContext(ctx, retryMessageQuery, at, msg, id)
+	return err
+}
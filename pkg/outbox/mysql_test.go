@@ -0,0 +1,111 @@
+package outbox
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+type mockDB struct {
+	db *sql.DB
+}
+
+func (m mockDB) Stop() error {
+	return m.db.Close()
+}
+
+func (m mockDB) Get() *sql.DB {
+	return m.db
+}
+
+func newMockStore(t *testing.T) (*mysqlStore, *sql.DB, sqlmock.Sqlmock) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New: %s", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	return &mysqlStore{db: mockDB{db: db}}, db, mock
+}
+
+func Test_Insert(t *testing.T) {
+	now := time.Date(2024, 01, 01, 0, 0, 0, 0, time.UTC)
+
+	_, db, mock := newMockStore(t)
+	mock.ExpectExec(regexp.QuoteMeta(insertMessageQuery+insertMessageValues+", "+insertMessageValues)+"$").
+		WithArgs("m1", "UserCreated", "u1", []byte(`{}`), now, now, "m2", "UserDeleted", "u1", []byte(`{}`), now, now).
+		WillReturnResult(sqlmock.NewResult(2, 2))
+
+	err := Insert(context.Background(), db,
+		Message{ID: "m1", Type: "UserCreated", Key: "u1", Payload: []byte(`{}`), CreatedAt: now},
+		Message{ID: "m2", Type: "UserDeleted", Key: "u1", Payload: []byte(`{}`), CreatedAt: now},
+	)
+	assert.Nil(t, err)
+	assert.Nil(t, mock.ExpectationsWereMet())
+
+	// nothing to insert
+	assert.Nil(t, Insert(context.Background(), db))
+}
+
+func Test_MySQLStore_Claim(t *testing.T) {
+	now := time.Date(2024, 01, 01, 0, 0, 0, 0, time.UTC)
+	columns := []string{"seq", "id", "message_type", "message_key", "payload", "created_at", "attempts"}
+
+	t.Run("claim and lease", func(t *testing.T) {
+		s, _, mock := newMockStore(t)
+		mock.ExpectBegin()
+		mock.ExpectQuery(regexp.QuoteMeta(claimMessageQuery)).
+			WithArgs(now, 10).
+			WillReturnRows(sqlmock.NewRows(columns).
+				AddRow(1, "m1", "UserCreated", "u1", []byte(`{"id":"u1"}`), now, 0).
+				AddRow(3, "m3", "UserCreated", "u2", []byte(`{"id":"u2"}`), now, 2))
+		mock.ExpectExec(regexp.QuoteMeta(strings.Replace(leaseMessageQuery, "(?)", "(?, ?)", 1))).
+			WithArgs(now.Add(time.Minute), 1, 3).
+			WillReturnResult(sqlmock.NewResult(0, 2))
+		mock.ExpectCommit()
+
+		msgs, err := s.Claim(context.Background(), now, time.Minute, 10)
+		assert.Nil(t, err)
+		assert.Equal(t, []Message{
+			{ID: "m1", Type: "UserCreated", Key: "u1", Payload: []byte(`{"id":"u1"}`), CreatedAt: now, Attempts: 1},
+			{ID: "m3", Type: "UserCreated", Key: "u2", Payload: []byte(`{"id":"u2"}`), CreatedAt: now, Attempts: 3},
+		}, msgs)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("nothing due", func(t *testing.T) {
+		s, _, mock := newMockStore(t)
+		mock.ExpectBegin()
+		mock.ExpectQuery(regexp.QuoteMeta(claimMessageQuery)).
+			WillReturnRows(sqlmock.NewRows(columns))
+		mock.ExpectRollback()
+
+		msgs, err := s.Claim(context.Background(), now, time.Minute, 10)
+		assert.Nil(t, err)
+		assert.Empty(t, msgs)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+}
+
+func Test_MySQLStore_AckAndRetry(t *testing.T) {
+	now := time.Date(2024, 01, 01, 0, 0, 0, 0, time.UTC)
+
+	s, _, mock := newMockStore(t)
+	mock.ExpectExec(regexp.QuoteMeta(ackMessageQuery)).
+		WithArgs("m1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta(retryMessageQuery)).
+		WithArgs(now, strings.Repeat("x", maxErrorLength), "m2").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	assert.Nil(t, s.Ack(context.Background(), "m1"))
+	assert.Nil(t, s.Retry(context.Background(), "m2", now, errors.New(strings.Repeat("x", maxErrorLength+1))))
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
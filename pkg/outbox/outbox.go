@@ -0,0 +1,80 @@
+package outbox
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+type Option struct {
+	Relay   RelayOption
+	Webhook WebhookOption
+}
+
+// Message is event stored in the outbox until it is published, payload is JSON.
+// message with the same key is published in the order it is inserted.
+type Message struct {
+	ID        string          `json:"id"`
+	Type      string          `json:"type"`
+	Key       string          `json:"key"`
+	Payload   json.RawMessage `json:"payload"`
+	CreatedAt time.Time       `json:"created_at"`
+
+	// Attempts is number of publish attempt including the current one
+	Attempts int `json:"-"`
+}
+
+// Publisher deliver message to the consumer.
+// delivery is at least once, consumer should deduplicate by Message.ID.
+type Publisher interface {
+	Publish(ctx context.Context, msg Message) error
+}
+
+// PublisherFunc adapt function as Publisher
+type PublisherFunc func(ctx context.Context, msg Message) error
+
+func (f PublisherFunc) Publish(ctx context.Context, msg Message) error {
+	return f(ctx, msg)
+}
+
+// Store keep unpublished message for the relay
+type Store interface {
+	// Claim return at most limit message due at now, claimed message is not due again until now + lease.
+	// only the oldest unpublished message of each key is due, so message of the same key is published in order.
+	Claim(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]Message, error)
+
+	// Ack remove published message
+	Ack(ctx context.Context, id string) error
+
+	// Retry schedule the next publish attempt of the message at the given time
+	Retry(ctx context.Context, id string, at time.Time, cause error) error
+}
+
+// Execer is implemented by *sql.DB and *sql.Tx
+type Execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+// Insert store messages in outbox table using exec.
+// exec should be the transaction of the mutation, so the message is stored only when the mutation is committed.
+func Insert(ctx context.Context, exec Execer, msgs ...Message) error {
+	if len(msgs) == 0 {
+		return nil
+	}
+
+	var query strings.Builder
+	query.WriteString(insertMessageQuery)
+	args := make([]interface{}, 0, len(msgs)*6)
+	for i, m := range msgs {
+		if i > 0 {
+			query.WriteString(", ")
+		}
+		query.WriteString(insertMessageValues)
+		args = append(args, m.ID, m.Type, m.Key, m.Payload, m.CreatedAt, m.CreatedAt)
+	}
+
+	_, err := exec.ExecContext(ctx, query.String(), args...)
+	return err
+}
@@ -0,0 +1,118 @@
+package outbox
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	// HeaderMessageID is set to Message.ID on webhook request, consumer should deduplicate by it
+	HeaderMessageID = "X-Outbox-Message-Id"
+
+	// HeaderSignature is hex encoded HMAC-SHA256 of the body, set when WebhookOption.Secret is set
+	HeaderSignature = "X-Outbox-Signature"
+
+	defaultWebhookTimeout = 10 * time.Second
+)
+
+// MemoryPublisher keep published message in memory, it is meant for test and local development
+type MemoryPublisher struct {
+	mu   sync.Mutex
+	msgs []Message
+}
+
+func NewMemoryPublisher() *MemoryPublisher {
+	return &MemoryPublisher{}
+}
+
+func (p *MemoryPublisher) Publish(ctx context.Context, msg Message) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.msgs = append(p.msgs, msg)
+	return nil
+}
+
+// Messages return published message in publish order
+func (p *MemoryPublisher) Messages() []Message {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return append([]Message(nil), p.msgs...)
+}
+
+type WebhookOption struct {
+	// URL receive message as JSON POST request, publishing is disabled when empty
+	URL string
+
+	// Secret sign the body, see HeaderSignature. optional
+	Secret string
+
+	// Timeout of single request, default to 10 seconds
+	Timeout time.Duration
+}
+
+func (o WebhookOption) timeout() time.Duration {
+	if o.Timeout <= 0 {
+		return defaultWebhookTimeout
+	}
+	return o.Timeout
+}
+
+type webhookPublisher struct {
+	opt    WebhookOption
+	client *http.Client
+}
+
+// NewWebhookPublisher POST message to WebhookOption.URL, non 2xx response is treated as failure
+func NewWebhookPublisher(opt WebhookOption) Publisher {
+	return &webhookPublisher{
+		opt:    opt,
+		client: &http.Client{Timeout: opt.timeout()},
+	}
+}
+
+func (p *webhookPublisher) Publish(ctx context.Context, msg Message) error {
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.opt.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderMessageID, msg.ID)
+	if p.opt.Secret != "" {
+		req.Header.Set(HeaderSignature, Sign(p.opt.Secret, body))
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook: unexpected status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// Sign return hex encoded HMAC-SHA256 of body, consumer use it to verify HeaderSignature
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,51 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_WebhookPublisher(t *testing.T) {
+	now := time.Date(2024, 01, 01, 0, 0, 0, 0, time.UTC)
+	msg := Message{ID: "m1", Type: "UserCreated", Key: "u1", Payload: []byte(`{"id":"u1"}`), CreatedAt: now, Attempts: 2}
+
+	var (
+		header http.Header
+		body   []byte
+		status = http.StatusNoContent
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header
+		body, _ = io.ReadAll(r.Body)
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(srv.Close)
+
+	pub := NewWebhookPublisher(WebhookOption{URL: srv.URL, Secret: "s3cret"})
+
+	assert.Nil(t, pub.Publish(context.Background(), msg))
+	assert.Equal(t, "application/json", header.Get("Content-Type"))
+	assert.Equal(t, "m1", header.Get(HeaderMessageID))
+	assert.Equal(t, Sign("s3cret", body), header.Get(HeaderSignature))
+
+	var got Message
+	assert.Nil(t, json.Unmarshal(body, &got))
+	assert.Equal(t, Message{ID: "m1", Type: "UserCreated", Key: "u1", Payload: []byte(`{"id":"u1"}`), CreatedAt: now}, got)
+
+	status = http.StatusServiceUnavailable
+	assert.EqualError(t, pub.Publish(context.Background(), msg), "webhook: unexpected status 503")
+}
+
+func Test_MemoryPublisher(t *testing.T) {
+	pub := NewMemoryPublisher()
+	assert.Nil(t, pub.Publish(context.Background(), Message{ID: "m1"}))
+	assert.Nil(t, pub.Publish(context.Background(), Message{ID: "m2"}))
+	assert.Equal(t, []Message{{ID: "m1"}, {ID: "m2"}}, pub.Messages())
+}
@@ -0,0 +1,162 @@
+package outbox
+
+import (
+	"context"
+	"time"
+
+	"github.com/tuingking/supersvc/pkg/logger"
+)
+
+const (
+	defaultRelayInterval  = time.Second
+	defaultRelayBatchSize = 100
+	defaultLease          = time.Minute
+	defaultMinBackoff     = time.Second
+	defaultMaxBackoff     = 10 * time.Minute
+)
+
+// RelayOption configure background job which publish outbox message
+type RelayOption struct {
+	// Interval between poll, default to 1 second
+	Interval time.Duration
+
+	// BatchSize is max message claimed at once, default to 100
+	BatchSize int
+
+	// Lease is how long claimed message is hidden from other relay, it should exceed publish timeout.
+	// message of crashed relay is published again after the lease, default to 1 minute
+	Lease time.Duration
+
+	// MinBackoff and MaxBackoff bound the exponential delay between retry, default to 1 second and 10 minutes
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+}
+
+func (o RelayOption) interval() time.Duration {
+	if o.Interval <= 0 {
+		return defaultRelayInterval
+	}
+	return o.Interval
+}
+
+func (o RelayOption) batchSize() int {
+	if o.BatchSize <= 0 {
+		return defaultRelayBatchSize
+	}
+	return o.BatchSize
+}
+
+func (o RelayOption) lease() time.Duration {
+	if o.Lease <= 0 {
+		return defaultLease
+	}
+	return o.Lease
+}
+
+// backoff return delay before the next attempt, doubled on every failed attempt
+func (o RelayOption) backoff(attempts int) time.Duration {
+	minDelay, maxDelay := o.MinBackoff, o.MaxBackoff
+	if minDelay <= 0 {
+		minDelay = defaultMinBackoff
+	}
+	if maxDelay <= 0 {
+		maxDelay = defaultMaxBackoff
+	}
+
+	d := minDelay
+	for i := 1; i < attempts && d < maxDelay; i++ {
+		d *= 2
+	}
+	if d > maxDelay {
+		return maxDelay
+	}
+	return d
+}
+
+// Relay periodically publish outbox message, failed message is retried until it is published.
+// message is claimed before published, so it is safe to run relay in every replica.
+type Relay interface {
+	// Run block until ctx is canceled
+	Run(ctx context.Context)
+}
+
+type relay struct {
+	opt   RelayOption
+	store Store
+	pub   Publisher
+	now   func() time.Time
+}
+
+func NewRelay(opt RelayOption, store Store, pub Publisher) Relay {
+	return &relay{
+		opt:   opt,
+		store: store,
+		pub:   pub,
+		now:   time.Now,
+	}
+}
+
+func (r *relay) Run(ctx context.Context) {
+	log := logger.Get(ctx)
+
+	ticker := time.NewTicker(r.opt.interval())
+	defer ticker.Stop()
+
+	log.Info().Dur("interval", r.opt.interval()).Msg("outbox relay started")
+	for {
+		select {
+		case <-ctx.Done():
+			log.Info().Msg("outbox relay stopped")
+			return
+		case <-ticker.C:
+			r.drain(ctx)
+		}
+	}
+}
+
+// drain publish due message batch by batch until no full batch is left
+func (r *relay) drain(ctx context.Context) {
+	log := logger.Get(ctx)
+
+	for ctx.Err() == nil {
+		msgs, err := r.store.Claim(ctx, r.now(), r.opt.lease(), r.opt.batchSize())
+		if err != nil {
+			log.Err(err).Msg("failed: claim outbox message")
+			return
+		}
+
+		// claimed message has distinct key, so the order doesn't matter
+		for _, msg := range msgs {
+			r.publish(ctx, msg)
+		}
+
+		if len(msgs) < r.opt.batchSize() {
+			return
+		}
+	}
+}
+
+func (r *relay) publish(ctx context.Context, msg Message) {
+	log := logger.Get(ctx).With().
+		Str("message_id", msg.ID).
+		Str("message_type", msg.Type).
+		Str("message_key", msg.Key).
+		Int("attempts", msg.Attempts).
+		Logger()
+
+	if err := r.pub.Publish(ctx, msg); err != nil {
+		at := r.now().Add(r.opt.backoff(msg.Attempts))
+		log.Err(err).Time("retry_at", at).Msg("failed: publish outbox message")
+
+		// message is published again after the lease when retry is not stored
+		if err := r.store.Retry(ctx, msg.ID, at, err); err != nil {
+			log.Err(err).Msg("failed: retry outbox message")
+		}
+		return
+	}
+
+	// message is published again after the lease when ack is not stored
+	if err := r.store.Ack(ctx, msg.ID); err != nil {
+		log.Err(err).Msg("failed: ack outbox message")
+	}
+}
@@ -0,0 +1,62 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_RelayOption_Backoff(t *testing.T) {
+	opt := RelayOption{MinBackoff: time.Second, MaxBackoff: 10 * time.Second}
+
+	assert.Equal(t, time.Second, opt.backoff(1))
+	assert.Equal(t, 2*time.Second, opt.backoff(2))
+	assert.Equal(t, 8*time.Second, opt.backoff(4))
+	assert.Equal(t, 10*time.Second, opt.backoff(5))
+	assert.Equal(t, 10*time.Second, opt.backoff(100))
+
+	assert.Equal(t, defaultMinBackoff, RelayOption{}.backoff(1))
+}
+
+func Test_Relay_Drain(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2024, 01, 01, 0, 0, 0, 0, time.UTC)
+
+	store := NewMemoryStore()
+	store.Add(
+		Message{ID: "m1", Key: "u1", CreatedAt: now},
+		Message{ID: "m2", Key: "u1", CreatedAt: now},
+		Message{ID: "m3", Key: "u2", CreatedAt: now},
+		Message{ID: "m4", Key: "u3", CreatedAt: now},
+	)
+
+	var published []string
+	fail := map[string]int{"m1": 1} // number of failure before success
+	pub := PublisherFunc(func(ctx context.Context, msg Message) error {
+		if fail[msg.ID] > 0 {
+			fail[msg.ID]--
+			return errors.New("unavailable")
+		}
+		published = append(published, msg.ID)
+		return nil
+	})
+
+	r := NewRelay(RelayOption{BatchSize: 2, MinBackoff: time.Second}, store, pub).(*relay)
+	r.now = func() time.Time { return now }
+
+	// m1 failed, m2 wait for m1
+	r.drain(ctx)
+	assert.Equal(t, []string{"m3", "m4"}, published)
+
+	// retried after backoff
+	now = now.Add(time.Second)
+	r.drain(ctx)
+	assert.Equal(t, []string{"m3", "m4", "m1"}, published)
+
+	r.drain(ctx)
+	assert.Equal(t, []string{"m3", "m4", "m1", "m2"}, published)
+	assert.Empty(t, store.Pending())
+}
@@ -0,0 +1,253 @@
+package parser
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+
+	"github.com/go-chi/chi/v5"
+)
+
+const defaultMaxBodySize int64 = 1 << 20 // 1 MB
+
+var (
+	ErrBodyTooLarge = errors.New("parser: request body too large")
+
+	// shared binder used by Bind
+	defaultBinder = NewBinder(BinderOption{}, InitParamParser())
+)
+
+// Validator is implemented by request struct that need validation after bind.
+type Validator interface {
+	Validate() error
+}
+
+// BindError is returned by Bind when request cannot be bound to the destination.
+// Source is one of: body, query, header, path, validate
+type BindError struct {
+	Source string
+	Err    error
+}
+
+func (e *BindError) Error() string {
+	return fmt.Sprintf("parser: bind %s: %s", e.Source, e.Err)
+}
+
+func (e *BindError) Unwrap() error {
+	return e.Err
+}
+
+type Binder interface {
+	Bind(r *http.Request, dest interface{}) error
+}
+
+type binder struct {
+	opt    BinderOption
+	query  ParamParser
+	header ParamParser
+	path   ParamParser
+}
+
+type BinderOption struct {
+	// MaxBodySize is the maximum JSON body in bytes, default to 1 MB
+	MaxBodySize int64
+}
+
+// NewBinder create binder, query param is decoded by p.
+// Header and path are decoded with the same time option as p.
+func NewBinder(opt BinderOption, p ParamParser) Binder {
+	if opt.MaxBodySize <= 0 {
+		opt.MaxBodySize = defaultMaxBodySize
+	}
+
+	// header & path share time option with query parser
+	headerOpt, pathOpt := defaultOption(), defaultOption()
+	if pp, ok := p.(*paramparser); ok {
+		headerOpt, pathOpt = pp.opt, pp.opt
+	}
+	headerOpt.aliasTag, pathOpt.aliasTag = "header", "path"
+
+	b := &binder{
+		opt:    opt,
+		query:  p,
+		header: newParamParser(headerOpt),
+		path:   newParamParser(pathOpt),
+	}
+
+	return b
+}
+
+// Bind fill dest from the request using the shared binder, see Binder.Bind
+func Bind(r *http.Request, dest interface{}) error {
+	return defaultBinder.Bind(r, dest)
+}
+
+// Bind fill dest fields in the following order, latter source override the former:
+//
+//	json:"name"   JSON body
+//	param:"name"  query string
+//	header:"Name" request header
+//	path:"name"   chi URL param
+//
+// Time value without zone offset is interpreted in X-Timezone header location.
+// dest is validated at the end when it implement Validator.
+func (b *binder) Bind(r *http.Request, dest interface{}) error {
+	v := reflect.ValueOf(dest)
+	if v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return errors.New("parser: bind destination should be a non nil pointer to struct")
+	}
+
+	loc, err := LocationFromHeader(r.Header)
+	if err != nil {
+		return &BindError{Source: "header", Err: err}
+	}
+
+	if err := b.bindBody(r, dest); err != nil {
+		return &BindError{Source: "body", Err: err}
+	}
+
+	src, err := queryValues(r, v.Elem().Type())
+	if err != nil {
+		return &BindError{Source: "query", Err: err}
+	}
+	if len(src) > 0 {
+		if err := b.query.In(loc).Decode(dest, src); err != nil {
+			return &BindError{Source: "query", Err: err}
+		}
+	}
+
+	if src := headerValues(r, v.Elem().Type()); len(src) > 0 {
+		if err := b.header.In(loc).Decode(dest, src); err != nil {
+			return &BindError{Source: "header", Err: err}
+		}
+	}
+
+	if src := pathValues(r, v.Elem().Type()); len(src) > 0 {
+		if err := b.path.In(loc).Decode(dest, src); err != nil {
+			return &BindError{Source: "path", Err: err}
+		}
+	}
+
+	if vd, ok := dest.(Validator); ok {
+		if err := vd.Validate(); err != nil {
+			return &BindError{Source: "validate", Err: err}
+		}
+	}
+
+	return nil
+}
+
+func (b *binder) bindBody(r *http.Request, dest interface{}) error {
+	if r.Body == nil || r.Body == http.NoBody {
+		return nil
+	}
+
+	body := http.MaxBytesReader(nil, r.Body, b.opt.MaxBodySize)
+	defer body.Close()
+
+	// skip empty body
+	var peek [1]byte
+	n, err := body.Read(peek[:])
+	if n == 0 {
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		return bodyError(err)
+	}
+
+	dec := json.NewDecoder(io.MultiReader(bytes.NewReader(peek[:n]), body))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(dest); err != nil {
+		return bodyError(err)
+	}
+
+	if err := dec.Decode(&struct{}{}); !errors.Is(err, io.EOF) {
+		if err != nil {
+			return bodyError(err)
+		}
+		return errors.New("body must only contain a single JSON value")
+	}
+
+	return nil
+}
+
+func bodyError(err error) error {
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		return ErrBodyTooLarge
+	}
+	return err
+}
+
+// queryValues collect query string declared by param:"..." tag, JSON:API style filter is expanded first.
+// field without the tag is never bound from query, otherwise the decoder fall back to the field name
+// and query could override body or header field.
+func queryValues(r *http.Request, t reflect.Type) (map[string][]string, error) {
+	query, err := expandFilter(r.URL.Query())
+	if err != nil {
+		return nil, err
+	}
+
+	src := make(map[string][]string)
+	for _, name := range tagNames(t, "param") {
+		if values, ok := query[name]; ok {
+			src[name] = values
+		}
+	}
+	return src, nil
+}
+
+// headerValues collect request header declared by header:"..." tag
+func headerValues(r *http.Request, t reflect.Type) map[string][]string {
+	src := make(map[string][]string)
+	for _, name := range tagNames(t, "header") {
+		if values := r.Header.Values(name); len(values) > 0 {
+			src[name] = values
+		}
+	}
+	return src
+}
+
+// pathValues collect chi URL param declared by path:"..." tag
+func pathValues(r *http.Request, t reflect.Type) map[string][]string {
+	src := make(map[string][]string)
+	rctx := chi.RouteContext(r.Context())
+	if rctx == nil {
+		return src
+	}
+
+	for _, name := range tagNames(t, "path") {
+		for i, key := range rctx.URLParams.Keys {
+			if key == name {
+				src[name] = []string{rctx.URLParams.Values[i]}
+			}
+		}
+	}
+	return src
+}
+
+// tagNames return value of the given tag, including from embedded struct
+func tagNames(t reflect.Type, tag string) []string {
+	var names []string
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+
+		ft := field.Type
+		if ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+		if field.Anonymous && ft.Kind() == reflect.Struct {
+			names = append(names, tagNames(ft, tag)...)
+			continue
+		}
+
+		if name := field.Tag.Get(tag); name != "" && name != "-" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
@@ -0,0 +1,86 @@
+package parser
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+const filterKeyPrefix = "filter["
+
+// regexFilterKey match JSON:API style filter key
+//
+// e.g: filter[email] or filter[created_at][gte]
+var regexFilterKey = regexp.MustCompile(`^filter\[([A-Za-z0-9_.]+)\](?:\[([A-Za-z]+)\])?$`)
+
+// filterOperators map bracket operator to the param suffix understood by qbuilder.
+// operator with multi value (in, nin) accept comma separated value.
+var filterOperators = map[string]string{
+	"eq":  "",
+	"neq": "__neq",
+	"gt":  "__gt",
+	"gte": "__gte",
+	"lt":  "__lt",
+	"lte": "__lte",
+	"in":  "__in",
+	"nin": "__nin",
+}
+
+// expandFilter translate bracket notation filter into param key.
+// Other keys are copied as is.
+//
+// e.g:
+//
+//	filter[email][eq]=foo@bar.com  -> email=foo@bar.com
+//	filter[created_at][gte]=2024-01-01 -> created_at__gte=2024-01-01
+//	filter[status][in]=1,2 -> status__in=1&status__in=2
+func expandFilter(src map[string][]string) (map[string][]string, error) {
+	hasFilter := false
+	for key := range src {
+		if strings.HasPrefix(key, filterKeyPrefix) {
+			hasFilter = true
+			break
+		}
+	}
+	if !hasFilter {
+		return src, nil
+	}
+
+	dest := make(map[string][]string, len(src))
+	for key, values := range src {
+		if !strings.HasPrefix(key, filterKeyPrefix) {
+			dest[key] = append(dest[key], values...)
+			continue
+		}
+
+		match := regexFilterKey.FindStringSubmatch(key)
+		if match == nil {
+			return nil, fmt.Errorf("parser: invalid filter key %q", key)
+		}
+
+		field, op := match[1], strings.ToLower(match[2])
+		if op == "" {
+			op = "eq"
+		}
+
+		suffix, ok := filterOperators[op]
+		if !ok {
+			return nil, fmt.Errorf("parser: unsupported filter operator %q on %q", op, field)
+		}
+
+		param := field + suffix
+		if op == "in" || op == "nin" {
+			for _, v := range values {
+				for _, s := range strings.Split(v, ",") {
+					if s = strings.TrimSpace(s); s != "" {
+						dest[param] = append(dest[param], s)
+					}
+				}
+			}
+			continue
+		}
+		dest[param] = append(dest[param], values...)
+	}
+
+	return dest, nil
+}
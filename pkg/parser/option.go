@@ -0,0 +1,58 @@
+package parser
+
+import "time"
+
+// DefaultTimeFormats is list of layout tried in order when decoding time value.
+var DefaultTimeFormats = []string{time.RFC3339, `2006-01-02`, `2006-01-02 15:04:05`, `2006-01-02T15:04:05`, `2006-01-02T15:04:05.000Z`,
+	`2006-01-02 15:04:05.000Z`, `2006-01-02 15:04:05-07:00`, `2006-01-02T15:04:05-07:00`, `2006-01-02 15:04:05 -07:00 MST`,
+	`2006-01-02T15:04:05 -07:00 MST`, `2006-01-02T15:04:05 -07:00MST`, `2006-01-02 15:04:05 -07:00MST`}
+
+type option struct {
+	// struct tag used as key, default to "param"
+	aliasTag string
+
+	// location used when time value has no zone offset, default to UTC
+	location *time.Location
+
+	// time layouts, see DefaultTimeFormats
+	timeFormats []string
+
+	// accept unix timestamp (seconds or milliseconds) as time value
+	unixTime bool
+}
+
+func defaultOption() option {
+	return option{
+		aliasTag:    "param",
+		location:    time.UTC,
+		timeFormats: DefaultTimeFormats,
+		unixTime:    true,
+	}
+}
+
+type Option func(*option)
+
+// WithLocation set default location for time value without zone offset.
+func WithLocation(loc *time.Location) Option {
+	return func(o *option) {
+		if loc != nil {
+			o.location = loc
+		}
+	}
+}
+
+// WithTimeFormats replace the layouts used to decode time value.
+func WithTimeFormats(formats ...string) Option {
+	return func(o *option) {
+		if len(formats) > 0 {
+			o.timeFormats = formats
+		}
+	}
+}
+
+// WithoutUnixTime disable decoding unix timestamp as time value.
+func WithoutUnixTime() Option {
+	return func(o *option) {
+		o.unixTime = false
+	}
+}
@@ -3,18 +3,44 @@ package parser
 import (
 	"database/sql"
 	"reflect"
+	"strconv"
 	"time"
 )
 
 func (p *paramparser) InitDecoder() {
 	nullString, nullBool, nullInt64, nullFloat64, nullTime := sql.NullString{}, sql.NullBool{}, sql.NullInt64{}, sql.NullFloat64{}, sql.NullTime{}
+	nullInt32, nullInt16, nullByte := sql.NullInt32{}, sql.NullInt16{}, sql.NullByte{}
 	p.decoder.RegisterConverter(nullString, convertsqlNullString)
 	p.decoder.RegisterConverter(nullBool, convertsqlNullBool)
 	p.decoder.RegisterConverter(nullInt64, convertsqlNullInt64)
+	p.decoder.RegisterConverter(nullInt32, convertsqlNullInt32)
+	p.decoder.RegisterConverter(nullInt16, convertsqlNullInt16)
+	p.decoder.RegisterConverter(nullByte, convertsqlNullByte)
 	p.decoder.RegisterConverter(nullFloat64, convertsqlNullFloat64)
 	p.decoder.RegisterConverter(nullTime, p.convertsqlNullTime)
+	p.decoder.RegisterConverter(time.Time{}, p.convertTime)
+
+	// generic sql.Null[T]
+	p.decoder.RegisterConverter(sql.Null[string]{}, convertsqlNull[string])
+	p.decoder.RegisterConverter(sql.Null[bool]{}, convertsqlNull[bool])
+	p.decoder.RegisterConverter(sql.Null[int]{}, convertsqlNull[int])
+	p.decoder.RegisterConverter(sql.Null[int8]{}, convertsqlNull[int8])
+	p.decoder.RegisterConverter(sql.Null[int16]{}, convertsqlNull[int16])
+	p.decoder.RegisterConverter(sql.Null[int32]{}, convertsqlNull[int32])
+	p.decoder.RegisterConverter(sql.Null[int64]{}, convertsqlNull[int64])
+	p.decoder.RegisterConverter(sql.Null[uint]{}, convertsqlNull[uint])
+	p.decoder.RegisterConverter(sql.Null[uint8]{}, convertsqlNull[uint8])
+	p.decoder.RegisterConverter(sql.Null[uint16]{}, convertsqlNull[uint16])
+	p.decoder.RegisterConverter(sql.Null[uint32]{}, convertsqlNull[uint32])
+	p.decoder.RegisterConverter(sql.Null[uint64]{}, convertsqlNull[uint64])
+	p.decoder.RegisterConverter(sql.Null[float32]{}, convertsqlNull[float32])
+	p.decoder.RegisterConverter(sql.Null[float64]{}, convertsqlNull[float64])
+	p.decoder.RegisterConverter(sql.Null[time.Time]{}, p.convertsqlNullGenericTime)
 }
 
+// NOTE: empty value is decoded as null (Valid=false), except for string.
+// empty string is a valid string, e.g: ?name= is decoded as sql.NullString{Valid: true, String: ""}
+
 func convertsqlNullString(value string) reflect.Value {
 	v := sql.NullString{}
 	if err := v.Scan(value); err != nil {
@@ -26,6 +52,9 @@ func convertsqlNullString(value string) reflect.Value {
 
 func convertsqlNullBool(value string) reflect.Value {
 	v := sql.NullBool{}
+	if value == "" {
+		return reflect.ValueOf(v)
+	}
 	if err := v.Scan(value); err != nil {
 		return reflect.Value{}
 	}
@@ -35,6 +64,45 @@ func convertsqlNullBool(value string) reflect.Value {
 
 func convertsqlNullInt64(value string) reflect.Value {
 	v := sql.NullInt64{}
+	if value == "" {
+		return reflect.ValueOf(v)
+	}
+	if err := v.Scan(value); err != nil {
+		return reflect.Value{}
+	}
+
+	return reflect.ValueOf(v)
+}
+
+func convertsqlNullInt32(value string) reflect.Value {
+	v := sql.NullInt32{}
+	if value == "" {
+		return reflect.ValueOf(v)
+	}
+	if err := v.Scan(value); err != nil {
+		return reflect.Value{}
+	}
+
+	return reflect.ValueOf(v)
+}
+
+func convertsqlNullInt16(value string) reflect.Value {
+	v := sql.NullInt16{}
+	if value == "" {
+		return reflect.ValueOf(v)
+	}
+	if err := v.Scan(value); err != nil {
+		return reflect.Value{}
+	}
+
+	return reflect.ValueOf(v)
+}
+
+func convertsqlNullByte(value string) reflect.Value {
+	v := sql.NullByte{}
+	if value == "" {
+		return reflect.ValueOf(v)
+	}
 	if err := v.Scan(value); err != nil {
 		return reflect.Value{}
 	}
@@ -44,6 +112,9 @@ func convertsqlNullInt64(value string) reflect.Value {
 
 func convertsqlNullFloat64(value string) reflect.Value {
 	v := sql.NullFloat64{}
+	if value == "" {
+		return reflect.ValueOf(v)
+	}
 	if err := v.Scan(value); err != nil {
 		return reflect.Value{}
 	}
@@ -52,16 +123,21 @@ func convertsqlNullFloat64(value string) reflect.Value {
 }
 
 func (p *paramparser) convertsqlNullTime(value string) reflect.Value {
-	// handle multi time format
 	v := sql.NullTime{}
-	formatToCheck := []string{time.RFC3339, `2006-01-02`, `2006-01-02 15:04:05`, `2006-01-02T15:04:05`, `2006-01-02T15:04:05.000Z`,
-		`2006-01-02 15:04:05.000Z`, `2006-01-02 15:04:05-07:00`, `2006-01-02T15:04:05-07:00`, `2006-01-02 15:04:05 -07:00 MST`,
-		`2006-01-02T15:04:05 -07:00 MST`, `2006-01-02T15:04:05 -07:00MST`, `2006-01-02 15:04:05 -07:00MST`}
+	if value == "" {
+		return reflect.ValueOf(v)
+	}
+	if t0, ok := p.parseTime(value); ok {
+		return p.generateTime(v, t0)
+	}
 
-	for _, format := range formatToCheck {
-		if t0, err := time.Parse(format, value); err == nil {
-			return p.generateTime(v, t0)
-		}
+	return reflect.Value{}
+}
+
+// convertTime also used for *time.Time
+func (p *paramparser) convertTime(value string) reflect.Value {
+	if t0, ok := p.parseTime(value); ok {
+		return reflect.ValueOf(t0)
 	}
 
 	return reflect.Value{}
@@ -72,3 +148,53 @@ func (p *paramparser) generateTime(result sql.NullTime, value time.Time) reflect
 	result.Time = value
 	return reflect.ValueOf(result)
 }
+
+// convertsqlNull decode sql.Null[T] where T is basic type supported by sql.Null.Scan
+func convertsqlNull[T any](value string) reflect.Value {
+	v := sql.Null[T]{}
+	if _, isString := any(v.V).(string); value == "" && !isString {
+		return reflect.ValueOf(v)
+	}
+	if err := v.Scan(value); err != nil {
+		return reflect.Value{}
+	}
+
+	return reflect.ValueOf(v)
+}
+
+func (p *paramparser) convertsqlNullGenericTime(value string) reflect.Value {
+	v := sql.Null[time.Time]{}
+	if value == "" {
+		return reflect.ValueOf(v)
+	}
+	if t0, ok := p.parseTime(value); ok {
+		v.V, v.Valid = t0, true
+		return reflect.ValueOf(v)
+	}
+
+	return reflect.Value{}
+}
+
+const unixMilliThreshold = 1e12
+
+// parseTime handle multi time format and unix timestamp.
+// value without zone offset is interpreted in parser location.
+func (p *paramparser) parseTime(value string) (time.Time, bool) {
+	for _, format := range p.opt.timeFormats {
+		if t0, err := time.ParseInLocation(format, value, p.opt.location); err == nil {
+			return t0, true
+		}
+	}
+
+	if p.opt.unixTime {
+		if n, err := strconv.ParseInt(value, 10, 64); err == nil {
+			// 1e12 in seconds is year 33658, assume milliseconds
+			if n >= unixMilliThreshold || n <= -unixMilliThreshold {
+				return time.UnixMilli(n).In(p.opt.location), true
+			}
+			return time.Unix(n, 0).In(p.opt.location), true
+		}
+	}
+
+	return time.Time{}, false
+}
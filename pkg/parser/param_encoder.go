@@ -2,9 +2,10 @@ package parser
 
 import (
 	"database/sql"
-	"fmt"
+	"database/sql/driver"
 	"reflect"
 	"strconv"
+	"strings"
 	"time"
 )
 
@@ -12,9 +13,29 @@ func (p *paramparser) InitEncoder() {
 	p.encoder.RegisterEncoder(sql.NullString{}, encodesqlNullString)
 	p.encoder.RegisterEncoder(sql.NullBool{}, encodesqlNullBool)
 	p.encoder.RegisterEncoder(sql.NullInt64{}, encodesqlNullInt64)
+	p.encoder.RegisterEncoder(sql.NullInt32{}, encodesqlNullInt32)
+	p.encoder.RegisterEncoder(sql.NullInt16{}, encodesqlNullInt16)
+	p.encoder.RegisterEncoder(sql.NullByte{}, encodesqlNullByte)
 	p.encoder.RegisterEncoder(sql.NullFloat64{}, encodesqlNullFloat64)
 	p.encoder.RegisterEncoder(sql.NullTime{}, encodesqlNullTime)
 	p.encoder.RegisterEncoder(time.Time{}, encodeTime)
+
+	// generic sql.Null[T]
+	p.encoder.RegisterEncoder(sql.Null[string]{}, encodesqlNull(func(v string) string { return v }))
+	p.encoder.RegisterEncoder(sql.Null[bool]{}, encodesqlNull(strconv.FormatBool))
+	p.encoder.RegisterEncoder(sql.Null[int]{}, encodesqlNull(formatInt[int]))
+	p.encoder.RegisterEncoder(sql.Null[int8]{}, encodesqlNull(formatInt[int8]))
+	p.encoder.RegisterEncoder(sql.Null[int16]{}, encodesqlNull(formatInt[int16]))
+	p.encoder.RegisterEncoder(sql.Null[int32]{}, encodesqlNull(formatInt[int32]))
+	p.encoder.RegisterEncoder(sql.Null[int64]{}, encodesqlNull(formatInt[int64]))
+	p.encoder.RegisterEncoder(sql.Null[uint]{}, encodesqlNull(formatUint[uint]))
+	p.encoder.RegisterEncoder(sql.Null[uint8]{}, encodesqlNull(formatUint[uint8]))
+	p.encoder.RegisterEncoder(sql.Null[uint16]{}, encodesqlNull(formatUint[uint16]))
+	p.encoder.RegisterEncoder(sql.Null[uint32]{}, encodesqlNull(formatUint[uint32]))
+	p.encoder.RegisterEncoder(sql.Null[uint64]{}, encodesqlNull(formatUint[uint64]))
+	p.encoder.RegisterEncoder(sql.Null[float32]{}, encodesqlNull(func(v float32) string { return strconv.FormatFloat(float64(v), 'g', -1, 32) }))
+	p.encoder.RegisterEncoder(sql.Null[float64]{}, encodesqlNull(func(v float64) string { return strconv.FormatFloat(v, 'g', -1, 64) }))
+	p.encoder.RegisterEncoder(sql.Null[time.Time]{}, encodesqlNull(func(v time.Time) string { return v.Format(time.RFC3339Nano) }))
 }
 
 func encodesqlNullString(v reflect.Value) string {
@@ -47,6 +68,37 @@ func encodesqlNullInt64(v reflect.Value) string {
 	return strconv.FormatInt(nullInt.Int64, 10)
 }
 
+func encodesqlNullInt32(v reflect.Value) string {
+	nullInt, _ := v.Interface().(sql.NullInt32)
+
+	if !nullInt.Valid {
+		return ""
+	}
+
+	return strconv.FormatInt(int64(nullInt.Int32), 10)
+}
+
+func encodesqlNullInt16(v reflect.Value) string {
+	nullInt, _ := v.Interface().(sql.NullInt16)
+
+	if !nullInt.Valid {
+		return ""
+	}
+
+	return strconv.FormatInt(int64(nullInt.Int16), 10)
+}
+
+func encodesqlNullByte(v reflect.Value) string {
+	nullByte, _ := v.Interface().(sql.NullByte)
+
+	if !nullByte.Valid {
+		return ""
+	}
+
+	return strconv.FormatUint(uint64(nullByte.Byte), 10)
+}
+
+// encodesqlNullFloat64 use the shortest representation that decode back to the same value
 func encodesqlNullFloat64(v reflect.Value) string {
 	nullFloat, _ := v.Interface().(sql.NullFloat64)
 
@@ -54,9 +106,10 @@ func encodesqlNullFloat64(v reflect.Value) string {
 		return ""
 	}
 
-	return fmt.Sprintf("%.2f", nullFloat.Float64)
+	return strconv.FormatFloat(nullFloat.Float64, 'g', -1, 64)
 }
 
+// encodesqlNullTime keep fractional second, e.g: 2022-01-01T00:00:00.123456789Z
 func encodesqlNullTime(v reflect.Value) string {
 	nullTime, _ := v.Interface().(sql.NullTime)
 
@@ -64,7 +117,7 @@ func encodesqlNullTime(v reflect.Value) string {
 		return ""
 	}
 
-	return nullTime.Time.Format(time.RFC3339)
+	return nullTime.Time.Format(time.RFC3339Nano)
 }
 
 func encodeTime(v reflect.Value) string {
@@ -74,5 +127,62 @@ func encodeTime(v reflect.Value) string {
 		return ""
 	}
 
-	return currTime.Format(time.RFC3339)
+	return currTime.Format(time.RFC3339Nano)
+}
+
+func encodesqlNull[T any](format func(T) string) func(reflect.Value) string {
+	return func(v reflect.Value) string {
+		null, _ := v.Interface().(sql.Null[T])
+
+		if !null.Valid {
+			return ""
+		}
+
+		return format(null.V)
+	}
+}
+
+func formatInt[T int | int8 | int16 | int32 | int64](v T) string {
+	return strconv.FormatInt(int64(v), 10)
+}
+
+func formatUint[T uint | uint8 | uint16 | uint32 | uint64](v T) string {
+	return strconv.FormatUint(uint64(v), 10)
+}
+
+// nullFields add name of null database/sql field of struct v to null, embedded struct is walked like schema.Encoder does
+func nullFields(v reflect.Value, tag string, null map[string]bool) {
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return
+	}
+
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field, fv := t.Field(i), v.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		name, _, _ := strings.Cut(field.Tag.Get(tag), ",")
+		if name == "" {
+			name = field.Name
+		}
+		if name == "-" {
+			continue
+		}
+
+		if valuer, ok := fv.Interface().(driver.Valuer); ok && field.Type.PkgPath() == "database/sql" {
+			if value, err := valuer.Value(); err == nil && value == nil {
+				null[name] = true
+			}
+			continue
+		}
+		nullFields(fv, tag, null)
+	}
 }
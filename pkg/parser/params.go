@@ -1,42 +1,108 @@
 package parser
 
 import (
+	"reflect"
+	"sync"
+	"time"
+
 	"github.com/gorilla/schema"
 )
 
 type ParamParser interface {
 	Encode(src interface{}, dest map[string][]string) error
 	Decode(dest interface{}, src map[string][]string) error
+
+	// In return parser which interpret time value without zone offset in the given location.
+	// e.g: "2024-01-01" with Asia/Jakarta will be decoded as 2024-01-01T00:00:00+07:00
+	In(loc *time.Location) ParamParser
 }
 
 type paramparser struct {
+	opt     option
 	encoder *schema.Encoder
 	decoder *schema.Decoder
+
+	// parser per location, see In
+	mu       sync.Mutex
+	inParser map[string]*paramparser
 }
 
-func InitParamParser() ParamParser {
+func InitParamParser(opts ...Option) ParamParser {
+	// default option
+	opt := defaultOption()
+
+	// override option
+	for _, fn := range opts {
+		fn(&opt)
+	}
+
+	return newParamParser(opt)
+}
 
+func newParamParser(opt option) *paramparser {
 	p := &paramparser{
-		encoder: schema.NewEncoder(),
-		decoder: schema.NewDecoder(),
+		opt:      opt,
+		encoder:  schema.NewEncoder(),
+		decoder:  schema.NewDecoder(),
+		inParser: make(map[string]*paramparser),
 	}
 
 	p.InitDecoder()
 	p.InitEncoder()
 
-	p.decoder.SetAliasTag("param")
+	p.decoder.SetAliasTag(opt.aliasTag)
 	p.decoder.IgnoreUnknownKeys(true)
 	p.decoder.ZeroEmpty(false)
 
-	p.encoder.SetAliasTag("param")
+	p.encoder.SetAliasTag(opt.aliasTag)
 
 	return p
 }
 
+// Encode skip null sql.NullString, sql.Null[T] and the like, so it is decoded back as null instead of valid zero value
 func (p *paramparser) Encode(src interface{}, dest map[string][]string) error {
-	return p.encoder.Encode(src, dest)
+	encoded := make(map[string][]string)
+	if err := p.encoder.Encode(src, encoded); err != nil {
+		return err
+	}
+
+	null := make(map[string]bool)
+	nullFields(reflect.ValueOf(src), p.opt.aliasTag, null)
+	for name, values := range encoded {
+		if !null[name] {
+			dest[name] = values
+		}
+	}
+	return nil
 }
 
+// Decode also accept JSON:API style filter, e.g: filter[created_at][gte]=2024-01-01
+// will be decoded into field with tag param:"created_at__gte"
 func (p *paramparser) Decode(dest interface{}, src map[string][]string) error {
+	src, err := expandFilter(src)
+	if err != nil {
+		return err
+	}
+
 	return p.decoder.Decode(dest, src)
 }
+
+func (p *paramparser) In(loc *time.Location) ParamParser {
+	if loc == nil || loc.String() == p.opt.location.String() {
+		return p
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if pp, ok := p.inParser[loc.String()]; ok {
+		return pp
+	}
+
+	opt := p.opt
+	opt.location = loc
+	pp := newParamParser(opt)
+	p.inParser[loc.String()] = pp
+
+	return pp
+}
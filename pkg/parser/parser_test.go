@@ -2,10 +2,16 @@ package parser_test
 
 import (
 	"database/sql"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
+	"testing/quick"
 	"time"
 
-	"github.com/tiket/TIX-DEPOSIT-BE/pkg/parser"
+	"github.com/go-chi/chi/v5"
+	"github.com/tuingking/supersvc/pkg/parser"
 	"gotest.tools/assert"
 )
 
@@ -228,13 +234,13 @@ func Test_Encode(t *testing.T) {
 				exp: map[string][]string{
 					"int64":   {"99"},
 					"bool":    {"true"},
-					"float64": {"10.70"},
+					"float64": {"10.7"},
 					"string":  {"active"},
 					"time":    {"2022-01-01T00:00:00Z"},
 				},
 			},
 			{
-				desc: "case: sql valid false",
+				desc: "case: sql valid false is skipped",
 				input: ParamSqlNull{
 					Int64:   sql.NullInt64{Valid: false},
 					Bool:    sql.NullBool{Valid: false},
@@ -242,13 +248,7 @@ func Test_Encode(t *testing.T) {
 					String:  sql.NullString{Valid: false},
 					Time:    sql.NullTime{Valid: false},
 				},
-				exp: map[string][]string{
-					"int64":   {""},
-					"bool":    {""},
-					"float64": {""},
-					"string":  {""},
-					"time":    {""},
-				},
+				exp: map[string][]string{},
 			},
 		}
 
@@ -265,3 +265,470 @@ func Test_Encode(t *testing.T) {
 		}
 	})
 }
+
+type ParamFilter struct {
+	Email        sql.NullString `param:"email"`
+	EmailNEQ     sql.NullString `param:"email__neq"`
+	Status       []int          `param:"status__in"`
+	StatusNIN    []int          `param:"status__nin"`
+	CreatedAtGTE sql.NullTime   `param:"created_at__gte"`
+	CreatedAtLT  sql.NullTime   `param:"created_at__lt"`
+	Page         int64          `param:"page"`
+}
+
+func Test_DecodeFilter(t *testing.T) {
+	testCase := []struct {
+		desc   string
+		param  map[string][]string
+		exp    ParamFilter
+		expErr bool
+	}{
+		{
+			desc: "bracket notation",
+			param: map[string][]string{
+				"filter[email][eq]":       {"foo@bar.com"},
+				"filter[email][neq]":      {"bar@foo.com"},
+				"filter[status][in]":      {"1,2"},
+				"filter[status][nin]":     {"3", "4,5"},
+				"filter[created_at][gte]": {"2024-01-01T00:00:00Z"},
+				"filter[created_at][lt]":  {"2024-02-01T00:00:00Z"},
+				"page":                    {"2"},
+			},
+			exp: ParamFilter{
+				Email:        sql.NullString{Valid: true, String: "foo@bar.com"},
+				EmailNEQ:     sql.NullString{Valid: true, String: "bar@foo.com"},
+				Status:       []int{1, 2},
+				StatusNIN:    []int{3, 4, 5},
+				CreatedAtGTE: sql.NullTime{Valid: true, Time: time.Date(2024, 01, 01, 0, 0, 0, 0, time.UTC)},
+				CreatedAtLT:  sql.NullTime{Valid: true, Time: time.Date(2024, 02, 01, 0, 0, 0, 0, time.UTC)},
+				Page:         2,
+			},
+		},
+		{
+			desc: "operator default to eq",
+			param: map[string][]string{
+				"filter[email]": {"foo@bar.com"},
+			},
+			exp: ParamFilter{
+				Email: sql.NullString{Valid: true, String: "foo@bar.com"},
+			},
+		},
+		{
+			desc: "unsupported operator",
+			param: map[string][]string{
+				"filter[email][like]": {"foo"},
+			},
+			expErr: true,
+		},
+		{
+			desc: "invalid filter key",
+			param: map[string][]string{
+				"filter[email": {"foo"},
+			},
+			expErr: true,
+		},
+	}
+
+	for _, tc := range testCase {
+		t.Run(tc.desc, func(t *testing.T) {
+			result := ParamFilter{}
+			parser := parser.InitParamParser()
+			err := parser.Decode(&result, tc.param)
+			if tc.expErr {
+				assert.Assert(t, err != nil)
+				return
+			}
+
+			assert.NilError(t, err)
+			assert.DeepEqual(t, tc.exp, result)
+		})
+	}
+}
+
+type ParamTime struct {
+	Time     time.Time    `param:"time"`
+	TimePtr  *time.Time   `param:"time_ptr"`
+	NullTime sql.NullTime `param:"null_time"`
+}
+
+func Test_DecodeTime(t *testing.T) {
+	jakarta, err := time.LoadLocation("Asia/Jakarta")
+	assert.NilError(t, err)
+
+	testCase := []struct {
+		desc   string
+		opts   []parser.Option
+		loc    *time.Location
+		value  string
+		exp    time.Time
+		expErr bool
+	}{
+		{
+			desc:  "date only default to UTC",
+			value: "2024-01-01",
+			exp:   time.Date(2024, 01, 01, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			desc:  "date only with default location",
+			opts:  []parser.Option{parser.WithLocation(jakarta)},
+			value: "2024-01-01",
+			exp:   time.Date(2024, 01, 01, 0, 0, 0, 0, jakarta),
+		},
+		{
+			desc:  "date only with request location",
+			loc:   jakarta,
+			value: "2024-01-01 10:00:00",
+			exp:   time.Date(2024, 01, 01, 10, 0, 0, 0, jakarta),
+		},
+		{
+			desc:  "zone offset is kept",
+			loc:   jakarta,
+			value: "2024-01-01T10:00:00Z",
+			exp:   time.Date(2024, 01, 01, 10, 0, 0, 0, time.UTC),
+		},
+		{
+			desc:  "unix seconds",
+			value: "1704067200",
+			exp:   time.Date(2024, 01, 01, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			desc:  "unix milliseconds",
+			value: "1704067200123",
+			exp:   time.Date(2024, 01, 01, 0, 0, 0, 123000000, time.UTC),
+		},
+		{
+			desc:   "unix disabled",
+			opts:   []parser.Option{parser.WithoutUnixTime()},
+			value:  "1704067200",
+			expErr: true,
+		},
+		{
+			desc:  "custom format",
+			opts:  []parser.Option{parser.WithTimeFormats("02/01/2006")},
+			value: "31/12/2024",
+			exp:   time.Date(2024, 12, 31, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			desc:   "custom format replace default",
+			opts:   []parser.Option{parser.WithTimeFormats("02/01/2006"), parser.WithoutUnixTime()},
+			value:  "2024-12-31",
+			expErr: true,
+		},
+	}
+
+	for _, tc := range testCase {
+		t.Run(tc.desc, func(t *testing.T) {
+			result := ParamTime{}
+			parser := parser.InitParamParser(tc.opts...).In(tc.loc)
+			err := parser.Decode(&result, map[string][]string{
+				"time":      {tc.value},
+				"time_ptr":  {tc.value},
+				"null_time": {tc.value},
+			})
+			if tc.expErr {
+				assert.Assert(t, err != nil)
+				assert.Assert(t, !result.NullTime.Valid)
+				return
+			}
+
+			assert.NilError(t, err)
+			assert.Assert(t, tc.exp.Equal(result.Time), "got %s", result.Time)
+			assert.Equal(t, tc.exp.Location().String(), result.Time.Location().String())
+			assert.Assert(t, result.TimePtr != nil && tc.exp.Equal(*result.TimePtr))
+			assert.Assert(t, result.NullTime.Valid && tc.exp.Equal(result.NullTime.Time))
+		})
+	}
+}
+
+func Test_LocationFromHeader(t *testing.T) {
+	testCase := []struct {
+		desc      string
+		header    string
+		expOffset int
+		expNil    bool
+		expErr    bool
+	}{
+		{desc: "empty header", header: "", expNil: true},
+		{desc: "iana name", header: "Asia/Jakarta", expOffset: 7 * 3600},
+		{desc: "offset", header: "-03:30", expOffset: -(3*3600 + 30*60)},
+		{desc: "invalid name", header: "Mars/Olympus", expErr: true},
+		{desc: "invalid offset", header: "+7", expErr: true},
+	}
+
+	for _, tc := range testCase {
+		t.Run(tc.desc, func(t *testing.T) {
+			h := http.Header{}
+			h.Set(parser.HeaderTimezone, tc.header)
+
+			loc, err := parser.LocationFromHeader(h)
+			if tc.expErr {
+				assert.Assert(t, err != nil)
+				return
+			}
+
+			assert.NilError(t, err)
+			if tc.expNil {
+				assert.Assert(t, loc == nil)
+				return
+			}
+			_, offset := time.Date(2024, 01, 01, 0, 0, 0, 0, loc).Zone()
+			assert.Equal(t, tc.expOffset, offset)
+		})
+	}
+}
+
+type BindRequest struct {
+	ID        string       `path:"id"`
+	Name      string       `json:"name"`
+	Age       int          `json:"age"`
+	Verbose   bool         `param:"verbose"`
+	Since     sql.NullTime `param:"since"`
+	RequestID string       `header:"X-Request-Id"`
+}
+
+func (r BindRequest) Validate() error {
+	if r.Age < 0 {
+		return errors.New("age must be positive")
+	}
+	return nil
+}
+
+func Test_Bind(t *testing.T) {
+	jakarta, err := time.LoadLocation("Asia/Jakarta")
+	assert.NilError(t, err)
+
+	testCase := []struct {
+		desc      string
+		binder    parser.Binder
+		target    string
+		body      string
+		header    map[string]string
+		exp       BindRequest
+		expSource string
+		expErr    error
+	}{
+		{
+			desc:   "bind all source",
+			target: "/users/abc?verbose=true&since=2024-01-01",
+			body:   `{"name":"foo","age":20}`,
+			header: map[string]string{"X-Request-Id": "req-1", parser.HeaderTimezone: "Asia/Jakarta"},
+			exp: BindRequest{
+				ID:        "abc",
+				Name:      "foo",
+				Age:       20,
+				Verbose:   true,
+				Since:     sql.NullTime{Valid: true, Time: time.Date(2024, 01, 01, 0, 0, 0, 0, jakarta)},
+				RequestID: "req-1",
+			},
+		},
+		{
+			desc:   "empty body",
+			target: "/users/abc",
+			exp:    BindRequest{ID: "abc"},
+		},
+		{
+			desc:   "query cannot override field without param tag",
+			target: "/users/abc?verbose=true&Name=bar&name=bar&Age=9&RequestID=req-2&ID=xyz",
+			body:   `{"name":"foo","age":20}`,
+			exp:    BindRequest{ID: "abc", Name: "foo", Age: 20, Verbose: true},
+		},
+		{
+			desc:      "unknown field",
+			target:    "/users/abc",
+			body:      `{"name":"foo","role":"admin"}`,
+			expSource: "body",
+		},
+		{
+			desc:      "multiple json value",
+			target:    "/users/abc",
+			body:      `{"name":"foo"} {"name":"bar"}`,
+			expSource: "body",
+		},
+		{
+			desc:      "body too large",
+			binder:    parser.NewBinder(parser.BinderOption{MaxBodySize: 8}, parser.InitParamParser()),
+			target:    "/users/abc",
+			body:      `{"name":"foo bar baz"}`,
+			expSource: "body",
+			expErr:    parser.ErrBodyTooLarge,
+		},
+		{
+			desc:      "invalid timezone",
+			target:    "/users/abc",
+			header:    map[string]string{parser.HeaderTimezone: "Mars/Olympus"},
+			expSource: "header",
+		},
+		{
+			desc:      "invalid query",
+			target:    "/users/abc?verbose=maybe",
+			expSource: "query",
+		},
+		{
+			desc:      "validation failed",
+			target:    "/users/abc",
+			body:      `{"age":-1}`,
+			expSource: "validate",
+		},
+	}
+
+	for _, tc := range testCase {
+		t.Run(tc.desc, func(t *testing.T) {
+			var (
+				result BindRequest
+				err    error
+			)
+
+			r := chi.NewRouter()
+			r.Post("/users/{id}", func(w http.ResponseWriter, r *http.Request) {
+				if tc.binder != nil {
+					err = tc.binder.Bind(r, &result)
+					return
+				}
+				err = parser.Bind(r, &result)
+			})
+
+			req := httptest.NewRequest(http.MethodPost, tc.target, strings.NewReader(tc.body))
+			for k, v := range tc.header {
+				req.Header.Set(k, v)
+			}
+			r.ServeHTTP(httptest.NewRecorder(), req)
+
+			if tc.expSource != "" {
+				var bindErr *parser.BindError
+				assert.Assert(t, errors.As(err, &bindErr), "got %v", err)
+				assert.Equal(t, tc.expSource, bindErr.Source)
+				if tc.expErr != nil {
+					assert.Assert(t, errors.Is(err, tc.expErr))
+				}
+				return
+			}
+
+			assert.NilError(t, err)
+			assert.DeepEqual(t, tc.exp, result)
+		})
+	}
+
+	t.Run("destination should be pointer to struct", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		assert.Assert(t, parser.Bind(req, BindRequest{}) != nil)
+	})
+}
+
+type ParamNullRoundTrip struct {
+	String  sql.NullString  `param:"string"`
+	Bool    sql.NullBool    `param:"bool"`
+	Int64   sql.NullInt64   `param:"int64"`
+	Int32   sql.NullInt32   `param:"int32"`
+	Int16   sql.NullInt16   `param:"int16"`
+	Byte    sql.NullByte    `param:"byte"`
+	Float64 sql.NullFloat64 `param:"float64"`
+	Time    sql.NullTime    `param:"time"`
+
+	GenericString  sql.Null[string]    `param:"generic_string"`
+	GenericInt     sql.Null[int]       `param:"generic_int"`
+	GenericInt8    sql.Null[int8]      `param:"generic_int8"`
+	GenericUint64  sql.Null[uint64]    `param:"generic_uint64"`
+	GenericFloat32 sql.Null[float32]   `param:"generic_float32"`
+	GenericFloat64 sql.Null[float64]   `param:"generic_float64"`
+	GenericTime    sql.Null[time.Time] `param:"generic_time"`
+}
+
+func Test_EncodeDecodeRoundTrip(t *testing.T) {
+	roundTrip := func(valid [15]bool, s string, b bool, i64 int64, i32 int32, i16 int16, u8 uint8, f64 float64, sec int64, nsec uint32,
+		i int, i8 int8, u64 uint64, f32 float32) bool {
+		tm := time.Unix(sec%(1<<35), int64(nsec%1e9)).In(time.FixedZone("", int(i16)%(14*60)*60))
+		// null field has no value, so the decoded value is compared as is
+		var src ParamNullRoundTrip
+		if valid[0] {
+			src.String = sql.NullString{Valid: true, String: s}
+		}
+		if valid[1] {
+			src.Bool = sql.NullBool{Valid: true, Bool: b}
+		}
+		if valid[2] {
+			src.Int64 = sql.NullInt64{Valid: true, Int64: i64}
+		}
+		if valid[3] {
+			src.Int32 = sql.NullInt32{Valid: true, Int32: i32}
+		}
+		if valid[4] {
+			src.Int16 = sql.NullInt16{Valid: true, Int16: i16}
+		}
+		if valid[5] {
+			src.Byte = sql.NullByte{Valid: true, Byte: u8}
+		}
+		if valid[6] {
+			src.Float64 = sql.NullFloat64{Valid: true, Float64: f64}
+		}
+		if valid[7] {
+			src.Time = sql.NullTime{Valid: true, Time: tm}
+		}
+		if valid[8] {
+			src.GenericString = sql.Null[string]{Valid: true, V: s}
+		}
+		if valid[9] {
+			src.GenericInt = sql.Null[int]{Valid: true, V: i}
+		}
+		if valid[10] {
+			src.GenericInt8 = sql.Null[int8]{Valid: true, V: i8}
+		}
+		if valid[11] {
+			src.GenericUint64 = sql.Null[uint64]{Valid: true, V: u64}
+		}
+		if valid[12] {
+			src.GenericFloat32 = sql.Null[float32]{Valid: true, V: f32}
+		}
+		if valid[13] {
+			src.GenericFloat64 = sql.Null[float64]{Valid: true, V: f64}
+		}
+		if valid[14] {
+			src.GenericTime = sql.Null[time.Time]{Valid: true, V: tm}
+		}
+
+		p := parser.InitParamParser()
+		encoded := map[string][]string{}
+		if err := p.Encode(src, encoded); err != nil {
+			t.Logf("encode: %s", err)
+			return false
+		}
+
+		var result ParamNullRoundTrip
+		if err := p.Decode(&result, encoded); err != nil {
+			t.Logf("decode %v: %s", encoded, err)
+			return false
+		}
+
+		// time is compared by instant, location is decoded as fixed zone
+		if src.Time.Valid != result.Time.Valid || !src.Time.Time.Equal(result.Time.Time) {
+			t.Logf("time: src %v, got %v", src.Time, result.Time)
+			return false
+		}
+		if src.GenericTime.Valid != result.GenericTime.Valid || !src.GenericTime.V.Equal(result.GenericTime.V) {
+			t.Logf("generic time: src %v, got %v", src.GenericTime, result.GenericTime)
+			return false
+		}
+		src.Time, result.Time = sql.NullTime{}, sql.NullTime{}
+		src.GenericTime, result.GenericTime = sql.Null[time.Time]{}, sql.Null[time.Time]{}
+
+		if src != result {
+			t.Logf("src %+v\ngot %+v", src, result)
+			return false
+		}
+		return true
+	}
+
+	err := quick.Check(roundTrip, &quick.Config{MaxCount: 1000})
+	assert.NilError(t, err)
+}
+
+func Test_DecodeEmptyAsNull(t *testing.T) {
+	var result ParamNullRoundTrip
+	src := map[string][]string{}
+	for _, key := range []string{"bool", "int64", "int32", "int16", "byte", "float64", "time", "generic_int", "generic_float64", "generic_time"} {
+		src[key] = []string{""}
+	}
+
+	err := parser.InitParamParser().Decode(&result, src)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, ParamNullRoundTrip{}, result)
+}
@@ -0,0 +1,37 @@
+package parser
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// HeaderTimezone is request header to override parser location per request.
+// value can be IANA name (Asia/Jakarta) or offset (+07:00)
+const HeaderTimezone = "X-Timezone"
+
+// LocationFromHeader return location from X-Timezone header.
+// nil location is returned when header is empty.
+func LocationFromHeader(h http.Header) (*time.Location, error) {
+	tz := strings.TrimSpace(h.Get(HeaderTimezone))
+	if tz == "" {
+		return nil, nil
+	}
+
+	if tz[0] == '+' || tz[0] == '-' {
+		t0, err := time.Parse("-07:00", tz)
+		if err != nil {
+			return nil, fmt.Errorf("parser: invalid %s offset %q", HeaderTimezone, tz)
+		}
+		_, offset := t0.Zone()
+		return time.FixedZone(tz, offset), nil
+	}
+
+	loc, err := time.LoadLocation(tz)
+	if err != nil {
+		return nil, fmt.Errorf("parser: invalid %s %q", HeaderTimezone, tz)
+	}
+
+	return loc, nil
+}
@@ -0,0 +1,209 @@
+package password
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	AlgorithmArgon2id = "argon2id"
+	AlgorithmBcrypt   = "bcrypt"
+
+	DefaultArgon2Time    = 3
+	DefaultArgon2Memory  = 64 * 1024 // 64 MiB
+	DefaultArgon2Threads = 2
+	DefaultBcryptCost    = 12
+
+	argon2SaltLength = 16
+	argon2KeyLength  = 32
+)
+
+var (
+	ErrMismatch    = errors.New("password doesn't match")
+	ErrUnknownHash = errors.New("unknown password hash format")
+)
+
+type Option struct {
+	// Algorithm of new hash, argon2id or bcrypt, default to argon2id.
+	// hash of the other algorithm is still verified, see Hasher.NeedsRehash
+	Algorithm string
+
+	Argon2 Argon2Option
+	Bcrypt BcryptOption
+}
+
+func (o Option) algorithm() string {
+	if o.Algorithm == "" {
+		return AlgorithmArgon2id
+	}
+	return o.Algorithm
+}
+
+type Argon2Option struct {
+	// Time is number of pass over the memory, default to 3
+	Time uint32
+
+	// Memory in KiB, default to 64 MiB
+	Memory uint32
+
+	// Threads default to 2
+	Threads uint8
+}
+
+func (o Argon2Option) time() uint32 {
+	if o.Time == 0 {
+		return DefaultArgon2Time
+	}
+	return o.Time
+}
+
+func (o Argon2Option) memory() uint32 {
+	if o.Memory == 0 {
+		return DefaultArgon2Memory
+	}
+	return o.Memory
+}
+
+func (o Argon2Option) threads() uint8 {
+	if o.Threads == 0 {
+		return DefaultArgon2Threads
+	}
+	return o.Threads
+}
+
+type BcryptOption struct {
+	// Cost default to 12
+	Cost int
+}
+
+func (o BcryptOption) cost() int {
+	if o.Cost == 0 {
+		return DefaultBcryptCost
+	}
+	return o.Cost
+}
+
+// Hasher hash password into self describing string, algorithm and parameter is stored in the hash
+type Hasher interface {
+	Hash(password string) (string, error)
+
+	// Verify return ErrMismatch when password doesn't match the hash of any supported algorithm
+	Verify(password, hash string) error
+
+	// NeedsRehash report whether hash is made with different algorithm or parameter than the current option
+	NeedsRehash(hash string) bool
+}
+
+type hasher struct {
+	opt Option
+}
+
+// NewHasher return hasher of opt, invalid option is reported by Hash
+func NewHasher(opt Option) Hasher {
+	return &hasher{opt: opt}
+}
+
+func (h *hasher) Hash(password string) (string, error) {
+	switch h.opt.algorithm() {
+	case AlgorithmArgon2id:
+	case AlgorithmBcrypt:
+		cost := h.opt.Bcrypt.cost()
+		if cost < bcrypt.MinCost || cost > bcrypt.MaxCost {
+			return "", fmt.Errorf("bcrypt cost should be between %d and %d", bcrypt.MinCost, bcrypt.MaxCost)
+		}
+		b, err := bcrypt.GenerateFromPassword([]byte(password), cost)
+		return string(b), err
+	default:
+		return "", fmt.Errorf("unknown password hash algorithm %q", h.opt.Algorithm)
+	}
+
+	salt := make([]byte, argon2SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	p := argon2Params{
+		memory:  h.opt.Argon2.memory(),
+		time:    h.opt.Argon2.time(),
+		threads: h.opt.Argon2.threads(),
+	}
+	return p.encode(salt, p.key(password, salt, argon2KeyLength)), nil
+}
+
+func (h *hasher) Verify(password, hash string) error {
+	if isBcrypt(hash) {
+		err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return ErrMismatch
+		}
+		return err
+	}
+
+	p, salt, key, err := decodeArgon2(hash)
+	if err != nil {
+		return err
+	}
+	if subtle.ConstantTimeCompare(key, p.key(password, salt, uint32(len(key)))) != 1 {
+		return ErrMismatch
+	}
+	return nil
+}
+
+func (h *hasher) NeedsRehash(hash string) bool {
+	if isBcrypt(hash) {
+		cost, err := bcrypt.Cost([]byte(hash))
+		return err != nil || h.opt.algorithm() != AlgorithmBcrypt || cost != h.opt.Bcrypt.cost()
+	}
+
+	p, _, _, err := decodeArgon2(hash)
+	return err != nil || h.opt.algorithm() != AlgorithmArgon2id ||
+		p.memory != h.opt.Argon2.memory() || p.time != h.opt.Argon2.time() || p.threads != h.opt.Argon2.threads()
+}
+
+func isBcrypt(hash string) bool {
+	return strings.HasPrefix(hash, "$2a$") || strings.HasPrefix(hash, "$2b$") || strings.HasPrefix(hash, "$2y$")
+}
+
+type argon2Params struct {
+	memory  uint32
+	time    uint32
+	threads uint8
+}
+
+func (p argon2Params) key(password string, salt []byte, length uint32) []byte {
+	return argon2.IDKey([]byte(password), salt, p.time, p.memory, p.threads, length)
+}
+
+// encode in PHC string format, e.g: $argon2id$v=19$m=65536,t=3,p=2$<salt>$<key>
+func (p argon2Params) encode(salt, key []byte) string {
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s", argon2.Version, p.memory, p.time, p.threads,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key))
+}
+
+func decodeArgon2(hash string) (p argon2Params, salt, key []byte, err error) {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 || parts[1] != AlgorithmArgon2id {
+		return p, nil, nil, ErrUnknownHash
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return p, nil, nil, ErrUnknownHash
+	}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &p.memory, &p.time, &p.threads); err != nil {
+		return p, nil, nil, ErrUnknownHash
+	}
+	if salt, err = base64.RawStdEncoding.DecodeString(parts[4]); err != nil {
+		return p, nil, nil, ErrUnknownHash
+	}
+	if key, err = base64.RawStdEncoding.DecodeString(parts[5]); err != nil || len(key) == 0 {
+		return p, nil, nil, ErrUnknownHash
+	}
+	return p, salt, key, nil
+}
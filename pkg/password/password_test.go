@@ -0,0 +1,81 @@
+package password
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// low cost option to keep the test fast
+var (
+	argon2Option = Option{Argon2: Argon2Option{Time: 1, Memory: 1024, Threads: 1}}
+	bcryptOption = Option{Algorithm: AlgorithmBcrypt, Bcrypt: BcryptOption{Cost: 4}}
+)
+
+func Test_Hasher(t *testing.T) {
+	testCase := []struct {
+		desc   string
+		opt    Option
+		prefix string
+	}{
+		{desc: "argon2id", opt: argon2Option, prefix: "$argon2id$v=19$m=1024,t=1,p=1$"},
+		{desc: "bcrypt", opt: bcryptOption, prefix: "$2a$04$"},
+	}
+
+	for _, tc := range testCase {
+		t.Run(tc.desc, func(t *testing.T) {
+			h := NewHasher(tc.opt)
+
+			hash, err := h.Hash("s3cret pass")
+			assert.Nil(t, err)
+			assert.True(t, strings.HasPrefix(hash, tc.prefix), hash)
+
+			other, err := h.Hash("s3cret pass")
+			assert.Nil(t, err)
+			assert.NotEqual(t, hash, other, "hash is salted")
+
+			assert.Nil(t, h.Verify("s3cret pass", hash))
+			assert.ErrorIs(t, h.Verify("s3cret Pass", hash), ErrMismatch)
+			assert.False(t, h.NeedsRehash(hash))
+		})
+	}
+}
+
+func Test_Hasher_NeedsRehash(t *testing.T) {
+	argon2Hash, err := NewHasher(argon2Option).Hash("foo")
+	assert.Nil(t, err)
+	bcryptHash, err := NewHasher(bcryptOption).Hash("foo")
+	assert.Nil(t, err)
+
+	t.Run("algorithm changed", func(t *testing.T) {
+		h := NewHasher(bcryptOption)
+		assert.True(t, h.NeedsRehash(argon2Hash))
+		assert.Nil(t, h.Verify("foo", argon2Hash), "old hash is still verified")
+	})
+
+	t.Run("parameter changed", func(t *testing.T) {
+		opt := argon2Option
+		opt.Argon2.Time = 2
+		assert.True(t, NewHasher(opt).NeedsRehash(argon2Hash))
+
+		opt = bcryptOption
+		opt.Bcrypt.Cost = 5
+		assert.True(t, NewHasher(opt).NeedsRehash(bcryptHash))
+	})
+
+	t.Run("unknown hash", func(t *testing.T) {
+		h := NewHasher(argon2Option)
+		assert.True(t, h.NeedsRehash("plain"))
+		assert.ErrorIs(t, h.Verify("foo", "plain"), ErrUnknownHash)
+		assert.ErrorIs(t, h.Verify("foo", "$argon2id$v=19$m=1024,t=1,p=1$!$!"), ErrUnknownHash)
+	})
+}
+
+func Test_Hasher_InvalidOption(t *testing.T) {
+	_, err := NewHasher(Option{Algorithm: "md5"}).Hash("foo")
+	assert.NotNil(t, err)
+
+	_, err = NewHasher(Option{Algorithm: AlgorithmBcrypt, Bcrypt: BcryptOption{Cost: 32}}).Hash("foo")
+	assert.NotNil(t, err)
+}
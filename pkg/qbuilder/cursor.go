@@ -95,12 +95,43 @@ func (c *cursor) Make() (clause string, args []interface{}, skip bool) {
 	case sql.NullString, sql.NullInt32, sql.NullInt64, sql.NullFloat64, sql.NullBool:
 		return c.makeClauseSqlNullType()
 	default:
+		if c.field.Kind() == reflect.Slice {
+			return c.makeClauseNamedArrayType()
+		}
 		skip = true
 	}
 
 	return
 }
 
+// makeClauseNamedArrayType handle slice of named basic type, e.g: []Status where Status is int
+func (c *cursor) makeClauseNamedArrayType() (clause string, args []interface{}, skip bool) {
+	var vals []interface{}
+	for i := 0; i < c.field.Len(); i++ {
+		elem := c.field.Index(i)
+		switch elem.Kind() {
+		case reflect.String:
+			vals = append(vals, elem.String())
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			vals = append(vals, elem.Int())
+		case reflect.Uint, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			vals = append(vals, elem.Uint())
+		case reflect.Float32, reflect.Float64:
+			vals = append(vals, elem.Float())
+		default:
+			skip = true
+			return
+		}
+	}
+
+	if len(vals) == 0 {
+		skip = true
+		return
+	}
+
+	return c.makeClauseMulti(vals)
+}
+
 func (c *cursor) makeClausePrimitiveType() (clause string, args []interface{}, skip bool) {
 	operand := c.GetOperand()
 
@@ -29,6 +29,7 @@ type queryBuilder struct {
 
 	// option
 	extraLimit int64
+	noLimit    bool
 
 	// result
 	args        []interface{}
@@ -61,6 +62,14 @@ func WithExtraLimit() Option {
 	}
 }
 
+// WithoutLimit ignore page and limit, all matching rows is returned.
+// The purpose is for streaming the whole result, e.g: export.
+func WithoutLimit() Option {
+	return func(qb *queryBuilder) {
+		qb.noLimit = true
+	}
+}
+
 // Add custom where clause
 func (q *queryBuilder) AddWhereClause(wc string, args ...interface{}) *queryBuilder {
 	q.customWhereClause = append(q.customWhereClause, wc)
@@ -139,6 +148,10 @@ func (q *queryBuilder) makeOrderByClause() string {
 }
 
 func (q *queryBuilder) makeLimitClause() string {
+	if q.noLimit {
+		return ""
+	}
+
 	offset := (q.page - 1) * q.limit
 	limitClause := fmt.Sprintf(" LIMIT %d, %d", offset, offset+q.limit+q.extraLimit)
 
@@ -36,6 +36,16 @@ type ParamArr struct {
 	Float64s []float64 `param:"float64s" db:"float64s"`
 }
 
+type NamedInt int
+
+type NamedString string
+
+type ParamNamedArr struct {
+	NamedInts    []NamedInt    `param:"named_ints" db:"named_ints"`
+	NamedStrings []NamedString `param:"named_strings__nin" db:"named_strings"`
+	Bytes        []byte        `param:"bytes" db:"bytes"`
+}
+
 type ParamNull struct {
 	NullString  sql.NullString  `param:"nullstring" db:"nullstring"`
 	NullInt32   sql.NullInt32   `param:"nullint32" db:"nullint32"`
@@ -167,6 +177,43 @@ func Test_QBuilder_Array(t *testing.T) {
 	}
 }
 
+func Test_QBuilder_NamedArray(t *testing.T) {
+	testCase := []struct {
+		desc      string
+		param     ParamNamedArr
+		expClause string
+		expArgs   []interface{}
+	}{
+		{
+			desc: "success",
+			param: ParamNamedArr{
+				NamedInts:    []NamedInt{1, 2},
+				NamedStrings: []NamedString{"a", "b"},
+			},
+			expClause: " WHERE 1=1 AND named_ints IN (?, ?) AND named_strings NOT IN (?, ?) LIMIT 0, 10",
+			expArgs:   []interface{}{int64(1), int64(2), "a", "b"},
+		},
+		{
+			desc: "empty array",
+			param: ParamNamedArr{
+				NamedInts:    []NamedInt{},
+				NamedStrings: nil,
+			},
+			expClause: " WHERE 1=1 LIMIT 0, 10",
+			expArgs:   nil,
+		},
+	}
+
+	for i, tc := range testCase {
+		t.Run(fmt.Sprintf("[%d] %s", i, tc.desc), func(t *testing.T) {
+			clause, args, err := New().Build(&tc.param)
+			assert.Nil(t, err)
+			assert.Equal(t, tc.expClause, clause)
+			assert.Equal(t, tc.expArgs, args)
+		})
+	}
+}
+
 func Test_QBuilder_SqlNull(t *testing.T) {
 	param := ParamNull{
 		NullString:  sql.NullString{Valid: true, String: "test"},
@@ -374,6 +421,19 @@ func Test_QBuilder_WithExtraLimit(t *testing.T) {
 	}
 }
 
+func Test_QBuilder_WithoutLimit(t *testing.T) {
+	param := ParamPaginationInt64{
+		Page:   2,
+		Limit:  10,
+		SortBy: []string{"-created_at"},
+	}
+
+	clause, args, err := New(WithoutLimit(), WithExtraLimit()).Build(&param)
+	assert.Nil(t, err)
+	assert.Equal(t, " WHERE 1=1 ORDER BY created_at DESC", clause)
+	assert.Nil(t, args)
+}
+
 func Test_ValidatePageAndLimit(t *testing.T) {
 	testCase := []struct {
 		desc     string
@@ -0,0 +1,76 @@
+package tenant
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/netip"
+
+	"github.com/tuingking/supersvc/pkg/ctxkey"
+)
+
+// MaxIDLength is max length of tenant id, see tenant_id column
+const MaxIDLength = 64
+
+var (
+	ErrMissing = errors.New("tenant is not set")
+	ErrInvalid = errors.New("tenant id should be 1-64 characters of letter, digit, '-', '_' or '.'")
+)
+
+type Option struct {
+	// TrustedGateways is CIDR of gateway, e.g: 10.0.0.0/8. X-Tenant-ID header sent by them is trusted without access token.
+	// the header sent by other client is only checked against the tenant of the access token.
+	TrustedGateways []string
+}
+
+// IsTrustedGateway report whether addr, host:port of the peer, is in Option.TrustedGateways. invalid CIDR is ignored
+func (o Option) IsTrustedGateway(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		host = addr
+	}
+	ip, err := netip.ParseAddr(host)
+	if err != nil {
+		return false
+	}
+
+	for _, cidr := range o.TrustedGateways {
+		prefix, err := netip.ParsePrefix(cidr)
+		if err == nil && prefix.Contains(ip.Unmap()) {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate return ErrInvalid when id is not a valid tenant id
+func Validate(id string) error {
+	if id == "" || len(id) > MaxIDLength {
+		return ErrInvalid
+	}
+	for _, c := range id {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == '-', c == '_', c == '.':
+		default:
+			return ErrInvalid
+		}
+	}
+	return nil
+}
+
+// WithID return ctx carrying the tenant, id is validated by FromContext
+func WithID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, ctxkey.XTenantID, id)
+}
+
+// FromContext return tenant id of ctx, ErrMissing is returned when ctx has none and ErrInvalid when it is not valid
+func FromContext(ctx context.Context) (string, error) {
+	id, _ := ctx.Value(ctxkey.XTenantID).(string)
+	if id == "" {
+		return "", ErrMissing
+	}
+	if err := Validate(id); err != nil {
+		return "", err
+	}
+	return id, nil
+}
@@ -0,0 +1,45 @@
+package tenant
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Validate(t *testing.T) {
+	for _, id := range []string{"acme", "ACME-01", "business_unit.id", strings.Repeat("a", MaxIDLength)} {
+		assert.Nil(t, Validate(id), id)
+	}
+	for _, id := range []string{"", "acme corp", "acme/../other", "acme'--", "ácme", strings.Repeat("a", MaxIDLength+1)} {
+		assert.ErrorIs(t, Validate(id), ErrInvalid, id)
+	}
+}
+
+func Test_FromContext(t *testing.T) {
+	_, err := FromContext(context.Background())
+	assert.ErrorIs(t, err, ErrMissing)
+
+	_, err = FromContext(WithID(context.Background(), ""))
+	assert.ErrorIs(t, err, ErrMissing)
+
+	_, err = FromContext(WithID(context.Background(), "acme corp"))
+	assert.ErrorIs(t, err, ErrInvalid)
+
+	id, err := FromContext(WithID(context.Background(), "acme"))
+	assert.Nil(t, err)
+	assert.Equal(t, "acme", id)
+}
+
+func Test_Option_IsTrustedGateway(t *testing.T) {
+	opt := Option{TrustedGateways: []string{"10.0.0.0/8", "invalid", "2001:db8::/32"}}
+
+	for _, addr := range []string{"10.1.2.3:8080", "10.1.2.3", "[::ffff:10.1.2.3]:8080", "[2001:db8::1]:8080"} {
+		assert.True(t, opt.IsTrustedGateway(addr), addr)
+	}
+	for _, addr := range []string{"192.0.2.1:1234", "11.0.0.1:80", "invalid", ""} {
+		assert.False(t, opt.IsTrustedGateway(addr), addr)
+	}
+	assert.False(t, Option{}.IsTrustedGateway("10.1.2.3:8080"), "no gateway is trusted by default")
+}
@@ -0,0 +1,134 @@
+package token
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const DefaultTTL = time.Hour
+
+var (
+	ErrNoSecret = errors.New("token secret is not set")
+	ErrInvalid  = errors.New("invalid token")
+	ErrExpired  = errors.New("token is expired")
+)
+
+type Option struct {
+	// Secret sign the token, token cannot be issued or verified when it is empty
+	Secret string
+
+	// TTL is how long issued token is valid, default to 1 hour
+	TTL time.Duration
+
+	// Issuer is set as iss claim and required on verify when not empty
+	Issuer string
+}
+
+func (o Option) GetTTL() time.Duration {
+	if o.TTL <= 0 {
+		return DefaultTTL
+	}
+	return o.TTL
+}
+
+// Claims of the token, time is unix second
+type Claims struct {
+	ID        string `json:"jti"`
+	Subject   string `json:"sub"`
+	Tenant    string `json:"tid,omitempty"`
+	Issuer    string `json:"iss,omitempty"`
+	IssuedAt  int64  `json:"iat"`
+	ExpiresAt int64  `json:"exp"`
+}
+
+// Signer issue and verify JWT signed with HMAC-SHA256 (HS256)
+type Signer interface {
+	// Sign issue token for the subject of the tenant, valid for Option.TTL
+	Sign(subject, tenant string) (string, Claims, error)
+
+	// Verify return claims of valid token, ErrExpired is returned when the token is expired
+	Verify(token string) (Claims, error)
+}
+
+type signer struct {
+	opt Option
+	now func() time.Time
+}
+
+func NewSigner(opt Option) Signer {
+	return &signer{
+		opt: opt,
+		now: time.Now,
+	}
+}
+
+// header is the same for every token, it is compared as is on verify
+var header = base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`))
+
+func (s *signer) Sign(subject, tenant string) (string, Claims, error) {
+	if s.opt.Secret == "" {
+		return "", Claims{}, ErrNoSecret
+	}
+
+	now := s.now()
+	claims := Claims{
+		ID:        uuid.New().String(),
+		Subject:   subject,
+		Tenant:    tenant,
+		Issuer:    s.opt.Issuer,
+		IssuedAt:  now.Unix(),
+		ExpiresAt: now.Add(s.opt.GetTTL()).Unix(),
+	}
+
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", Claims{}, err
+	}
+
+	unsigned := header + "." + base64.RawURLEncoding.EncodeToString(payload)
+	return unsigned + "." + s.sign(unsigned), claims, nil
+}
+
+func (s *signer) Verify(token string) (Claims, error) {
+	var claims Claims
+	if s.opt.Secret == "" {
+		return claims, ErrNoSecret
+	}
+
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 || parts[0] != header {
+		return claims, ErrInvalid
+	}
+	if !hmac.Equal([]byte(parts[2]), []byte(s.sign(parts[0]+"."+parts[1]))) {
+		return claims, ErrInvalid
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return claims, ErrInvalid
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil || claims.Subject == "" {
+		return claims, ErrInvalid
+	}
+	if s.opt.Issuer != "" && claims.Issuer != s.opt.Issuer {
+		return claims, ErrInvalid
+	}
+	if !s.now().Before(time.Unix(claims.ExpiresAt, 0)) {
+		return claims, ErrExpired
+	}
+
+	return claims, nil
+}
+
+func (s *signer) sign(unsigned string) string {
+	mac := hmac.New(sha256.New, []byte(s.opt.Secret))
+	mac.Write([]byte(unsigned))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,68 @@
+package token
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Signer(t *testing.T) {
+	now := time.Date(2024, 01, 01, 0, 0, 0, 0, time.UTC)
+	s := &signer{opt: Option{Secret: "secret", TTL: time.Minute, Issuer: "supersvc"}, now: func() time.Time { return now }}
+
+	token, claims, err := s.Sign("u1", "acme")
+	assert.Nil(t, err)
+	assert.NotEmpty(t, claims.ID)
+	assert.Equal(t, "u1", claims.Subject)
+	assert.Equal(t, "acme", claims.Tenant)
+	assert.Equal(t, now.Unix(), claims.IssuedAt)
+	assert.Equal(t, now.Add(time.Minute).Unix(), claims.ExpiresAt)
+
+	verified, err := s.Verify(token)
+	assert.Nil(t, err)
+	assert.Equal(t, claims, verified)
+
+	t.Run("tampered", func(t *testing.T) {
+		parts := strings.Split(token, ".")
+
+		other, _, err := s.Sign("u2", "acme")
+		assert.Nil(t, err)
+		payload := strings.Split(other, ".")[1]
+
+		for _, v := range []string{
+			"",
+			parts[0] + "." + parts[1],
+			parts[0] + "." + payload + "." + parts[2],
+			`eyJhbGciOiJub25lIn0.` + parts[1] + ".",
+		} {
+			_, err := s.Verify(v)
+			assert.ErrorIs(t, err, ErrInvalid, v)
+		}
+	})
+
+	t.Run("other secret", func(t *testing.T) {
+		_, err := NewSigner(Option{Secret: "other", Issuer: "supersvc"}).Verify(token)
+		assert.ErrorIs(t, err, ErrInvalid)
+	})
+
+	t.Run("other issuer", func(t *testing.T) {
+		other := &signer{opt: Option{Secret: "secret", Issuer: "foo"}, now: s.now}
+		_, err := other.Verify(token)
+		assert.ErrorIs(t, err, ErrInvalid)
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		later := &signer{opt: s.opt, now: func() time.Time { return now.Add(time.Minute) }}
+		_, err := later.Verify(token)
+		assert.ErrorIs(t, err, ErrExpired)
+	})
+
+	t.Run("no secret", func(t *testing.T) {
+		_, _, err := NewSigner(Option{}).Sign("u1", "")
+		assert.ErrorIs(t, err, ErrNoSecret)
+		_, err = NewSigner(Option{}).Verify(token)
+		assert.ErrorIs(t, err, ErrNoSecret)
+	})
+}
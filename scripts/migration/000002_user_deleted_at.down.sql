@@ -0,0 +1,3 @@
+ALTER TABLE `user`
+  DROP KEY `user_deleted_at_ix`,
+  DROP COLUMN `deleted_at`;
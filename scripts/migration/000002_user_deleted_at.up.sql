@@ -0,0 +1,3 @@
+ALTER TABLE `user`
+  ADD COLUMN `deleted_at` timestamp(6) NULL DEFAULT NULL AFTER `created_at`,
+  ADD KEY `user_deleted_at_ix` (`deleted_at`);
@@ -0,0 +1,4 @@
+ALTER TABLE `user`
+  DROP KEY `user_status_suspended_until_ix`,
+  DROP COLUMN `suspension_reason`,
+  DROP COLUMN `suspended_until`;
@@ -0,0 +1,4 @@
+ALTER TABLE `user`
+  ADD COLUMN `suspended_until` timestamp(6) NULL DEFAULT NULL AFTER `status`,
+  ADD COLUMN `suspension_reason` varchar(250) NOT NULL DEFAULT '' AFTER `suspended_until`,
+  ADD KEY `user_status_suspended_until_ix` (`status`, `suspended_until`);
@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS `idempotency_key` (
+  `idempotency_key` varchar(255) COLLATE utf8mb4_bin NOT NULL,
+  `request_hash` char(64) NOT NULL,
+  `status_code` int NOT NULL DEFAULT 0,
+  `response_body` mediumblob NULL,
+  `expires_at` timestamp(6) NOT NULL,
+  `created_at` timestamp(6) NOT NULL,
+  PRIMARY KEY (`idempotency_key`),
+  KEY `idempotency_key_expires_at_ix` (`expires_at`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
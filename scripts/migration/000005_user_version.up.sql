@@ -0,0 +1,2 @@
+ALTER TABLE `user`
+  ADD COLUMN `version` int unsigned NOT NULL DEFAULT 1 AFTER `suspension_reason`;
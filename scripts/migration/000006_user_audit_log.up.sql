@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS `user_audit_log` (
+  `id` varchar(36) NOT NULL,
+  `user_id` varchar(36) NOT NULL,
+  `action` varchar(32) NOT NULL,
+  `actor` varchar(250) NOT NULL DEFAULT '',
+  `request_id` varchar(64) NOT NULL DEFAULT '',
+  `reason` varchar(250) NOT NULL DEFAULT '',
+  `changes` json NOT NULL,
+  `created_at` timestamp(6) NOT NULL,
+  PRIMARY KEY (`id`),
+  KEY `user_audit_log_user_id_created_at_ix` (`user_id`, `created_at`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
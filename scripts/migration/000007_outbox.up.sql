@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS `outbox` (
+  `seq` bigint unsigned NOT NULL AUTO_INCREMENT,
+  `id` varchar(36) NOT NULL,
+  `message_type` varchar(64) NOT NULL,
+  `message_key` varchar(64) NOT NULL,
+  `payload` json NOT NULL,
+  `created_at` timestamp(6) NOT NULL,
+  `attempts` int NOT NULL DEFAULT 0,
+  `next_attempt_at` timestamp(6) NOT NULL,
+  `last_error` varchar(1000) NOT NULL DEFAULT '',
+  PRIMARY KEY (`seq`),
+  UNIQUE KEY `outbox_id_uq` (`id`),
+  KEY `outbox_message_key_ix` (`message_key`, `seq`),
+  KEY `outbox_next_attempt_at_ix` (`next_attempt_at`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
@@ -0,0 +1,2 @@
+ALTER TABLE `user`
+  ADD COLUMN `erased_at` timestamp(6) NULL DEFAULT NULL AFTER `version`;
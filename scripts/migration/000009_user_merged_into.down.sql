@@ -0,0 +1,3 @@
+ALTER TABLE `user`
+  DROP KEY `user_merged_into_ix`,
+  DROP COLUMN `merged_into`;
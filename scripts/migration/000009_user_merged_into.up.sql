@@ -0,0 +1,3 @@
+ALTER TABLE `user`
+  ADD COLUMN `merged_into` varchar(36) NOT NULL DEFAULT '' AFTER `erased_at`,
+  ADD KEY `user_merged_into_ix` (`merged_into`);
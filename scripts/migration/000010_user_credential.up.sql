@@ -0,0 +1,17 @@
+CREATE TABLE IF NOT EXISTS `user_credential` (
+  `user_id` varchar(36) NOT NULL,
+  `password_hash` varchar(255) COLLATE utf8mb4_bin NOT NULL,
+  `failed_attempts` int unsigned NOT NULL DEFAULT 0,
+  `locked_until` timestamp(6) NULL DEFAULT NULL,
+  `password_changed_at` timestamp(6) NOT NULL,
+  PRIMARY KEY (`user_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
+
+CREATE TABLE IF NOT EXISTS `user_password_reset` (
+  `token_hash` char(64) NOT NULL,
+  `user_id` varchar(36) NOT NULL,
+  `expires_at` timestamp(6) NOT NULL,
+  `created_at` timestamp(6) NOT NULL,
+  PRIMARY KEY (`token_hash`),
+  KEY `user_password_reset_user_id_ix` (`user_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS `user_verification`;
+
+ALTER TABLE `user`
+  DROP COLUMN `phone_verified_at`,
+  DROP COLUMN `email_verified_at`;
@@ -0,0 +1,14 @@
+ALTER TABLE `user`
+  ADD COLUMN `email_verified_at` timestamp(6) NULL DEFAULT NULL AFTER `merged_into`,
+  ADD COLUMN `phone_verified_at` timestamp(6) NULL DEFAULT NULL AFTER `email_verified_at`;
+
+CREATE TABLE IF NOT EXISTS `user_verification` (
+  `user_id` varchar(36) NOT NULL,
+  `channel` varchar(16) NOT NULL,
+  `contact` varchar(255) NOT NULL,
+  `code_hash` char(64) NOT NULL,
+  `attempts` int unsigned NOT NULL DEFAULT 0,
+  `expires_at` timestamp(6) NOT NULL,
+  `created_at` timestamp(6) NOT NULL,
+  PRIMARY KEY (`user_id`, `channel`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
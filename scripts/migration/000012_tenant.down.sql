@@ -0,0 +1,30 @@
+-- fail when the same email or user id is stored by more than one tenant, or stored idempotency key is longer than 255
+ALTER TABLE `idempotency_key`
+  MODIFY COLUMN `idempotency_key` varchar(255) COLLATE utf8mb4_bin NOT NULL;
+
+ALTER TABLE `user_verification`
+  DROP PRIMARY KEY,
+  ADD PRIMARY KEY (`user_id`, `channel`),
+  DROP COLUMN `tenant_id`;
+
+ALTER TABLE `user_password_reset`
+  DROP KEY `user_password_reset_user_id_ix`,
+  ADD KEY `user_password_reset_user_id_ix` (`user_id`),
+  DROP COLUMN `tenant_id`;
+
+ALTER TABLE `user_credential`
+  DROP PRIMARY KEY,
+  ADD PRIMARY KEY (`user_id`),
+  DROP COLUMN `tenant_id`;
+
+ALTER TABLE `user_audit_log`
+  DROP KEY `user_audit_log_user_id_created_at_ix`,
+  ADD KEY `user_audit_log_user_id_created_at_ix` (`user_id`, `created_at`),
+  DROP COLUMN `tenant_id`;
+
+ALTER TABLE `user`
+  DROP KEY `user_phone_ix`,
+  ADD KEY `user_phone_ix` (`phone`),
+  DROP KEY `user_email_uq`,
+  ADD UNIQUE KEY `user_email_uq` (`email`),
+  DROP COLUMN `tenant_id`;
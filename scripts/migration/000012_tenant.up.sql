@@ -0,0 +1,37 @@
+-- existing rows belong to the 'default' tenant, the default is dropped so every insert set the tenant
+ALTER TABLE `user`
+  ADD COLUMN `tenant_id` varchar(64) NOT NULL DEFAULT 'default' FIRST,
+  DROP KEY `user_email_uq`,
+  ADD UNIQUE KEY `user_email_uq` (`tenant_id`, `email`),
+  DROP KEY `user_phone_ix`,
+  ADD KEY `user_phone_ix` (`tenant_id`, `phone`);
+ALTER TABLE `user` ALTER COLUMN `tenant_id` DROP DEFAULT;
+
+ALTER TABLE `user_audit_log`
+  ADD COLUMN `tenant_id` varchar(64) NOT NULL DEFAULT 'default' FIRST,
+  DROP KEY `user_audit_log_user_id_created_at_ix`,
+  ADD KEY `user_audit_log_user_id_created_at_ix` (`tenant_id`, `user_id`, `created_at`);
+ALTER TABLE `user_audit_log` ALTER COLUMN `tenant_id` DROP DEFAULT;
+
+-- upsert of credential and verification is keyed by the tenant, so it never update row of other tenant
+ALTER TABLE `user_credential`
+  ADD COLUMN `tenant_id` varchar(64) NOT NULL DEFAULT 'default' FIRST,
+  DROP PRIMARY KEY,
+  ADD PRIMARY KEY (`tenant_id`, `user_id`);
+ALTER TABLE `user_credential` ALTER COLUMN `tenant_id` DROP DEFAULT;
+
+ALTER TABLE `user_password_reset`
+  ADD COLUMN `tenant_id` varchar(64) NOT NULL DEFAULT 'default' FIRST,
+  DROP KEY `user_password_reset_user_id_ix`,
+  ADD KEY `user_password_reset_user_id_ix` (`tenant_id`, `user_id`);
+ALTER TABLE `user_password_reset` ALTER COLUMN `tenant_id` DROP DEFAULT;
+
+ALTER TABLE `user_verification`
+  ADD COLUMN `tenant_id` varchar(64) NOT NULL DEFAULT 'default' FIRST,
+  DROP PRIMARY KEY,
+  ADD PRIMARY KEY (`tenant_id`, `user_id`, `channel`);
+ALTER TABLE `user_verification` ALTER COLUMN `tenant_id` DROP DEFAULT;
+
+-- idempotency key is prefixed with the tenant, see idempotency.ScopedKey
+ALTER TABLE `idempotency_key`
+  MODIFY COLUMN `idempotency_key` varchar(320) COLLATE utf8mb4_bin NOT NULL;
@@ -0,0 +1,3 @@
+-- ETag header of the stored response is sent again on replay
+ALTER TABLE `idempotency_key`
+  ADD COLUMN `etag` varchar(255) NOT NULL DEFAULT '' AFTER `status_code`;
@@ -0,0 +1,2 @@
+ALTER TABLE `user_verification`
+  DROP COLUMN `window_started_at`;
@@ -0,0 +1,5 @@
+-- attempts is counted across resend since window_started_at, pending code start its window when it is created
+ALTER TABLE `user_verification`
+  ADD COLUMN `window_started_at` timestamp(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6) AFTER `attempts`;
+UPDATE `user_verification` SET `window_started_at` = `created_at`;
+ALTER TABLE `user_verification` ALTER COLUMN `window_started_at` DROP DEFAULT;
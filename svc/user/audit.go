@@ -0,0 +1,178 @@
+package user
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/tuingking/supersvc/entity"
+	"github.com/tuingking/supersvc/pkg/ctxkey"
+)
+
+// actor of audit log when the context doesn't carry ctxkey.XActor
+const (
+	ActorAnonymous = "anonymous"
+	ActorSweeper   = "sweeper"
+)
+
+type AuditAction string
+
+const (
+	AuditActionCreate         AuditAction = "create"
+	AuditActionUpdate         AuditAction = "update"
+	AuditActionStatusChange   AuditAction = "status_change"
+	AuditActionDelete         AuditAction = "delete"
+	AuditActionRestore        AuditAction = "restore"
+	AuditActionPurge          AuditAction = "purge"
+	AuditActionErase          AuditAction = "erase"
+	AuditActionDataExport     AuditAction = "data_export"
+	AuditActionMerge          AuditAction = "merge"
+	AuditActionPasswordChange AuditAction = "password_change"
+	AuditActionPasswordReset  AuditAction = "password_reset"
+	AuditActionVerify         AuditAction = "verify"
+)
+
+func (a *AuditAction) UnmarshalText(text []byte) error {
+	switch v := AuditAction(text); v {
+	case AuditActionCreate, AuditActionUpdate, AuditActionStatusChange, AuditActionDelete, AuditActionRestore, AuditActionPurge,
+		AuditActionErase, AuditActionDataExport, AuditActionMerge, AuditActionPasswordChange, AuditActionPasswordReset,
+		AuditActionVerify:
+		*a = v
+		return nil
+	}
+	return fmt.Errorf("invalid audit action %q", text)
+}
+
+// AuditLog is record of single user mutation, it is kept after the user is purged
+type AuditLog struct {
+	ID        string        `json:"id"`
+	UserID    string        `json:"user_id"`
+	Action    AuditAction   `json:"action"`
+	Actor     string        `json:"actor"`
+	RequestID string        `json:"request_id,omitempty"`
+	Reason    string        `json:"reason,omitempty"`
+	Changes   []AuditChange `json:"changes"`
+	CreatedAt time.Time     `json:"created_at"`
+}
+
+// AuditChange is value of single field before and after the mutation, nil means not set
+type AuditChange struct {
+	Field string      `json:"field"`
+	From  interface{} `json:"from"`
+	To    interface{} `json:"to"`
+}
+
+type GetAuditLogParam struct {
+	Action       []AuditAction `param:"action__in" db:"action" enum:"create,update,status_change,delete,restore,purge,erase,data_export,merge,password_change,password_reset,verify"`
+	CreatedAtGTE sql.NullTime  `param:"created_at__gte" db:"created_at"`
+	CreatedAtLTE sql.NullTime  `param:"created_at__lte" db:"created_at"`
+
+	Page  int64 `param:"page"`
+	Limit int64 `param:"limit"`
+
+	// SortBy default to -created_at, newest first
+	SortBy []string `param:"sortBy"`
+}
+
+// newAuditLog record the mutation, actor and request id is taken from ctx
+func newAuditLog(ctx context.Context, m mutation) AuditLog {
+	actor, _ := ctx.Value(ctxkey.XActor).(string)
+	if actor == "" {
+		actor = ActorAnonymous
+	}
+	requestID, _ := ctx.Value(ctxkey.XRequestID).(string)
+
+	userID := m.after.ID
+	if userID == "" {
+		userID = m.before.ID
+	}
+
+	changes := diffUser(m.before, m.after)
+	if m.action == AuditActionErase {
+		redactChanges(changes)
+	}
+
+	return AuditLog{
+		ID:        uuid.New().String(),
+		UserID:    userID,
+		Action:    m.action,
+		Actor:     actor,
+		RequestID: requestID,
+		Reason:    m.reason,
+		Changes:   changes,
+		CreatedAt: time.Now(),
+	}
+}
+
+// diffUser return changed field in json name, id, created_at and version is excluded
+func diffUser(before, after User) []AuditChange {
+	changes := []AuditChange{}
+	add := func(field string, from, to interface{}) {
+		if from != to {
+			changes = append(changes, AuditChange{Field: field, From: from, To: to})
+		}
+	}
+
+	add("name", before.Name, after.Name)
+	add("phone", before.Phone, after.Phone)
+	add("email", before.Email, after.Email)
+	add("status", auditStatus(before), auditStatus(after))
+	add("deleted_at", auditTime(before.DeletedAt), auditTime(after.DeletedAt))
+	add("suspended_until", auditTime(before.SuspendedUntil), auditTime(after.SuspendedUntil))
+	add("suspension_reason", before.SuspensionReason, after.SuspensionReason)
+	add("erased_at", auditTime(before.ErasedAt), auditTime(after.ErasedAt))
+	add("merged_into", before.MergedInto, after.MergedInto)
+	add("email_verified_at", auditTime(before.EmailVerifiedAt), auditTime(after.EmailVerifiedAt))
+	add("phone_verified_at", auditTime(before.PhoneVerifiedAt), auditTime(after.PhoneVerifiedAt))
+
+	return changes
+}
+
+// AuditRedacted replace value of personal data in audit log of erased user
+const AuditRedacted = "[redacted]"
+
+// redactChanges replace non empty value of name, phone and email with AuditRedacted, return true when any is replaced
+func redactChanges(changes []AuditChange) bool {
+	redact := func(v *interface{}) bool {
+		if *v == nil || *v == "" || *v == AuditRedacted {
+			return false
+		}
+		*v = AuditRedacted
+		return true
+	}
+
+	var redacted bool
+	for i := range changes {
+		switch changes[i].Field {
+		case "name", "phone", "email":
+			from, to := redact(&changes[i].From), redact(&changes[i].To)
+			redacted = redacted || from || to
+		}
+	}
+	return redacted
+}
+
+// auditStatus return status name, nil when the user doesn't exist, i.e. before create and after purge
+func auditStatus(u User) interface{} {
+	if u.ID == "" {
+		return nil
+	}
+	return u.Status.String()
+}
+
+func auditTime(t *time.Time) interface{} {
+	if t == nil {
+		return nil
+	}
+	return t.UTC().Format(time.RFC3339Nano)
+}
+
+// GetUserHistory return audit log of the user, including purged user
+func (s *service) GetUserHistory(ctx context.Context, id string, p GetAuditLogParam) ([]AuditLog, entity.Pagination, error) {
+	if len(p.SortBy) == 0 {
+		p.SortBy = []string{"-created_at"}
+	}
+	return s.repo.FindAuditLog(ctx, id, p)
+}
@@ -0,0 +1,141 @@
+package user
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tuingking/supersvc/pkg/ctxkey"
+)
+
+func Test_DiffUser(t *testing.T) {
+	until := time.Date(2024, 02, 01, 0, 0, 0, 0, time.UTC)
+
+	t.Run("create", func(t *testing.T) {
+		assert.Equal(t, []AuditChange{
+			{Field: "name", From: "", To: "foo"},
+			{Field: "phone", From: "", To: "+6281234567890"},
+			{Field: "email", From: "", To: "foo@bar.com"},
+			{Field: "status", From: nil, To: "active"},
+		}, diffUser(User{}, stubUser))
+	})
+
+	t.Run("suspend", func(t *testing.T) {
+		after := stubUser
+		after.Status, after.SuspendedUntil, after.SuspensionReason = UserStatusSuspend, &until, "spam"
+
+		assert.Equal(t, []AuditChange{
+			{Field: "status", From: "active", To: "suspended"},
+			{Field: "suspended_until", From: nil, To: "2024-02-01T00:00:00Z"},
+			{Field: "suspension_reason", From: "", To: "spam"},
+		}, diffUser(stubUser, after))
+	})
+
+	t.Run("no change", func(t *testing.T) {
+		after := stubUser
+		after.Version++
+		assert.Equal(t, []AuditChange{}, diffUser(stubUser, after))
+	})
+}
+
+func Test_NewAuditLog(t *testing.T) {
+	ctx := context.WithValue(tenantCtx, ctxkey.XActor, "admin@foo.com")
+	ctx = context.WithValue(ctx, ctxkey.XRequestID, "req-1")
+
+	log := newAuditLog(ctx, mutation{action: AuditActionPurge, before: stubUser})
+	assert.NotEmpty(t, log.ID)
+	assert.Equal(t, "u1", log.UserID)
+	assert.Equal(t, "admin@foo.com", log.Actor)
+	assert.Equal(t, "req-1", log.RequestID)
+	assert.Len(t, log.Changes, 4)
+
+	log = newAuditLog(tenantCtx, mutation{action: AuditActionCreate, after: stubUser})
+	assert.Equal(t, ActorAnonymous, log.Actor)
+	assert.Empty(t, log.RequestID)
+}
+
+func Test_Service_AuditLog(t *testing.T) {
+	ctx := context.WithValue(tenantCtx, ctxkey.XActor, "admin@foo.com")
+
+	actions := func(logs []AuditLog) []AuditAction {
+		var v []AuditAction
+		for _, l := range logs {
+			v = append(v, l.Action)
+		}
+		return v
+	}
+
+	t.Run("every mutation is audited", func(t *testing.T) {
+		repo := newTestRepository()
+		svc := NewService(Option{}, repo)
+
+		usr, err := svc.CreateUser(ctx, User{Name: "foo", Email: "foo@bar.com"})
+		assert.Nil(t, err)
+		usr, err = svc.UpdateUser(ctx, usr.ID, usr.Version, UpdateUser{Name: "bar", Email: "bar@foo.com"})
+		assert.Nil(t, err)
+		usr, err = svc.ChangeStatus(ctx, usr.ID, UserStatusBanned, "fraud")
+		assert.Nil(t, err)
+		assert.Nil(t, svc.DeleteUser(ctx, usr.ID, usr.Version))
+		_, err = svc.RestoreUser(ctx, usr.ID)
+		assert.Nil(t, err)
+
+		logs, _, err := svc.GetUserHistory(ctx, usr.ID, GetAuditLogParam{})
+		assert.Nil(t, err)
+		assert.Equal(t, []AuditAction{
+			AuditActionRestore,
+			AuditActionDelete,
+			AuditActionStatusChange,
+			AuditActionUpdate,
+			AuditActionCreate,
+		}, actions(logs), "newest first")
+
+		assert.Equal(t, "admin@foo.com", logs[2].Actor)
+		assert.Equal(t, "fraud", logs[2].Reason)
+		assert.Equal(t, []AuditChange{{Field: "status", From: "inactive", To: "banned"}}, logs[2].Changes)
+		assert.Equal(t, []AuditChange{
+			{Field: "name", From: "foo", To: "bar"},
+			{Field: "email", From: "foo@bar.com", To: "bar@foo.com"},
+		}, logs[3].Changes)
+	})
+
+	t.Run("history is kept after purge", func(t *testing.T) {
+		deleted := stubUser
+		deletedAt := time.Now()
+		deleted.DeletedAt = &deletedAt
+
+		repo := newTestRepository(deleted)
+		svc := NewService(Option{}, repo)
+
+		assert.Nil(t, svc.PurgeUser(ctx, deleted.ID))
+
+		logs, _, err := svc.GetUserHistory(ctx, deleted.ID, GetAuditLogParam{})
+		assert.Nil(t, err)
+		assert.Equal(t, []AuditAction{AuditActionPurge}, actions(logs))
+	})
+
+	t.Run("failed mutation is not audited", func(t *testing.T) {
+		repo := newTestRepository(stubUser)
+		svc := NewService(Option{}, repo)
+
+		_, err := svc.UpdateUser(ctx, stubUser.ID, stubUser.Version+1, UpdateUser{Name: "bar", Email: "bar@foo.com"})
+		assert.ErrorIs(t, err, ErrVersionConflict)
+		assert.ErrorIs(t, svc.PurgeUser(ctx, stubUser.ID), ErrNotFound)
+		assert.Empty(t, repo.rows().auditLogs)
+	})
+
+	t.Run("lifted suspension", func(t *testing.T) {
+		expired := time.Now().Add(-time.Minute)
+		suspended := stubUser
+		suspended.Status, suspended.SuspendedUntil = UserStatusSuspend, &expired
+
+		repo := newTestRepository(suspended)
+		svc := NewService(Option{}, repo)
+
+		_, err := svc.LiftExpiredSuspension(context.WithValue(tenantCtx, ctxkey.XActor, ActorSweeper))
+		assert.Nil(t, err)
+		assert.Len(t, repo.rows().auditLogs, 1)
+		assert.Equal(t, ActorSweeper, repo.rows().auditLogs[0].Actor)
+		assert.Equal(t, "suspension expired", repo.rows().auditLogs[0].Reason)
+	})
+}
@@ -0,0 +1,421 @@
+package user
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"hash/fnv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+
+	"github.com/tuingking/supersvc/pkg/cache"
+	"github.com/tuingking/supersvc/pkg/logger"
+)
+
+const (
+	defaultCacheTTL         = 5 * time.Minute
+	defaultCacheNegativeTTL = 30 * time.Second
+
+	// cacheGenerations is number of invalidation counter, key is hashed to one of them
+	cacheGenerations = 256
+)
+
+type CacheOption struct {
+	// TTL is how long found user is cached, default to 5 minute
+	TTL time.Duration
+
+	// NegativeTTL is how long not found user is cached, default to 30 second
+	NegativeTTL time.Duration
+}
+
+func (o CacheOption) ttl() time.Duration {
+	if o.TTL <= 0 {
+		return defaultCacheTTL
+	}
+	return o.TTL
+}
+
+func (o CacheOption) negativeTTL() time.Duration {
+	if o.NegativeTTL <= 0 {
+		return defaultCacheNegativeTTL
+	}
+	return o.NegativeTTL
+}
+
+// cachedUser is cached FindByID, User is nil when the user is not found
+type cachedUser struct {
+	User *User `json:"user,omitempty"`
+}
+
+// cachedEmail is cached FindByEmail, ID is empty when no user has the email.
+// the user itself is cached by id, so both lookup is invalidated by the id.
+type cachedEmail struct {
+	ID string `json:"id,omitempty"`
+}
+
+// cache key is prefixed with the tenant, so user of other tenant is never found in the cache
+func userCacheKey(tenantID, id string) string {
+	return "user:" + tenantID + ":id:" + id
+}
+
+func emailCacheKey(tenantID, email string) string {
+	return "user:" + tenantID + ":email:" + strings.ToLower(email)
+}
+
+type cacheTxKey struct{}
+
+// cacheTx collect key invalidated in Tx, they are invalidated again when the transaction ends
+type cacheTx struct {
+	mu   sync.Mutex
+	keys []string
+}
+
+func (tx *cacheTx) add(keys ...string) {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+	tx.keys = append(tx.keys, keys...)
+}
+
+func inCacheTx(ctx context.Context) bool {
+	_, ok := ctx.Value(cacheTxKey{}).(*cacheTx)
+	return ok
+}
+
+// cachedRepository cache FindByID and FindByEmail of not deleted user, including not found result.
+// key of the changed user is invalidated by every mutation, other method is not cached.
+type cachedRepository struct {
+	Repository
+	opt   CacheOption
+	cache cache.Cache
+	group singleflight.Group
+
+	// generations is incremented on invalidation, result loaded across it is stale and is not cached
+	generations [cacheGenerations]atomic.Uint64
+}
+
+// NewCachedRepository decorate repo with cache c.
+// concurrent lookup of the same key is loaded from repo once, cache error is logged and the lookup falls back to repo.
+// lookup in Tx is not cached so it reads its own change, the changed key is invalidated again when the Tx ends.
+func NewCachedRepository(opt CacheOption, repo Repository, c cache.Cache) Repository {
+	return &cachedRepository{
+		Repository: repo,
+		opt:        opt,
+		cache:      c,
+	}
+}
+
+func (r *cachedRepository) FindByID(ctx context.Context, id string, opts ...FindOption) (User, error) {
+	tenantID, err := ctxTenant(ctx)
+	if err != nil {
+		return User{}, err
+	}
+	if inCacheTx(ctx) {
+		return r.Repository.FindByID(ctx, id, opts...)
+	}
+
+	usr, err := r.findByID(ctx, tenantID, id)
+	if errors.Is(err, ErrNotFound) && newFindOption(opts...).withDeleted {
+		// deleted user is not cached
+		return r.Repository.FindByID(ctx, id, opts...)
+	}
+	return usr, err
+}
+
+func (r *cachedRepository) findByID(ctx context.Context, tenantID, id string) (User, error) {
+	key := userCacheKey(tenantID, id)
+
+	var entry cachedUser
+	if r.get(ctx, key, &entry) {
+		if entry.User == nil {
+			return User{}, ErrNotFound
+		}
+		return *entry.User, nil
+	}
+
+	v, err := r.load(ctx, key, func(ctx context.Context) (interface{}, interface{}, error) {
+		usr, err := r.Repository.FindByID(ctx, id)
+		if err != nil {
+			return usr, cachedUser{}, err
+		}
+		return usr, cachedUser{User: &usr}, nil
+	})
+	usr, _ := v.(User)
+	return usr, err
+}
+
+func (r *cachedRepository) FindByEmail(ctx context.Context, email string) (User, error) {
+	tenantID, err := ctxTenant(ctx)
+	if err != nil {
+		return User{}, err
+	}
+	if inCacheTx(ctx) {
+		return r.Repository.FindByEmail(ctx, email)
+	}
+
+	key := emailCacheKey(tenantID, email)
+
+	var entry cachedEmail
+	if r.get(ctx, key, &entry) {
+		if entry.ID == "" {
+			return User{}, ErrNotFound
+		}
+		// the user may be deleted or its email is changed after the email is cached
+		if usr, err := r.findByID(ctx, tenantID, entry.ID); err == nil && strings.EqualFold(usr.Email, email) {
+			return usr, nil
+		}
+	}
+
+	v, err := r.load(ctx, key, func(ctx context.Context) (interface{}, interface{}, error) {
+		usr, err := r.Repository.FindByEmail(ctx, email)
+		if err != nil {
+			return usr, cachedEmail{}, err
+		}
+		return usr, cachedEmail{ID: usr.ID}, nil
+	})
+	usr, _ := v.(User)
+	return usr, err
+}
+
+// load call fn once for concurrent caller of the key, fn return the result and its cache entry.
+// the entry is cached when fn succeed, or with negative ttl when it return ErrNotFound.
+// fn is not canceled by the first caller, other caller is waiting for the same result.
+func (r *cachedRepository) load(ctx context.Context, key string, fn func(ctx context.Context) (interface{}, interface{}, error)) (interface{}, error) {
+	v, err, _ := r.group.Do(key, func() (interface{}, error) {
+		ctx := context.WithoutCancel(ctx)
+		gen := r.generation(key).Load()
+
+		v, entry, err := fn(ctx)
+		switch {
+		case err == nil:
+			r.set(ctx, key, gen, entry, r.opt.ttl())
+		case errors.Is(err, ErrNotFound):
+			r.set(ctx, key, gen, entry, r.opt.negativeTTL())
+		}
+		return v, err
+	})
+	return v, err
+}
+
+// get decode cached entry of the key into v, it return false when the key is not cached
+func (r *cachedRepository) get(ctx context.Context, key string, v interface{}) bool {
+	log := logger.Get(ctx)
+
+	data, err := r.cache.Get(ctx, key)
+	if errors.Is(err, cache.ErrMiss) {
+		return false
+	}
+	if err != nil {
+		log.Warn().Err(err).Str("key", key).Msg("failed: cache.Get")
+		return false
+	}
+
+	if err := json.Unmarshal(data, v); err != nil {
+		log.Warn().Err(err).Str("key", key).Msg("failed: decode cached user")
+		return false
+	}
+	return true
+}
+
+// set cache v unless the key is invalidated since gen is read.
+// invalidation racing with the write is detected after it, and the written entry is deleted.
+func (r *cachedRepository) set(ctx context.Context, key string, gen uint64, v interface{}, ttl time.Duration) {
+	log := logger.Get(ctx)
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		log.Err(err).Str("key", key).Msg("failed: encode cached user")
+		return
+	}
+
+	if r.generation(key).Load() != gen {
+		return
+	}
+	if err := r.cache.Set(ctx, key, data, ttl); err != nil {
+		log.Warn().Err(err).Str("key", key).Msg("failed: cache.Set")
+		return
+	}
+	if r.generation(key).Load() != gen {
+		r.delete(ctx, key)
+	}
+}
+
+// invalidate delete the keys, in Tx they are deleted again when the transaction ends
+func (r *cachedRepository) invalidate(ctx context.Context, keys ...string) {
+	for _, key := range keys {
+		r.generation(key).Add(1)
+		r.group.Forget(key)
+	}
+	r.delete(ctx, keys...)
+
+	if tx, ok := ctx.Value(cacheTxKey{}).(*cacheTx); ok {
+		tx.add(keys...)
+	}
+}
+
+func (r *cachedRepository) delete(ctx context.Context, keys ...string) {
+	log := logger.Get(ctx)
+
+	if len(keys) == 0 {
+		return
+	}
+	// the change is already made, so the key is deleted even when the caller is gone
+	if err := r.cache.Delete(context.WithoutCancel(ctx), keys...); err != nil {
+		log.Err(err).Strs("keys", keys).Msg("failed: cache.Delete, stale user is served until it expires")
+	}
+}
+
+func (r *cachedRepository) generation(key string) *atomic.Uint64 {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return &r.generations[h.Sum32()%cacheGenerations]
+}
+
+func (r *cachedRepository) Tx(ctx context.Context, fn func(ctx context.Context) error) error {
+	if inCacheTx(ctx) {
+		return r.Repository.Tx(ctx, fn)
+	}
+
+	tx := &cacheTx{}
+	err := r.Repository.Tx(context.WithValue(ctx, cacheTxKey{}, tx), fn)
+
+	// lookup outside the Tx may cache the row before the commit, invalidate the key again after it
+	r.invalidate(ctx, tx.keys...)
+	return err
+}
+
+func (r *cachedRepository) Create(ctx context.Context, v User) error {
+	tenantID, err := ctxTenant(ctx)
+	if err != nil {
+		return err
+	}
+	if err := r.Repository.Create(ctx, v); err != nil {
+		return err
+	}
+
+	r.invalidate(ctx, userCacheKey(tenantID, v.ID), emailCacheKey(tenantID, v.Email))
+	return nil
+}
+
+func (r *cachedRepository) CreateBatch(ctx context.Context, users []User) error {
+	tenantID, err := ctxTenant(ctx)
+	if err != nil {
+		return err
+	}
+	if err := r.Repository.CreateBatch(ctx, users); err != nil {
+		return err
+	}
+
+	keys := make([]string, 0, 2*len(users))
+	for _, v := range users {
+		keys = append(keys, userCacheKey(tenantID, v.ID), emailCacheKey(tenantID, v.Email))
+	}
+	r.invalidate(ctx, keys...)
+	return nil
+}
+
+// Update invalidate the new email, the old email is checked against the user when it is found in the cache
+func (r *cachedRepository) Update(ctx context.Context, v User) error {
+	tenantID, err := ctxTenant(ctx)
+	if err != nil {
+		return err
+	}
+	if err := r.Repository.Update(ctx, v); err != nil {
+		return err
+	}
+
+	r.invalidate(ctx, userCacheKey(tenantID, v.ID), emailCacheKey(tenantID, v.Email))
+	return nil
+}
+
+func (r *cachedRepository) Delete(ctx context.Context, id string, version int64) error {
+	tenantID, err := ctxTenant(ctx)
+	if err != nil {
+		return err
+	}
+	if err := r.Repository.Delete(ctx, id, version); err != nil {
+		return err
+	}
+
+	r.invalidate(ctx, userCacheKey(tenantID, id))
+	return nil
+}
+
+// Restore invalidate the email of the restored user too, it may be cached as not found
+func (r *cachedRepository) Restore(ctx context.Context, id string) error {
+	tenantID, err := ctxTenant(ctx)
+	if err != nil {
+		return err
+	}
+	if err := r.Repository.Restore(ctx, id); err != nil {
+		return err
+	}
+
+	keys := []string{userCacheKey(tenantID, id)}
+	if usr, err := r.Repository.FindByID(ctx, id, WithDeleted()); err == nil {
+		keys = append(keys, emailCacheKey(tenantID, usr.Email))
+	}
+	r.invalidate(ctx, keys...)
+	return nil
+}
+
+func (r *cachedRepository) Purge(ctx context.Context, id string) error {
+	tenantID, err := ctxTenant(ctx)
+	if err != nil {
+		return err
+	}
+	if err := r.Repository.Purge(ctx, id); err != nil {
+		return err
+	}
+
+	r.invalidate(ctx, userCacheKey(tenantID, id))
+	return nil
+}
+
+func (r *cachedRepository) Erase(ctx context.Context, v User) error {
+	tenantID, err := ctxTenant(ctx)
+	if err != nil {
+		return err
+	}
+	if err := r.Repository.Erase(ctx, v); err != nil {
+		return err
+	}
+
+	r.invalidate(ctx, userCacheKey(tenantID, v.ID), emailCacheKey(tenantID, v.Email))
+	return nil
+}
+
+// Merge invalidate v only, user merged into v before is already deleted so it is not cached
+func (r *cachedRepository) Merge(ctx context.Context, v User) error {
+	tenantID, err := ctxTenant(ctx)
+	if err != nil {
+		return err
+	}
+	if err := r.Repository.Merge(ctx, v); err != nil {
+		return err
+	}
+
+	r.invalidate(ctx, userCacheKey(tenantID, v.ID))
+	return nil
+}
+
+func (r *cachedRepository) LiftExpiredSuspension(ctx context.Context, now time.Time, limit int) ([]User, error) {
+	tenantID, err := ctxTenant(ctx)
+	if err != nil {
+		return nil, err
+	}
+	users, err := r.Repository.LiftExpiredSuspension(ctx, now, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([]string, 0, len(users))
+	for _, usr := range users {
+		keys = append(keys, userCacheKey(tenantID, usr.ID))
+	}
+	r.invalidate(ctx, keys...)
+	return users, nil
+}
@@ -0,0 +1,273 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tuingking/supersvc/pkg/cache"
+	"github.com/tuingking/supersvc/pkg/tenant"
+)
+
+// countingRepository count lookup reaching the repository, onFind is called after the user is read by FindByID
+type countingRepository struct {
+	Repository
+	findByID    atomic.Int32
+	findByEmail atomic.Int32
+	onFind      func()
+}
+
+func (r *countingRepository) FindByID(ctx context.Context, id string, opts ...FindOption) (User, error) {
+	r.findByID.Add(1)
+	usr, err := r.Repository.FindByID(ctx, id, opts...)
+	if r.onFind != nil {
+		r.onFind()
+	}
+	return usr, err
+}
+
+func (r *countingRepository) FindByEmail(ctx context.Context, email string) (User, error) {
+	r.findByEmail.Add(1)
+	return r.Repository.FindByEmail(ctx, email)
+}
+
+// ttlCache record ttl of the last Set of every key
+type ttlCache struct {
+	cache.Cache
+	mu   sync.Mutex
+	ttls map[string]time.Duration
+}
+
+func (c *ttlCache) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	c.mu.Lock()
+	c.ttls[key] = ttl
+	c.mu.Unlock()
+	return c.Cache.Set(ctx, key, value, ttl)
+}
+
+// failingCache is cache which is down
+type failingCache struct{}
+
+func (failingCache) Get(ctx context.Context, key string) ([]byte, error) {
+	return nil, errors.New("connection refused")
+}
+
+func (failingCache) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	return errors.New("connection refused")
+}
+
+func (failingCache) Delete(ctx context.Context, keys ...string) error {
+	return errors.New("connection refused")
+}
+
+func newTestCachedRepository(t *testing.T, c cache.Cache) (Repository, *countingRepository) {
+	counting := &countingRepository{Repository: NewMemoryRepository(nil)}
+	assert.Nil(t, counting.Create(tenant.WithID(context.Background(), stubTenant), testCacheUser))
+	return NewCachedRepository(CacheOption{}, counting, c), counting
+}
+
+var testCacheUser = User{ID: "u1", Name: "foo", Email: "foo@bar.com", Status: UserStatusActive, CreatedAt: time.Date(2024, 01, 01, 0, 0, 0, 0, time.UTC), Version: 1}
+
+func Test_CachedRepository_Conformance(t *testing.T) {
+	testRepositoryConformance(t, func(t *testing.T) Repository {
+		return NewCachedRepository(CacheOption{}, NewMemoryRepository(nil), cache.NewLRU(cache.LRUOption{}))
+	})
+}
+
+func Test_CachedRepository_FindByID(t *testing.T) {
+	ctx := tenant.WithID(context.Background(), stubTenant)
+	repo, counting := newTestCachedRepository(t, cache.NewLRU(cache.LRUOption{}))
+
+	for i := 0; i < 2; i++ {
+		got, err := repo.FindByID(ctx, "u1")
+		assert.Nil(t, err)
+		assert.Equal(t, testCacheUser, got)
+	}
+	assert.Equal(t, int32(1), counting.findByID.Load())
+
+	// not found is cached until the user is created
+	for i := 0; i < 2; i++ {
+		_, err := repo.FindByID(ctx, "u2")
+		assert.ErrorIs(t, err, ErrNotFound)
+	}
+	assert.Equal(t, int32(2), counting.findByID.Load())
+	assert.Nil(t, repo.Create(ctx, User{ID: "u2", Name: "bar", Email: "bar@bar.com", Version: 1}))
+	_, err := repo.FindByID(ctx, "u2")
+	assert.Nil(t, err)
+
+	assert.Nil(t, repo.Update(ctx, User{ID: "u1", Name: "baz", Email: "foo@bar.com", Version: 1}))
+	got, err := repo.FindByID(ctx, "u1")
+	assert.Nil(t, err)
+	assert.Equal(t, "baz", got.Name)
+	assert.Equal(t, int64(2), got.Version)
+
+	assert.Nil(t, repo.Delete(ctx, "u1", 2))
+	_, err = repo.FindByID(ctx, "u1")
+	assert.ErrorIs(t, err, ErrNotFound)
+	got, err = repo.FindByID(ctx, "u1", WithDeleted())
+	assert.Nil(t, err, "deleted user is read from the repository")
+	assert.NotNil(t, got.DeletedAt)
+}
+
+func Test_CachedRepository_FindByEmail(t *testing.T) {
+	ctx := tenant.WithID(context.Background(), stubTenant)
+	repo, counting := newTestCachedRepository(t, cache.NewLRU(cache.LRUOption{}))
+
+	for _, email := range []string{"foo@bar.com", "FOO@bar.com"} {
+		got, err := repo.FindByEmail(ctx, email)
+		assert.Nil(t, err)
+		assert.Equal(t, testCacheUser, got)
+	}
+	assert.Equal(t, int32(1), counting.findByEmail.Load(), "email is case insensitive")
+
+	// old email is no longer found after it is changed
+	assert.Nil(t, repo.Update(ctx, User{ID: "u1", Name: "foo", Email: "baz@bar.com", Version: 1}))
+	_, err := repo.FindByEmail(ctx, "foo@bar.com")
+	assert.ErrorIs(t, err, ErrNotFound)
+	got, err := repo.FindByEmail(ctx, "baz@bar.com")
+	assert.Nil(t, err)
+	assert.Equal(t, "u1", got.ID)
+
+	// not found is cached until the email is used
+	for i := 0; i < 2; i++ {
+		_, err := repo.FindByEmail(ctx, "new@bar.com")
+		assert.ErrorIs(t, err, ErrNotFound)
+	}
+	calls := counting.findByEmail.Load()
+	_, _ = repo.FindByEmail(ctx, "new@bar.com")
+	assert.Equal(t, calls, counting.findByEmail.Load())
+	assert.Nil(t, repo.Create(ctx, User{ID: "u2", Name: "new", Email: "new@bar.com", Version: 1}))
+	_, err = repo.FindByEmail(ctx, "new@bar.com")
+	assert.Nil(t, err)
+
+	// restored user is found by the email which is cached as not found while it is deleted
+	assert.Nil(t, repo.Delete(ctx, "u1", 2))
+	_, err = repo.FindByEmail(ctx, "baz@bar.com")
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.Nil(t, repo.Restore(ctx, "u1"))
+	got, err = repo.FindByEmail(ctx, "baz@bar.com")
+	assert.Nil(t, err)
+	assert.Nil(t, got.DeletedAt)
+}
+
+func Test_CachedRepository_TTL(t *testing.T) {
+	ctx := tenant.WithID(context.Background(), stubTenant)
+	c := &ttlCache{Cache: cache.NewLRU(cache.LRUOption{}), ttls: make(map[string]time.Duration)}
+	counting := &countingRepository{Repository: NewMemoryRepository(nil)}
+	assert.Nil(t, counting.Create(ctx, testCacheUser))
+
+	repo := NewCachedRepository(CacheOption{}, counting, c)
+	_, _ = repo.FindByID(ctx, "u1")
+	_, _ = repo.FindByID(ctx, "u2")
+	assert.Equal(t, defaultCacheTTL, c.ttls[userCacheKey(stubTenant, "u1")])
+	assert.Equal(t, defaultCacheNegativeTTL, c.ttls[userCacheKey(stubTenant, "u2")])
+
+	repo = NewCachedRepository(CacheOption{TTL: time.Hour, NegativeTTL: time.Minute}, counting, c)
+	_, _ = repo.FindByEmail(ctx, "foo@bar.com")
+	_, _ = repo.FindByEmail(ctx, "bar@bar.com")
+	assert.Equal(t, time.Hour, c.ttls[emailCacheKey(stubTenant, "foo@bar.com")])
+	assert.Equal(t, time.Minute, c.ttls[emailCacheKey(stubTenant, "bar@bar.com")])
+}
+
+func Test_CachedRepository_Stampede(t *testing.T) {
+	ctx := tenant.WithID(context.Background(), stubTenant)
+	repo, counting := newTestCachedRepository(t, cache.NewLRU(cache.LRUOption{}))
+	release := make(chan struct{})
+	counting.onFind = func() { <-release }
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			got, err := repo.FindByID(ctx, "u1")
+			assert.Nil(t, err)
+			assert.Equal(t, "u1", got.ID)
+		}()
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	assert.Equal(t, int32(1), counting.findByID.Load(), "concurrent miss is loaded once")
+}
+
+func Test_CachedRepository_StaleLoad(t *testing.T) {
+	ctx := tenant.WithID(context.Background(), stubTenant)
+	repo, counting := newTestCachedRepository(t, cache.NewLRU(cache.LRUOption{}))
+
+	loading, release := make(chan struct{}), make(chan struct{})
+	var once sync.Once
+	counting.onFind = func() {
+		once.Do(func() {
+			close(loading)
+			<-release
+		})
+	}
+
+	// the user is read, then updated before the read result is cached
+	done := make(chan User)
+	go func() {
+		usr, _ := repo.FindByID(ctx, "u1")
+		done <- usr
+	}()
+	<-loading
+	assert.Nil(t, repo.Update(ctx, User{ID: "u1", Name: "bar", Email: "foo@bar.com", Version: 1}))
+	close(release)
+	assert.Equal(t, int64(1), (<-done).Version)
+
+	got, err := repo.FindByID(ctx, "u1")
+	assert.Nil(t, err)
+	assert.Equal(t, int64(2), got.Version, "user read before the update is not cached")
+}
+
+func Test_CachedRepository_Tx(t *testing.T) {
+	ctx := tenant.WithID(context.Background(), stubTenant)
+	c := cache.NewLRU(cache.LRUOption{})
+	repo, _ := newTestCachedRepository(t, c)
+	_, _ = repo.FindByID(ctx, "u1")
+
+	err := repo.Tx(ctx, func(ctx context.Context) error {
+		assert.Nil(t, repo.Update(ctx, User{ID: "u1", Name: "bar", Email: "foo@bar.com", Version: 1}))
+		got, err := repo.FindByID(ctx, "u1")
+		assert.Nil(t, err)
+		assert.Equal(t, int64(2), got.Version, "lookup in tx read its own change")
+
+		// other caller read the user before it is committed
+		assert.Nil(t, c.Set(ctx, userCacheKey(stubTenant, "u1"), []byte(`{"user":{"id":"u1","version":1}}`), time.Hour))
+		return nil
+	})
+	assert.Nil(t, err)
+
+	got, err := repo.FindByID(ctx, "u1")
+	assert.Nil(t, err)
+	assert.Equal(t, int64(2), got.Version, "key is invalidated again after commit")
+
+	err = repo.Tx(ctx, func(ctx context.Context) error {
+		assert.Nil(t, repo.Update(ctx, User{ID: "u1", Name: "baz", Email: "foo@bar.com", Version: 2}))
+		_, _ = repo.FindByID(ctx, "u1")
+		return errors.New("rollback")
+	})
+	assert.NotNil(t, err)
+
+	got, err = repo.FindByID(ctx, "u1")
+	assert.Nil(t, err)
+	assert.Equal(t, "bar", got.Name, "rolled back change is not cached")
+}
+
+func Test_CachedRepository_CacheDown(t *testing.T) {
+	ctx := tenant.WithID(context.Background(), stubTenant)
+	repo, counting := newTestCachedRepository(t, failingCache{})
+
+	for i := 0; i < 2; i++ {
+		got, err := repo.FindByID(ctx, "u1")
+		assert.Nil(t, err)
+		assert.Equal(t, testCacheUser, got)
+	}
+	assert.Equal(t, int32(2), counting.findByID.Load(), "lookup falls back to the repository")
+	assert.Nil(t, repo.Update(ctx, User{ID: "u1", Name: "bar", Email: "foo@bar.com", Version: 1}))
+}
@@ -0,0 +1,361 @@
+package user
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"time"
+	"unicode/utf8"
+
+	"github.com/pkg/errors"
+	"github.com/tuingking/supersvc/pkg/logger"
+	"github.com/tuingking/supersvc/pkg/notify"
+	"github.com/tuingking/supersvc/pkg/password"
+)
+
+const (
+	defaultMinPasswordLength = 8
+	defaultMaxFailedLogin    = 5
+	defaultLockoutDuration   = 15 * time.Minute
+	defaultResetTokenTTL     = time.Hour
+
+	// maxPasswordLength is the input limit of bcrypt, it is applied to every algorithm
+	maxPasswordLength = 72
+)
+
+type AuthOption struct {
+	Password password.Option
+
+	// MinPasswordLength default to 8
+	MinPasswordLength int
+
+	// MaxFailedLogin is number of consecutive failed login before the user is locked, default to 5
+	MaxFailedLogin int
+
+	// LockoutDuration default to 15 minute
+	LockoutDuration time.Duration
+
+	// ResetTokenTTL is how long password reset token is valid, default to 1 hour
+	ResetTokenTTL time.Duration
+}
+
+func (o AuthOption) minPasswordLength() int {
+	if o.MinPasswordLength <= 0 {
+		return defaultMinPasswordLength
+	}
+	return o.MinPasswordLength
+}
+
+func (o AuthOption) maxFailedLogin() int {
+	if o.MaxFailedLogin <= 0 {
+		return defaultMaxFailedLogin
+	}
+	return o.MaxFailedLogin
+}
+
+func (o AuthOption) lockoutDuration() time.Duration {
+	if o.LockoutDuration <= 0 {
+		return defaultLockoutDuration
+	}
+	return o.LockoutDuration
+}
+
+func (o AuthOption) resetTokenTTL() time.Duration {
+	if o.ResetTokenTTL <= 0 {
+		return defaultResetTokenTTL
+	}
+	return o.ResetTokenTTL
+}
+
+// Credential is password of the user, it is never returned by the API
+type Credential struct {
+	UserID            string
+	PasswordHash      string
+	FailedAttempts    int
+	LockedUntil       *time.Time
+	PasswordChangedAt time.Time
+}
+
+func (c Credential) lockedAt(now time.Time) bool {
+	return c.LockedUntil != nil && c.LockedUntil.After(now)
+}
+
+// PasswordReset is stored password reset token, only hash of the token is stored
+type PasswordReset struct {
+	TokenHash string
+	UserID    string
+	ExpiresAt time.Time
+	CreatedAt time.Time
+}
+
+func hashResetToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func passwordResetMessage(email, token string, ttl time.Duration) notify.Message {
+	body := fmt.Sprintf("Use this token to reset your password: %s. It expires in %s. Ignore this email if you didn't request it.", token, ttl)
+	return notify.Message{Channel: notify.ChannelEmail, To: email, Subject: "Reset your password", Body: body}
+}
+
+func (s *service) validatePassword(pw string) error {
+	var verr ValidationError
+	switch {
+	case utf8.RuneCountInString(pw) < s.opt.Auth.minPasswordLength():
+		verr.add("password", fmt.Sprintf("must be at least %d characters", s.opt.Auth.minPasswordLength()))
+	case len(pw) > maxPasswordLength:
+		verr.add("password", fmt.Sprintf("must not exceed %d bytes", maxPasswordLength))
+	}
+
+	if len(verr.Fields) > 0 {
+		return &verr
+	}
+	return nil
+}
+
+func (s *service) Authenticate(ctx context.Context, email, pw string) (User, error) {
+	log := logger.Get(ctx)
+
+	email, err := normalizeEmail(email)
+	if err != nil {
+		return User{}, ErrInvalidCredential
+	}
+
+	usr, err := s.repo.FindByEmail(ctx, email)
+	if errors.Is(err, ErrNotFound) {
+		// take about the same time as wrong password, so unknown email cannot be told apart
+		s.verifyDummy(pw)
+		return User{}, ErrInvalidCredential
+	}
+	if err != nil {
+		return User{}, err
+	}
+
+	cred, err := s.verifyPassword(ctx, usr.ID, pw)
+	if err != nil {
+		return User{}, err
+	}
+
+	if usr.Status != UserStatusActive {
+		return User{}, errors.Wrapf(ErrInvalidCredential, "user is %s", usr.Status)
+	}
+
+	if s.hasher.NeedsRehash(cred.PasswordHash) {
+		if hash, err := s.hasher.Hash(pw); err != nil {
+			log.Err(err).Msg("failed: rehash password")
+		} else if err := s.repo.UpgradePasswordHash(ctx, usr.ID, cred.PasswordHash, hash); err != nil {
+			log.Err(err).Msg("failed: upgrade password hash")
+		}
+	}
+
+	return usr, nil
+}
+
+// verifyPassword check pw against the credential of the user, failed attempt lock the user after AuthOption.MaxFailedLogin.
+// ErrInvalidCredential is returned when the user has no password or it doesn't match.
+func (s *service) verifyPassword(ctx context.Context, userID, pw string) (Credential, error) {
+	log := logger.Get(ctx)
+	now := time.Now()
+
+	cred, err := s.repo.FindCredential(ctx, userID)
+	if errors.Is(err, ErrNotFound) {
+		s.verifyDummy(pw)
+		return cred, ErrInvalidCredential
+	}
+	if err != nil {
+		return cred, err
+	}
+	if cred.lockedAt(now) {
+		return cred, errors.Wrapf(ErrAccountLocked, "locked until %s", cred.LockedUntil.Format(time.RFC3339))
+	}
+
+	err = s.hasher.Verify(pw, cred.PasswordHash)
+	if errors.Is(err, password.ErrMismatch) {
+		cred, err := s.repo.RecordLoginFailure(ctx, userID, s.opt.Auth.maxFailedLogin(), now.Add(s.opt.Auth.lockoutDuration()))
+		if err != nil {
+			return cred, err
+		}
+		if cred.lockedAt(now) {
+			log.Warn().Str("user_id", userID).Time("locked_until", *cred.LockedUntil).Msg("user locked after too many failed login")
+			return cred, errors.Wrapf(ErrAccountLocked, "locked until %s", cred.LockedUntil.Format(time.RFC3339))
+		}
+		return cred, ErrInvalidCredential
+	}
+	if err != nil {
+		log.Err(err).Str("user_id", userID).Msg("failed: verify password")
+		return cred, err
+	}
+
+	if cred.FailedAttempts > 0 || cred.LockedUntil != nil {
+		if err := s.repo.ResetLoginFailure(ctx, userID); err != nil {
+			return cred, err
+		}
+	}
+
+	return cred, nil
+}
+
+func (s *service) verifyDummy(pw string) {
+	s.dummyOnce.Do(func() {
+		s.dummyHash, _ = s.hasher.Hash("dummy password")
+	})
+	s.hasher.Verify(pw, s.dummyHash)
+}
+
+// VerifyAccess compare issuedAt in second, the precision of the token, so token issued in the second
+// of password change is kept, otherwise login right after password change would be rejected
+func (s *service) VerifyAccess(ctx context.Context, id string, issuedAt time.Time) (User, error) {
+	usr, err := s.repo.FindByID(ctx, id)
+	if errors.Is(err, ErrNotFound) {
+		return User{}, errors.Wrap(ErrAccessRevoked, "user is deleted")
+	}
+	if err != nil {
+		return User{}, err
+	}
+	if usr.Status != UserStatusActive {
+		return User{}, errors.Wrapf(ErrAccessRevoked, "user is %s", usr.Status)
+	}
+
+	cred, err := s.repo.FindCredential(ctx, id)
+	if errors.Is(err, ErrNotFound) {
+		return User{}, errors.Wrap(ErrAccessRevoked, "user has no password")
+	}
+	if err != nil {
+		return User{}, err
+	}
+	if issuedAt.Before(cred.PasswordChangedAt.Truncate(time.Second)) {
+		return User{}, errors.Wrap(ErrAccessRevoked, "password is changed")
+	}
+
+	return usr, nil
+}
+
+func (s *service) ChangePassword(ctx context.Context, id, current, pw string) error {
+	log := logger.Get(ctx)
+
+	if err := s.validatePassword(pw); err != nil {
+		return err
+	}
+
+	usr, err := s.repo.FindByID(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if _, err := s.verifyPassword(ctx, id, current); err != nil {
+		return err
+	}
+
+	hash, err := s.hasher.Hash(pw)
+	if err != nil {
+		log.Err(err).Msg("failed: hash password")
+		return err
+	}
+
+	save := func(ctx context.Context) error { return s.repo.SavePassword(ctx, id, hash, time.Now()) }
+	if err := s.mutate(ctx, save, mutation{action: AuditActionPasswordChange, before: usr, after: usr}); err != nil {
+		log.Err(err).Msg("failed: change password")
+		return err
+	}
+
+	log.Info().Str("user_id", id).Msg("password changed")
+
+	return nil
+}
+
+func (s *service) RequestPasswordReset(ctx context.Context, email string) error {
+	log := logger.Get(ctx)
+
+	email, err := normalizeEmail(email)
+	if err != nil {
+		return nil
+	}
+
+	usr, err := s.repo.FindByEmail(ctx, email)
+	if errors.Is(err, ErrNotFound) {
+		log.Debug().Msg("password reset of unknown email is ignored")
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		log.Err(err).Msg("failed: generate reset token")
+		return err
+	}
+
+	now := time.Now()
+	token := base64.RawURLEncoding.EncodeToString(b)
+	err = s.repo.CreatePasswordReset(ctx, PasswordReset{
+		TokenHash: hashResetToken(token),
+		UserID:    usr.ID,
+		ExpiresAt: now.Add(s.opt.Auth.resetTokenTTL()),
+		CreatedAt: now,
+	})
+	if err != nil {
+		log.Err(err).Msg("failed: create password reset")
+		return err
+	}
+
+	// failed delivery is not returned either, it would tell the email is registered
+	if err := s.notifier.Notify(ctx, passwordResetMessage(usr.Email, token, s.opt.Auth.resetTokenTTL())); err != nil {
+		log.Err(err).Str("user_id", usr.ID).Msg("failed: send password reset token")
+		return nil
+	}
+
+	log.Info().Str("user_id", usr.ID).Msg("password reset requested")
+
+	return nil
+}
+
+func (s *service) ResetPassword(ctx context.Context, token, pw string) error {
+	log := logger.Get(ctx)
+
+	if err := s.validatePassword(pw); err != nil {
+		return err
+	}
+
+	hash, err := s.hasher.Hash(pw)
+	if err != nil {
+		log.Err(err).Msg("failed: hash password")
+		return err
+	}
+
+	var userID string
+	err = s.repo.Tx(ctx, func(ctx context.Context) error {
+		id, err := s.repo.UsePasswordReset(ctx, hashResetToken(token), time.Now())
+		if errors.Is(err, ErrNotFound) {
+			return ErrInvalidResetToken
+		}
+		if err != nil {
+			return err
+		}
+		userID = id
+
+		usr, err := s.repo.FindByID(ctx, userID)
+		if errors.Is(err, ErrNotFound) {
+			return ErrInvalidResetToken
+		}
+		if err != nil {
+			return err
+		}
+
+		if err := s.repo.SavePassword(ctx, userID, hash, time.Now()); err != nil {
+			return err
+		}
+		return s.record(ctx, mutation{action: AuditActionPasswordReset, before: usr, after: usr})
+	})
+	if err != nil {
+		log.Err(err).Msg("failed: reset password")
+		return err
+	}
+
+	log.Info().Str("user_id", userID).Msg("password reset")
+
+	return nil
+}
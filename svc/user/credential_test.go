@@ -0,0 +1,320 @@
+package user
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tuingking/supersvc/pkg/notify"
+	"github.com/tuingking/supersvc/pkg/password"
+)
+
+// low cost option to keep the test fast
+var stubAuthOption = AuthOption{
+	Password:       password.Option{Argon2: password.Argon2Option{Time: 1, Memory: 1024, Threads: 1}},
+	MaxFailedLogin: 3,
+}
+
+// newCredentialService return service with stubUser which password is pw
+func newCredentialService(t *testing.T, opt AuthOption, pw string) (*service, *testRepository) {
+	repo := newTestRepository(stubUser)
+	svc := NewService(Option{Auth: opt}, repo).(*service)
+
+	hash, err := svc.hasher.Hash(pw)
+	assert.Nil(t, err)
+	assert.Nil(t, repo.SavePassword(tenantCtx, stubUser.ID, hash, time.Now()))
+
+	return svc, repo
+}
+
+func Test_Service_Authenticate(t *testing.T) {
+	ctx := tenantCtx
+
+	t.Run("success", func(t *testing.T) {
+		svc, _ := newCredentialService(t, stubAuthOption, "s3cret pass")
+
+		usr, err := svc.Authenticate(ctx, " FOO@bar.com ", "s3cret pass")
+		assert.Nil(t, err)
+		assert.Equal(t, stubUser, usr)
+	})
+
+	t.Run("invalid credential", func(t *testing.T) {
+		svc, repo := newCredentialService(t, stubAuthOption, "s3cret pass")
+
+		_, err := svc.Authenticate(ctx, "foo@bar.com", "wrong pass")
+		assert.ErrorIs(t, err, ErrInvalidCredential)
+		assert.Equal(t, 1, repo.rows().credentials[stubUser.ID].FailedAttempts)
+
+		_, err = svc.Authenticate(ctx, "unknown@bar.com", "s3cret pass")
+		assert.ErrorIs(t, err, ErrInvalidCredential)
+
+		_, err = svc.Authenticate(ctx, "not an email", "s3cret pass")
+		assert.ErrorIs(t, err, ErrInvalidCredential)
+
+		// success clear failed attempt
+		_, err = svc.Authenticate(ctx, "foo@bar.com", "s3cret pass")
+		assert.Nil(t, err)
+		assert.Equal(t, 0, repo.rows().credentials[stubUser.ID].FailedAttempts)
+	})
+
+	t.Run("no password", func(t *testing.T) {
+		repo := newTestRepository(stubUser)
+		svc := NewService(Option{Auth: stubAuthOption}, repo)
+
+		_, err := svc.Authenticate(ctx, "foo@bar.com", "s3cret pass")
+		assert.ErrorIs(t, err, ErrInvalidCredential)
+	})
+
+	t.Run("not active", func(t *testing.T) {
+		svc, repo := newCredentialService(t, stubAuthOption, "s3cret pass")
+		usr := repo.rows().users[stubUser.ID]
+		usr.Status = UserStatusBanned
+		repo.rows().users[stubUser.ID] = usr
+
+		_, err := svc.Authenticate(ctx, "foo@bar.com", "s3cret pass")
+		assert.ErrorIs(t, err, ErrInvalidCredential)
+	})
+
+	t.Run("lockout", func(t *testing.T) {
+		svc, repo := newCredentialService(t, stubAuthOption, "s3cret pass")
+
+		for i := 0; i < 2; i++ {
+			_, err := svc.Authenticate(ctx, "foo@bar.com", "wrong pass")
+			assert.ErrorIs(t, err, ErrInvalidCredential)
+		}
+		_, err := svc.Authenticate(ctx, "foo@bar.com", "wrong pass")
+		assert.ErrorIs(t, err, ErrAccountLocked)
+
+		// correct password is rejected while locked
+		_, err = svc.Authenticate(ctx, "foo@bar.com", "s3cret pass")
+		assert.ErrorIs(t, err, ErrAccountLocked)
+
+		cred := repo.rows().credentials[stubUser.ID]
+		assert.WithinDuration(t, time.Now().Add(defaultLockoutDuration), *cred.LockedUntil, time.Minute)
+
+		// lock expired
+		expired := time.Now().Add(-time.Second)
+		cred.LockedUntil = &expired
+		repo.rows().credentials[stubUser.ID] = cred
+
+		_, err = svc.Authenticate(ctx, "foo@bar.com", "s3cret pass")
+		assert.Nil(t, err)
+		assert.Nil(t, repo.rows().credentials[stubUser.ID].LockedUntil)
+	})
+
+	t.Run("upgrade hash", func(t *testing.T) {
+		old := AuthOption{Password: password.Option{Algorithm: password.AlgorithmBcrypt, Bcrypt: password.BcryptOption{Cost: 4}}}
+		_, repo := newCredentialService(t, old, "s3cret pass")
+		assert.True(t, strings.HasPrefix(repo.rows().credentials[stubUser.ID].PasswordHash, "$2a$"))
+
+		svc := NewService(Option{Auth: stubAuthOption}, repo)
+		_, err := svc.Authenticate(ctx, "foo@bar.com", "s3cret pass")
+		assert.Nil(t, err)
+		assert.True(t, strings.HasPrefix(repo.rows().credentials[stubUser.ID].PasswordHash, "$argon2id$"))
+
+		_, err = svc.Authenticate(ctx, "foo@bar.com", "s3cret pass")
+		assert.Nil(t, err)
+	})
+}
+
+func Test_Service_VerifyAccess(t *testing.T) {
+	ctx := tenantCtx
+	issuedAt := time.Now().Add(-time.Minute)
+
+	newAccessService := func(t *testing.T) (*service, *testRepository) {
+		svc, repo := newCredentialService(t, stubAuthOption, "s3cret pass")
+		cred := repo.rows().credentials[stubUser.ID]
+		assert.Nil(t, repo.SavePassword(ctx, stubUser.ID, cred.PasswordHash, issuedAt.Add(-time.Hour)))
+		return svc, repo
+	}
+
+	t.Run("password changed", func(t *testing.T) {
+		svc, _ := newAccessService(t)
+
+		usr, err := svc.VerifyAccess(ctx, stubUser.ID, issuedAt)
+		assert.Nil(t, err)
+		assert.Equal(t, stubUser, usr)
+
+		assert.Nil(t, svc.ChangePassword(ctx, stubUser.ID, "s3cret pass", "n3w s3cret"))
+		_, err = svc.VerifyAccess(ctx, stubUser.ID, issuedAt)
+		assert.ErrorIs(t, err, ErrAccessRevoked, "token issued before password change")
+
+		_, err = svc.VerifyAccess(ctx, stubUser.ID, time.Now().Truncate(time.Second))
+		assert.Nil(t, err, "token issued in the second of password change")
+	})
+
+	t.Run("not active", func(t *testing.T) {
+		svc, _ := newAccessService(t)
+
+		_, err := svc.ChangeStatus(ctx, stubUser.ID, UserStatusBanned, "fraud")
+		assert.Nil(t, err)
+		_, err = svc.VerifyAccess(ctx, stubUser.ID, issuedAt)
+		assert.ErrorIs(t, err, ErrAccessRevoked)
+	})
+
+	t.Run("deleted", func(t *testing.T) {
+		svc, _ := newAccessService(t)
+
+		assert.Nil(t, svc.DeleteUser(ctx, stubUser.ID, stubUser.Version))
+		_, err := svc.VerifyAccess(ctx, stubUser.ID, issuedAt)
+		assert.ErrorIs(t, err, ErrAccessRevoked)
+	})
+
+	t.Run("no password", func(t *testing.T) {
+		svc := NewService(Option{}, newTestRepository(stubUser))
+
+		_, err := svc.VerifyAccess(ctx, stubUser.ID, issuedAt)
+		assert.ErrorIs(t, err, ErrAccessRevoked)
+	})
+}
+
+func Test_Service_ChangePassword(t *testing.T) {
+	ctx := tenantCtx
+
+	t.Run("changed", func(t *testing.T) {
+		svc, repo := newCredentialService(t, stubAuthOption, "s3cret pass")
+
+		assert.Nil(t, svc.ChangePassword(ctx, stubUser.ID, "s3cret pass", "n3w s3cret"))
+
+		_, err := svc.Authenticate(ctx, "foo@bar.com", "s3cret pass")
+		assert.ErrorIs(t, err, ErrInvalidCredential)
+		_, err = svc.Authenticate(ctx, "foo@bar.com", "n3w s3cret")
+		assert.Nil(t, err)
+
+		assert.Len(t, repo.rows().auditLogs, 1)
+		assert.Equal(t, AuditActionPasswordChange, repo.rows().auditLogs[0].Action)
+		assert.Empty(t, repo.rows().auditLogs[0].Changes)
+		assert.Empty(t, repo.events())
+	})
+
+	t.Run("wrong current password", func(t *testing.T) {
+		svc, repo := newCredentialService(t, stubAuthOption, "s3cret pass")
+
+		err := svc.ChangePassword(ctx, stubUser.ID, "wrong pass", "n3w s3cret")
+		assert.ErrorIs(t, err, ErrInvalidCredential)
+		assert.Empty(t, repo.rows().auditLogs)
+	})
+
+	t.Run("invalid password", func(t *testing.T) {
+		svc, _ := newCredentialService(t, stubAuthOption, "s3cret pass")
+
+		for _, pw := range []string{"short", strings.Repeat("a", maxPasswordLength+1)} {
+			err := svc.ChangePassword(ctx, stubUser.ID, "s3cret pass", pw)
+			assert.ErrorIs(t, err, ErrValidation)
+		}
+	})
+}
+
+var resetTokenRegexp = regexp.MustCompile(`reset your password: ([\w-]+)`)
+
+// resetToken return the password reset token of the last message
+func (n *stubNotifier) resetToken() string {
+	if len(n.msgs) == 0 {
+		return ""
+	}
+	m := resetTokenRegexp.FindStringSubmatch(n.msgs[len(n.msgs)-1].Body)
+	if m == nil {
+		return ""
+	}
+	return m[1]
+}
+
+func Test_Service_ResetPassword(t *testing.T) {
+	ctx := tenantCtx
+
+	t.Run("reset", func(t *testing.T) {
+		repo, notifier := newTestRepository(stubUser), &stubNotifier{}
+		svc := NewService(Option{Auth: stubAuthOption}, repo, WithNotifier(notifier))
+
+		assert.Nil(t, svc.RequestPasswordReset(ctx, "foo@bar.com"))
+		assert.Len(t, notifier.msgs, 1)
+		assert.Equal(t, notify.ChannelEmail, notifier.msgs[0].Channel)
+		assert.Equal(t, stubUser.Email, notifier.msgs[0].To)
+		token := notifier.resetToken()
+		assert.NotEmpty(t, token)
+
+		// only hash of the token is stored
+		reset, ok := repo.rows().resets[hashResetToken(token)]
+		assert.True(t, ok)
+		assert.WithinDuration(t, time.Now().Add(defaultResetTokenTTL), reset.ExpiresAt, time.Minute)
+
+		assert.Nil(t, svc.ResetPassword(ctx, token, "n3w s3cret"))
+		_, err := svc.Authenticate(ctx, "foo@bar.com", "n3w s3cret")
+		assert.Nil(t, err)
+
+		assert.Len(t, repo.rows().auditLogs, 1)
+		assert.Equal(t, AuditActionPasswordReset, repo.rows().auditLogs[0].Action)
+
+		// single use
+		err = svc.ResetPassword(ctx, token, "an0ther s3cret")
+		assert.ErrorIs(t, err, ErrInvalidResetToken)
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		repo, notifier := newTestRepository(stubUser), &stubNotifier{}
+		svc := NewService(Option{Auth: AuthOption{Password: stubAuthOption.Password, ResetTokenTTL: time.Nanosecond}}, repo, WithNotifier(notifier))
+
+		assert.Nil(t, svc.RequestPasswordReset(ctx, "foo@bar.com"))
+
+		time.Sleep(time.Millisecond)
+		err := svc.ResetPassword(ctx, notifier.resetToken(), "n3w s3cret")
+		assert.ErrorIs(t, err, ErrInvalidResetToken)
+		assert.Empty(t, repo.rows().credentials)
+	})
+
+	t.Run("password change invalidate token", func(t *testing.T) {
+		svc, _ := newCredentialService(t, stubAuthOption, "s3cret pass")
+		notifier := &stubNotifier{}
+		svc.notifier = notifier
+
+		assert.Nil(t, svc.RequestPasswordReset(ctx, "foo@bar.com"))
+		assert.Nil(t, svc.ChangePassword(ctx, stubUser.ID, "s3cret pass", "n3w s3cret"))
+
+		err := svc.ResetPassword(ctx, notifier.resetToken(), "an0ther s3cret")
+		assert.ErrorIs(t, err, ErrInvalidResetToken)
+	})
+
+	t.Run("unknown email", func(t *testing.T) {
+		repo, notifier := newTestRepository(stubUser), &stubNotifier{}
+		svc := NewService(Option{Auth: stubAuthOption}, repo, WithNotifier(notifier))
+
+		for _, email := range []string{"unknown@bar.com", "invalid"} {
+			assert.Nil(t, svc.RequestPasswordReset(ctx, email), "unknown email cannot be told apart")
+		}
+		assert.Empty(t, notifier.msgs)
+		assert.Empty(t, repo.rows().resets)
+	})
+
+	t.Run("failed delivery", func(t *testing.T) {
+		repo := newTestRepository(stubUser)
+		svc := NewService(Option{Auth: stubAuthOption}, repo, WithNotifier(&stubNotifier{err: errors.New("provider unavailable")}))
+
+		assert.Nil(t, svc.RequestPasswordReset(ctx, "foo@bar.com"), "registered email cannot be told apart")
+	})
+
+	t.Run("invalid password", func(t *testing.T) {
+		repo, notifier := newTestRepository(stubUser), &stubNotifier{}
+		svc := NewService(Option{Auth: stubAuthOption}, repo, WithNotifier(notifier))
+
+		assert.Nil(t, svc.RequestPasswordReset(ctx, "foo@bar.com"))
+		token := notifier.resetToken()
+
+		err := svc.ResetPassword(ctx, token, "short")
+		assert.ErrorIs(t, err, ErrValidation)
+
+		// token is not used
+		assert.Nil(t, svc.ResetPassword(ctx, token, "n3w s3cret"))
+	})
+}
+
+func Test_Service_EraseUser_Credential(t *testing.T) {
+	ctx := tenantCtx
+	svc, repo := newCredentialService(t, stubAuthOption, "s3cret pass")
+
+	_, err := svc.EraseUser(ctx, stubUser.ID, "")
+	assert.Nil(t, err)
+	assert.Empty(t, repo.rows().credentials)
+}
@@ -0,0 +1,148 @@
+package user
+
+import (
+	"context"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/pkg/errors"
+	"github.com/tuingking/supersvc/pkg/logger"
+)
+
+const defaultNameSimilarity = 0.8
+
+type DuplicateOption struct {
+	// NameSimilarity is minimum similarity of name, from 0 to 1, for users with the same phone to be duplicate.
+	// similarity is 1 - edit distance / length of the longer name, default to 0.8
+	NameSimilarity float64
+}
+
+func (o DuplicateOption) nameSimilarity() float64 {
+	if o.NameSimilarity <= 0 || o.NameSimilarity > 1 {
+		return defaultNameSimilarity
+	}
+	return o.NameSimilarity
+}
+
+// DuplicateGroup is users which is likely the same person, oldest first
+type DuplicateGroup struct {
+	Phone string `json:"phone"`
+	Users []User `json:"users"`
+}
+
+// FindDuplicateUsers group not deleted user by phone then by similar name.
+// only user sharing phone is read, phone is compared as stored so phone stored before normalization
+// is only matched once the user is updated, see normalizeUser.
+func (s *service) FindDuplicateUsers(ctx context.Context) ([]DuplicateGroup, error) {
+	log := logger.Get(ctx)
+
+	users, err := s.repo.FindPhoneDuplicates(ctx)
+	if err != nil {
+		log.Err(err).Msg("failed: find phone duplicates")
+		return nil, err
+	}
+
+	groups := []DuplicateGroup{}
+	for start := 0; start < len(users); {
+		end := start + 1
+		for end < len(users) && users[end].Phone == users[start].Phone {
+			end++
+		}
+		for _, similar := range groupBySimilarName(users[start:end], s.opt.Duplicate.nameSimilarity()) {
+			if len(similar) > 1 {
+				groups = append(groups, DuplicateGroup{Phone: users[start].Phone, Users: similar})
+			}
+		}
+		start = end
+	}
+
+	return groups, nil
+}
+
+// groupBySimilarName put user into the first group having a member with similar name, order of users is kept
+func groupBySimilarName(users []User, threshold float64) [][]User {
+	var groups [][]User
+next:
+	for _, u := range users {
+		for i, group := range groups {
+			for _, member := range group {
+				if nameSimilarity(u.Name, member.Name) >= threshold {
+					groups[i] = append(groups[i], u)
+					continue next
+				}
+			}
+		}
+		groups = append(groups, []User{u})
+	}
+	return groups
+}
+
+// nameSimilarity compare name case insensitively ignoring repeated space, 1 means equal
+func nameSimilarity(a, b string) float64 {
+	a = strings.Join(strings.Fields(strings.ToLower(a)), " ")
+	b = strings.Join(strings.Fields(strings.ToLower(b)), " ")
+	if a == b {
+		return 1
+	}
+
+	longest := max(utf8.RuneCountInString(a), utf8.RuneCountInString(b))
+	return 1 - float64(editDistance([]rune(a), []rune(b)))/float64(longest)
+}
+
+// editDistance return levenshtein distance of a and b
+func editDistance(a, b []rune) int {
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(b)]
+}
+
+func (s *service) MergeUser(ctx context.Context, survivorID, duplicateID string, reason string) (User, error) {
+	log := logger.Get(ctx)
+
+	if survivorID == duplicateID {
+		return User{}, errors.Wrap(ErrInvalidMerge, "user cannot be merged into itself")
+	}
+
+	survivor, err := s.repo.FindByID(ctx, survivorID)
+	if err != nil {
+		return survivor, err
+	}
+
+	duplicate, err := s.repo.FindByID(ctx, duplicateID)
+	if err != nil {
+		return survivor, err
+	}
+
+	before := duplicate
+	now := time.Now()
+	duplicate.DeletedAt, duplicate.MergedInto = &now, survivor.ID
+
+	after := duplicate
+	after.Version++
+
+	merge := func(ctx context.Context) error { return s.repo.Merge(ctx, duplicate) }
+	if err := s.mutate(ctx, merge, mutation{action: AuditActionMerge, before: before, after: after, reason: reason}); err != nil {
+		log.Err(err).Msg("failed: merge user")
+		return survivor, err
+	}
+
+	log.Info().Str("user_id", duplicateID).Str("survivor_id", survivorID).Msg("user merged")
+
+	return survivor, nil
+}
@@ -0,0 +1,114 @@
+package user
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_NameSimilarity(t *testing.T) {
+	testCase := []struct {
+		a, b string
+		exp  float64
+	}{
+		{a: "Budi Santoso", b: " budi  SANTOSO ", exp: 1},
+		{a: "Budi Santoso", b: "Budi Santosa", exp: 1 - 1.0/12},
+		{a: "Siti", b: "Budi Santoso", exp: 1 - 10.0/12},
+		{a: "", b: "foo", exp: 0},
+	}
+
+	for _, tc := range testCase {
+		t.Run(tc.a+" "+tc.b, func(t *testing.T) {
+			assert.InDelta(t, tc.exp, nameSimilarity(tc.a, tc.b), 1e-9)
+		})
+	}
+}
+
+func Test_Service_FindDuplicateUsers(t *testing.T) {
+	user := func(id, name, phone string) User {
+		u := stubUser
+		u.ID, u.Name, u.Phone = id, name, phone
+		return u
+	}
+	deletedAt := time.Now()
+
+	u1 := user("u1", "Budi Santoso", "+6281234567890")
+	u2 := user("u2", "budi  santosa", "+6281234567890")
+	u3 := user("u3", "Siti", "+6281234567890")
+	u4 := user("u4", "Budi Santoso", "+6281111111111")
+	u5 := user("u5", "Budi Santoso", "+6281234567890")
+	u5.DeletedAt = &deletedAt
+	u6 := user("u6", "Siti", "")
+	u7 := user("u7", "Siti", "")
+	u8 := user("u8", "Budi Santoso", "0812-3456-7890")
+
+	svc := NewService(Option{DefaultRegion: "ID"}, newTestRepository(u1, u2, u3, u4, u5, u6, u7, u8))
+
+	groups, err := svc.FindDuplicateUsers(tenantCtx)
+	assert.Nil(t, err)
+	assert.Equal(t, []DuplicateGroup{{Phone: "+6281234567890", Users: []User{u1, u2}}}, groups)
+
+	t.Run("stricter similarity", func(t *testing.T) {
+		svc := NewService(Option{DefaultRegion: "ID", Duplicate: DuplicateOption{NameSimilarity: 1}}, newTestRepository(u1, u2, u3))
+
+		groups, err := svc.FindDuplicateUsers(tenantCtx)
+		assert.Nil(t, err)
+		assert.Empty(t, groups)
+	})
+}
+
+func Test_Service_MergeUser(t *testing.T) {
+	ctx := tenantCtx
+
+	u2 := stubUser
+	u2.ID, u2.Email = "u2", "foo2@bar.com"
+	u3 := stubUser
+	u3.ID, u3.Email = "u3", "foo3@bar.com"
+
+	repo := newTestRepository(stubUser, u2, u3)
+	svc := NewService(Option{}, repo)
+
+	_, err := svc.MergeUser(ctx, "u2", "u3", "")
+	assert.Nil(t, err)
+	survivor, err := svc.MergeUser(ctx, "u1", "u2", "same person")
+	assert.Nil(t, err)
+	assert.Equal(t, stubUser, survivor)
+
+	merged := repo.rows().users["u2"]
+	assert.Equal(t, "u1", merged.MergedInto)
+	assert.NotNil(t, merged.DeletedAt)
+	assert.Equal(t, int64(2), merged.Version)
+	assert.Equal(t, "u1", repo.rows().users["u3"].MergedInto, "merged into u2 before is moved to u1")
+
+	// old id is resolved to the survivor
+	for _, id := range []string{"u1", "u2", "u3"} {
+		usr, err := svc.GetUserByID(ctx, id)
+		assert.Nil(t, err)
+		assert.Equal(t, "u1", usr.ID)
+	}
+
+	// merged user is kept
+	_, err = svc.RestoreUser(ctx, "u2")
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.ErrorIs(t, svc.PurgeUser(ctx, "u2"), ErrNotFound)
+
+	logs, _, err := svc.GetUserHistory(ctx, "u2", GetAuditLogParam{})
+	assert.Nil(t, err)
+	assert.Equal(t, AuditActionMerge, logs[len(logs)-1].Action)
+	assert.Equal(t, "same person", logs[len(logs)-1].Reason)
+	assert.Contains(t, logs[len(logs)-1].Changes, AuditChange{Field: "merged_into", From: "", To: "u1"})
+	assert.Equal(t, EventUserMerged, repo.events()[len(repo.events())-1].Type)
+	assert.Equal(t, "u2", repo.events()[len(repo.events())-1].Key)
+
+	t.Run("invalid", func(t *testing.T) {
+		_, err := svc.MergeUser(ctx, "u1", "u1", "")
+		assert.ErrorIs(t, err, ErrInvalidMerge)
+
+		_, err = svc.MergeUser(ctx, "u2", "u1", "")
+		assert.ErrorIs(t, err, ErrNotFound, "merged survivor")
+
+		_, err = svc.MergeUser(ctx, "u1", "u2", "")
+		assert.ErrorIs(t, err, ErrNotFound, "merged duplicate")
+	})
+}
@@ -5,31 +5,75 @@ import (
 	"time"
 )
 
-const (
-	UserStatusInActive = iota
-	UserStatusActive
-	UserStatusBanned
-	UserStatusSuspend
-)
-
 type User struct {
-	ID        string    `json:"id" db:"id"`
-	Name      string    `json:"name" db:"name"`
-	Phone     string    `json:"phone" db:"phone"`
-	Email     string    `json:"email" db:"email"`
-	Status    int       `json:"status" db:"status"`
-	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	ID        string     `json:"id" db:"id"`
+	Name      string     `json:"name" db:"name"`
+	Phone     string     `json:"phone" db:"phone"`
+	Email     string     `json:"email" db:"email"`
+	Status    Status     `json:"status" db:"status"`
+	CreatedAt time.Time  `json:"created_at" db:"created_at"`
+	DeletedAt *time.Time `json:"deleted_at,omitempty" db:"deleted_at"`
+
+	// SuspendedUntil is when suspension is lifted, nil means suspended until lifted manually
+	SuspendedUntil   *time.Time `json:"suspended_until,omitempty" db:"suspended_until"`
+	SuspensionReason string     `json:"suspension_reason,omitempty" db:"suspension_reason"`
+
+	// Version is incremented on every change, it is used for optimistic concurrency control
+	Version int64 `json:"version" db:"version"`
+
+	// ErasedAt is when name, phone and email is anonymized, see Service.EraseUser
+	ErasedAt *time.Time `json:"erased_at,omitempty" db:"erased_at"`
+
+	// MergedInto is id of the user this duplicate is merged into, merged user is soft deleted
+	MergedInto string `json:"merged_into,omitempty" db:"merged_into"`
+
+	// EmailVerifiedAt and PhoneVerifiedAt is when the current email and phone is verified, it is cleared when the contact change
+	EmailVerifiedAt *time.Time `json:"email_verified_at,omitempty" db:"email_verified_at"`
+	PhoneVerifiedAt *time.Time `json:"phone_verified_at,omitempty" db:"phone_verified_at"`
+}
+
+// clearStaleVerification unset verification of email and phone which is changed from before
+func (u *User) clearStaleVerification(before User) {
+	if u.Email != before.Email {
+		u.EmailVerifiedAt = nil
+	}
+	if u.Phone != before.Phone {
+		u.PhoneVerifiedAt = nil
+	}
+}
+
+// setStatus change user status, suspension is cleared when user is no longer suspended
+func (u *User) setStatus(to Status) {
+	u.Status = to
+	if to != UserStatusSuspend {
+		u.SuspendedUntil = nil
+		u.SuspensionReason = ""
+	}
 }
 
 type CreateUser struct {
 	Name   string `json:"name" db:"name"`
 	Phone  string `json:"phone" db:"phone"`
 	Email  string `json:"email" db:"email"`
-	Status int    `json:"status" db:"status"`
+	Status Status `json:"status" db:"status"`
+}
+
+// UpdateUser replace all mutable field of user
+type UpdateUser struct {
+	Name   string `json:"name"`
+	Phone  string `json:"phone"`
+	Email  string `json:"email"`
+	Status Status `json:"status"`
 }
 
 type GetUserParam struct {
-	Email sql.NullString `param:"email" db:"email"`
+	Email        sql.NullString `param:"email" db:"email"`
+	Status       []Status       `param:"status__in" db:"status" enum:"inactive,active,banned,suspended"`
+	CreatedAtGTE sql.NullTime   `param:"created_at__gte" db:"created_at"`
+	CreatedAtLTE sql.NullTime   `param:"created_at__lte" db:"created_at"`
+
+	// IncludeDeleted include soft deleted user, for admin
+	IncludeDeleted bool `param:"include_deleted"`
 
 	Page   int64    `param:"page"`
 	Limit  int64    `param:"limit"`
@@ -0,0 +1,69 @@
+package user
+
+import (
+	"fmt"
+)
+
+// Error is user domain error.
+// Code is application error code, it is returned as error code in http response.
+type Error struct {
+	Code int
+	Msg  string
+}
+
+func (e *Error) Error() string {
+	return e.Msg
+}
+
+func (e *Error) ErrorCode() int {
+	return e.Code
+}
+
+// user error code is in range 1000-1999
+var (
+	ErrNotFound                = &Error{Code: 1001, Msg: "user not found"}
+	ErrDuplicateEmail          = &Error{Code: 1002, Msg: "email already used by another user"}
+	ErrInvalidPatch            = &Error{Code: 1003, Msg: "invalid patch"}
+	ErrInvalidStatus           = &Error{Code: 1004, Msg: "invalid user status"}
+	ErrInvalidTransition       = &Error{Code: 1005, Msg: "invalid user status transition"}
+	ErrInvalidSuspension       = &Error{Code: 1006, Msg: "invalid suspension"}
+	ErrNotSuspended            = &Error{Code: 1007, Msg: "user is not suspended"}
+	ErrValidation              = &Error{Code: 1008, Msg: "invalid user"}
+	ErrVersionConflict         = &Error{Code: 1009, Msg: "user has been modified, reload and retry"}
+	ErrInvalidMerge            = &Error{Code: 1010, Msg: "invalid user merge"}
+	ErrInvalidCredential       = &Error{Code: 1011, Msg: "invalid email or password"}
+	ErrAccountLocked           = &Error{Code: 1012, Msg: "too many failed login, retry later"}
+	ErrInvalidResetToken       = &Error{Code: 1013, Msg: "invalid or expired password reset token"}
+	ErrInvalidVerificationCode = &Error{Code: 1014, Msg: "invalid or expired verification code"}
+	ErrContactVerified         = &Error{Code: 1015, Msg: "contact is already verified"}
+	ErrNoContact               = &Error{Code: 1016, Msg: "user has no contact to verify"}
+	ErrInvalidSortBy           = &Error{Code: 1017, Msg: "invalid sort field"}
+	ErrInvalidTenant           = &Error{Code: 1018, Msg: "missing or invalid tenant"}
+	ErrVerificationCooldown    = &Error{Code: 1019, Msg: "verification code is sent recently, retry later"}
+	ErrAccessRevoked           = &Error{Code: 1020, Msg: "access is revoked, login again"}
+	ErrInvalidImport           = &Error{Code: 1021, Msg: "invalid import source"}
+	ErrImportLineTooLong       = &Error{Code: 1022, Msg: "import line is too long"}
+)
+
+// TransitionError is returned when user status transition is not allowed
+type TransitionError struct {
+	From           Status
+	To             Status
+	Allowed        []Status
+	ReasonRequired bool
+}
+
+func (e *TransitionError) Error() string {
+	if e.ReasonRequired {
+		return fmt.Sprintf("%s: %s to %s require reason", ErrInvalidTransition, e.From, e.To)
+	}
+	return fmt.Sprintf("%s: %s to %s is not allowed", ErrInvalidTransition, e.From, e.To)
+}
+
+func (e *TransitionError) Is(target error) bool {
+	return target == ErrInvalidTransition
+}
+
+func (e *TransitionError) ErrorCode() int {
+	return ErrInvalidTransition.Code
+}
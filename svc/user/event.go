@@ -0,0 +1,127 @@
+package user
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/tuingking/supersvc/pkg/outbox"
+)
+
+// domain event published through the outbox, message key is user id.
+// the outbox is shared by every tenant, so every payload carry the tenant of the user.
+const (
+	EventUserCreated       = "UserCreated"
+	EventUserStatusChanged = "UserStatusChanged"
+	EventUserDeleted       = "UserDeleted"
+	EventUserErased        = "UserErased"
+	EventUserMerged        = "UserMerged"
+)
+
+// UserCreatedEvent is payload of UserCreated
+type UserCreatedEvent struct {
+	TenantID string `json:"tenant_id"`
+	User
+}
+
+// UserStatusChangedEvent is payload of UserStatusChanged
+type UserStatusChangedEvent struct {
+	TenantID       string     `json:"tenant_id"`
+	UserID         string     `json:"user_id"`
+	From           Status     `json:"from"`
+	To             Status     `json:"to"`
+	Reason         string     `json:"reason,omitempty"`
+	SuspendedUntil *time.Time `json:"suspended_until,omitempty"`
+	Version        int64      `json:"version"`
+}
+
+// UserDeletedEvent is payload of UserDeleted, it is published on soft delete
+type UserDeletedEvent struct {
+	TenantID  string    `json:"tenant_id"`
+	UserID    string    `json:"user_id"`
+	DeletedAt time.Time `json:"deleted_at"`
+	Version   int64     `json:"version"`
+}
+
+// UserErasedEvent is payload of UserErased, consumer should erase personal data it holds about the user
+type UserErasedEvent struct {
+	TenantID string    `json:"tenant_id"`
+	UserID   string    `json:"user_id"`
+	ErasedAt time.Time `json:"erased_at"`
+	Version  int64     `json:"version"`
+}
+
+// UserMergedEvent is payload of UserMerged, consumer should move reference of the user to the survivor
+type UserMergedEvent struct {
+	TenantID   string `json:"tenant_id"`
+	UserID     string `json:"user_id"`
+	SurvivorID string `json:"survivor_id"`
+	Version    int64  `json:"version"`
+}
+
+// mutation is change of single user from before to after, zero before is used on create and zero after on purge.
+// it is recorded as audit log and domain event in the transaction of the change.
+type mutation struct {
+	action AuditAction
+	before User
+	after  User
+	reason string
+}
+
+// events return domain event of the mutation of user of the tenant, a mutation may not produce any event
+func (m mutation) events(tenantID string) ([]outbox.Message, error) {
+	var payloads []interface{}
+	var types []string
+
+	switch {
+	case m.action == AuditActionCreate:
+		types, payloads = append(types, EventUserCreated), append(payloads, UserCreatedEvent{TenantID: tenantID, User: m.after})
+	case m.action == AuditActionDelete && m.after.DeletedAt != nil:
+		types, payloads = append(types, EventUserDeleted), append(payloads, UserDeletedEvent{
+			TenantID:  tenantID,
+			UserID:    m.after.ID,
+			DeletedAt: *m.after.DeletedAt,
+			Version:   m.after.Version,
+		})
+	case m.action == AuditActionErase && m.after.ErasedAt != nil:
+		types, payloads = append(types, EventUserErased), append(payloads, UserErasedEvent{
+			TenantID: tenantID,
+			UserID:   m.after.ID,
+			ErasedAt: *m.after.ErasedAt,
+			Version:  m.after.Version,
+		})
+	case m.action == AuditActionMerge && m.after.MergedInto != "":
+		types, payloads = append(types, EventUserMerged), append(payloads, UserMergedEvent{
+			TenantID:   tenantID,
+			UserID:     m.after.ID,
+			SurvivorID: m.after.MergedInto,
+			Version:    m.after.Version,
+		})
+	case m.after.ID != "" && m.before.Status != m.after.Status:
+		types, payloads = append(types, EventUserStatusChanged), append(payloads, UserStatusChangedEvent{
+			TenantID:       tenantID,
+			UserID:         m.after.ID,
+			From:           m.before.Status,
+			To:             m.after.Status,
+			Reason:         m.reason,
+			SuspendedUntil: m.after.SuspendedUntil,
+			Version:        m.after.Version,
+		})
+	}
+
+	msgs := make([]outbox.Message, 0, len(payloads))
+	for i, payload := range payloads {
+		b, err := json.Marshal(payload)
+		if err != nil {
+			return nil, err
+		}
+		msgs = append(msgs, outbox.Message{
+			ID:        uuid.New().String(),
+			Type:      types[i],
+			Key:       m.after.ID,
+			Payload:   b,
+			CreatedAt: time.Now(),
+		})
+	}
+	return msgs, nil
+}
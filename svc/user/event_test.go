@@ -0,0 +1,87 @@
+package user
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tuingking/supersvc/pkg/outbox"
+)
+
+func Test_Service_Event(t *testing.T) {
+	ctx := tenantCtx
+
+	types := func(msgs []outbox.Message) []string {
+		var v []string
+		for _, m := range msgs {
+			v = append(v, m.Type)
+		}
+		return v
+	}
+
+	t.Run("mutation publish event", func(t *testing.T) {
+		repo := newTestRepository()
+		svc := NewService(Option{}, repo)
+
+		usr, err := svc.CreateUser(ctx, User{Name: "foo", Email: "foo@bar.com"})
+		assert.Nil(t, err)
+		usr, err = svc.UpdateUser(ctx, usr.ID, usr.Version, UpdateUser{Name: "bar", Email: "bar@foo.com"})
+		assert.Nil(t, err)
+		usr, err = svc.ChangeStatus(ctx, usr.ID, UserStatusBanned, "fraud")
+		assert.Nil(t, err)
+		assert.Nil(t, svc.DeleteUser(ctx, usr.ID, usr.Version))
+
+		assert.Equal(t, []string{EventUserCreated, EventUserStatusChanged, EventUserDeleted}, types(repo.events()))
+		for _, m := range repo.events() {
+			assert.NotEmpty(t, m.ID)
+			assert.Equal(t, usr.ID, m.Key)
+		}
+
+		var changed UserStatusChangedEvent
+		assert.Nil(t, json.Unmarshal(repo.events()[1].Payload, &changed))
+		assert.Equal(t, UserStatusChangedEvent{
+			TenantID: stubTenant,
+			UserID:   usr.ID,
+			From:     UserStatusInActive,
+			To:       UserStatusBanned,
+			Reason:   "fraud",
+			Version:  3,
+		}, changed)
+
+		var deleted UserDeletedEvent
+		assert.Nil(t, json.Unmarshal(repo.events()[2].Payload, &deleted))
+		assert.Equal(t, int64(4), deleted.Version)
+	})
+
+	t.Run("failed mutation doesn't publish event", func(t *testing.T) {
+		repo := newTestRepository(stubUser)
+		svc := NewService(Option{}, repo)
+
+		assert.ErrorIs(t, svc.DeleteUser(ctx, stubUser.ID, stubUser.Version+1), ErrVersionConflict)
+		past := time.Now().Add(-time.Hour)
+		_, err := svc.SuspendUser(ctx, stubUser.ID, &past, "")
+		assert.ErrorIs(t, err, ErrInvalidSuspension)
+		assert.Empty(t, repo.events())
+	})
+
+	t.Run("lifted suspension", func(t *testing.T) {
+		expired := time.Now().Add(-time.Minute)
+		suspended := stubUser
+		suspended.Status, suspended.SuspendedUntil = UserStatusSuspend, &expired
+
+		repo := newTestRepository(suspended)
+		svc := NewService(Option{}, repo)
+
+		_, err := svc.LiftExpiredSuspension(ctx)
+		assert.Nil(t, err)
+		assert.Equal(t, []string{EventUserStatusChanged}, types(repo.events()))
+
+		var changed UserStatusChangedEvent
+		assert.Nil(t, json.Unmarshal(repo.events()[0].Payload, &changed))
+		assert.Equal(t, UserStatusSuspend, changed.From)
+		assert.Equal(t, UserStatusActive, changed.To)
+		assert.Equal(t, "suspension expired", changed.Reason)
+		assert.Equal(t, int64(2), changed.Version)
+	})
+}
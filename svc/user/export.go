@@ -0,0 +1,128 @@
+package user
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/tuingking/supersvc/pkg/logger"
+)
+
+// ExportWriter write exported user one by one.
+// Flush is called once after the last user, also when there is no user.
+type ExportWriter interface {
+	Write(u User) error
+	Flush() error
+}
+
+// csvExportColumns is header of exported CSV
+var csvExportColumns = []string{
+	"id", "name", "phone", "email", "status", "created_at",
+	"deleted_at", "suspended_until", "suspension_reason", "version", "erased_at", "merged_into",
+	"email_verified_at", "phone_verified_at",
+}
+
+type csvExportWriter struct {
+	w         *csv.Writer
+	hasHeader bool
+}
+
+// NewCSVExportWriter write CSV with header, time is formatted as RFC3339 and empty when not set
+func NewCSVExportWriter(dst io.Writer) ExportWriter {
+	return &csvExportWriter{w: csv.NewWriter(dst)}
+}
+
+func (c *csvExportWriter) Write(u User) error {
+	if err := c.writeHeader(); err != nil {
+		return err
+	}
+
+	// csv.Writer is buffered, the error is reported on the next flush
+	return c.w.Write([]string{
+		u.ID,
+		u.Name,
+		u.Phone,
+		u.Email,
+		u.Status.String(),
+		formatExportTime(&u.CreatedAt),
+		formatExportTime(u.DeletedAt),
+		formatExportTime(u.SuspendedUntil),
+		u.SuspensionReason,
+		strconv.FormatInt(u.Version, 10),
+		formatExportTime(u.ErasedAt),
+		u.MergedInto,
+		formatExportTime(u.EmailVerifiedAt),
+		formatExportTime(u.PhoneVerifiedAt),
+	})
+}
+
+func (c *csvExportWriter) Flush() error {
+	if err := c.writeHeader(); err != nil {
+		return err
+	}
+	c.w.Flush()
+	return c.w.Error()
+}
+
+func (c *csvExportWriter) writeHeader() error {
+	if c.hasHeader {
+		return nil
+	}
+	c.hasHeader = true
+	return c.w.Write(csvExportColumns)
+}
+
+func formatExportTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+type ndjsonExportWriter struct {
+	enc *json.Encoder
+}
+
+// NewNDJSONExportWriter write newline delimited JSON, one user per line
+func NewNDJSONExportWriter(dst io.Writer) ExportWriter {
+	return &ndjsonExportWriter{enc: json.NewEncoder(dst)}
+}
+
+func (n *ndjsonExportWriter) Write(u User) error {
+	return n.enc.Encode(u)
+}
+
+func (n *ndjsonExportWriter) Flush() error {
+	return nil
+}
+
+// ExportUsers write every user matching p to dst, page and limit is ignored.
+// users are streamed from the database, the result is never buffered entirely.
+// number of exported user is returned, also on error.
+func (s *service) ExportUsers(ctx context.Context, p GetUserParam, dst ExportWriter) (int, error) {
+	log := logger.Get(ctx)
+
+	var n int
+	err := s.repo.Stream(ctx, p, func(u User) error {
+		if err := dst.Write(u); err != nil {
+			return err
+		}
+		n++
+		return nil
+	})
+	if err != nil {
+		log.Err(err).Int("exported", n).Msg("failed: repo.Stream")
+		return n, err
+	}
+
+	if err := dst.Flush(); err != nil {
+		log.Err(err).Int("exported", n).Msg("failed: flush export")
+		return n, err
+	}
+
+	log.Info().Int("exported", n).Msg("users exported")
+	return n, nil
+}
@@ -0,0 +1,96 @@
+package user
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_CSVExportWriter(t *testing.T) {
+	until := time.Date(2024, 02, 01, 0, 0, 0, 0, time.UTC)
+	suspended := stubUser
+	suspended.ID, suspended.Status, suspended.SuspendedUntil, suspended.SuspensionReason = "u2", UserStatusSuspend, &until, "spam, abuse"
+	suspended.EmailVerifiedAt = &stubUser.CreatedAt
+
+	var buf bytes.Buffer
+	w := NewCSVExportWriter(&buf)
+	assert.Nil(t, w.Write(stubUser))
+	assert.Nil(t, w.Write(suspended))
+	assert.Nil(t, w.Flush())
+
+	assert.Equal(t, "id,name,phone,email,status,created_at,deleted_at,suspended_until,suspension_reason,version,erased_at,merged_into,email_verified_at,phone_verified_at\n"+
+		"u1,foo,+6281234567890,foo@bar.com,active,2024-01-01T00:00:00Z,,,,1,,,,\n"+
+		"u2,foo,+6281234567890,foo@bar.com,suspended,2024-01-01T00:00:00Z,,2024-02-01T00:00:00Z,\"spam, abuse\",1,,,2024-01-01T00:00:00Z,\n",
+		buf.String())
+
+	t.Run("empty", func(t *testing.T) {
+		var buf bytes.Buffer
+		assert.Nil(t, NewCSVExportWriter(&buf).Flush())
+		assert.Equal(t, "id,name,phone,email,status,created_at,deleted_at,suspended_until,suspension_reason,version,erased_at,merged_into,email_verified_at,phone_verified_at\n", buf.String())
+	})
+}
+
+func Test_NDJSONExportWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewNDJSONExportWriter(&buf)
+	assert.Nil(t, w.Write(stubUser))
+	assert.Nil(t, w.Flush())
+
+	assert.Equal(t, `{"id":"u1","name":"foo","phone":"+6281234567890","email":"foo@bar.com","status":"active","created_at":"2024-01-01T00:00:00Z","version":1}`+"\n", buf.String())
+}
+
+type failingExportWriter struct {
+	n int
+}
+
+func (f *failingExportWriter) Write(u User) error {
+	if f.n == 0 {
+		return errors.New("broken pipe")
+	}
+	f.n--
+	return nil
+}
+
+func (f *failingExportWriter) Flush() error {
+	return nil
+}
+
+func Test_Service_ExportUsers(t *testing.T) {
+	deletedAt := time.Date(2024, 01, 02, 0, 0, 0, 0, time.UTC)
+	u2, u3 := stubUser, stubUser
+	u2.ID, u2.Email = "u2", "bar@foo.com"
+	u3.ID, u3.Email, u3.DeletedAt = "u3", "baz@foo.com", &deletedAt
+
+	t.Run("export", func(t *testing.T) {
+		svc := NewService(Option{}, newTestRepository(stubUser, u2, u3))
+
+		var buf bytes.Buffer
+		n, err := svc.ExportUsers(tenantCtx, GetUserParam{}, NewNDJSONExportWriter(&buf))
+		assert.Nil(t, err)
+		assert.Equal(t, 2, n)
+		assert.Equal(t, 2, bytes.Count(buf.Bytes(), []byte("\n")))
+	})
+
+	t.Run("write error stop the export", func(t *testing.T) {
+		svc := NewService(Option{}, newTestRepository(stubUser, u2, u3))
+
+		n, err := svc.ExportUsers(tenantCtx, GetUserParam{IncludeDeleted: true}, &failingExportWriter{n: 1})
+		assert.EqualError(t, err, "broken pipe")
+		assert.Equal(t, 1, n)
+	})
+
+	t.Run("canceled", func(t *testing.T) {
+		svc := NewService(Option{}, newTestRepository(stubUser))
+
+		ctx, cancel := context.WithCancel(tenantCtx)
+		cancel()
+
+		var buf bytes.Buffer
+		_, err := svc.ExportUsers(ctx, GetUserParam{}, NewCSVExportWriter(&buf))
+		assert.ErrorIs(t, err, context.Canceled)
+	})
+}
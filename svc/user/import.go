@@ -0,0 +1,391 @@
+package user
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/tuingking/supersvc/pkg/logger"
+)
+
+const (
+	defaultImportBatchSize = 500
+
+	// maxImportLineSize is max size of single NDJSON line
+	maxImportLineSize = 64 << 10 // 64 KB
+)
+
+// ImportOption configure bulk import
+type ImportOption struct {
+	// BatchSize is number of user inserted in single statement, default to 500
+	BatchSize int
+}
+
+func (o ImportOption) batchSize() int {
+	if o.BatchSize <= 0 {
+		return defaultImportBatchSize
+	}
+	return o.BatchSize
+}
+
+type ImportStatus string
+
+const (
+	ImportStatusCreated ImportStatus = "created"
+	ImportStatusSkipped ImportStatus = "skipped"
+	ImportStatusFailed  ImportStatus = "failed"
+)
+
+// ImportReport is result of bulk import.
+// on dry run nothing is stored, created means the row would be created.
+type ImportReport struct {
+	DryRun  bool              `json:"dry_run"`
+	Created int               `json:"created"`
+	Skipped int               `json:"skipped"`
+	Failed  int               `json:"failed"`
+	Rows    []ImportRowResult `json:"rows"`
+}
+
+type ImportRowResult struct {
+	Line   int          `json:"line"`
+	Status ImportStatus `json:"status"`
+	ID     string       `json:"id,omitempty"`
+	Email  string       `json:"email,omitempty"`
+	Reason string       `json:"reason,omitempty"`
+	Fields []FieldError `json:"fields,omitempty"`
+}
+
+func (r *ImportReport) add(row ImportRowResult) {
+	switch row.Status {
+	case ImportStatusCreated:
+		r.Created++
+	case ImportStatusSkipped:
+		r.Skipped++
+	case ImportStatusFailed:
+		r.Failed++
+	}
+	r.Rows = append(r.Rows, row)
+}
+
+func (r *ImportReport) skip(line int, email, reason string) {
+	r.add(ImportRowResult{Line: line, Status: ImportStatusSkipped, Email: email, Reason: reason})
+}
+
+// sortRows order rows by line, row of a batch is added after the rows failed while the batch is filled
+func (r *ImportReport) sortRows() {
+	sort.SliceStable(r.Rows, func(i, j int) bool {
+		return r.Rows[i].Line < r.Rows[j].Line
+	})
+}
+
+func (r *ImportReport) fail(line int, email string, err error) {
+	row := ImportRowResult{Line: line, Status: ImportStatusFailed, Email: email, Reason: err.Error()}
+
+	var validationErr *ValidationError
+	if errors.As(err, &validationErr) {
+		row.Reason, row.Fields = ErrValidation.Error(), validationErr.Fields
+	}
+	r.add(row)
+}
+
+// ImportRow is single row of import source. Err is set when the row cannot be parsed.
+type ImportRow struct {
+	Line int
+	User User
+	Err  error
+}
+
+// ImportReader read user to import row by row, io.EOF is returned when there is no more row.
+// unparseable row is returned as ImportRow.Err, other error stop the import.
+type ImportReader interface {
+	Next() (ImportRow, error)
+}
+
+// ImportSourceError is returned by ImportUsers when the source cannot be read, e.g: broken request body.
+// it is ErrInvalidImport, ErrorCode is the code of user error it wraps if any, e.g: ErrImportLineTooLong.
+type ImportSourceError struct {
+	Err error
+}
+
+func (e *ImportSourceError) Error() string {
+	return fmt.Sprintf("%s: %s", ErrInvalidImport, e.Err)
+}
+
+func (e *ImportSourceError) Is(target error) bool {
+	return target == ErrInvalidImport
+}
+
+func (e *ImportSourceError) Unwrap() error {
+	return e.Err
+}
+
+func (e *ImportSourceError) ErrorCode() int {
+	var userErr *Error
+	if errors.As(e.Err, &userErr) {
+		return userErr.Code
+	}
+	return ErrInvalidImport.Code
+}
+
+// csvColumns is column accepted in CSV header
+var csvColumns = map[string]func(u *User, v string) error{
+	"name":  func(u *User, v string) error { u.Name = v; return nil },
+	"phone": func(u *User, v string) error { u.Phone = v; return nil },
+	"email": func(u *User, v string) error { u.Email = v; return nil },
+	"status": func(u *User, v string) error {
+		if strings.TrimSpace(v) == "" {
+			return nil
+		}
+		return u.Status.UnmarshalText([]byte(v))
+	},
+}
+
+type csvImportReader struct {
+	r       *csv.Reader
+	columns []func(u *User, v string) error
+}
+
+// NewCSVImportReader read CSV with header, e.g: name,phone,email,status.
+// column order is free, status default to inactive. invalid header is returned as ImportSourceError.
+func NewCSVImportReader(src io.Reader) (ImportReader, error) {
+	r := csv.NewReader(src)
+	r.TrimLeadingSpace = true
+	r.ReuseRecord = true
+
+	header, err := r.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, &ImportSourceError{Err: errors.New("csv: header is required")}
+	}
+	if err != nil {
+		return nil, &ImportSourceError{Err: fmt.Errorf("csv: read header: %w", err)}
+	}
+
+	columns := make([]func(u *User, v string) error, len(header))
+	for i, name := range header {
+		set, ok := csvColumns[strings.ToLower(strings.TrimSpace(name))]
+		if !ok {
+			return nil, &ImportSourceError{Err: fmt.Errorf("csv: unknown column %q", name)}
+		}
+		columns[i] = set
+	}
+
+	return &csvImportReader{r: r, columns: columns}, nil
+}
+
+func (c *csvImportReader) Next() (ImportRow, error) {
+	record, err := c.r.Read()
+	if errors.Is(err, io.EOF) {
+		return ImportRow{}, io.EOF
+	}
+
+	var parseErr *csv.ParseError
+	if errors.As(err, &parseErr) {
+		return ImportRow{Line: parseErr.StartLine, Err: parseErr.Err}, nil
+	}
+	if err != nil {
+		return ImportRow{}, err
+	}
+
+	line, _ := c.r.FieldPos(0)
+	row := ImportRow{Line: line}
+	for i, v := range record {
+		if err := c.columns[i](&row.User, v); err != nil {
+			row.Err = err
+			break
+		}
+	}
+	return row, nil
+}
+
+type ndjsonImportReader struct {
+	s    *bufio.Scanner
+	line int
+}
+
+// NewNDJSONImportReader read newline delimited JSON, one user per line. blank line is ignored.
+func NewNDJSONImportReader(src io.Reader) ImportReader {
+	s := bufio.NewScanner(src)
+	s.Buffer(make([]byte, 0, 4096), maxImportLineSize)
+	return &ndjsonImportReader{s: s}
+}
+
+func (n *ndjsonImportReader) Next() (ImportRow, error) {
+	for n.s.Scan() {
+		n.line++
+
+		b := bytes.TrimSpace(n.s.Bytes())
+		if len(b) == 0 {
+			continue
+		}
+
+		var v CreateUser
+		dec := json.NewDecoder(bytes.NewReader(b))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&v); err != nil {
+			return ImportRow{Line: n.line, Err: err}, nil
+		}
+
+		return ImportRow{
+			Line: n.line,
+			User: User{Name: v.Name, Phone: v.Phone, Email: v.Email, Status: v.Status},
+		}, nil
+	}
+
+	err := n.s.Err()
+	if errors.Is(err, bufio.ErrTooLong) {
+		return ImportRow{}, fmt.Errorf("ndjson: line %d exceeds %d bytes: %w", n.line+1, maxImportLineSize, ErrImportLineTooLong)
+	}
+	if err != nil {
+		return ImportRow{}, fmt.Errorf("ndjson: line %d: %w", n.line+1, err)
+	}
+	return ImportRow{}, io.EOF
+}
+
+// ImportUsers validate every row using the same rule as CreateUser.
+// row with email which is already used or appear earlier in the source is skipped.
+// rows are inserted batch by batch, the source is never buffered entirely.
+// when the import is stopped by error other than invalid row, report of the rows before the error is returned with it.
+// error reading the source is returned as ImportSourceError.
+func (s *service) ImportUsers(ctx context.Context, src ImportReader, dryRun bool) (ImportReport, error) {
+	log := logger.Get(ctx)
+
+	report := ImportReport{DryRun: dryRun, Rows: []ImportRowResult{}}
+	seen := make(map[string]int) // email -> line
+	batch := make([]ImportRow, 0, s.opt.Import.batchSize())
+
+	for {
+		row, err := src.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			log.Err(err).Msg("failed: read import row")
+			report.sortRows()
+			return report, &ImportSourceError{Err: err}
+		}
+
+		if row.Err != nil {
+			report.fail(row.Line, "", row.Err)
+			continue
+		}
+
+		if err := s.newUser(&row.User); err != nil {
+			report.fail(row.Line, row.User.Email, err)
+			continue
+		}
+
+		if line, ok := seen[row.User.Email]; ok {
+			report.skip(row.Line, row.User.Email, fmt.Sprintf("duplicate email of line %d", line))
+			continue
+		}
+		seen[row.User.Email] = row.Line
+
+		batch = append(batch, row)
+		if len(batch) == cap(batch) {
+			if err := s.importBatch(ctx, &report, batch, dryRun); err != nil {
+				log.Err(err).Msg("failed: import batch")
+				report.sortRows()
+				return report, err
+			}
+			batch = batch[:0]
+		}
+	}
+
+	if err := s.importBatch(ctx, &report, batch, dryRun); err != nil {
+		log.Err(err).Msg("failed: import batch")
+		report.sortRows()
+		return report, err
+	}
+	report.sortRows()
+
+	log.Info().
+		Bool("dry_run", dryRun).
+		Int("created", report.Created).
+		Int("skipped", report.Skipped).
+		Int("failed", report.Failed).
+		Msg("users imported")
+
+	return report, nil
+}
+
+// importBatch skip row with existing email and insert the rest
+func (s *service) importBatch(ctx context.Context, report *ImportReport, batch []ImportRow, dryRun bool) error {
+	if len(batch) == 0 {
+		return nil
+	}
+
+	emails := make([]string, len(batch))
+	for i, row := range batch {
+		emails[i] = row.User.Email
+	}
+
+	existing, err := s.repo.FindExistingEmail(ctx, emails)
+	if err != nil {
+		return err
+	}
+
+	var (
+		rows  []ImportRow
+		users []User
+	)
+	for _, row := range batch {
+		if existing[row.User.Email] {
+			report.skip(row.Line, row.User.Email, ErrDuplicateEmail.Error())
+			continue
+		}
+		rows = append(rows, row)
+		users = append(users, row.User)
+	}
+
+	if !dryRun {
+		ms := make([]mutation, len(users))
+		for i, u := range users {
+			ms[i] = mutation{action: AuditActionCreate, after: u, reason: "import"}
+		}
+
+		create := func(ctx context.Context) error { return s.repo.CreateBatch(ctx, users) }
+		err := s.mutate(ctx, create, ms...)
+		if errors.Is(err, ErrDuplicateEmail) {
+			// email is used concurrently after FindExistingEmail, the batch is rolled back.
+			// retry row by row so only the duplicate row is skipped
+			return s.importRows(ctx, report, rows)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, row := range rows {
+		res := ImportRowResult{Line: row.Line, Status: ImportStatusCreated, Email: row.User.Email}
+		if !dryRun {
+			res.ID = row.User.ID
+		}
+		report.add(res)
+	}
+
+	return nil
+}
+
+// importRows insert row one by one, row whose email is already used is skipped
+func (s *service) importRows(ctx context.Context, report *ImportReport, rows []ImportRow) error {
+	for _, row := range rows {
+		create := func(ctx context.Context) error { return s.repo.Create(ctx, row.User) }
+		err := s.mutate(ctx, create, mutation{action: AuditActionCreate, after: row.User, reason: "import"})
+		if errors.Is(err, ErrDuplicateEmail) {
+			report.skip(row.Line, row.User.Email, ErrDuplicateEmail.Error())
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		report.add(ImportRowResult{Line: row.Line, Status: ImportStatusCreated, ID: row.User.ID, Email: row.User.Email})
+	}
+	return nil
+}
@@ -0,0 +1,218 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func readAll(t *testing.T, r ImportReader) []ImportRow {
+	var rows []ImportRow
+	for {
+		row, err := r.Next()
+		if errors.Is(err, io.EOF) {
+			return rows
+		}
+		if !assert.Nil(t, err) {
+			return rows
+		}
+		rows = append(rows, row)
+	}
+}
+
+func Test_CSVImportReader(t *testing.T) {
+	src := "Email,name,phone,status\n" +
+		"foo@bar.com,foo,,active\n" +
+		"bar@foo.com,bar,0812\n" +
+		"baz@foo.com,baz,,deleted\n" +
+		"qux@foo.com,qux,,\n"
+
+	r, err := NewCSVImportReader(strings.NewReader(src))
+	assert.Nil(t, err)
+
+	rows := readAll(t, r)
+	assert.Len(t, rows, 4)
+	assert.Equal(t, ImportRow{Line: 2, User: User{Name: "foo", Email: "foo@bar.com", Status: UserStatusActive}}, rows[0])
+	assert.Equal(t, 3, rows[1].Line)
+	assert.NotNil(t, rows[1].Err, "wrong number of fields")
+	assert.Equal(t, 4, rows[2].Line)
+	assert.NotNil(t, rows[2].Err, "invalid status")
+	assert.Equal(t, ImportRow{Line: 5, User: User{Name: "qux", Email: "qux@foo.com"}}, rows[3])
+
+	t.Run("unknown column", func(t *testing.T) {
+		_, err := NewCSVImportReader(strings.NewReader("email,role\n"))
+		assert.NotNil(t, err)
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		_, err := NewCSVImportReader(strings.NewReader(""))
+		assert.NotNil(t, err)
+	})
+}
+
+func Test_NDJSONImportReader(t *testing.T) {
+	src := `{"name":"foo","email":"foo@bar.com","status":"active"}` + "\n" +
+		"\n" +
+		`{"name":"bar","role":"admin"}` + "\n" +
+		`{"name":` + "\n" +
+		`{"name":"baz","email":"baz@foo.com"}`
+
+	rows := readAll(t, NewNDJSONImportReader(strings.NewReader(src)))
+	assert.Len(t, rows, 4)
+	assert.Equal(t, ImportRow{Line: 1, User: User{Name: "foo", Email: "foo@bar.com", Status: UserStatusActive}}, rows[0])
+	assert.Equal(t, 3, rows[1].Line)
+	assert.NotNil(t, rows[1].Err, "unknown field")
+	assert.Equal(t, 4, rows[2].Line)
+	assert.NotNil(t, rows[2].Err, "invalid json")
+	assert.Equal(t, ImportRow{Line: 5, User: User{Name: "baz", Email: "baz@foo.com"}}, rows[3])
+
+	t.Run("line too long", func(t *testing.T) {
+		r := NewNDJSONImportReader(strings.NewReader(`{"name":"foo"}` + "\n" + strings.Repeat(" ", maxImportLineSize+1)))
+		_, err := r.Next()
+		assert.Nil(t, err)
+		_, err = r.Next()
+		assert.ErrorIs(t, err, ErrImportLineTooLong)
+	})
+}
+
+func Test_Service_ImportUsers(t *testing.T) {
+	src := "name,email,status\n" +
+		"foo,Foo@Bar.com,active\n" + // existing
+		"bar,bar@foo.com,\n" +
+		"baz,baz@foo.com,banned\n" + // not initial status
+		"qux,BAR@foo.com,\n" + // duplicate of line 3
+		",quux@foo.com,\n" + // invalid name
+		"corge,corge@foo.com,active\n" +
+		"grault,grault@foo.com,\n"
+
+	run := func(t *testing.T, dryRun bool) (ImportReport, *testRepository) {
+		repo := newTestRepository(stubUser)
+		svc := NewService(Option{Import: ImportOption{BatchSize: 2}}, repo)
+
+		r, err := NewCSVImportReader(strings.NewReader(src))
+		assert.Nil(t, err)
+
+		report, err := svc.ImportUsers(tenantCtx, r, dryRun)
+		assert.Nil(t, err)
+		return report, repo
+	}
+
+	t.Run("import", func(t *testing.T) {
+		report, repo := run(t, false)
+
+		assert.Equal(t, 3, report.Created)
+		assert.Equal(t, 2, report.Skipped)
+		assert.Equal(t, 2, report.Failed)
+		assert.Len(t, repo.rows().users, 4)
+		assert.Equal(t, 2, repo.batches)
+
+		var (
+			lines    []int
+			statuses []ImportStatus
+		)
+		for _, row := range report.Rows {
+			lines = append(lines, row.Line)
+			statuses = append(statuses, row.Status)
+		}
+		assert.Equal(t, []int{2, 3, 4, 5, 6, 7, 8}, lines)
+		assert.Equal(t, []ImportStatus{
+			ImportStatusSkipped,
+			ImportStatusCreated,
+			ImportStatusFailed,
+			ImportStatusSkipped,
+			ImportStatusFailed,
+			ImportStatusCreated,
+			ImportStatusCreated,
+		}, statuses)
+
+		assert.Equal(t, "bar@foo.com", report.Rows[1].Email)
+		assert.Contains(t, repo.rows().users, report.Rows[1].ID)
+		assert.Equal(t, "duplicate email of line 3", report.Rows[3].Reason)
+		assert.Equal(t, []FieldError{{Field: "name", Message: "is required"}}, report.Rows[4].Fields)
+	})
+
+	t.Run("dry run", func(t *testing.T) {
+		report, repo := run(t, true)
+
+		assert.True(t, report.DryRun)
+		assert.Equal(t, 3, report.Created)
+		assert.Empty(t, report.Rows[1].ID)
+		assert.Len(t, repo.rows().users, 1)
+		assert.Equal(t, 0, repo.batches)
+	})
+}
+
+// racingRepository miss every existing email, like email used concurrently after FindExistingEmail
+type racingRepository struct {
+	*testRepository
+}
+
+func (r racingRepository) FindExistingEmail(ctx context.Context, emails []string) (map[string]bool, error) {
+	return map[string]bool{}, nil
+}
+
+// failingImportReader return the rows then err
+type failingImportReader struct {
+	rows []ImportRow
+	err  error
+}
+
+func (r *failingImportReader) Next() (ImportRow, error) {
+	if len(r.rows) == 0 {
+		return ImportRow{}, r.err
+	}
+	row := r.rows[0]
+	r.rows = r.rows[1:]
+	return row, nil
+}
+
+func Test_Service_ImportUsers_Error(t *testing.T) {
+	t.Run("email used concurrently", func(t *testing.T) {
+		repo := newTestRepository(stubUser)
+		svc := NewService(Option{Import: ImportOption{BatchSize: 3}}, racingRepository{repo})
+
+		src := "name,email\n" +
+			"bar,bar@foo.com\n" +
+			"foo,foo@bar.com\n" + // existing
+			"baz,baz@foo.com\n"
+		r, err := NewCSVImportReader(strings.NewReader(src))
+		assert.Nil(t, err)
+
+		report, err := svc.ImportUsers(tenantCtx, r, false)
+		assert.Nil(t, err)
+		assert.Equal(t, 1, repo.batches)
+		assert.Equal(t, 2, report.Created, "batch is retried row by row")
+		assert.Equal(t, 1, report.Skipped)
+		assert.Equal(t, 0, report.Failed)
+		assert.Equal(t, ImportRowResult{Line: 3, Status: ImportStatusSkipped, Email: "foo@bar.com", Reason: ErrDuplicateEmail.Error()}, report.Rows[1])
+		assert.Contains(t, repo.rows().users, report.Rows[0].ID)
+		assert.Contains(t, repo.rows().users, report.Rows[2].ID)
+		assert.Len(t, repo.rows().auditLogs, 2)
+	})
+
+	t.Run("partial report is returned with the error", func(t *testing.T) {
+		repo := newTestRepository()
+		svc := NewService(Option{Import: ImportOption{BatchSize: 1}}, repo)
+
+		readErr := errors.New("unexpected EOF")
+		src := &failingImportReader{
+			rows: []ImportRow{
+				{Line: 2, User: User{Name: "foo", Email: "foo@bar.com"}},
+				{Line: 3, User: User{Email: "bar@foo.com"}},
+			},
+			err: readErr,
+		}
+
+		report, err := svc.ImportUsers(tenantCtx, src, false)
+		assert.ErrorIs(t, err, readErr)
+		assert.ErrorIs(t, err, ErrInvalidImport, "read error is caused by the source")
+		assert.Equal(t, 1, report.Created)
+		assert.Equal(t, 1, report.Failed)
+		assert.Len(t, report.Rows, 2)
+		assert.Contains(t, repo.rows().users, report.Rows[0].ID, "stored row is reported")
+	})
+}
@@ -0,0 +1,865 @@
+package user
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tuingking/supersvc/entity"
+	"github.com/tuingking/supersvc/pkg/outbox"
+	"github.com/tuingking/supersvc/pkg/qbuilder"
+)
+
+// errMemoryDuplicateID is returned when the primary key is already used, like duplicate entry of mysql PRIMARY key
+var errMemoryDuplicateID = errors.New("duplicate user id")
+
+type memoryVerificationKey struct {
+	userID  string
+	channel VerificationChannel
+}
+
+// memoryState is every row of a tenant kept by memoryRepository, it is copied when transaction start so it can be rolled back
+type memoryState struct {
+	users         map[string]User
+	auditLogs     []AuditLog
+	credentials   map[string]Credential
+	resets        map[string]PasswordReset
+	verifications map[memoryVerificationKey]Verification
+}
+
+func newMemoryState() *memoryState {
+	return &memoryState{
+		users:         make(map[string]User),
+		credentials:   make(map[string]Credential),
+		resets:        make(map[string]PasswordReset),
+		verifications: make(map[memoryVerificationKey]Verification),
+	}
+}
+
+// clone copy the maps and slice, stored value is never modified in place so it is shared
+func (s *memoryState) clone() *memoryState {
+	return &memoryState{
+		users:         maps.Clone(s.users),
+		auditLogs:     slices.Clone(s.auditLogs),
+		credentials:   maps.Clone(s.credentials),
+		resets:        maps.Clone(s.resets),
+		verifications: maps.Clone(s.verifications),
+	}
+}
+
+type memoryRepository struct {
+	mu      sync.Mutex
+	tenants map[string]*memoryState
+
+	// events is where event is added when the transaction is committed, pending is event of the running transaction
+	events  *outbox.MemoryStore
+	pending []outbox.Message
+}
+
+type memoryTxKey struct{}
+
+// NewMemoryRepository return Repository which keep user in memory, it is meant for test and local development.
+// it follows the same semantic as the mysql repository: email is unique case insensitively including soft deleted user,
+// version is checked on update and Tx is rolled back when fn return error.
+// row is kept by tenant of the ctx, only user id is unique across tenant like the primary key.
+// event is added to events when the mutation is committed, it is discarded when events is nil.
+func NewMemoryRepository(events *outbox.MemoryStore) Repository {
+	return &memoryRepository{
+		tenants: make(map[string]*memoryState),
+		events:  events,
+	}
+}
+
+// state return rows of the tenant of ctx, it should be called while the repository is locked
+func (r *memoryRepository) state(ctx context.Context) (*memoryState, error) {
+	tenantID, err := ctxTenant(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	s, ok := r.tenants[tenantID]
+	if !ok {
+		s = newMemoryState()
+		r.tenants[tenantID] = s
+	}
+	return s, nil
+}
+
+// lock hold the repository until the returned func is called, transaction started by Tx already hold it
+func (r *memoryRepository) lock(ctx context.Context) func() {
+	if r.inTx(ctx) {
+		return func() {}
+	}
+	r.mu.Lock()
+	return r.mu.Unlock
+}
+
+func (r *memoryRepository) inTx(ctx context.Context) bool {
+	tx, _ := ctx.Value(memoryTxKey{}).(*memoryRepository)
+	return tx == r
+}
+
+// Tx hold the repository for the whole fn, so transaction is serializable
+func (r *memoryRepository) Tx(ctx context.Context, fn func(ctx context.Context) error) error {
+	if r.inTx(ctx) {
+		return fn(ctx)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	snapshot := make(map[string]*memoryState, len(r.tenants))
+	for tenantID, s := range r.tenants {
+		snapshot[tenantID] = s.clone()
+	}
+	committed := false
+	defer func() {
+		if !committed {
+			r.tenants = snapshot
+		}
+		r.pending = nil
+	}()
+
+	if err := fn(context.WithValue(ctx, memoryTxKey{}, r)); err != nil {
+		return err
+	}
+
+	committed = true
+	if r.events != nil {
+		r.events.Add(r.pending...)
+	}
+	return nil
+}
+
+// memoryTime round t like timestamp(6) column and return it in UTC like the mysql driver
+func memoryTime(t time.Time) time.Time {
+	return t.Round(time.Microsecond).UTC()
+}
+
+func memoryTimePtr(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	v := memoryTime(*t)
+	return &v
+}
+
+// emailUsed return true when email is used by user other than id, including soft deleted user
+func (s *memoryState) emailUsed(email, id string) bool {
+	for _, u := range s.users {
+		if u.ID != id && strings.EqualFold(u.Email, email) {
+			return true
+		}
+	}
+	return false
+}
+
+// insertUser store the column set by createUserQuery into s, the other column is left to its default
+func (r *memoryRepository) insertUser(s *memoryState, v User) error {
+	for _, other := range r.tenants {
+		if _, ok := other.users[v.ID]; ok {
+			return errMemoryDuplicateID
+		}
+	}
+	if s.emailUsed(v.Email, v.ID) {
+		return ErrDuplicateEmail
+	}
+
+	s.users[v.ID] = User{
+		ID:        v.ID,
+		Name:      v.Name,
+		Phone:     v.Phone,
+		Email:     v.Email,
+		Status:    v.Status,
+		CreatedAt: memoryTime(v.CreatedAt),
+		Version:   v.Version,
+	}
+	return nil
+}
+
+func (r *memoryRepository) Create(ctx context.Context, v User) error {
+	defer r.lock(ctx)()
+
+	s, err := r.state(ctx)
+	if err != nil {
+		return err
+	}
+	return r.insertUser(s, v)
+}
+
+func (r *memoryRepository) CreateBatch(ctx context.Context, users []User) error {
+	return r.Tx(ctx, func(ctx context.Context) error {
+		s, err := r.state(ctx)
+		if err != nil {
+			return err
+		}
+		for _, v := range users {
+			if err := r.insertUser(s, v); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (r *memoryRepository) FindExistingEmail(ctx context.Context, emails []string) (map[string]bool, error) {
+	defer r.lock(ctx)()
+
+	s, err := r.state(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	existing := make(map[string]bool)
+	for _, u := range s.users {
+		for _, email := range emails {
+			if strings.EqualFold(u.Email, email) {
+				existing[strings.ToLower(u.Email)] = true
+				break
+			}
+		}
+	}
+	return existing, nil
+}
+
+func (r *memoryRepository) FindByID(ctx context.Context, id string, opts ...FindOption) (User, error) {
+	defer r.lock(ctx)()
+
+	s, err := r.state(ctx)
+	if err != nil {
+		return User{}, err
+	}
+
+	usr, ok := s.users[id]
+	if !ok || (usr.DeletedAt != nil && !newFindOption(opts...).withDeleted) {
+		return User{}, ErrNotFound
+	}
+	return usr, nil
+}
+
+func (r *memoryRepository) FindByEmail(ctx context.Context, email string) (User, error) {
+	defer r.lock(ctx)()
+
+	s, err := r.state(ctx)
+	if err != nil {
+		return User{}, err
+	}
+
+	for _, u := range s.users {
+		if u.DeletedAt == nil && strings.EqualFold(u.Email, email) {
+			return u, nil
+		}
+	}
+	return User{}, ErrNotFound
+}
+
+// updateUser apply fn to the user matching where, ErrNotFound is returned when there is none
+func (s *memoryState) updateUser(id string, where func(User) bool, fn func(*User) error) error {
+	usr, ok := s.users[id]
+	if !ok || !where(usr) {
+		return ErrNotFound
+	}
+	if err := fn(&usr); err != nil {
+		return err
+	}
+	s.users[id] = usr
+	return nil
+}
+
+// versionConflict map ErrNotFound of updateUser which check the version to ErrVersionConflict
+func versionConflict(err error) error {
+	if errors.Is(err, ErrNotFound) {
+		return ErrVersionConflict
+	}
+	return err
+}
+
+func (r *memoryRepository) Update(ctx context.Context, v User) error {
+	defer r.lock(ctx)()
+
+	s, err := r.state(ctx)
+	if err != nil {
+		return err
+	}
+
+	err = s.updateUser(v.ID, func(u User) bool { return u.Version == v.Version && u.DeletedAt == nil }, func(u *User) error {
+		if s.emailUsed(v.Email, v.ID) {
+			return ErrDuplicateEmail
+		}
+		u.Name, u.Phone, u.Email, u.Status = v.Name, v.Phone, v.Email, v.Status
+		u.SuspendedUntil, u.SuspensionReason = memoryTimePtr(v.SuspendedUntil), v.SuspensionReason
+		u.EmailVerifiedAt, u.PhoneVerifiedAt = memoryTimePtr(v.EmailVerifiedAt), memoryTimePtr(v.PhoneVerifiedAt)
+		u.Version++
+		return nil
+	})
+	return versionConflict(err)
+}
+
+func (r *memoryRepository) Delete(ctx context.Context, id string, version int64) error {
+	defer r.lock(ctx)()
+
+	s, err := r.state(ctx)
+	if err != nil {
+		return err
+	}
+
+	now := memoryTime(time.Now())
+	err = s.updateUser(id, func(u User) bool { return u.Version == version && u.DeletedAt == nil }, func(u *User) error {
+		u.DeletedAt = &now
+		u.Version++
+		return nil
+	})
+	return versionConflict(err)
+}
+
+// restorable is soft deleted user which is not merged, see restoreUserQuery
+func restorable(u User) bool {
+	return u.DeletedAt != nil && u.MergedInto == ""
+}
+
+func (r *memoryRepository) Restore(ctx context.Context, id string) error {
+	defer r.lock(ctx)()
+
+	s, err := r.state(ctx)
+	if err != nil {
+		return err
+	}
+
+	return s.updateUser(id, restorable, func(u *User) error {
+		u.DeletedAt = nil
+		u.Version++
+		return nil
+	})
+}
+
+func (r *memoryRepository) Purge(ctx context.Context, id string) error {
+	defer r.lock(ctx)()
+
+	s, err := r.state(ctx)
+	if err != nil {
+		return err
+	}
+
+	usr, ok := s.users[id]
+	if !ok || !restorable(usr) {
+		return ErrNotFound
+	}
+	delete(s.users, id)
+	return nil
+}
+
+func (r *memoryRepository) Erase(ctx context.Context, v User) error {
+	defer r.lock(ctx)()
+
+	s, err := r.state(ctx)
+	if err != nil {
+		return err
+	}
+
+	err = s.updateUser(v.ID, func(u User) bool { return u.Version == v.Version && u.ErasedAt == nil }, func(u *User) error {
+		if s.emailUsed(v.Email, v.ID) {
+			return ErrDuplicateEmail
+		}
+		u.Name, u.Phone, u.Email, u.ErasedAt = v.Name, v.Phone, v.Email, memoryTimePtr(v.ErasedAt)
+		u.EmailVerifiedAt, u.PhoneVerifiedAt = nil, nil
+		u.Version++
+		return nil
+	})
+	return versionConflict(err)
+}
+
+func (r *memoryRepository) Merge(ctx context.Context, v User) error {
+	return r.Tx(ctx, func(ctx context.Context) error {
+		s, err := r.state(ctx)
+		if err != nil {
+			return err
+		}
+
+		err = s.updateUser(v.ID, func(u User) bool { return u.Version == v.Version && u.DeletedAt == nil }, func(u *User) error {
+			u.DeletedAt, u.MergedInto = memoryTimePtr(v.DeletedAt), v.MergedInto
+			u.Version++
+			return nil
+		})
+		if err != nil {
+			return versionConflict(err)
+		}
+
+		for id, u := range s.users {
+			if u.MergedInto == v.ID {
+				u.MergedInto = v.MergedInto
+				s.users[id] = u
+			}
+		}
+		return nil
+	})
+}
+
+// suspensionExpired return true when u is picked by lockExpiredSuspensionQuery
+func suspensionExpired(u User, now time.Time) bool {
+	return u.Status == UserStatusSuspend && u.SuspendedUntil != nil && !u.SuspendedUntil.After(now) && u.DeletedAt == nil
+}
+
+func (r *memoryRepository) LiftExpiredSuspension(ctx context.Context, now time.Time, limit int) ([]User, error) {
+	defer r.lock(ctx)()
+
+	s, err := r.state(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var users []User
+	for _, u := range s.users {
+		if suspensionExpired(u, now) {
+			users = append(users, u)
+		}
+	}
+	slices.SortFunc(users, func(a, b User) int {
+		if c := compareTime(a.SuspendedUntil, b.SuspendedUntil); c != 0 {
+			return c
+		}
+		return strings.Compare(a.ID, b.ID)
+	})
+	users = users[:min(len(users), max(limit, 0))]
+
+	for _, u := range users {
+		u.Status, u.SuspendedUntil, u.SuspensionReason = UserStatusActive, nil, ""
+		u.Version++
+		s.users[u.ID] = u
+	}
+	return users, nil
+}
+
+func (r *memoryRepository) FindPhoneDuplicates(ctx context.Context) ([]User, error) {
+	defer r.lock(ctx)()
+
+	s, err := r.state(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	byPhone := make(map[string]int)
+	for _, u := range s.users {
+		if u.Phone != "" && u.DeletedAt == nil && u.ErasedAt == nil {
+			byPhone[u.Phone]++
+		}
+	}
+
+	var users []User
+	for _, u := range s.users {
+		if u.DeletedAt == nil && u.ErasedAt == nil && byPhone[u.Phone] > 1 {
+			users = append(users, u)
+		}
+	}
+	slices.SortFunc(users, func(a, b User) int {
+		return compareSortBy(a, b, []string{"phone", "created_at", "id"}, userSortFields)
+	})
+	return users, nil
+}
+
+func (r *memoryRepository) FindExpiredSuspensionTenants(ctx context.Context, now time.Time) ([]string, error) {
+	defer r.lock(ctx)()
+
+	var tenants []string
+	for tenantID, s := range r.tenants {
+		for _, u := range s.users {
+			if suspensionExpired(u, now) {
+				tenants = append(tenants, tenantID)
+				break
+			}
+		}
+	}
+	slices.Sort(tenants)
+	return tenants, nil
+}
+
+// matchUser return true when u match the where clause built by qbuilder from p
+func matchUser(u User, p GetUserParam) bool {
+	if p.Email.Valid && !strings.EqualFold(u.Email, p.Email.String) {
+		return false
+	}
+	if len(p.Status) > 0 && !slices.Contains(p.Status, u.Status) {
+		return false
+	}
+	if p.CreatedAtGTE.Valid && u.CreatedAt.Before(p.CreatedAtGTE.Time) {
+		return false
+	}
+	if p.CreatedAtLTE.Valid && u.CreatedAt.After(p.CreatedAtLTE.Time) {
+		return false
+	}
+	return p.IncludeDeleted || u.DeletedAt == nil
+}
+
+// findUsers return user matching p sorted by p.SortBy, id is the last sort field so the order is stable
+func (s *memoryState) findUsers(p GetUserParam) ([]User, error) {
+	if err := validateSortBy(p.SortBy, userSortFields); err != nil {
+		return nil, err
+	}
+
+	var users []User
+	for _, u := range s.users {
+		if matchUser(u, p) {
+			users = append(users, u)
+		}
+	}
+	sortBy := append(slices.Clone(p.SortBy), "id")
+	slices.SortFunc(users, func(a, b User) int { return compareSortBy(a, b, sortBy, userSortFields) })
+	return users, nil
+}
+
+// paginate return the page of rows like the LIMIT clause built by qbuilder
+func paginate[T any](rows []T, page, limit int64) ([]T, entity.Pagination) {
+	pagination := entity.Pagination{Total: int64(len(rows))}
+	pagination.Page, limit = qbuilder.ValidatePageAndLimit(page, limit)
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 {
+		limit = 10
+	}
+
+	start := min((page-1)*limit, pagination.Total)
+	end := min(start+limit, pagination.Total)
+	pagination.Size, pagination.HasNext = end-start, pagination.Total > end
+	if start == end {
+		// no row is scanned
+		return nil, pagination
+	}
+	return rows[start:end], pagination
+}
+
+func (r *memoryRepository) FindAll(ctx context.Context, p GetUserParam) ([]User, entity.Pagination, error) {
+	defer r.lock(ctx)()
+
+	s, err := r.state(ctx)
+	if err != nil {
+		return nil, entity.Pagination{}, err
+	}
+
+	users, err := s.findUsers(p)
+	if err != nil {
+		return nil, entity.Pagination{}, err
+	}
+	users, pagination := paginate(users, p.Page, p.Limit)
+	return users, pagination, nil
+}
+
+// Stream call fn outside of the lock so fn can use the repository
+func (r *memoryRepository) Stream(ctx context.Context, p GetUserParam, fn func(User) error) error {
+	unlock := r.lock(ctx)
+	s, err := r.state(ctx)
+	var users []User
+	if err == nil {
+		users, err = s.findUsers(p)
+	}
+	unlock()
+	if err != nil {
+		return err
+	}
+
+	for _, u := range users {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := fn(u); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// copyChanges return changes as it is read from the json column, so the stored changes is never shared
+func copyChanges(changes []AuditChange) ([]AuditChange, error) {
+	b, err := json.Marshal(changes)
+	if err != nil {
+		return nil, err
+	}
+	var v []AuditChange
+	if err := json.Unmarshal(b, &v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+func (r *memoryRepository) CreateAuditLog(ctx context.Context, logs ...AuditLog) error {
+	defer r.lock(ctx)()
+
+	s, err := r.state(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, v := range logs {
+		changes, err := copyChanges(v.Changes)
+		if err != nil {
+			return err
+		}
+		v.Changes, v.CreatedAt = changes, memoryTime(v.CreatedAt)
+		s.auditLogs = append(s.auditLogs, v)
+	}
+	return nil
+}
+
+func (r *memoryRepository) CreateEvent(ctx context.Context, msgs ...outbox.Message) error {
+	if r.inTx(ctx) {
+		r.pending = append(r.pending, msgs...)
+		return nil
+	}
+	if r.events != nil {
+		r.events.Add(msgs...)
+	}
+	return nil
+}
+
+// matchAuditLog return true when v match the where clause built by qbuilder from p
+func matchAuditLog(v AuditLog, p GetAuditLogParam) bool {
+	if len(p.Action) > 0 && !slices.Contains(p.Action, v.Action) {
+		return false
+	}
+	if p.CreatedAtGTE.Valid && v.CreatedAt.Before(p.CreatedAtGTE.Time) {
+		return false
+	}
+	return !p.CreatedAtLTE.Valid || !v.CreatedAt.After(p.CreatedAtLTE.Time)
+}
+
+func (r *memoryRepository) FindAuditLog(ctx context.Context, userID string, p GetAuditLogParam) ([]AuditLog, entity.Pagination, error) {
+	defer r.lock(ctx)()
+
+	s, err := r.state(ctx)
+	if err != nil {
+		return nil, entity.Pagination{}, err
+	}
+	if err := validateSortBy(p.SortBy, auditLogSortFields); err != nil {
+		return nil, entity.Pagination{}, err
+	}
+
+	var logs []AuditLog
+	for _, v := range s.auditLogs {
+		if v.UserID == userID && matchAuditLog(v, p) {
+			logs = append(logs, v)
+		}
+	}
+	sortBy := append(slices.Clone(p.SortBy), "id")
+	slices.SortFunc(logs, func(a, b AuditLog) int { return compareSortBy(a, b, sortBy, auditLogSortFields) })
+
+	logs, pagination := paginate(logs, p.Page, p.Limit)
+	for i := range logs {
+		changes, err := copyChanges(logs[i].Changes)
+		if err != nil {
+			return nil, pagination, err
+		}
+		logs[i].Changes = changes
+	}
+	return logs, pagination, nil
+}
+
+func (r *memoryRepository) RedactAuditLog(ctx context.Context, userID string) error {
+	defer r.lock(ctx)()
+
+	s, err := r.state(ctx)
+	if err != nil {
+		return err
+	}
+
+	for i, v := range s.auditLogs {
+		if v.UserID != userID {
+			continue
+		}
+		changes, err := copyChanges(v.Changes)
+		if err != nil {
+			return err
+		}
+		if redactChanges(changes) {
+			s.auditLogs[i].Changes = changes
+		}
+	}
+	return nil
+}
+
+func (r *memoryRepository) FindCredential(ctx context.Context, userID string) (Credential, error) {
+	defer r.lock(ctx)()
+
+	s, err := r.state(ctx)
+	if err != nil {
+		return Credential{}, err
+	}
+
+	cred, ok := s.credentials[userID]
+	if !ok {
+		return Credential{}, ErrNotFound
+	}
+	return cred, nil
+}
+
+// deletePasswordReset delete every password reset of the user, see deleteUserPasswordResetQuery
+func (s *memoryState) deletePasswordReset(userID string) {
+	maps.DeleteFunc(s.resets, func(_ string, v PasswordReset) bool { return v.UserID == userID })
+}
+
+func (r *memoryRepository) SavePassword(ctx context.Context, userID, hash string, at time.Time) error {
+	defer r.lock(ctx)()
+
+	s, err := r.state(ctx)
+	if err != nil {
+		return err
+	}
+
+	s.credentials[userID] = Credential{UserID: userID, PasswordHash: hash, PasswordChangedAt: memoryTime(at)}
+	s.deletePasswordReset(userID)
+	return nil
+}
+
+func (r *memoryRepository) UpgradePasswordHash(ctx context.Context, userID, old, new string) error {
+	defer r.lock(ctx)()
+
+	s, err := r.state(ctx)
+	if err != nil {
+		return err
+	}
+
+	if cred, ok := s.credentials[userID]; ok && cred.PasswordHash == old {
+		cred.PasswordHash = new
+		s.credentials[userID] = cred
+	}
+	return nil
+}
+
+func (r *memoryRepository) RecordLoginFailure(ctx context.Context, userID string, max int, lockUntil time.Time) (Credential, error) {
+	defer r.lock(ctx)()
+
+	s, err := r.state(ctx)
+	if err != nil {
+		return Credential{}, err
+	}
+
+	cred, ok := s.credentials[userID]
+	if !ok {
+		return Credential{}, ErrNotFound
+	}
+
+	cred.FailedAttempts++
+	if cred.FailedAttempts >= max {
+		cred.FailedAttempts, cred.LockedUntil = 0, memoryTimePtr(&lockUntil)
+	}
+	s.credentials[userID] = cred
+	return cred, nil
+}
+
+func (r *memoryRepository) ResetLoginFailure(ctx context.Context, userID string) error {
+	defer r.lock(ctx)()
+
+	s, err := r.state(ctx)
+	if err != nil {
+		return err
+	}
+
+	cred, ok := s.credentials[userID]
+	if !ok {
+		return ErrNotFound
+	}
+	cred.FailedAttempts, cred.LockedUntil = 0, nil
+	s.credentials[userID] = cred
+	return nil
+}
+
+func (r *memoryRepository) DeleteCredential(ctx context.Context, userID string) error {
+	defer r.lock(ctx)()
+
+	s, err := r.state(ctx)
+	if err != nil {
+		return err
+	}
+
+	delete(s.credentials, userID)
+	s.deletePasswordReset(userID)
+	return nil
+}
+
+func (r *memoryRepository) CreatePasswordReset(ctx context.Context, v PasswordReset) error {
+	defer r.lock(ctx)()
+
+	s, err := r.state(ctx)
+	if err != nil {
+		return err
+	}
+
+	if _, ok := s.resets[v.TokenHash]; ok {
+		return errors.New("duplicate password reset token")
+	}
+	v.ExpiresAt, v.CreatedAt = memoryTime(v.ExpiresAt), memoryTime(v.CreatedAt)
+	s.resets[v.TokenHash] = v
+	return nil
+}
+
+func (r *memoryRepository) UsePasswordReset(ctx context.Context, tokenHash string, now time.Time) (string, error) {
+	defer r.lock(ctx)()
+
+	s, err := r.state(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	v, ok := s.resets[tokenHash]
+	if !ok || !v.ExpiresAt.After(now) {
+		return "", ErrNotFound
+	}
+	s.deletePasswordReset(v.UserID)
+	return v.UserID, nil
+}
+
+func (r *memoryRepository) SaveVerification(ctx context.Context, v Verification, cooldown, window time.Duration) error {
+	defer r.lock(ctx)()
+
+	s, err := r.state(ctx)
+	if err != nil {
+		return err
+	}
+
+	key := memoryVerificationKey{userID: v.UserID, channel: v.Channel}
+	var prev *Verification
+	if pending, ok := s.verifications[key]; ok {
+		prev = &pending
+	}
+
+	v.ExpiresAt, v.CreatedAt = memoryTime(v.ExpiresAt), memoryTime(v.CreatedAt)
+	v, err = v.renew(prev, cooldown, window)
+	if err != nil {
+		return err
+	}
+	s.verifications[key] = v
+	return nil
+}
+
+func (r *memoryRepository) AttemptVerification(ctx context.Context, userID string, channel VerificationChannel, max int, now time.Time) (Verification, error) {
+	defer r.lock(ctx)()
+
+	s, err := r.state(ctx)
+	if err != nil {
+		return Verification{}, err
+	}
+
+	key := memoryVerificationKey{userID: userID, channel: channel}
+	v, ok := s.verifications[key]
+	if !ok || v.Attempts >= max || !v.ExpiresAt.After(now) {
+		return Verification{}, ErrNotFound
+	}
+	v.Attempts++
+	s.verifications[key] = v
+	return v, nil
+}
+
+func (r *memoryRepository) DeleteVerification(ctx context.Context, userID string, channel VerificationChannel) error {
+	defer r.lock(ctx)()
+
+	s, err := r.state(ctx)
+	if err != nil {
+		return err
+	}
+
+	delete(s.verifications, memoryVerificationKey{userID: userID, channel: channel})
+	return nil
+}
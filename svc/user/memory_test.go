@@ -0,0 +1,97 @@
+package user
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tuingking/supersvc/pkg/outbox"
+	"github.com/tuingking/supersvc/pkg/tenant"
+)
+
+func Test_MemoryRepository_Event(t *testing.T) {
+	ctx := tenant.WithID(context.Background(), stubTenant)
+	events := outbox.NewMemoryStore()
+	svc := NewService(Option{}, NewMemoryRepository(events))
+
+	usr, err := svc.CreateUser(ctx, User{Name: "foo", Email: "foo@bar.com", Status: UserStatusActive})
+	assert.Nil(t, err)
+
+	pending := events.Pending()
+	assert.Len(t, pending, 1, "event is added when the mutation is committed")
+	assert.Equal(t, EventUserCreated, pending[0].Type)
+	assert.Equal(t, usr.ID, pending[0].Key)
+
+	var created UserCreatedEvent
+	assert.Nil(t, json.Unmarshal(pending[0].Payload, &created))
+	assert.Equal(t, stubTenant, created.TenantID, "event carry the tenant of the user")
+
+	_, err = svc.CreateUser(ctx, User{Name: "bar", Email: "FOO@bar.com", Status: UserStatusActive})
+	assert.ErrorIs(t, err, ErrDuplicateEmail)
+	assert.Len(t, events.Pending(), 1, "event of rolled back mutation is discarded")
+
+	history, _, err := svc.GetUserHistory(ctx, usr.ID, GetAuditLogParam{})
+	assert.Nil(t, err)
+	assert.Len(t, history, 1)
+}
+
+func Test_MemoryRepository_Concurrent(t *testing.T) {
+	ctx := tenant.WithID(context.Background(), stubTenant)
+	repo := NewMemoryRepository(nil)
+	usr := User{ID: "u1", Name: "foo", Email: "foo@bar.com", Status: UserStatusActive, CreatedAt: time.Now(), Version: 1}
+	assert.Nil(t, repo.Create(ctx, usr))
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		updated  int
+		conflict int
+	)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := repo.Tx(ctx, func(ctx context.Context) error {
+				if _, _, err := repo.FindAll(ctx, GetUserParam{}); err != nil {
+					return err
+				}
+				return repo.Update(ctx, usr)
+			})
+
+			mu.Lock()
+			defer mu.Unlock()
+			switch {
+			case err == nil:
+				updated++
+			case errors.Is(err, ErrVersionConflict):
+				conflict++
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, 1, updated, "only one update of the same version win")
+	assert.Equal(t, 9, conflict)
+}
+
+func Test_MemoryRepository_NotShared(t *testing.T) {
+	ctx := tenant.WithID(context.Background(), stubTenant)
+	repo := NewMemoryRepository(nil)
+
+	changes := []AuditChange{{Field: "name", From: "foo", To: "bar"}}
+	assert.Nil(t, repo.CreateAuditLog(ctx, AuditLog{ID: "a1", UserID: "u1", Action: AuditActionUpdate, Changes: changes}))
+	changes[0].To = "baz"
+
+	logs, _, err := repo.FindAuditLog(ctx, "u1", GetAuditLogParam{})
+	assert.Nil(t, err)
+	assert.Equal(t, "bar", logs[0].Changes[0].To)
+
+	logs[0].Changes[0].To = "baz"
+	logs, _, err = repo.FindAuditLog(ctx, "u1", GetAuditLogParam{})
+	assert.Nil(t, err)
+	assert.Equal(t, "bar", logs[0].Changes[0].To, "stored changes is not modified through the result")
+}
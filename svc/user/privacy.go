@@ -0,0 +1,114 @@
+package user
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+
+	"github.com/tuingking/supersvc/pkg/logger"
+)
+
+const (
+	// erasedEmailDomain is domain of email of erased user, .invalid is reserved so it is never deliverable
+	erasedEmailDomain = "erased.invalid"
+
+	// userDataHistoryPageSize is number of audit log read per page on data export
+	userDataHistoryPageSize = 100
+)
+
+// UserData is everything stored about the user, it is the response of data access request
+type UserData struct {
+	User       User       `json:"user"`
+	History    []AuditLog `json:"history"`
+	ExportedAt time.Time  `json:"exported_at"`
+}
+
+// erasedToken return random token which replace personal data of erased user.
+// it is not derived from the personal data, so it cannot be reversed.
+func erasedToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return "erased-" + hex.EncodeToString(b), nil
+}
+
+func (s *service) EraseUser(ctx context.Context, id string, reason string) (User, error) {
+	log := logger.Get(ctx)
+
+	usr, err := s.repo.FindByID(ctx, id, WithDeleted())
+	if err != nil {
+		return usr, err
+	}
+	if usr.ErasedAt != nil {
+		return usr, nil
+	}
+
+	token, err := erasedToken()
+	if err != nil {
+		log.Err(err).Msg("failed: generate erased token")
+		return usr, err
+	}
+
+	before := usr
+	now := time.Now()
+	usr.Name, usr.Phone, usr.Email, usr.ErasedAt = token, token, token+"@"+erasedEmailDomain, &now
+	usr.EmailVerifiedAt, usr.PhoneVerifiedAt = nil, nil
+
+	after := usr
+	after.Version++
+
+	erase := func(ctx context.Context) error {
+		if err := s.repo.Erase(ctx, usr); err != nil {
+			return err
+		}
+		if err := s.repo.DeleteCredential(ctx, id); err != nil {
+			return err
+		}
+		if err := s.deleteVerification(ctx, id); err != nil {
+			return err
+		}
+		return s.repo.RedactAuditLog(ctx, id)
+	}
+	if err := s.mutate(ctx, erase, mutation{action: AuditActionErase, before: before, after: after, reason: reason}); err != nil {
+		log.Err(err).Msg("failed: erase user")
+		return before, err
+	}
+
+	log.Info().Str("user_id", id).Msg("user erased")
+
+	return after, nil
+}
+
+func (s *service) ExportUserData(ctx context.Context, id string) (UserData, error) {
+	log := logger.Get(ctx)
+
+	usr, err := s.repo.FindByID(ctx, id, WithDeleted())
+	if err != nil {
+		return UserData{}, err
+	}
+
+	data := UserData{User: usr, History: []AuditLog{}}
+	p := GetAuditLogParam{Page: 1, Limit: userDataHistoryPageSize, SortBy: []string{"created_at"}}
+	for {
+		logs, pagination, err := s.repo.FindAuditLog(ctx, id, p)
+		if err != nil {
+			return UserData{}, err
+		}
+		data.History = append(data.History, logs...)
+		if !pagination.HasNext {
+			break
+		}
+		p.Page++
+	}
+
+	// the export itself is audited, it doesn't change the user
+	if err := s.record(ctx, mutation{action: AuditActionDataExport, before: usr, after: usr}); err != nil {
+		log.Err(err).Msg("failed: record user data export")
+		return UserData{}, err
+	}
+	data.ExportedAt = time.Now()
+
+	return data, nil
+}
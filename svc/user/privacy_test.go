@@ -0,0 +1,115 @@
+package user
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Service_EraseUser(t *testing.T) {
+	ctx := tenantCtx
+
+	t.Run("erase", func(t *testing.T) {
+		repo := newTestRepository()
+		svc := NewService(Option{}, repo)
+
+		usr, err := svc.CreateUser(ctx, User{Name: "foo", Phone: "+6281234567890", Email: "foo@bar.com"})
+		assert.Nil(t, err)
+		usr, err = svc.UpdateUser(ctx, usr.ID, usr.Version, UpdateUser{Name: "bar", Phone: usr.Phone, Email: "bar@foo.com"})
+		assert.Nil(t, err)
+
+		erased, err := svc.EraseUser(ctx, usr.ID, "gdpr request")
+		assert.Nil(t, err)
+		assert.Equal(t, stored(erased), repo.rows().users[usr.ID])
+		assert.NotNil(t, erased.ErasedAt)
+		assert.Equal(t, int64(3), erased.Version)
+		assert.True(t, strings.HasPrefix(erased.Name, "erased-"))
+		assert.Equal(t, erased.Name, erased.Phone)
+		assert.Equal(t, erased.Name+"@erased.invalid", erased.Email)
+		assert.Equal(t, usr.Status, erased.Status)
+
+		// no personal data is left in the audit log
+		logs, _, err := svc.GetUserHistory(ctx, usr.ID, GetAuditLogParam{})
+		assert.Nil(t, err)
+		assert.Len(t, logs, 3)
+		for _, log := range logs {
+			for _, c := range log.Changes {
+				for _, v := range []interface{}{c.From, c.To} {
+					assert.NotContains(t, []interface{}{"foo", "bar", "foo@bar.com", "bar@foo.com", "+6281234567890"}, v)
+				}
+			}
+		}
+		assert.Equal(t, AuditActionErase, logs[0].Action)
+		assert.Equal(t, "gdpr request", logs[0].Reason)
+
+		assert.Equal(t, EventUserErased, repo.events()[len(repo.events())-1].Type)
+	})
+
+	t.Run("idempotent", func(t *testing.T) {
+		repo := newTestRepository(stubUser)
+		svc := NewService(Option{}, repo)
+
+		erased, err := svc.EraseUser(ctx, stubUser.ID, "")
+		assert.Nil(t, err)
+
+		again, err := svc.EraseUser(ctx, stubUser.ID, "")
+		assert.Nil(t, err)
+		assert.Equal(t, stored(erased), again)
+		assert.Len(t, repo.rows().auditLogs, 1)
+		assert.Len(t, repo.events(), 1)
+	})
+
+	t.Run("soft deleted user", func(t *testing.T) {
+		deleted := stubUser
+		deletedAt := time.Now()
+		deleted.DeletedAt = &deletedAt
+
+		svc := NewService(Option{}, newTestRepository(deleted))
+
+		erased, err := svc.EraseUser(ctx, deleted.ID, "")
+		assert.Nil(t, err)
+		assert.NotNil(t, erased.ErasedAt)
+		assert.Equal(t, &deletedAt, erased.DeletedAt)
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		svc := NewService(Option{}, newTestRepository())
+
+		_, err := svc.EraseUser(ctx, "u1", "")
+		assert.ErrorIs(t, err, ErrNotFound)
+	})
+}
+
+func Test_Service_ExportUserData(t *testing.T) {
+	ctx := tenantCtx
+
+	repo := newTestRepository()
+	svc := NewService(Option{}, repo)
+
+	usr, err := svc.CreateUser(ctx, User{Name: "foo", Email: "foo@bar.com"})
+	assert.Nil(t, err)
+	usr, err = svc.ChangeStatus(ctx, usr.ID, UserStatusActive, "")
+	assert.Nil(t, err)
+
+	data, err := svc.ExportUserData(ctx, usr.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, usr, data.User)
+	assert.Len(t, data.History, 2)
+	assert.False(t, data.ExportedAt.IsZero())
+
+	// export is audited without changing the user
+	again, err := svc.ExportUserData(ctx, usr.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, usr, again.User)
+	assert.Len(t, again.History, 3)
+	assert.Equal(t, AuditActionDataExport, again.History[2].Action)
+	assert.Empty(t, again.History[2].Changes)
+	assert.Len(t, repo.events(), 2)
+
+	t.Run("not found", func(t *testing.T) {
+		_, err := svc.ExportUserData(ctx, "u2")
+		assert.ErrorIs(t, err, ErrNotFound)
+	})
+}
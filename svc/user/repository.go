@@ -2,19 +2,134 @@ package user
 
 import (
 	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
 
-	"github.com/rs/zerolog"
+	gomysql "github.com/go-sql-driver/mysql"
+	"github.com/jmoiron/sqlx"
 	"github.com/tuingking/supersvc/entity"
-	"github.com/tuingking/supersvc/pkg/ctxkey"
+	"github.com/tuingking/supersvc/pkg/logger"
 	"github.com/tuingking/supersvc/pkg/mysql"
+	"github.com/tuingking/supersvc/pkg/outbox"
 	"github.com/tuingking/supersvc/pkg/qbuilder"
 )
 
+//go:generate go run github.com/vektra/mockery/v2@v2.53.3 --name=Repository --output=mocks --outpkg=mocks
+
+// Repository of user, every method is scoped by tenant of the ctx, see tenant.WithID.
+// ErrInvalidTenant is returned when ctx has no valid tenant, user of other tenant is never read or changed.
 type Repository interface {
 	FindAll(ctx context.Context, p GetUserParam) ([]User, entity.Pagination, error)
+
+	// Stream call fn for every user matching p, page and limit is ignored.
+	// rows are read one by one, it stops on the first error returned by fn or when ctx is done.
+	Stream(ctx context.Context, p GetUserParam, fn func(User) error) error
+
+	FindByID(ctx context.Context, id string, opts ...FindOption) (User, error)
+
+	// FindByEmail return not deleted user of the normalized email
+	FindByEmail(ctx context.Context, email string) (User, error)
 	Create(ctx context.Context, v User) error
+
+	// CreateBatch insert users using multi rows insert in single transaction
+	CreateBatch(ctx context.Context, users []User) error
+
+	// FindExistingEmail return the given email which is already used, including by soft deleted user
+	FindExistingEmail(ctx context.Context, emails []string) (map[string]bool, error)
+
+	// FindPhoneDuplicates return not deleted and not erased user whose phone is shared by another one,
+	// ordered by phone then created_at
+	FindPhoneDuplicates(ctx context.Context) ([]User, error)
+
+	// Update replace mutable field and increment the version.
+	// v.Version is the expected current version, ErrVersionConflict is returned when the user is changed or deleted.
+	Update(ctx context.Context, v User) error
+
+	// Delete soft delete the user, deleted user is excluded from find unless WithDeleted is used.
+	// ErrVersionConflict is returned when the user is changed or deleted.
+	Delete(ctx context.Context, id string, version int64) error
+
+	// Restore undelete soft deleted user, merged user cannot be restored
+	Restore(ctx context.Context, id string) error
+
+	// Purge permanently delete soft deleted user, merged user is kept so its id can still be resolved
+	Purge(ctx context.Context, id string) error
+
+	// Erase replace name, phone and email and set erased_at, including soft deleted user.
+	// ErrVersionConflict is returned when the user is changed or already erased.
+	Erase(ctx context.Context, v User) error
+
+	// Merge soft delete v and set merged_into, user merged into v before is moved to v.MergedInto.
+	// ErrVersionConflict is returned when v is changed or deleted.
+	Merge(ctx context.Context, v User) error
+
+	// LiftExpiredSuspension reactivate at most limit user whose suspension is expired at now.
+	// it is safe to be called concurrently from multiple replica, reactivated user before the change is returned.
+	LiftExpiredSuspension(ctx context.Context, now time.Time, limit int) ([]User, error)
+
+	// FindExpiredSuspensionTenants return tenant having user whose suspension is expired at now.
+	// it is the only method which is not scoped by tenant, ctx doesn't need one.
+	FindExpiredSuspensionTenants(ctx context.Context, now time.Time) ([]string, error)
+
+	// Tx run fn in transaction, method called with the ctx given to fn use the transaction.
+	// the transaction is committed when fn return nil, nested Tx join the outer transaction.
+	Tx(ctx context.Context, fn func(ctx context.Context) error) error
+
+	// CreateAuditLog insert audit log using multi rows insert
+	CreateAuditLog(ctx context.Context, logs ...AuditLog) error
+
+	// CreateEvent insert domain event to the outbox, it should be called in Tx of the mutation.
+	// the outbox is shared by every tenant, tenant is in the event payload.
+	CreateEvent(ctx context.Context, msgs ...outbox.Message) error
+
+	// FindAuditLog return audit log of the user, including purged user
+	FindAuditLog(ctx context.Context, userID string, p GetAuditLogParam) ([]AuditLog, entity.Pagination, error)
+
+	// RedactAuditLog replace name, phone and email in audit log of the user with AuditRedacted
+	RedactAuditLog(ctx context.Context, userID string) error
+
+	// FindCredential return password of the user, ErrNotFound is returned when the user has no password
+	FindCredential(ctx context.Context, userID string) (Credential, error)
+
+	// SavePassword set password hash of the user, failed login is cleared and pending password reset is deleted
+	SavePassword(ctx context.Context, userID, hash string, at time.Time) error
+
+	// UpgradePasswordHash replace the hash only when it is still old, so concurrent password change is kept
+	UpgradePasswordHash(ctx context.Context, userID, old, new string) error
+
+	// RecordLoginFailure count failed login and return the updated credential.
+	// when the count reach max, the user is locked until lockUntil and the count start over.
+	RecordLoginFailure(ctx context.Context, userID string, max int, lockUntil time.Time) (Credential, error)
+
+	// ResetLoginFailure clear failed login count and lock
+	ResetLoginFailure(ctx context.Context, userID string) error
+
+	// DeleteCredential delete password and pending password reset of the user
+	DeleteCredential(ctx context.Context, userID string) error
+
+	CreatePasswordReset(ctx context.Context, v PasswordReset) error
+
+	// UsePasswordReset return user id of the token hash which is not expired at now.
+	// every password reset of the user is deleted, so the token can be used once.
+	UsePasswordReset(ctx context.Context, tokenHash string, now time.Time) (string, error)
+
+	// SaveVerification replace pending verification of the user and channel.
+	// ErrVerificationCooldown is returned when the pending code is created less than cooldown before v,
+	// attempts of the pending code is kept until window since its first code is elapsed.
+	SaveVerification(ctx context.Context, v Verification, cooldown, window time.Duration) error
+
+	// AttemptVerification count an attempt and return the pending verification.
+	// ErrNotFound is returned when there is none, it is expired at now or max attempt is reached.
+	AttemptVerification(ctx context.Context, userID string, channel VerificationChannel, max int, now time.Time) (Verification, error)
+	DeleteVerification(ctx context.Context, userID string, channel VerificationChannel) error
 }
 
+// mysqlErrDuplicateEntry is mysql error number for duplicate entry of unique key
+const mysqlErrDuplicateEntry = 1062
+
 type repository struct {
 	opt RepositoryOption
 	db  mysql.MySQL
@@ -29,62 +144,478 @@ func NewRepository(opt RepositoryOption, db mysql.MySQL) Repository {
 	}
 }
 
+// dbtx is implemented by *sql.DB and *sql.Tx
+type dbtx interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+type txKey struct{}
+
+// conn return the transaction started by Tx, or the db when there is none
+func (r *repository) conn(ctx context.Context) dbtx {
+	if tx, ok := ctx.Value(txKey{}).(*sql.Tx); ok {
+		return tx
+	}
+	return r.db.Get()
+}
+
+func (r *repository) Tx(ctx context.Context, fn func(ctx context.Context) error) error {
+	log := logger.Get(ctx)
+
+	if _, ok := ctx.Value(txKey{}).(*sql.Tx); ok {
+		return fn(ctx)
+	}
+
+	tx, err := r.db.Get().BeginTx(ctx, nil)
+	if err != nil {
+		log.Err(err).Msg("failed: db.BeginTx")
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := fn(context.WithValue(ctx, txKey{}, tx)); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Err(err).Msg("failed: tx.Commit")
+		return err
+	}
+
+	return nil
+}
+
+type findOption struct {
+	withDeleted bool
+}
+
+type FindOption func(*findOption)
+
+// WithDeleted include soft deleted user
+func WithDeleted() FindOption {
+	return func(o *findOption) {
+		o.withDeleted = true
+	}
+}
+
+func newFindOption(opts ...FindOption) findOption {
+	var o findOption
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
 func (r *repository) Create(ctx context.Context, v User) error {
-	logger := ctx.Value(ctxkey.ZeroLogSubLogger).(zerolog.Logger)
+	log := logger.Get(ctx)
+
+	tenantID, err := ctxTenant(ctx)
+	if err != nil {
+		return err
+	}
 
-	res, err := r.db.Get().ExecContext(ctx, createUserQuery,
+	res, err := r.conn(ctx).ExecContext(ctx, createUserQuery,
+		tenantID,
 		v.ID,
 		v.Name,
 		v.Phone,
 		v.Email,
 		v.Status,
 		v.CreatedAt,
+		v.Version,
 	)
 	if err != nil {
-		logger.Err(err).Msg("failed: db.ExecContext")
-		return err
+		log.Err(err).Msg("failed: db.ExecContext")
+		return translateError(err)
 	}
 
 	rowAffected, _ := res.RowsAffected()
-	logger.Debug().Int64("rows_affected", rowAffected)
+	log.Debug().Int64("rows_affected", rowAffected)
 
 	return nil
 }
 
+func (r *repository) CreateBatch(ctx context.Context, users []User) error {
+	log := logger.Get(ctx)
+
+	tenantID, err := ctxTenant(ctx)
+	if err != nil {
+		return err
+	}
+	if len(users) == 0 {
+		return nil
+	}
+
+	var query strings.Builder
+	query.WriteString(insertUserQuery)
+	args := make([]interface{}, 0, len(users)*8)
+	for i, v := range users {
+		if i > 0 {
+			query.WriteString(", ")
+		}
+		query.WriteString(insertUserValues)
+		args = append(args, tenantID, v.ID, v.Name, v.Phone, v.Email, v.Status, v.CreatedAt, v.Version)
+	}
+
+	return r.Tx(ctx, func(ctx context.Context) error {
+		if _, err := r.conn(ctx).ExecContext(ctx, query.String(), args...); err != nil {
+			log.Err(err).Msg("failed: tx.ExecContext")
+			return translateError(err)
+		}
+		return nil
+	})
+}
+
+func (r *repository) FindExistingEmail(ctx context.Context, emails []string) (map[string]bool, error) {
+	log := logger.Get(ctx)
+
+	tenantID, err := ctxTenant(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	existing := make(map[string]bool)
+	if len(emails) == 0 {
+		return existing, nil
+	}
+
+	query, args, err := sqlx.In(findExistingEmailQuery, tenantID, emails)
+	if err != nil {
+		log.Err(err).Msg("failed: sqlx.In")
+		return nil, err
+	}
+
+	rows, err := r.conn(ctx).QueryContext(ctx, query, args...)
+	if err != nil {
+		log.Err(err).Msg("failed: db.QueryContext")
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var email string
+		if err := rows.Scan(&email); err != nil {
+			log.Err(err).Msg("failed: rows.Scan")
+			return nil, err
+		}
+		// email column is case insensitive
+		existing[strings.ToLower(email)] = true
+	}
+
+	return existing, rows.Err()
+}
+
+func (r *repository) FindByID(ctx context.Context, id string, opts ...FindOption) (User, error) {
+	log := logger.Get(ctx)
+
+	tenantID, err := ctxTenant(ctx)
+	if err != nil {
+		return User{}, err
+	}
+
+	query := getActiveUserByIDQuery
+	if newFindOption(opts...).withDeleted {
+		query = getUserByIDQuery
+	}
+
+	usr, err := scanUser(r.conn(ctx).QueryRowContext(ctx, query, tenantID, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return usr, ErrNotFound
+	}
+	if err != nil {
+		log.Error().Err(err).Msg("failed: row.Scan")
+		return usr, err
+	}
+
+	return usr, nil
+}
+
+func (r *repository) FindByEmail(ctx context.Context, email string) (User, error) {
+	log := logger.Get(ctx)
+
+	tenantID, err := ctxTenant(ctx)
+	if err != nil {
+		return User{}, err
+	}
+
+	usr, err := scanUser(r.conn(ctx).QueryRowContext(ctx, getUserByEmailQuery, tenantID, email))
+	if errors.Is(err, sql.ErrNoRows) {
+		return usr, ErrNotFound
+	}
+	if err != nil {
+		log.Error().Err(err).Msg("failed: row.Scan")
+		return usr, err
+	}
+
+	return usr, nil
+}
+
+// Update replace name, phone, email, status and suspension.
+// version is always incremented, so 0 rows affected means the version doesn't match
+func (r *repository) Update(ctx context.Context, v User) error {
+	tenantID, err := ctxTenant(ctx)
+	if err != nil {
+		return err
+	}
+
+	err = r.execByID(ctx, updateUserQuery,
+		v.Name,
+		v.Phone,
+		v.Email,
+		v.Status,
+		v.SuspendedUntil,
+		v.SuspensionReason,
+		v.EmailVerifiedAt,
+		v.PhoneVerifiedAt,
+		tenantID,
+		v.ID,
+		v.Version,
+	)
+	if errors.Is(err, ErrNotFound) {
+		return ErrVersionConflict
+	}
+	return translateError(err)
+}
+
+func (r *repository) Delete(ctx context.Context, id string, version int64) error {
+	tenantID, err := ctxTenant(ctx)
+	if err != nil {
+		return err
+	}
+
+	err = r.execByID(ctx, softDeleteUserQuery, time.Now(), tenantID, id, version)
+	if errors.Is(err, ErrNotFound) {
+		return ErrVersionConflict
+	}
+	return err
+}
+
+func (r *repository) Restore(ctx context.Context, id string) error {
+	tenantID, err := ctxTenant(ctx)
+	if err != nil {
+		return err
+	}
+	return r.execByID(ctx, restoreUserQuery, tenantID, id)
+}
+
+func (r *repository) Purge(ctx context.Context, id string) error {
+	tenantID, err := ctxTenant(ctx)
+	if err != nil {
+		return err
+	}
+	return r.execByID(ctx, purgeUserQuery, tenantID, id)
+}
+
+func (r *repository) Erase(ctx context.Context, v User) error {
+	tenantID, err := ctxTenant(ctx)
+	if err != nil {
+		return err
+	}
+
+	err = r.execByID(ctx, eraseUserQuery, v.Name, v.Phone, v.Email, v.ErasedAt, tenantID, v.ID, v.Version)
+	if errors.Is(err, ErrNotFound) {
+		return ErrVersionConflict
+	}
+	return translateError(err)
+}
+
+func (r *repository) Merge(ctx context.Context, v User) error {
+	log := logger.Get(ctx)
+
+	tenantID, err := ctxTenant(ctx)
+	if err != nil {
+		return err
+	}
+
+	return r.Tx(ctx, func(ctx context.Context) error {
+		err := r.execByID(ctx, mergeUserQuery, v.DeletedAt, v.MergedInto, tenantID, v.ID, v.Version)
+		if errors.Is(err, ErrNotFound) {
+			return ErrVersionConflict
+		}
+		if err != nil {
+			return err
+		}
+
+		if _, err := r.conn(ctx).ExecContext(ctx, moveMergedUserQuery, v.MergedInto, tenantID, v.ID); err != nil {
+			log.Err(err).Msg("failed: tx.ExecContext")
+			return err
+		}
+		return nil
+	})
+}
+
+// execByID exec query which affect single user, ErrNotFound is returned when no row affected
+func (r *repository) execByID(ctx context.Context, query string, args ...interface{}) error {
+	log := logger.Get(ctx)
+
+	res, err := r.conn(ctx).ExecContext(ctx, query, args...)
+	if err != nil {
+		log.Err(err).Msg("failed: db.ExecContext")
+		return err
+	}
+
+	rowAffected, err := res.RowsAffected()
+	if err != nil {
+		log.Err(err).Msg("failed: res.RowsAffected")
+		return err
+	}
+	if rowAffected == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+func (r *repository) LiftExpiredSuspension(ctx context.Context, now time.Time, limit int) ([]User, error) {
+	log := logger.Get(ctx)
+
+	tenantID, err := ctxTenant(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var users []User
+	err = r.Tx(ctx, func(ctx context.Context) error {
+		tx := r.conn(ctx)
+
+		rows, err := tx.QueryContext(ctx, lockExpiredSuspensionQuery, tenantID, UserStatusSuspend, now, limit)
+		if err != nil {
+			log.Err(err).Msg("failed: tx.QueryContext")
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			usr, err := scanUser(rows)
+			if err != nil {
+				log.Err(err).Msg("failed: rows.Scan")
+				return err
+			}
+			users = append(users, usr)
+		}
+		if err := rows.Err(); err != nil {
+			log.Err(err).Msg("failed: rows.Err")
+			return err
+		}
+		rows.Close()
+
+		for _, usr := range users {
+			if _, err := tx.ExecContext(ctx, liftSuspensionQuery, UserStatusActive, tenantID, usr.ID); err != nil {
+				log.Err(err).Str("user_id", usr.ID).Msg("failed: tx.ExecContext")
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return users, nil
+}
+
+func (r *repository) FindPhoneDuplicates(ctx context.Context) ([]User, error) {
+	log := logger.Get(ctx)
+
+	tenantID, err := ctxTenant(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := r.conn(ctx).QueryContext(ctx, findPhoneDuplicateQuery, tenantID, tenantID)
+	if err != nil {
+		log.Err(err).Msg("failed: db.QueryContext")
+		return nil, err
+	}
+	defer rows.Close()
+
+	var users []User
+	for rows.Next() {
+		usr, err := scanUser(rows)
+		if err != nil {
+			log.Err(err).Msg("failed: rows.Scan")
+			return nil, err
+		}
+		users = append(users, usr)
+	}
+
+	return users, rows.Err()
+}
+
+func (r *repository) FindExpiredSuspensionTenants(ctx context.Context, now time.Time) ([]string, error) {
+	log := logger.Get(ctx)
+
+	rows, err := r.conn(ctx).QueryContext(ctx, findExpiredSuspensionTenantsQuery, UserStatusSuspend, now)
+	if err != nil {
+		log.Err(err).Msg("failed: db.QueryContext")
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tenants []string
+	for rows.Next() {
+		var tenantID string
+		if err := rows.Scan(&tenantID); err != nil {
+			log.Err(err).Msg("failed: rows.Scan")
+			return nil, err
+		}
+		tenants = append(tenants, tenantID)
+	}
+
+	return tenants, rows.Err()
+}
+
+// translateError map mysql error to user domain error
+func translateError(err error) error {
+	var mysqlErr *gomysql.MySQLError
+	if errors.As(err, &mysqlErr) && mysqlErr.Number == mysqlErrDuplicateEntry && strings.Contains(mysqlErr.Message, "user_email_uq") {
+		return ErrDuplicateEmail
+	}
+	return err
+}
+
 func (r *repository) FindAll(ctx context.Context, p GetUserParam) ([]User, entity.Pagination, error) {
-	logger := ctx.Value(ctxkey.ZeroLogSubLogger).(zerolog.Logger)
+	log := logger.Get(ctx)
 
 	var (
 		results    []User
 		pagination entity.Pagination
 	)
 
+	tenantID, err := ctxTenant(ctx)
+	if err != nil {
+		return results, pagination, err
+	}
+	if err := validateSortBy(p.SortBy, userSortFields); err != nil {
+		return results, pagination, err
+	}
 	p.Page, p.Limit = qbuilder.ValidatePageAndLimit(p.Page, p.Limit)
 
+	// tenant clause is used by the count too
 	qb := qbuilder.New(qbuilder.WithExtraLimit())
+	qb.AddWhereClause(tenantWhereClause, tenantID)
+	if !p.IncludeDeleted {
+		qb.AddWhereClause(notDeletedWhereClause)
+	}
 	clause, args, err := qb.Build(&p)
 	if err != nil {
-		logger.Error().Err(err).Msg("failed: qbuilder.Build")
+		log.Error().Err(err).Msg("failed: qbuilder.Build")
 		return results, pagination, err
 	}
 
-	rows, err := r.db.Get().QueryContext(ctx, getUserQuery+clause, args...)
+	rows, err := r.conn(ctx).QueryContext(ctx, getUserQuery+clause, args...)
 	if err != nil {
-		logger.Error().Err(err).Msg("failed: db.QueryContext")
+		log.Error().Err(err).Msg("failed: db.QueryContext")
 		return results, pagination, err
 	}
+	defer rows.Close()
 
 	for rows.Next() {
-		var usr User
-		if err := rows.Scan(
-			&usr.ID,
-			&usr.Name,
-			&usr.Phone,
-			&usr.Email,
-			&usr.Status,
-			&usr.CreatedAt,
-		); err != nil {
-			logger.Error().Err(err).Msg("failed: rows.Scan")
+		usr, err := scanUser(rows)
+		if err != nil {
+			log.Error().Err(err).Msg("failed: rows.Scan")
 			return results, pagination, err
 		}
 		results = append(results, usr)
@@ -92,12 +623,12 @@ func (r *repository) FindAll(ctx context.Context, p GetUserParam) ([]User, entit
 
 	clausec, argsc, err := qb.BuildCount()
 	if err != nil {
-		logger.Error().Err(err).Msg("failed: qbuilder.BuildCount")
+		log.Error().Err(err).Msg("failed: qbuilder.BuildCount")
 		return results, pagination, err
 	}
 
 	var totalData int
-	row := r.db.Get().QueryRowContext(ctx, countUserQuery+clausec, argsc...)
+	row := r.conn(ctx).QueryRowContext(ctx, countUserQuery+clausec, argsc...)
 	row.Scan(&totalData)
 
 	size := int64(len(results))
@@ -116,3 +647,505 @@ func (r *repository) FindAll(ctx context.Context, p GetUserParam) ([]User, entit
 
 	return results, pagination, nil
 }
+
+func (r *repository) Stream(ctx context.Context, p GetUserParam, fn func(User) error) error {
+	log := logger.Get(ctx)
+
+	tenantID, err := ctxTenant(ctx)
+	if err != nil {
+		return err
+	}
+	if err := validateSortBy(p.SortBy, userSortFields); err != nil {
+		return err
+	}
+
+	qb := qbuilder.New(qbuilder.WithoutLimit())
+	qb.AddWhereClause(tenantWhereClause, tenantID)
+	if !p.IncludeDeleted {
+		qb.AddWhereClause(notDeletedWhereClause)
+	}
+	clause, args, err := qb.Build(&p)
+	if err != nil {
+		log.Error().Err(err).Msg("failed: qbuilder.Build")
+		return err
+	}
+
+	rows, err := r.conn(ctx).QueryContext(ctx, getUserQuery+clause, args...)
+	if err != nil {
+		log.Error().Err(err).Msg("failed: db.QueryContext")
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		usr, err := scanUser(rows)
+		if err != nil {
+			log.Error().Err(err).Msg("failed: rows.Scan")
+			return err
+		}
+		if err := fn(usr); err != nil {
+			return err
+		}
+	}
+
+	// rows.Err is ctx.Err when the query is canceled
+	return rows.Err()
+}
+
+// scanner is implemented by *sql.Row and *sql.Rows
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanUser scan single row of user columns, see getUserQuery
+func scanUser(row scanner) (User, error) {
+	var usr User
+	err := row.Scan(
+		&usr.ID,
+		&usr.Name,
+		&usr.Phone,
+		&usr.Email,
+		&usr.Status,
+		&usr.CreatedAt,
+		&usr.DeletedAt,
+		&usr.SuspendedUntil,
+		&usr.SuspensionReason,
+		&usr.Version,
+		&usr.ErasedAt,
+		&usr.MergedInto,
+		&usr.EmailVerifiedAt,
+		&usr.PhoneVerifiedAt,
+	)
+	return usr, err
+}
+
+func (r *repository) CreateAuditLog(ctx context.Context, logs ...AuditLog) error {
+	log := logger.Get(ctx)
+
+	tenantID, err := ctxTenant(ctx)
+	if err != nil {
+		return err
+	}
+	if len(logs) == 0 {
+		return nil
+	}
+
+	var query strings.Builder
+	query.WriteString(insertAuditLogQuery)
+	args := make([]interface{}, 0, len(logs)*9)
+	for i, v := range logs {
+		changes, err := json.Marshal(v.Changes)
+		if err != nil {
+			log.Err(err).Msg("failed: marshal audit changes")
+			return err
+		}
+
+		if i > 0 {
+			query.WriteString(", ")
+		}
+		query.WriteString(insertAuditLogValues)
+		args = append(args, tenantID, v.ID, v.UserID, v.Action, v.Actor, v.RequestID, v.Reason, changes, v.CreatedAt)
+	}
+
+	if _, err := r.conn(ctx).ExecContext(ctx, query.String(), args...); err != nil {
+		log.Err(err).Msg("failed: db.ExecContext")
+		return err
+	}
+
+	return nil
+}
+
+func (r *repository) CreateEvent(ctx context.Context, msgs ...outbox.Message) error {
+	log := logger.Get(ctx)
+
+	if err := outbox.Insert(ctx, r.conn(ctx), msgs...); err != nil {
+		log.Err(err).Msg("failed: outbox.Insert")
+		return err
+	}
+	return nil
+}
+
+func (r *repository) FindAuditLog(ctx context.Context, userID string, p GetAuditLogParam) ([]AuditLog, entity.Pagination, error) {
+	log := logger.Get(ctx)
+
+	var (
+		results    []AuditLog
+		pagination entity.Pagination
+	)
+
+	tenantID, err := ctxTenant(ctx)
+	if err != nil {
+		return results, pagination, err
+	}
+	if err := validateSortBy(p.SortBy, auditLogSortFields); err != nil {
+		return results, pagination, err
+	}
+	p.Page, p.Limit = qbuilder.ValidatePageAndLimit(p.Page, p.Limit)
+
+	qb := qbuilder.New(qbuilder.WithExtraLimit())
+	qb.AddWhereClause(tenantWhereClause, tenantID)
+	qb.AddWhereClause(auditLogUserClause, userID)
+	clause, args, err := qb.Build(&p)
+	if err != nil {
+		log.Error().Err(err).Msg("failed: qbuilder.Build")
+		return results, pagination, err
+	}
+
+	rows, err := r.conn(ctx).QueryContext(ctx, getAuditLogQuery+clause, args...)
+	if err != nil {
+		log.Error().Err(err).Msg("failed: db.QueryContext")
+		return results, pagination, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			v       AuditLog
+			changes []byte
+		)
+		if err := rows.Scan(&v.ID, &v.UserID, &v.Action, &v.Actor, &v.RequestID, &v.Reason, &changes, &v.CreatedAt); err != nil {
+			log.Error().Err(err).Msg("failed: rows.Scan")
+			return results, pagination, err
+		}
+		if err := json.Unmarshal(changes, &v.Changes); err != nil {
+			log.Error().Err(err).Str("audit_log_id", v.ID).Msg("failed: unmarshal audit changes")
+			return results, pagination, err
+		}
+		results = append(results, v)
+	}
+	if err := rows.Err(); err != nil {
+		log.Error().Err(err).Msg("failed: rows.Err")
+		return results, pagination, err
+	}
+
+	clausec, argsc, err := qb.BuildCount()
+	if err != nil {
+		log.Error().Err(err).Msg("failed: qbuilder.BuildCount")
+		return results, pagination, err
+	}
+
+	var totalData int
+	row := r.conn(ctx).QueryRowContext(ctx, countAuditLogQuery+clausec, argsc...)
+	row.Scan(&totalData)
+
+	size := int64(len(results))
+	hasNext := len(results) > int(p.Limit)
+	if hasNext {
+		results = results[:p.Limit]
+		size = p.Limit
+	}
+
+	pagination = entity.Pagination{
+		Page:    p.Page,
+		Size:    size,
+		HasNext: hasNext,
+		Total:   int64(totalData),
+	}
+
+	return results, pagination, nil
+}
+
+func (r *repository) RedactAuditLog(ctx context.Context, userID string) error {
+	log := logger.Get(ctx)
+
+	tenantID, err := ctxTenant(ctx)
+	if err != nil {
+		return err
+	}
+
+	return r.Tx(ctx, func(ctx context.Context) error {
+		tx := r.conn(ctx)
+
+		rows, err := tx.QueryContext(ctx, lockAuditLogChangesQuery, tenantID, userID)
+		if err != nil {
+			log.Err(err).Msg("failed: tx.QueryContext")
+			return err
+		}
+		defer rows.Close()
+
+		type redactedLog struct {
+			id      string
+			changes []byte
+		}
+		var redacted []redactedLog
+		for rows.Next() {
+			var (
+				id      string
+				changes []byte
+				v       []AuditChange
+			)
+			if err := rows.Scan(&id, &changes); err != nil {
+				log.Err(err).Msg("failed: rows.Scan")
+				return err
+			}
+			if err := json.Unmarshal(changes, &v); err != nil {
+				log.Err(err).Str("audit_log_id", id).Msg("failed: unmarshal audit changes")
+				return err
+			}
+			if !redactChanges(v) {
+				continue
+			}
+			b, err := json.Marshal(v)
+			if err != nil {
+				log.Err(err).Str("audit_log_id", id).Msg("failed: marshal audit changes")
+				return err
+			}
+			redacted = append(redacted, redactedLog{id: id, changes: b})
+		}
+		if err := rows.Err(); err != nil {
+			log.Err(err).Msg("failed: rows.Err")
+			return err
+		}
+		rows.Close()
+
+		for _, v := range redacted {
+			if _, err := tx.ExecContext(ctx, updateAuditLogChangesQuery, v.changes, tenantID, v.id); err != nil {
+				log.Err(err).Str("audit_log_id", v.id).Msg("failed: tx.ExecContext")
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (r *repository) FindCredential(ctx context.Context, userID string) (Credential, error) {
+	return r.findCredential(ctx, getCredentialQuery, userID)
+}
+
+func (r *repository) findCredential(ctx context.Context, query, userID string) (Credential, error) {
+	log := logger.Get(ctx)
+
+	var v Credential
+	tenantID, err := ctxTenant(ctx)
+	if err != nil {
+		return v, err
+	}
+
+	err = r.conn(ctx).QueryRowContext(ctx, query, tenantID, userID).
+		Scan(&v.UserID, &v.PasswordHash, &v.FailedAttempts, &v.LockedUntil, &v.PasswordChangedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return v, ErrNotFound
+	}
+	if err != nil {
+		log.Error().Err(err).Msg("failed: row.Scan")
+		return v, err
+	}
+
+	return v, nil
+}
+
+func (r *repository) SavePassword(ctx context.Context, userID, hash string, at time.Time) error {
+	log := logger.Get(ctx)
+
+	tenantID, err := ctxTenant(ctx)
+	if err != nil {
+		return err
+	}
+
+	return r.Tx(ctx, func(ctx context.Context) error {
+		tx := r.conn(ctx)
+		if _, err := tx.ExecContext(ctx, saveCredentialQuery, tenantID, userID, hash, at); err != nil {
+			log.Err(err).Msg("failed: tx.ExecContext")
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, deleteUserPasswordResetQuery, tenantID, userID); err != nil {
+			log.Err(err).Msg("failed: tx.ExecContext")
+			return err
+		}
+		return nil
+	})
+}
+
+func (r *repository) UpgradePasswordHash(ctx context.Context, userID, old, new string) error {
+	log := logger.Get(ctx)
+
+	tenantID, err := ctxTenant(ctx)
+	if err != nil {
+		return err
+	}
+
+	if _, err := r.conn(ctx).ExecContext(ctx, upgradePasswordHashQuery, new, tenantID, userID, old); err != nil {
+		log.Err(err).Msg("failed: db.ExecContext")
+		return err
+	}
+	return nil
+}
+
+func (r *repository) RecordLoginFailure(ctx context.Context, userID string, max int, lockUntil time.Time) (Credential, error) {
+	var cred Credential
+	tenantID, err := ctxTenant(ctx)
+	if err != nil {
+		return cred, err
+	}
+
+	err = r.Tx(ctx, func(ctx context.Context) error {
+		var err error
+		cred, err = r.findCredential(ctx, lockCredentialQuery, userID)
+		if err != nil {
+			return err
+		}
+
+		cred.FailedAttempts++
+		if cred.FailedAttempts >= max {
+			cred.FailedAttempts, cred.LockedUntil = 0, &lockUntil
+		}
+		return r.execByID(ctx, updateLoginFailureQuery, cred.FailedAttempts, cred.LockedUntil, tenantID, userID)
+	})
+	return cred, err
+}
+
+func (r *repository) ResetLoginFailure(ctx context.Context, userID string) error {
+	tenantID, err := ctxTenant(ctx)
+	if err != nil {
+		return err
+	}
+	return r.execByID(ctx, updateLoginFailureQuery, 0, nil, tenantID, userID)
+}
+
+func (r *repository) DeleteCredential(ctx context.Context, userID string) error {
+	log := logger.Get(ctx)
+
+	tenantID, err := ctxTenant(ctx)
+	if err != nil {
+		return err
+	}
+
+	return r.Tx(ctx, func(ctx context.Context) error {
+		tx := r.conn(ctx)
+		if _, err := tx.ExecContext(ctx, deleteCredentialQuery, tenantID, userID); err != nil {
+			log.Err(err).Msg("failed: tx.ExecContext")
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, deleteUserPasswordResetQuery, tenantID, userID); err != nil {
+			log.Err(err).Msg("failed: tx.ExecContext")
+			return err
+		}
+		return nil
+	})
+}
+
+func (r *repository) CreatePasswordReset(ctx context.Context, v PasswordReset) error {
+	log := logger.Get(ctx)
+
+	tenantID, err := ctxTenant(ctx)
+	if err != nil {
+		return err
+	}
+
+	if _, err := r.conn(ctx).ExecContext(ctx, createPasswordResetQuery, tenantID, v.TokenHash, v.UserID, v.ExpiresAt, v.CreatedAt); err != nil {
+		log.Err(err).Msg("failed: db.ExecContext")
+		return err
+	}
+	return nil
+}
+
+func (r *repository) UsePasswordReset(ctx context.Context, tokenHash string, now time.Time) (string, error) {
+	log := logger.Get(ctx)
+
+	tenantID, err := ctxTenant(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	var userID string
+	err = r.Tx(ctx, func(ctx context.Context) error {
+		tx := r.conn(ctx)
+
+		err := tx.QueryRowContext(ctx, lockPasswordResetQuery, tenantID, tokenHash, now).Scan(&userID)
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNotFound
+		}
+		if err != nil {
+			log.Err(err).Msg("failed: row.Scan")
+			return err
+		}
+
+		if _, err := tx.ExecContext(ctx, deleteUserPasswordResetQuery, tenantID, userID); err != nil {
+			log.Err(err).Msg("failed: tx.ExecContext")
+			return err
+		}
+		return nil
+	})
+	return userID, err
+}
+
+// SaveVerification lock the pending code, so concurrent resend cannot reset the attempts
+func (r *repository) SaveVerification(ctx context.Context, v Verification, cooldown, window time.Duration) error {
+	log := logger.Get(ctx)
+
+	tenantID, err := ctxTenant(ctx)
+	if err != nil {
+		return err
+	}
+
+	return r.Tx(ctx, func(ctx context.Context) error {
+		var (
+			prev    *Verification
+			pending Verification
+		)
+		err := r.conn(ctx).QueryRowContext(ctx, lockVerificationQuery, tenantID, v.UserID, v.Channel).
+			Scan(&pending.Attempts, &pending.WindowStartedAt, &pending.CreatedAt)
+		switch {
+		case err == nil:
+			prev = &pending
+		case !errors.Is(err, sql.ErrNoRows):
+			log.Err(err).Msg("failed: row.Scan")
+			return err
+		}
+
+		v, err := v.renew(prev, cooldown, window)
+		if err != nil {
+			return err
+		}
+
+		_, err = r.conn(ctx).ExecContext(ctx, saveVerificationQuery, tenantID, v.UserID, v.Channel, v.Contact, v.CodeHash, v.Attempts, v.WindowStartedAt, v.ExpiresAt, v.CreatedAt)
+		if err != nil {
+			log.Err(err).Msg("failed: tx.ExecContext")
+			return err
+		}
+		return nil
+	})
+}
+
+func (r *repository) AttemptVerification(ctx context.Context, userID string, channel VerificationChannel, max int, now time.Time) (Verification, error) {
+	log := logger.Get(ctx)
+
+	var v Verification
+	tenantID, err := ctxTenant(ctx)
+	if err != nil {
+		return v, err
+	}
+
+	err = r.Tx(ctx, func(ctx context.Context) error {
+		if err := r.execByID(ctx, attemptVerificationQuery, tenantID, userID, channel, max, now); err != nil {
+			return err
+		}
+
+		err := r.conn(ctx).QueryRowContext(ctx, getVerificationQuery, tenantID, userID, channel).
+			Scan(&v.UserID, &v.Channel, &v.Contact, &v.CodeHash, &v.Attempts, &v.WindowStartedAt, &v.ExpiresAt, &v.CreatedAt)
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNotFound
+		}
+		if err != nil {
+			log.Err(err).Msg("failed: row.Scan")
+			return err
+		}
+		return nil
+	})
+	return v, err
+}
+
+func (r *repository) DeleteVerification(ctx context.Context, userID string, channel VerificationChannel) error {
+	log := logger.Get(ctx)
+
+	tenantID, err := ctxTenant(ctx)
+	if err != nil {
+		return err
+	}
+
+	if _, err := r.conn(ctx).ExecContext(ctx, deleteVerificationQuery, tenantID, userID, channel); err != nil {
+		log.Err(err).Msg("failed: db.ExecContext")
+		return err
+	}
+	return nil
+}
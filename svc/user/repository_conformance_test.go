@@ -0,0 +1,749 @@
+package user
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	gomysql "github.com/go-sql-driver/mysql"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tuingking/supersvc/entity"
+	"github.com/tuingking/supersvc/pkg/tenant"
+)
+
+// conformanceMySQLDSNEnv is dsn of migrated mysql database used by Test_MySQLRepository_Conformance.
+// every table of the user service is emptied before each test, don't point it to a database in use.
+const conformanceMySQLDSNEnv = "USER_TEST_MYSQL_DSN"
+
+func Test_MemoryRepository_Conformance(t *testing.T) {
+	testRepositoryConformance(t, func(t *testing.T) Repository {
+		return NewMemoryRepository(nil)
+	})
+}
+
+func Test_MySQLRepository_Conformance(t *testing.T) {
+	dsn := os.Getenv(conformanceMySQLDSNEnv)
+	if dsn == "" {
+		t.Skipf("%s is not set", conformanceMySQLDSNEnv)
+	}
+
+	cfg, err := gomysql.ParseDSN(dsn)
+	require.Nil(t, err)
+	cfg.ParseTime = true
+
+	db, err := sql.Open("mysql", cfg.FormatDSN())
+	require.Nil(t, err)
+	t.Cleanup(func() { db.Close() })
+	require.Nil(t, db.Ping())
+
+	testRepositoryConformance(t, func(t *testing.T) Repository {
+		for _, table := range []string{"user", "user_audit_log", "user_credential", "user_password_reset", "user_verification", "outbox"} {
+			_, err := db.Exec("DELETE FROM `" + table + "`")
+			require.Nil(t, err)
+		}
+		return NewRepository(RepositoryOption{}, mockDB{db: db})
+	})
+}
+
+// testRepositoryConformance is behavior every Repository implementation must have, newRepo return empty repository.
+// order of rows which are equal in every sort field is not specified, so the assertion doesn't depend on it.
+func testRepositoryConformance(t *testing.T, newRepo func(t *testing.T) Repository) {
+	ctx := tenant.WithID(context.Background(), stubTenant)
+	base := time.Date(2024, 01, 01, 0, 0, 0, 0, time.UTC)
+
+	newUser := func(id, name, email string, status Status, createdAt time.Time) User {
+		return User{ID: id, Name: name, Phone: "+62812" + id, Email: email, Status: status, CreatedAt: createdAt, Version: 1}
+	}
+	ids := func(users []User) []string {
+		var v []string
+		for _, u := range users {
+			v = append(v, u.ID)
+		}
+		return v
+	}
+
+	t.Run("create and find", func(t *testing.T) {
+		repo := newRepo(t)
+		usr := newUser("u1", "foo", "foo@bar.com", UserStatusActive, base)
+
+		// only the inserted column is stored
+		created := usr
+		created.DeletedAt, created.MergedInto = &base, "u2"
+		assert.Nil(t, repo.Create(ctx, created))
+
+		got, err := repo.FindByID(ctx, "u1")
+		assert.Nil(t, err)
+		assert.Equal(t, usr, got)
+
+		got, err = repo.FindByEmail(ctx, "FOO@bar.com")
+		assert.Nil(t, err, "email is case insensitive")
+		assert.Equal(t, usr, got)
+
+		_, err = repo.FindByID(ctx, "u2")
+		assert.ErrorIs(t, err, ErrNotFound)
+		_, err = repo.FindByEmail(ctx, "bar@foo.com")
+		assert.ErrorIs(t, err, ErrNotFound)
+
+		err = repo.Create(ctx, newUser("u2", "bar", "Foo@Bar.com", UserStatusActive, base))
+		assert.ErrorIs(t, err, ErrDuplicateEmail)
+
+		err = repo.Create(ctx, newUser("u1", "bar", "bar@foo.com", UserStatusActive, base))
+		assert.NotNil(t, err)
+		assert.NotErrorIs(t, err, ErrDuplicateEmail)
+	})
+
+	t.Run("email of deleted user stay unique", func(t *testing.T) {
+		repo := newRepo(t)
+		require.Nil(t, repo.Create(ctx, newUser("u1", "foo", "foo@bar.com", UserStatusActive, base)))
+		require.Nil(t, repo.Delete(ctx, "u1", 1))
+
+		_, err := repo.FindByID(ctx, "u1")
+		assert.ErrorIs(t, err, ErrNotFound)
+		_, err = repo.FindByEmail(ctx, "foo@bar.com")
+		assert.ErrorIs(t, err, ErrNotFound)
+
+		deleted, err := repo.FindByID(ctx, "u1", WithDeleted())
+		assert.Nil(t, err)
+		assert.NotNil(t, deleted.DeletedAt)
+		assert.Equal(t, int64(2), deleted.Version)
+
+		err = repo.Create(ctx, newUser("u2", "bar", "foo@bar.com", UserStatusActive, base))
+		assert.ErrorIs(t, err, ErrDuplicateEmail)
+
+		existing, err := repo.FindExistingEmail(ctx, []string{"FOO@bar.com", "bar@foo.com"})
+		assert.Nil(t, err)
+		assert.Equal(t, map[string]bool{"foo@bar.com": true}, existing)
+
+		existing, err = repo.FindExistingEmail(ctx, nil)
+		assert.Nil(t, err)
+		assert.Empty(t, existing)
+	})
+
+	t.Run("find phone duplicates", func(t *testing.T) {
+		repo := newRepo(t)
+		withPhone := func(u User, phone string) User {
+			u.Phone = phone
+			return u
+		}
+		u1 := withPhone(newUser("u1", "foo", "u1@bar.com", UserStatusActive, base.Add(time.Hour)), "+628111")
+		u2 := withPhone(newUser("u2", "foo", "u2@bar.com", UserStatusActive, base), "+628111")
+		for _, u := range []User{
+			u1, u2,
+			withPhone(newUser("u3", "foo", "u3@bar.com", UserStatusActive, base), "+628222"),
+			withPhone(newUser("u4", "foo", "u4@bar.com", UserStatusActive, base), "+628222"),
+			withPhone(newUser("u5", "foo", "u5@bar.com", UserStatusActive, base), ""),
+			withPhone(newUser("u6", "foo", "u6@bar.com", UserStatusActive, base), ""),
+		} {
+			require.Nil(t, repo.Create(ctx, u))
+		}
+		require.Nil(t, repo.Delete(ctx, "u4", 1))
+		other := tenant.WithID(context.Background(), "t2")
+		require.Nil(t, repo.Create(other, withPhone(newUser("u7", "foo", "u7@bar.com", UserStatusActive, base), "+628222")))
+
+		users, err := repo.FindPhoneDuplicates(ctx)
+		assert.Nil(t, err)
+		assert.Equal(t, []User{u2, u1}, users, "deleted user, empty phone and user of other tenant is excluded")
+	})
+
+	t.Run("create batch", func(t *testing.T) {
+		repo := newRepo(t)
+		err := repo.CreateBatch(ctx, []User{
+			newUser("u1", "foo", "foo@bar.com", UserStatusActive, base),
+			newUser("u2", "bar", "FOO@bar.com", UserStatusActive, base),
+		})
+		assert.ErrorIs(t, err, ErrDuplicateEmail)
+
+		_, err = repo.FindByID(ctx, "u1")
+		assert.ErrorIs(t, err, ErrNotFound, "batch is inserted all or nothing")
+
+		err = repo.CreateBatch(ctx, []User{
+			newUser("u1", "foo", "foo@bar.com", UserStatusActive, base),
+			newUser("u2", "bar", "bar@foo.com", UserStatusActive, base),
+		})
+		assert.Nil(t, err)
+		assert.Nil(t, repo.CreateBatch(ctx, nil))
+
+		_, pagination, err := repo.FindAll(ctx, GetUserParam{})
+		assert.Nil(t, err)
+		assert.Equal(t, int64(2), pagination.Total)
+	})
+
+	t.Run("find all", func(t *testing.T) {
+		repo := newRepo(t)
+		for _, u := range []User{
+			newUser("u1", "alice", "alice@foo.com", UserStatusActive, base),
+			newUser("u2", "Bob", "bob@foo.com", UserStatusInActive, base.Add(time.Hour)),
+			newUser("u3", "carol", "carol@foo.com", UserStatusActive, base.Add(2*time.Hour)),
+			newUser("u4", "dave", "dave@foo.com", UserStatusBanned, base.Add(3*time.Hour)),
+			newUser("u5", "erin", "erin@foo.com", UserStatusActive, base.Add(4*time.Hour)),
+		} {
+			require.Nil(t, repo.Create(ctx, u))
+		}
+		require.Nil(t, repo.Delete(ctx, "u5", 1))
+
+		testCase := []struct {
+			desc          string
+			param         GetUserParam
+			expIDs        []string
+			expPagination entity.Pagination
+		}{
+			{
+				desc:          "exclude deleted",
+				param:         GetUserParam{SortBy: []string{"created_at"}},
+				expIDs:        []string{"u1", "u2", "u3", "u4"},
+				expPagination: entity.Pagination{Page: 1, Size: 4, Total: 4},
+			},
+			{
+				desc:          "include deleted",
+				param:         GetUserParam{IncludeDeleted: true, Status: []Status{UserStatusActive}, SortBy: []string{"-created_at"}},
+				expIDs:        []string{"u5", "u3", "u1"},
+				expPagination: entity.Pagination{Page: 1, Size: 3, Total: 3},
+			},
+			{
+				desc:          "status in",
+				param:         GetUserParam{Status: []Status{UserStatusInActive, UserStatusBanned}, SortBy: []string{"created_at"}},
+				expIDs:        []string{"u2", "u4"},
+				expPagination: entity.Pagination{Page: 1, Size: 2, Total: 2},
+			},
+			{
+				desc:          "email",
+				param:         GetUserParam{Email: sql.NullString{String: "CAROL@foo.com", Valid: true}},
+				expIDs:        []string{"u3"},
+				expPagination: entity.Pagination{Page: 1, Size: 1, Total: 1},
+			},
+			{
+				desc: "created at range",
+				param: GetUserParam{
+					CreatedAtGTE: sql.NullTime{Time: base.Add(time.Hour), Valid: true},
+					CreatedAtLTE: sql.NullTime{Time: base.Add(3 * time.Hour), Valid: true},
+					SortBy:       []string{"created_at"},
+				},
+				expIDs:        []string{"u2", "u3", "u4"},
+				expPagination: entity.Pagination{Page: 1, Size: 3, Total: 3},
+			},
+			{
+				desc:          "sort case insensitive descending",
+				param:         GetUserParam{SortBy: []string{"-name"}},
+				expIDs:        []string{"u4", "u3", "u2", "u1"},
+				expPagination: entity.Pagination{Page: 1, Size: 4, Total: 4},
+			},
+			{
+				desc:          "sort multiple field",
+				param:         GetUserParam{SortBy: []string{"-status", "-created_at"}},
+				expIDs:        []string{"u4", "u3", "u1", "u2"},
+				expPagination: entity.Pagination{Page: 1, Size: 4, Total: 4},
+			},
+			{
+				desc:          "first page",
+				param:         GetUserParam{Limit: 3, SortBy: []string{"created_at"}},
+				expIDs:        []string{"u1", "u2", "u3"},
+				expPagination: entity.Pagination{Page: 1, Size: 3, HasNext: true, Total: 4},
+			},
+			{
+				desc:          "last page",
+				param:         GetUserParam{Page: 2, Limit: 3, SortBy: []string{"created_at"}},
+				expIDs:        []string{"u4"},
+				expPagination: entity.Pagination{Page: 2, Size: 1, Total: 4},
+			},
+			{
+				desc:          "after last page",
+				param:         GetUserParam{Page: 3, Limit: 2, SortBy: []string{"created_at"}},
+				expPagination: entity.Pagination{Page: 3, Size: 0, Total: 4},
+			},
+			{
+				desc:          "no match",
+				param:         GetUserParam{Email: sql.NullString{String: "erin@foo.com", Valid: true}},
+				expPagination: entity.Pagination{Page: 1, Size: 0, Total: 0},
+			},
+		}
+
+		for _, tc := range testCase {
+			t.Run(tc.desc, func(t *testing.T) {
+				users, pagination, err := repo.FindAll(ctx, tc.param)
+				assert.Nil(t, err)
+				assert.Equal(t, tc.expIDs, ids(users))
+				assert.Equal(t, tc.expPagination, pagination)
+			})
+		}
+
+		t.Run("invalid sort field", func(t *testing.T) {
+			_, _, err := repo.FindAll(ctx, GetUserParam{SortBy: []string{"name; DROP TABLE user"}})
+			assert.ErrorIs(t, err, ErrInvalidSortBy)
+		})
+
+		t.Run("stream", func(t *testing.T) {
+			var streamed []User
+			err := repo.Stream(ctx, GetUserParam{IncludeDeleted: true, Limit: 1, SortBy: []string{"-created_at"}}, func(u User) error {
+				streamed = append(streamed, u)
+				return nil
+			})
+			assert.Nil(t, err)
+			assert.Equal(t, []string{"u5", "u4", "u3", "u2", "u1"}, ids(streamed), "page and limit is ignored")
+
+			errStop := errors.New("stop")
+			var calls int
+			err = repo.Stream(ctx, GetUserParam{}, func(u User) error {
+				calls++
+				return errStop
+			})
+			assert.ErrorIs(t, err, errStop)
+			assert.Equal(t, 1, calls)
+
+			err = repo.Stream(ctx, GetUserParam{SortBy: []string{"password"}}, func(u User) error { return nil })
+			assert.ErrorIs(t, err, ErrInvalidSortBy)
+		})
+	})
+
+	t.Run("update", func(t *testing.T) {
+		repo := newRepo(t)
+		require.Nil(t, repo.Create(ctx, newUser("u1", "foo", "foo@bar.com", UserStatusActive, base)))
+		require.Nil(t, repo.Create(ctx, newUser("u2", "bar", "bar@foo.com", UserStatusActive, base)))
+
+		until, verifiedAt := base.Add(time.Hour), base.Add(time.Minute)
+		usr := newUser("u1", "baz", "baz@bar.com", UserStatusSuspend, base)
+		usr.SuspendedUntil, usr.SuspensionReason, usr.EmailVerifiedAt = &until, "spam", &verifiedAt
+		assert.Nil(t, repo.Update(ctx, usr))
+
+		got, err := repo.FindByID(ctx, "u1")
+		assert.Nil(t, err)
+		usr.Version = 2
+		assert.Equal(t, usr, got)
+
+		usr.Version = 1
+		assert.ErrorIs(t, repo.Update(ctx, usr), ErrVersionConflict)
+
+		other := newUser("u2", "bar", "BAZ@bar.com", UserStatusActive, base)
+		assert.ErrorIs(t, repo.Update(ctx, other), ErrDuplicateEmail)
+
+		usr.Version, usr.Email = 2, "Baz@Bar.com"
+		assert.Nil(t, repo.Update(ctx, usr), "case of own email can be changed")
+
+		require.Nil(t, repo.Delete(ctx, "u2", 1))
+		other.Version, other.Email = 2, "bar@foo.com"
+		assert.ErrorIs(t, repo.Update(ctx, other), ErrVersionConflict, "deleted user cannot be updated")
+	})
+
+	t.Run("delete restore and purge", func(t *testing.T) {
+		repo := newRepo(t)
+		require.Nil(t, repo.Create(ctx, newUser("u1", "foo", "foo@bar.com", UserStatusActive, base)))
+
+		assert.ErrorIs(t, repo.Delete(ctx, "u1", 2), ErrVersionConflict)
+		assert.ErrorIs(t, repo.Purge(ctx, "u1"), ErrNotFound, "user must be deleted before purged")
+		assert.ErrorIs(t, repo.Restore(ctx, "u1"), ErrNotFound)
+
+		assert.Nil(t, repo.Delete(ctx, "u1", 1))
+		assert.ErrorIs(t, repo.Delete(ctx, "u1", 2), ErrVersionConflict)
+
+		assert.Nil(t, repo.Restore(ctx, "u1"))
+		got, err := repo.FindByID(ctx, "u1")
+		assert.Nil(t, err)
+		assert.Nil(t, got.DeletedAt)
+		assert.Equal(t, int64(3), got.Version)
+
+		require.Nil(t, repo.Delete(ctx, "u1", 3))
+		assert.Nil(t, repo.Purge(ctx, "u1"))
+		_, err = repo.FindByID(ctx, "u1", WithDeleted())
+		assert.ErrorIs(t, err, ErrNotFound)
+		assert.ErrorIs(t, repo.Purge(ctx, "u1"), ErrNotFound)
+		assert.ErrorIs(t, repo.Restore(ctx, "u1"), ErrNotFound)
+
+		assert.Nil(t, repo.Create(ctx, newUser("u2", "foo", "foo@bar.com", UserStatusActive, base)), "email of purged user can be used")
+	})
+
+	t.Run("erase", func(t *testing.T) {
+		repo := newRepo(t)
+		require.Nil(t, repo.Create(ctx, newUser("u1", "foo", "foo@bar.com", UserStatusActive, base)))
+		usr := newUser("u1", "foo", "foo@bar.com", UserStatusActive, base)
+		usr.EmailVerifiedAt = &base
+		require.Nil(t, repo.Update(ctx, usr))
+		require.Nil(t, repo.Delete(ctx, "u1", 2))
+
+		erasedAt := base.Add(time.Hour)
+		erased := User{ID: "u1", Name: "erased", Email: "u1@erased.invalid", ErasedAt: &erasedAt, Version: 3}
+		assert.Nil(t, repo.Erase(ctx, erased), "deleted user can be erased")
+
+		got, err := repo.FindByID(ctx, "u1", WithDeleted())
+		assert.Nil(t, err)
+		assert.Equal(t, "erased", got.Name)
+		assert.Equal(t, "", got.Phone)
+		assert.Equal(t, "u1@erased.invalid", got.Email)
+		assert.Equal(t, &erasedAt, got.ErasedAt)
+		assert.Nil(t, got.EmailVerifiedAt)
+		assert.Equal(t, int64(4), got.Version)
+
+		erased.Version = 4
+		assert.ErrorIs(t, repo.Erase(ctx, erased), ErrVersionConflict, "user is erased once")
+	})
+
+	t.Run("merge", func(t *testing.T) {
+		repo := newRepo(t)
+		for _, id := range []string{"u1", "u2", "u3"} {
+			require.Nil(t, repo.Create(ctx, newUser(id, "foo", id+"@bar.com", UserStatusActive, base)))
+		}
+
+		mergedAt := base.Add(time.Hour)
+		merge := func(id, into string, version int64) error {
+			return repo.Merge(ctx, User{ID: id, DeletedAt: &mergedAt, MergedInto: into, Version: version})
+		}
+		assert.Nil(t, merge("u3", "u2", 1))
+		assert.Nil(t, merge("u2", "u1", 1))
+		assert.ErrorIs(t, merge("u2", "u1", 2), ErrVersionConflict, "merged user is deleted")
+		assert.ErrorIs(t, merge("u1", "u2", 2), ErrVersionConflict)
+
+		for _, id := range []string{"u2", "u3"} {
+			got, err := repo.FindByID(ctx, id, WithDeleted())
+			assert.Nil(t, err)
+			assert.Equal(t, "u1", got.MergedInto, "merged user is moved to the survivor")
+			assert.Equal(t, &mergedAt, got.DeletedAt)
+
+			assert.ErrorIs(t, repo.Restore(ctx, id), ErrNotFound)
+			assert.ErrorIs(t, repo.Purge(ctx, id), ErrNotFound)
+		}
+	})
+
+	t.Run("lift expired suspension", func(t *testing.T) {
+		repo := newRepo(t)
+		suspend := func(id string, until time.Time) {
+			require.Nil(t, repo.Create(ctx, newUser(id, "foo", id+"@bar.com", UserStatusSuspend, base)))
+			usr := newUser(id, "foo", id+"@bar.com", UserStatusSuspend, base)
+			usr.SuspendedUntil, usr.SuspensionReason = &until, "spam"
+			require.Nil(t, repo.Update(ctx, usr))
+		}
+		suspend("u1", base.Add(-2*time.Hour))
+		suspend("u2", base.Add(-time.Hour))
+		suspend("u3", base)
+		suspend("u4", base.Add(time.Hour))
+		suspend("u5", base.Add(-3*time.Hour))
+		require.Nil(t, repo.Delete(ctx, "u5", 2))
+		require.Nil(t, repo.Create(ctx, newUser("u6", "foo", "u6@bar.com", UserStatusActive, base)))
+
+		lifted, err := repo.LiftExpiredSuspension(ctx, base, 2)
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"u1", "u2"}, ids(lifted), "earliest expired first")
+		assert.Equal(t, UserStatusSuspend, lifted[0].Status, "user before the change is returned")
+
+		lifted, err = repo.LiftExpiredSuspension(ctx, base, 2)
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"u3"}, ids(lifted))
+
+		lifted, err = repo.LiftExpiredSuspension(ctx, base, 2)
+		assert.Nil(t, err)
+		assert.Empty(t, lifted)
+
+		got, err := repo.FindByID(ctx, "u1")
+		assert.Nil(t, err)
+		assert.Equal(t, UserStatusActive, got.Status)
+		assert.Nil(t, got.SuspendedUntil)
+		assert.Equal(t, "", got.SuspensionReason)
+		assert.Equal(t, int64(3), got.Version)
+	})
+
+	t.Run("tx", func(t *testing.T) {
+		repo := newRepo(t)
+		audit := AuditLog{ID: "a1", UserID: "u1", Action: AuditActionCreate, Actor: "admin", Changes: []AuditChange{}, CreatedAt: base}
+
+		errRollback := errors.New("rollback")
+		err := repo.Tx(ctx, func(ctx context.Context) error {
+			if err := repo.Create(ctx, newUser("u1", "foo", "foo@bar.com", UserStatusActive, base)); err != nil {
+				return err
+			}
+			if err := repo.CreateAuditLog(ctx, audit); err != nil {
+				return err
+			}
+			if _, err := repo.FindByID(ctx, "u1"); err != nil {
+				return err
+			}
+			return errRollback
+		})
+		assert.ErrorIs(t, err, errRollback)
+
+		_, err = repo.FindByID(ctx, "u1")
+		assert.ErrorIs(t, err, ErrNotFound)
+		logs, _, err := repo.FindAuditLog(ctx, "u1", GetAuditLogParam{})
+		assert.Nil(t, err)
+		assert.Empty(t, logs)
+
+		err = repo.Tx(ctx, func(ctx context.Context) error {
+			if err := repo.Create(ctx, newUser("u1", "foo", "foo@bar.com", UserStatusActive, base)); err != nil {
+				return err
+			}
+			// nested Tx join the outer transaction
+			return repo.Tx(ctx, func(ctx context.Context) error {
+				return repo.CreateAuditLog(ctx, audit)
+			})
+		})
+		assert.Nil(t, err)
+
+		_, err = repo.FindByID(ctx, "u1")
+		assert.Nil(t, err)
+		logs, _, err = repo.FindAuditLog(ctx, "u1", GetAuditLogParam{})
+		assert.Nil(t, err)
+		assert.Equal(t, []AuditLog{audit}, logs)
+	})
+
+	t.Run("audit log", func(t *testing.T) {
+		repo := newRepo(t)
+		logs := []AuditLog{
+			{ID: "a1", UserID: "u1", Action: AuditActionCreate, Actor: "admin", Changes: []AuditChange{{Field: "name", From: nil, To: "foo"}}, CreatedAt: base},
+			{ID: "a2", UserID: "u1", Action: AuditActionUpdate, Actor: "admin", RequestID: "req-2", Changes: []AuditChange{{Field: "email", From: "foo@bar.com", To: "bar@foo.com"}, {Field: "status", From: "active", To: "banned"}}, CreatedAt: base.Add(time.Hour)},
+			{ID: "a3", UserID: "u1", Action: AuditActionStatusChange, Actor: "admin", Reason: "fraud", Changes: []AuditChange{{Field: "status", From: "banned", To: "active"}}, CreatedAt: base.Add(2 * time.Hour)},
+			{ID: "b1", UserID: "u2", Action: AuditActionCreate, Actor: "admin", Changes: []AuditChange{{Field: "name", From: nil, To: "bar"}}, CreatedAt: base},
+		}
+		require.Nil(t, repo.CreateAuditLog(ctx, logs...))
+		require.Nil(t, repo.CreateAuditLog(ctx))
+
+		got, pagination, err := repo.FindAuditLog(ctx, "u1", GetAuditLogParam{Limit: 2, SortBy: []string{"-created_at"}})
+		assert.Nil(t, err)
+		assert.Equal(t, []AuditLog{logs[2], logs[1]}, got)
+		assert.Equal(t, entity.Pagination{Page: 1, Size: 2, HasNext: true, Total: 3}, pagination)
+
+		got, _, err = repo.FindAuditLog(ctx, "u1", GetAuditLogParam{Action: []AuditAction{AuditActionUpdate, AuditActionStatusChange}, SortBy: []string{"created_at"}})
+		assert.Nil(t, err)
+		assert.Equal(t, []AuditLog{logs[1], logs[2]}, got)
+
+		got, _, err = repo.FindAuditLog(ctx, "u1", GetAuditLogParam{CreatedAtLTE: sql.NullTime{Time: base.Add(time.Hour), Valid: true}, SortBy: []string{"-created_at"}})
+		assert.Nil(t, err)
+		assert.Equal(t, []AuditLog{logs[1], logs[0]}, got)
+
+		_, _, err = repo.FindAuditLog(ctx, "u1", GetAuditLogParam{SortBy: []string{"changes"}})
+		assert.ErrorIs(t, err, ErrInvalidSortBy)
+
+		assert.Nil(t, repo.RedactAuditLog(ctx, "u1"))
+		got, _, err = repo.FindAuditLog(ctx, "u1", GetAuditLogParam{SortBy: []string{"created_at"}})
+		assert.Nil(t, err)
+		assert.Equal(t, []AuditChange{{Field: "name", From: nil, To: AuditRedacted}}, got[0].Changes)
+		assert.Equal(t, []AuditChange{{Field: "email", From: AuditRedacted, To: AuditRedacted}, {Field: "status", From: "active", To: "banned"}}, got[1].Changes)
+		assert.Equal(t, logs[2], got[2])
+
+		got, _, err = repo.FindAuditLog(ctx, "u2", GetAuditLogParam{})
+		assert.Nil(t, err)
+		assert.Equal(t, []AuditLog{logs[3]}, got, "audit log of other user is kept")
+	})
+
+	t.Run("credential", func(t *testing.T) {
+		repo := newRepo(t)
+
+		_, err := repo.FindCredential(ctx, "u1")
+		assert.ErrorIs(t, err, ErrNotFound)
+		_, err = repo.RecordLoginFailure(ctx, "u1", 2, base)
+		assert.ErrorIs(t, err, ErrNotFound)
+
+		require.Nil(t, repo.SavePassword(ctx, "u1", "hash-1", base))
+		cred, err := repo.FindCredential(ctx, "u1")
+		assert.Nil(t, err)
+		assert.Equal(t, Credential{UserID: "u1", PasswordHash: "hash-1", PasswordChangedAt: base}, cred)
+
+		lockUntil := base.Add(time.Hour)
+		cred, err = repo.RecordLoginFailure(ctx, "u1", 2, lockUntil)
+		assert.Nil(t, err)
+		assert.Equal(t, 1, cred.FailedAttempts)
+		assert.Nil(t, cred.LockedUntil)
+
+		cred, err = repo.RecordLoginFailure(ctx, "u1", 2, lockUntil)
+		assert.Nil(t, err)
+		assert.Equal(t, 0, cred.FailedAttempts, "count start over when locked")
+		assert.Equal(t, &lockUntil, cred.LockedUntil)
+
+		got, err := repo.FindCredential(ctx, "u1")
+		assert.Nil(t, err)
+		assert.Equal(t, cred, got)
+
+		assert.Nil(t, repo.ResetLoginFailure(ctx, "u1"))
+		got, err = repo.FindCredential(ctx, "u1")
+		assert.Nil(t, err)
+		assert.Nil(t, got.LockedUntil)
+
+		assert.Nil(t, repo.UpgradePasswordHash(ctx, "u1", "hash-0", "hash-2"))
+		got, _ = repo.FindCredential(ctx, "u1")
+		assert.Equal(t, "hash-1", got.PasswordHash, "hash is changed only when it is still old")
+		assert.Nil(t, repo.UpgradePasswordHash(ctx, "u1", "hash-1", "hash-2"))
+		got, _ = repo.FindCredential(ctx, "u1")
+		assert.Equal(t, "hash-2", got.PasswordHash)
+
+		assert.Nil(t, repo.DeleteCredential(ctx, "u1"))
+		_, err = repo.FindCredential(ctx, "u1")
+		assert.ErrorIs(t, err, ErrNotFound)
+	})
+
+	t.Run("password reset", func(t *testing.T) {
+		repo := newRepo(t)
+		reset := func(token, userID string) {
+			require.Nil(t, repo.CreatePasswordReset(ctx, PasswordReset{TokenHash: token, UserID: userID, ExpiresAt: base.Add(time.Hour), CreatedAt: base}))
+		}
+		reset("t1", "u1")
+		reset("t2", "u1")
+		reset("t3", "u2")
+
+		_, err := repo.UsePasswordReset(ctx, "t1", base.Add(time.Hour))
+		assert.ErrorIs(t, err, ErrNotFound, "expired")
+		_, err = repo.UsePasswordReset(ctx, "t0", base)
+		assert.ErrorIs(t, err, ErrNotFound)
+
+		userID, err := repo.UsePasswordReset(ctx, "t1", base)
+		assert.Nil(t, err)
+		assert.Equal(t, "u1", userID)
+
+		_, err = repo.UsePasswordReset(ctx, "t1", base)
+		assert.ErrorIs(t, err, ErrNotFound, "token is used once")
+		_, err = repo.UsePasswordReset(ctx, "t2", base)
+		assert.ErrorIs(t, err, ErrNotFound, "every token of the user is deleted")
+
+		require.Nil(t, repo.SavePassword(ctx, "u2", "hash-1", base))
+		_, err = repo.UsePasswordReset(ctx, "t3", base)
+		assert.ErrorIs(t, err, ErrNotFound, "password change delete pending reset")
+
+		reset("t4", "u2")
+		require.Nil(t, repo.DeleteCredential(ctx, "u2"))
+		_, err = repo.UsePasswordReset(ctx, "t4", base)
+		assert.ErrorIs(t, err, ErrNotFound)
+	})
+
+	t.Run("verification", func(t *testing.T) {
+		repo := newRepo(t)
+		cooldown, window := time.Minute, time.Hour
+		v := Verification{UserID: "u1", Channel: VerificationChannelEmail, Contact: "foo@bar.com", CodeHash: "hash-1", ExpiresAt: base.Add(time.Hour), CreatedAt: base}
+		require.Nil(t, repo.SaveVerification(ctx, v, cooldown, window))
+
+		got, err := repo.AttemptVerification(ctx, "u1", VerificationChannelEmail, 2, base)
+		assert.Nil(t, err)
+		v.Attempts, v.WindowStartedAt = 1, base
+		assert.Equal(t, v, got)
+
+		got, err = repo.AttemptVerification(ctx, "u1", VerificationChannelEmail, 2, base)
+		assert.Nil(t, err)
+		assert.Equal(t, 2, got.Attempts)
+
+		_, err = repo.AttemptVerification(ctx, "u1", VerificationChannelEmail, 2, base)
+		assert.ErrorIs(t, err, ErrNotFound, "max attempt")
+		_, err = repo.AttemptVerification(ctx, "u1", VerificationChannelPhone, 2, base)
+		assert.ErrorIs(t, err, ErrNotFound)
+
+		v.CodeHash, v.CreatedAt, v.ExpiresAt = "hash-2", base.Add(cooldown/2), base.Add(2*time.Hour)
+		assert.ErrorIs(t, repo.SaveVerification(ctx, v, cooldown, window), ErrVerificationCooldown)
+		got, err = repo.AttemptVerification(ctx, "u1", VerificationChannelEmail, 3, base)
+		assert.Nil(t, err)
+		assert.Equal(t, "hash-1", got.CodeHash, "code is not replaced in cooldown")
+
+		v.CreatedAt, v.Attempts = base.Add(cooldown), 0
+		require.Nil(t, repo.SaveVerification(ctx, v, cooldown, window))
+		_, err = repo.AttemptVerification(ctx, "u1", VerificationChannelEmail, 3, base)
+		assert.ErrorIs(t, err, ErrNotFound, "attempt is kept when replaced in window")
+		got, err = repo.AttemptVerification(ctx, "u1", VerificationChannelEmail, 4, base)
+		assert.Nil(t, err)
+		assert.Equal(t, "hash-2", got.CodeHash)
+		assert.Equal(t, 4, got.Attempts)
+		assert.Equal(t, base, got.WindowStartedAt)
+
+		v.CodeHash, v.CreatedAt = "hash-3", base.Add(window)
+		require.Nil(t, repo.SaveVerification(ctx, v, cooldown, window))
+		got, err = repo.AttemptVerification(ctx, "u1", VerificationChannelEmail, 2, base)
+		assert.Nil(t, err)
+		assert.Equal(t, 1, got.Attempts, "attempt start over when window is elapsed")
+		assert.Equal(t, v.CreatedAt, got.WindowStartedAt)
+
+		_, err = repo.AttemptVerification(ctx, "u1", VerificationChannelEmail, 2, v.ExpiresAt)
+		assert.ErrorIs(t, err, ErrNotFound, "expired")
+
+		assert.Nil(t, repo.DeleteVerification(ctx, "u1", VerificationChannelEmail))
+		_, err = repo.AttemptVerification(ctx, "u1", VerificationChannelEmail, 2, base)
+		assert.ErrorIs(t, err, ErrNotFound)
+		require.Nil(t, repo.SaveVerification(ctx, v, cooldown, window), "no cooldown after delete")
+	})
+
+	t.Run("tenant isolation", func(t *testing.T) {
+		repo := newRepo(t)
+		other := tenant.WithID(context.Background(), "t2")
+
+		usr := newUser("u1", "foo", "foo@bar.com", UserStatusActive, base)
+		require.Nil(t, repo.Create(ctx, usr))
+		require.Nil(t, repo.CreateAuditLog(ctx, AuditLog{ID: "a1", UserID: "u1", Action: AuditActionCreate, Changes: []AuditChange{{Field: "name", To: "foo"}}, CreatedAt: base}))
+		require.Nil(t, repo.SavePassword(ctx, "u1", "hash-1", base))
+		require.Nil(t, repo.CreatePasswordReset(ctx, PasswordReset{TokenHash: "r1", UserID: "u1", ExpiresAt: base.Add(time.Hour), CreatedAt: base}))
+		require.Nil(t, repo.SaveVerification(ctx, Verification{UserID: "u1", Channel: VerificationChannelEmail, Contact: "foo@bar.com", CodeHash: "hash-1", ExpiresAt: base.Add(time.Hour), CreatedAt: base}, time.Minute, time.Hour))
+
+		// read
+		_, err := repo.FindByID(other, "u1", WithDeleted())
+		assert.ErrorIs(t, err, ErrNotFound)
+		_, err = repo.FindByEmail(other, "foo@bar.com")
+		assert.ErrorIs(t, err, ErrNotFound)
+		found, err := repo.FindExistingEmail(other, []string{"foo@bar.com"})
+		assert.Nil(t, err)
+		assert.Empty(t, found)
+		users, pagination, err := repo.FindAll(other, GetUserParam{IncludeDeleted: true})
+		assert.Nil(t, err)
+		assert.Empty(t, users)
+		assert.Equal(t, int64(0), pagination.Total, "count is scoped too")
+		assert.Nil(t, repo.Stream(other, GetUserParam{IncludeDeleted: true}, func(u User) error {
+			t.Errorf("user %s of other tenant is streamed", u.ID)
+			return nil
+		}))
+		logs, _, err := repo.FindAuditLog(other, "u1", GetAuditLogParam{})
+		assert.Nil(t, err)
+		assert.Empty(t, logs)
+		_, err = repo.FindCredential(other, "u1")
+		assert.ErrorIs(t, err, ErrNotFound)
+
+		// write
+		changed := usr
+		changed.Name, changed.Email = "bar", "bar@foo.com"
+		assert.ErrorIs(t, repo.Update(other, changed), ErrVersionConflict)
+		assert.ErrorIs(t, repo.Delete(other, "u1", 1), ErrVersionConflict)
+		assert.ErrorIs(t, repo.Erase(other, User{ID: "u1", Name: "erased", Email: "u1@erased.invalid", ErasedAt: &base, Version: 1}), ErrVersionConflict)
+		assert.ErrorIs(t, repo.Merge(other, User{ID: "u1", DeletedAt: &base, MergedInto: "u2", Version: 1}), ErrVersionConflict)
+		assert.ErrorIs(t, repo.Restore(other, "u1"), ErrNotFound)
+		assert.ErrorIs(t, repo.Purge(other, "u1"), ErrNotFound)
+		assert.Nil(t, repo.RedactAuditLog(other, "u1"))
+		_, err = repo.RecordLoginFailure(other, "u1", 1, base.Add(time.Hour))
+		assert.ErrorIs(t, err, ErrNotFound)
+		assert.Nil(t, repo.UpgradePasswordHash(other, "u1", "hash-1", "hash-2"))
+		assert.Nil(t, repo.DeleteCredential(other, "u1"))
+		_, err = repo.UsePasswordReset(other, "r1", base)
+		assert.ErrorIs(t, err, ErrNotFound)
+		_, err = repo.AttemptVerification(other, "u1", VerificationChannelEmail, 1, base)
+		assert.ErrorIs(t, err, ErrNotFound)
+		assert.Nil(t, repo.DeleteVerification(other, "u1", VerificationChannelEmail))
+
+		got, err := repo.FindByID(ctx, "u1")
+		assert.Nil(t, err)
+		assert.Equal(t, usr, got, "user is not changed by other tenant")
+		logs, _, err = repo.FindAuditLog(ctx, "u1", GetAuditLogParam{})
+		assert.Nil(t, err)
+		assert.Equal(t, "foo", logs[0].Changes[0].To, "audit log is not redacted by other tenant")
+		cred, err := repo.FindCredential(ctx, "u1")
+		assert.Nil(t, err)
+		assert.Equal(t, Credential{UserID: "u1", PasswordHash: "hash-1", PasswordChangedAt: base}, cred)
+		userID, err := repo.UsePasswordReset(ctx, "r1", base)
+		assert.Nil(t, err)
+		assert.Equal(t, "u1", userID)
+		verification, err := repo.AttemptVerification(ctx, "u1", VerificationChannelEmail, 1, base)
+		assert.Nil(t, err)
+		assert.Equal(t, 1, verification.Attempts)
+
+		// email is unique per tenant, id is unique across tenants
+		assert.Nil(t, repo.Create(other, newUser("u2", "foo", "foo@bar.com", UserStatusActive, base)))
+		err = repo.Create(other, newUser("u1", "bar", "bar@foo.com", UserStatusActive, base))
+		assert.NotNil(t, err)
+		assert.NotErrorIs(t, err, ErrDuplicateEmail)
+
+		suspended := newUser("u3", "baz", "baz@bar.com", UserStatusSuspend, base)
+		require.Nil(t, repo.Create(other, suspended))
+		suspended.SuspendedUntil, suspended.SuspensionReason = &base, "spam"
+		require.Nil(t, repo.Update(other, suspended))
+		tenants, err := repo.FindExpiredSuspensionTenants(context.Background(), base)
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"t2"}, tenants)
+		lifted, err := repo.LiftExpiredSuspension(ctx, base, 10)
+		assert.Nil(t, err)
+		assert.Empty(t, lifted, "suspension of other tenant is not lifted")
+
+		_, err = repo.FindByID(context.Background(), "u1")
+		assert.ErrorIs(t, err, ErrInvalidTenant)
+		assert.ErrorIs(t, repo.Create(context.Background(), newUser("u4", "foo", "foo@bar.com", UserStatusActive, base)), ErrInvalidTenant)
+		_, _, err = repo.FindAll(tenant.WithID(context.Background(), "t2' OR '1'='1"), GetUserParam{})
+		assert.ErrorIs(t, err, ErrInvalidTenant)
+	})
+}
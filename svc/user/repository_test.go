@@ -0,0 +1,767 @@
+package user
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	gomysql "github.com/go-sql-driver/mysql"
+	"github.com/stretchr/testify/assert"
+	"github.com/tuingking/supersvc/entity"
+	"github.com/tuingking/supersvc/pkg/tenant"
+)
+
+type mockDB struct {
+	db *sql.DB
+}
+
+func (m mockDB) Stop() error {
+	return m.db.Close()
+}
+
+func (m mockDB) Get() *sql.DB {
+	return m.db
+}
+
+func newMockRepository(t *testing.T) (*repository, sqlmock.Sqlmock) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New: %s", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	return &repository{db: mockDB{db: db}}, mock
+}
+
+var userColumns = []string{"id", "name", "phone", "email", "status", "created_at", "deleted_at", "suspended_until", "suspension_reason", "version", "erased_at", "merged_into", "email_verified_at", "phone_verified_at"}
+
+func Test_Repository_FindByID(t *testing.T) {
+	createdAt := time.Date(2024, 01, 01, 0, 0, 0, 0, time.UTC)
+
+	t.Run("found", func(t *testing.T) {
+		repo, mock := newMockRepository(t)
+		mock.ExpectQuery(regexp.QuoteMeta(getActiveUserByIDQuery)).
+			WithArgs(stubTenant, "u1").
+			WillReturnRows(sqlmock.NewRows(userColumns).AddRow("u1", "foo", "0812", "foo@bar.com", UserStatusActive, createdAt, nil, nil, "", 2, nil, "", nil, nil))
+
+		usr, err := repo.FindByID(tenantCtx, "u1")
+		assert.Nil(t, err)
+		assert.Equal(t, User{ID: "u1", Name: "foo", Phone: "0812", Email: "foo@bar.com", Status: UserStatusActive, CreatedAt: createdAt, Version: 2}, usr)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("with deleted", func(t *testing.T) {
+		deletedAt := createdAt.Add(time.Hour)
+
+		repo, mock := newMockRepository(t)
+		mock.ExpectQuery(regexp.QuoteMeta(getUserByIDQuery)+"$").
+			WithArgs(stubTenant, "u1").
+			WillReturnRows(sqlmock.NewRows(userColumns).AddRow("u1", "foo", "0812", "foo@bar.com", UserStatusActive, createdAt, deletedAt, nil, "", 3, nil, "", nil, nil))
+
+		usr, err := repo.FindByID(tenantCtx, "u1", WithDeleted())
+		assert.Nil(t, err)
+		assert.Equal(t, &deletedAt, usr.DeletedAt)
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		repo, mock := newMockRepository(t)
+		mock.ExpectQuery(regexp.QuoteMeta(getActiveUserByIDQuery)).
+			WithArgs(stubTenant, "u1").
+			WillReturnRows(sqlmock.NewRows(userColumns))
+
+		_, err := repo.FindByID(tenantCtx, "u1")
+		assert.ErrorIs(t, err, ErrNotFound)
+	})
+}
+
+func Test_Repository_Update(t *testing.T) {
+	until := time.Date(2024, 01, 01, 0, 0, 0, 0, time.UTC)
+
+	usr := User{
+		ID:               "u1",
+		Name:             "foo",
+		Phone:            "0812",
+		Email:            "foo@bar.com",
+		Status:           UserStatusSuspend,
+		SuspendedUntil:   &until,
+		SuspensionReason: "spam",
+		Version:          3,
+	}
+
+	t.Run("updated", func(t *testing.T) {
+		repo, mock := newMockRepository(t)
+		mock.ExpectExec(regexp.QuoteMeta(updateUserQuery)).
+			WithArgs("foo", "0812", "foo@bar.com", UserStatusSuspend, &until, "spam", nil, nil, stubTenant, "u1", 3).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		assert.Nil(t, repo.Update(tenantCtx, usr))
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("version changed", func(t *testing.T) {
+		repo, mock := newMockRepository(t)
+		mock.ExpectExec(regexp.QuoteMeta(updateUserQuery)).
+			WithArgs("foo", "0812", "foo@bar.com", UserStatusSuspend, &until, "spam", nil, nil, stubTenant, "u1", 3).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+
+		assert.ErrorIs(t, repo.Update(tenantCtx, usr), ErrVersionConflict)
+	})
+}
+
+func Test_Repository_Create_DuplicateEmail(t *testing.T) {
+	testCase := []struct {
+		desc   string
+		err    error
+		expErr error
+	}{
+		{
+			desc:   "duplicate email",
+			err:    &gomysql.MySQLError{Number: 1062, Message: "Duplicate entry 'foo@bar.com' for key 'user.user_email_uq'"},
+			expErr: ErrDuplicateEmail,
+		},
+		{
+			desc:   "duplicate other key",
+			err:    &gomysql.MySQLError{Number: 1062, Message: "Duplicate entry 'u1' for key 'user.PRIMARY'"},
+			expErr: &gomysql.MySQLError{Number: 1062, Message: "Duplicate entry 'u1' for key 'user.PRIMARY'"},
+		},
+		{
+			desc:   "other error",
+			err:    sql.ErrConnDone,
+			expErr: sql.ErrConnDone,
+		},
+	}
+
+	for _, tc := range testCase {
+		t.Run(tc.desc, func(t *testing.T) {
+			repo, mock := newMockRepository(t)
+			mock.ExpectExec(regexp.QuoteMeta(createUserQuery)).
+				WillReturnError(tc.err)
+
+			err := repo.Create(tenantCtx, User{ID: "u1", Email: "foo@bar.com"})
+			assert.Equal(t, tc.expErr, err)
+		})
+	}
+}
+
+func Test_Repository_Delete(t *testing.T) {
+	t.Run("soft delete", func(t *testing.T) {
+		repo, mock := newMockRepository(t)
+		mock.ExpectExec(regexp.QuoteMeta(softDeleteUserQuery)).
+			WithArgs(sqlmock.AnyArg(), stubTenant, "u1", 1).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		assert.Nil(t, repo.Delete(tenantCtx, "u1", 1))
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("version changed or already deleted", func(t *testing.T) {
+		repo, mock := newMockRepository(t)
+		mock.ExpectExec(regexp.QuoteMeta(softDeleteUserQuery)).
+			WithArgs(sqlmock.AnyArg(), stubTenant, "u1", 1).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+
+		assert.ErrorIs(t, repo.Delete(tenantCtx, "u1", 1), ErrVersionConflict)
+	})
+}
+
+func Test_Repository_Restore(t *testing.T) {
+	repo, mock := newMockRepository(t)
+	mock.ExpectExec(regexp.QuoteMeta(restoreUserQuery)).
+		WithArgs(stubTenant, "u1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta(restoreUserQuery)).
+		WithArgs(stubTenant, "u2").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	assert.Nil(t, repo.Restore(tenantCtx, "u1"))
+	assert.ErrorIs(t, repo.Restore(tenantCtx, "u2"), ErrNotFound)
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+func Test_Repository_Purge(t *testing.T) {
+	repo, mock := newMockRepository(t)
+	mock.ExpectExec(regexp.QuoteMeta(purgeUserQuery)).
+		WithArgs(stubTenant, "u1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta(purgeUserQuery)).
+		WithArgs(stubTenant, "u2").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	assert.Nil(t, repo.Purge(tenantCtx, "u1"))
+	assert.ErrorIs(t, repo.Purge(tenantCtx, "u2"), ErrNotFound)
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+func Test_Repository_FindAll_ExcludeDeleted(t *testing.T) {
+	testCase := []struct {
+		desc      string
+		param     GetUserParam
+		expClause string
+	}{
+		{
+			desc:      "exclude deleted by default",
+			param:     GetUserParam{},
+			expClause: " WHERE 1=1 AND tenant_id = ? AND deleted_at IS NULL",
+		},
+		{
+			desc:      "include deleted",
+			param:     GetUserParam{IncludeDeleted: true},
+			expClause: " WHERE 1=1 AND tenant_id = ?",
+		},
+	}
+
+	for _, tc := range testCase {
+		t.Run(tc.desc, func(t *testing.T) {
+			repo, mock := newMockRepository(t)
+			mock.ExpectQuery(regexp.QuoteMeta(getUserQuery+tc.expClause+" LIMIT 0, 11") + "$").
+				WithArgs(stubTenant).
+				WillReturnRows(sqlmock.NewRows(userColumns))
+			mock.ExpectQuery(regexp.QuoteMeta(countUserQuery+tc.expClause) + "$").
+				WithArgs(stubTenant).
+				WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+
+			_, _, err := repo.FindAll(tenantCtx, tc.param)
+			assert.Nil(t, err)
+			assert.Nil(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func Test_Repository_FindAll_InvalidSortBy(t *testing.T) {
+	// sortBy is written as is in ORDER BY, so no query is expected
+	repo, mock := newMockRepository(t)
+
+	_, _, err := repo.FindAll(tenantCtx, GetUserParam{SortBy: []string{"-created_at", "(SELECT 1)"}})
+	assert.ErrorIs(t, err, ErrInvalidSortBy)
+
+	_, _, err = repo.FindAuditLog(tenantCtx, "u1", GetAuditLogParam{SortBy: []string{"user_id DESC"}})
+	assert.ErrorIs(t, err, ErrInvalidSortBy)
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+func Test_Repository_LiftExpiredSuspension(t *testing.T) {
+	now := time.Date(2024, 01, 01, 0, 0, 0, 0, time.UTC)
+
+	t.Run("lift locked user", func(t *testing.T) {
+		repo, mock := newMockRepository(t)
+		mock.ExpectBegin()
+		mock.ExpectQuery(regexp.QuoteMeta(lockExpiredSuspensionQuery)).
+			WithArgs(stubTenant, UserStatusSuspend, now, 10).
+			WillReturnRows(sqlmock.NewRows(userColumns).
+				AddRow("u1", "foo", "", "foo@bar.com", UserStatusSuspend, now, nil, now, "spam", 2, nil, "", nil, nil).
+				AddRow("u2", "bar", "", "bar@foo.com", UserStatusSuspend, now, nil, now, "", 1, nil, "", nil, nil))
+		mock.ExpectExec(regexp.QuoteMeta(liftSuspensionQuery)).
+			WithArgs(UserStatusActive, stubTenant, "u1").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(regexp.QuoteMeta(liftSuspensionQuery)).
+			WithArgs(UserStatusActive, stubTenant, "u2").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		users, err := repo.LiftExpiredSuspension(tenantCtx, now, 10)
+		assert.Nil(t, err)
+		assert.Len(t, users, 2)
+		assert.Equal(t, User{ID: "u1", Name: "foo", Email: "foo@bar.com", Status: UserStatusSuspend, CreatedAt: now, SuspendedUntil: &now, SuspensionReason: "spam", Version: 2}, users[0])
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("rollback on failure", func(t *testing.T) {
+		repo, mock := newMockRepository(t)
+		mock.ExpectBegin()
+		mock.ExpectQuery(regexp.QuoteMeta(lockExpiredSuspensionQuery)).
+			WithArgs(stubTenant, UserStatusSuspend, now, 10).
+			WillReturnRows(sqlmock.NewRows(userColumns).AddRow("u1", "foo", "", "foo@bar.com", UserStatusSuspend, now, nil, now, "", 1, nil, "", nil, nil))
+		mock.ExpectExec(regexp.QuoteMeta(liftSuspensionQuery)).
+			WithArgs(UserStatusActive, stubTenant, "u1").
+			WillReturnError(sql.ErrConnDone)
+		mock.ExpectRollback()
+
+		_, err := repo.LiftExpiredSuspension(tenantCtx, now, 10)
+		assert.ErrorIs(t, err, sql.ErrConnDone)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+}
+
+func Test_Repository_CreateBatch(t *testing.T) {
+	createdAt := time.Date(2024, 01, 01, 0, 0, 0, 0, time.UTC)
+	users := []User{
+		{ID: "u1", Name: "foo", Email: "foo@bar.com", CreatedAt: createdAt, Version: 1},
+		{ID: "u2", Name: "bar", Email: "bar@foo.com", CreatedAt: createdAt, Version: 1},
+	}
+	query := regexp.QuoteMeta(insertUserQuery+insertUserValues+", "+insertUserValues) + "$"
+
+	t.Run("success", func(t *testing.T) {
+		repo, mock := newMockRepository(t)
+		mock.ExpectBegin()
+		mock.ExpectExec(query).
+			WithArgs(stubTenant, "u1", "foo", "", "foo@bar.com", UserStatusInActive, createdAt, 1, stubTenant, "u2", "bar", "", "bar@foo.com", UserStatusInActive, createdAt, 1).
+			WillReturnResult(sqlmock.NewResult(0, 2))
+		mock.ExpectCommit()
+
+		err := repo.CreateBatch(tenantCtx, users)
+		assert.Nil(t, err)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("duplicate email", func(t *testing.T) {
+		repo, mock := newMockRepository(t)
+		mock.ExpectBegin()
+		mock.ExpectExec(query).
+			WillReturnError(&gomysql.MySQLError{Number: 1062, Message: "Duplicate entry 'foo@bar.com' for key 'user.user_email_uq'"})
+		mock.ExpectRollback()
+
+		err := repo.CreateBatch(tenantCtx, users)
+		assert.ErrorIs(t, err, ErrDuplicateEmail)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+}
+
+func Test_Repository_FindExistingEmail(t *testing.T) {
+	repo, mock := newMockRepository(t)
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT email FROM user WHERE tenant_id = ? AND email IN (?, ?)")).
+		WithArgs(stubTenant, "foo@bar.com", "bar@foo.com").
+		WillReturnRows(sqlmock.NewRows([]string{"email"}).AddRow("Foo@Bar.com"))
+
+	existing, err := repo.FindExistingEmail(tenantCtx, []string{"foo@bar.com", "bar@foo.com"})
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]bool{"foo@bar.com": true}, existing)
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+func Test_Repository_FindPhoneDuplicates(t *testing.T) {
+	createdAt := time.Date(2024, 01, 01, 0, 0, 0, 0, time.UTC)
+	repo, mock := newMockRepository(t)
+	mock.ExpectQuery(regexp.QuoteMeta(findPhoneDuplicateQuery)).
+		WithArgs(stubTenant, stubTenant).
+		WillReturnRows(sqlmock.NewRows(userColumns).
+			AddRow("u1", "foo", "+62812", "foo@bar.com", UserStatusActive, createdAt, nil, nil, "", 1, nil, "", nil, nil).
+			AddRow("u2", "foo", "+62812", "bar@foo.com", UserStatusActive, createdAt, nil, nil, "", 1, nil, "", nil, nil))
+
+	users, err := repo.FindPhoneDuplicates(tenantCtx)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"u1", "u2"}, []string{users[0].ID, users[1].ID})
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+func Test_Repository_FindExpiredSuspensionTenants(t *testing.T) {
+	now := time.Date(2024, 01, 01, 0, 0, 0, 0, time.UTC)
+	repo, mock := newMockRepository(t)
+	mock.ExpectQuery(regexp.QuoteMeta(findExpiredSuspensionTenantsQuery)).
+		WithArgs(UserStatusSuspend, now).
+		WillReturnRows(sqlmock.NewRows([]string{"tenant_id"}).AddRow("t1").AddRow("t2"))
+
+	tenants, err := repo.FindExpiredSuspensionTenants(context.Background(), now)
+	assert.Nil(t, err, "tenant is not required")
+	assert.Equal(t, []string{"t1", "t2"}, tenants)
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+func Test_Repository_InvalidTenant(t *testing.T) {
+	repo, mock := newMockRepository(t)
+
+	for _, ctx := range []context.Context{context.Background(), tenant.WithID(context.Background(), "t1' OR '1'='1")} {
+		_, err := repo.FindByID(ctx, "u1")
+		assert.ErrorIs(t, err, ErrInvalidTenant)
+		_, _, err = repo.FindAll(ctx, GetUserParam{})
+		assert.ErrorIs(t, err, ErrInvalidTenant)
+		assert.ErrorIs(t, repo.Update(ctx, User{ID: "u1", Version: 1}), ErrInvalidTenant)
+	}
+	assert.Nil(t, mock.ExpectationsWereMet(), "no query is run")
+}
+
+func Test_Repository_Stream(t *testing.T) {
+	createdAt := time.Date(2024, 01, 01, 0, 0, 0, 0, time.UTC)
+	query := regexp.QuoteMeta(getUserQuery+" WHERE 1=1 AND status IN (?) AND "+tenantWhereClause+" AND "+notDeletedWhereClause+" ORDER BY created_at DESC") + "$"
+
+	t.Run("stream all rows", func(t *testing.T) {
+		repo, mock := newMockRepository(t)
+		mock.ExpectQuery(query).
+			WithArgs(UserStatusActive, stubTenant).
+			WillReturnRows(sqlmock.NewRows(userColumns).
+				AddRow("u1", "foo", "", "foo@bar.com", UserStatusActive, createdAt, nil, nil, "", 1, nil, "", nil, nil).
+				AddRow("u2", "bar", "", "bar@foo.com", UserStatusActive, createdAt, nil, nil, "", 1, nil, "", nil, nil))
+
+		var ids []string
+		err := repo.Stream(tenantCtx, GetUserParam{Status: []Status{UserStatusActive}, Page: 3, Limit: 1, SortBy: []string{"-created_at"}}, func(u User) error {
+			ids = append(ids, u.ID)
+			return nil
+		})
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"u1", "u2"}, ids)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("stop on fn error", func(t *testing.T) {
+		repo, mock := newMockRepository(t)
+		mock.ExpectQuery(query).
+			WillReturnRows(sqlmock.NewRows(userColumns).
+				AddRow("u1", "foo", "", "foo@bar.com", UserStatusActive, createdAt, nil, nil, "", 1, nil, "", nil, nil).
+				AddRow("u2", "bar", "", "bar@foo.com", UserStatusActive, createdAt, nil, nil, "", 1, nil, "", nil, nil))
+
+		var calls int
+		errStop := errors.New("stop")
+		err := repo.Stream(tenantCtx, GetUserParam{Status: []Status{UserStatusActive}, SortBy: []string{"-created_at"}}, func(u User) error {
+			calls++
+			return errStop
+		})
+		assert.ErrorIs(t, err, errStop)
+		assert.Equal(t, 1, calls)
+	})
+}
+
+func Test_Repository_Tx(t *testing.T) {
+	createdAt := time.Date(2024, 01, 01, 0, 0, 0, 0, time.UTC)
+	usr := User{ID: "u1", Name: "foo", Email: "foo@bar.com", CreatedAt: createdAt, Version: 1}
+	audit := AuditLog{ID: "a1", UserID: "u1", Action: AuditActionCreate, Actor: "admin", Changes: []AuditChange{}, CreatedAt: createdAt}
+
+	t.Run("commit", func(t *testing.T) {
+		repo, mock := newMockRepository(t)
+		mock.ExpectBegin()
+		mock.ExpectExec(regexp.QuoteMeta(createUserQuery)).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(regexp.QuoteMeta(insertAuditLogQuery+insertAuditLogValues)+"$").
+			WithArgs(stubTenant, "a1", "u1", AuditActionCreate, "admin", "", "", []byte("[]"), createdAt).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		err := repo.Tx(tenantCtx, func(ctx context.Context) error {
+			if err := repo.Create(ctx, usr); err != nil {
+				return err
+			}
+			// nested Tx join the outer transaction
+			return repo.Tx(ctx, func(ctx context.Context) error {
+				return repo.CreateAuditLog(ctx, audit)
+			})
+		})
+		assert.Nil(t, err)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("rollback", func(t *testing.T) {
+		repo, mock := newMockRepository(t)
+		mock.ExpectBegin()
+		mock.ExpectExec(regexp.QuoteMeta(createUserQuery)).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(regexp.QuoteMeta(insertAuditLogQuery)).WillReturnError(sql.ErrConnDone)
+		mock.ExpectRollback()
+
+		err := repo.Tx(tenantCtx, func(ctx context.Context) error {
+			if err := repo.Create(ctx, usr); err != nil {
+				return err
+			}
+			return repo.CreateAuditLog(ctx, audit)
+		})
+		assert.ErrorIs(t, err, sql.ErrConnDone)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+}
+
+func Test_Repository_FindAuditLog(t *testing.T) {
+	createdAt := time.Date(2024, 01, 01, 0, 0, 0, 0, time.UTC)
+	columns := []string{"id", "user_id", "action", "actor", "request_id", "reason", "changes", "created_at"}
+	clause := " WHERE 1=1 AND action IN (?) AND " + tenantWhereClause + " AND " + auditLogUserClause + " ORDER BY created_at DESC"
+
+	repo, mock := newMockRepository(t)
+	mock.ExpectQuery(regexp.QuoteMeta(getAuditLogQuery+clause+" LIMIT 0, 2")+"$").
+		WithArgs(AuditActionStatusChange, stubTenant, "u1").
+		WillReturnRows(sqlmock.NewRows(columns).
+			AddRow("a2", "u1", AuditActionStatusChange, "admin", "req-2", "fraud", []byte(`[{"field":"status","from":"active","to":"banned"}]`), createdAt.Add(time.Hour)).
+			AddRow("a1", "u1", AuditActionStatusChange, "admin", "req-1", "", []byte(`[{"field":"status","from":"inactive","to":"active"}]`), createdAt))
+	mock.ExpectQuery(regexp.QuoteMeta(countAuditLogQuery+clause)+"$").
+		WithArgs(AuditActionStatusChange, stubTenant, "u1").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(2))
+
+	logs, pagination, err := repo.FindAuditLog(tenantCtx, "u1", GetAuditLogParam{
+		Action: []AuditAction{AuditActionStatusChange},
+		Limit:  1,
+		SortBy: []string{"-created_at"},
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, []AuditLog{{
+		ID:        "a2",
+		UserID:    "u1",
+		Action:    AuditActionStatusChange,
+		Actor:     "admin",
+		RequestID: "req-2",
+		Reason:    "fraud",
+		Changes:   []AuditChange{{Field: "status", From: "active", To: "banned"}},
+		CreatedAt: createdAt.Add(time.Hour),
+	}}, logs)
+	assert.Equal(t, entity.Pagination{Page: 1, Size: 1, HasNext: true, Total: 2}, pagination)
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+func Test_Repository_Erase(t *testing.T) {
+	erasedAt := time.Date(2024, 01, 01, 0, 0, 0, 0, time.UTC)
+	usr := User{ID: "u1", Name: "erased-1", Phone: "erased-1", Email: "erased-1@erased.invalid", ErasedAt: &erasedAt, Version: 2}
+
+	t.Run("erased", func(t *testing.T) {
+		repo, mock := newMockRepository(t)
+		mock.ExpectExec(regexp.QuoteMeta(eraseUserQuery)).
+			WithArgs("erased-1", "erased-1", "erased-1@erased.invalid", &erasedAt, stubTenant, "u1", 2).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		assert.Nil(t, repo.Erase(tenantCtx, usr))
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("version changed or already erased", func(t *testing.T) {
+		repo, mock := newMockRepository(t)
+		mock.ExpectExec(regexp.QuoteMeta(eraseUserQuery)).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+
+		assert.ErrorIs(t, repo.Erase(tenantCtx, usr), ErrVersionConflict)
+	})
+}
+
+func Test_Repository_RedactAuditLog(t *testing.T) {
+	repo, mock := newMockRepository(t)
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(lockAuditLogChangesQuery)).
+		WithArgs(stubTenant, "u1").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "changes"}).
+			AddRow("a1", []byte(`[{"field":"name","from":"","to":"foo"},{"field":"status","from":null,"to":"active"}]`)).
+			AddRow("a2", []byte(`[{"field":"status","from":"active","to":"banned"}]`)).
+			AddRow("a3", []byte(`[{"field":"email","from":"foo@bar.com","to":"bar@foo.com"}]`)))
+	mock.ExpectExec(regexp.QuoteMeta(updateAuditLogChangesQuery)).
+		WithArgs([]byte(`[{"field":"name","from":"","to":"[redacted]"},{"field":"status","from":null,"to":"active"}]`), stubTenant, "a1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta(updateAuditLogChangesQuery)).
+		WithArgs([]byte(`[{"field":"email","from":"[redacted]","to":"[redacted]"}]`), stubTenant, "a3").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	assert.Nil(t, repo.RedactAuditLog(tenantCtx, "u1"))
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+func Test_Repository_Merge(t *testing.T) {
+	deletedAt := time.Date(2024, 01, 01, 0, 0, 0, 0, time.UTC)
+	usr := User{ID: "u2", DeletedAt: &deletedAt, MergedInto: "u1", Version: 2}
+
+	t.Run("merged", func(t *testing.T) {
+		repo, mock := newMockRepository(t)
+		mock.ExpectBegin()
+		mock.ExpectExec(regexp.QuoteMeta(mergeUserQuery)).
+			WithArgs(&deletedAt, "u1", stubTenant, "u2", 2).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(regexp.QuoteMeta(moveMergedUserQuery)).
+			WithArgs("u1", stubTenant, "u2").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		assert.Nil(t, repo.Merge(tenantCtx, usr))
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("version changed or deleted", func(t *testing.T) {
+		repo, mock := newMockRepository(t)
+		mock.ExpectBegin()
+		mock.ExpectExec(regexp.QuoteMeta(mergeUserQuery)).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectRollback()
+
+		assert.ErrorIs(t, repo.Merge(tenantCtx, usr), ErrVersionConflict)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+}
+
+func Test_Repository_RecordLoginFailure(t *testing.T) {
+	changedAt := time.Date(2024, 01, 01, 0, 0, 0, 0, time.UTC)
+	lockUntil := changedAt.Add(time.Hour)
+	columns := []string{"user_id", "password_hash", "failed_attempts", "locked_until", "password_changed_at"}
+
+	t.Run("count", func(t *testing.T) {
+		repo, mock := newMockRepository(t)
+		mock.ExpectBegin()
+		mock.ExpectQuery(regexp.QuoteMeta(lockCredentialQuery)).
+			WithArgs(stubTenant, "u1").
+			WillReturnRows(sqlmock.NewRows(columns).AddRow("u1", "hash", 1, nil, changedAt))
+		mock.ExpectExec(regexp.QuoteMeta(updateLoginFailureQuery)).
+			WithArgs(2, nil, stubTenant, "u1").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		cred, err := repo.RecordLoginFailure(tenantCtx, "u1", 3, lockUntil)
+		assert.Nil(t, err)
+		assert.Equal(t, Credential{UserID: "u1", PasswordHash: "hash", FailedAttempts: 2, PasswordChangedAt: changedAt}, cred)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("lock", func(t *testing.T) {
+		repo, mock := newMockRepository(t)
+		mock.ExpectBegin()
+		mock.ExpectQuery(regexp.QuoteMeta(lockCredentialQuery)).
+			WithArgs(stubTenant, "u1").
+			WillReturnRows(sqlmock.NewRows(columns).AddRow("u1", "hash", 2, nil, changedAt))
+		mock.ExpectExec(regexp.QuoteMeta(updateLoginFailureQuery)).
+			WithArgs(0, &lockUntil, stubTenant, "u1").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		cred, err := repo.RecordLoginFailure(tenantCtx, "u1", 3, lockUntil)
+		assert.Nil(t, err)
+		assert.Equal(t, 0, cred.FailedAttempts)
+		assert.Equal(t, &lockUntil, cred.LockedUntil)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("no credential", func(t *testing.T) {
+		repo, mock := newMockRepository(t)
+		mock.ExpectBegin()
+		mock.ExpectQuery(regexp.QuoteMeta(lockCredentialQuery)).
+			WithArgs(stubTenant, "u1").
+			WillReturnRows(sqlmock.NewRows(columns))
+		mock.ExpectRollback()
+
+		_, err := repo.RecordLoginFailure(tenantCtx, "u1", 3, lockUntil)
+		assert.ErrorIs(t, err, ErrNotFound)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+}
+
+func Test_Repository_SavePassword(t *testing.T) {
+	changedAt := time.Date(2024, 01, 01, 0, 0, 0, 0, time.UTC)
+
+	repo, mock := newMockRepository(t)
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(saveCredentialQuery)).
+		WithArgs(stubTenant, "u1", "hash", changedAt).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta(deleteUserPasswordResetQuery)).
+		WithArgs(stubTenant, "u1").
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectCommit()
+
+	assert.Nil(t, repo.SavePassword(tenantCtx, "u1", "hash", changedAt))
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+func Test_Repository_UsePasswordReset(t *testing.T) {
+	now := time.Date(2024, 01, 01, 0, 0, 0, 0, time.UTC)
+
+	t.Run("used", func(t *testing.T) {
+		repo, mock := newMockRepository(t)
+		mock.ExpectBegin()
+		mock.ExpectQuery(regexp.QuoteMeta(lockPasswordResetQuery)).
+			WithArgs(stubTenant, "token-hash", now).
+			WillReturnRows(sqlmock.NewRows([]string{"user_id"}).AddRow("u1"))
+		mock.ExpectExec(regexp.QuoteMeta(deleteUserPasswordResetQuery)).
+			WithArgs(stubTenant, "u1").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		userID, err := repo.UsePasswordReset(tenantCtx, "token-hash", now)
+		assert.Nil(t, err)
+		assert.Equal(t, "u1", userID)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("unknown or expired", func(t *testing.T) {
+		repo, mock := newMockRepository(t)
+		mock.ExpectBegin()
+		mock.ExpectQuery(regexp.QuoteMeta(lockPasswordResetQuery)).
+			WithArgs(stubTenant, "token-hash", now).
+			WillReturnRows(sqlmock.NewRows([]string{"user_id"}))
+		mock.ExpectRollback()
+
+		_, err := repo.UsePasswordReset(tenantCtx, "token-hash", now)
+		assert.ErrorIs(t, err, ErrNotFound)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+}
+
+func Test_Repository_SaveVerification(t *testing.T) {
+	now := time.Date(2024, 01, 01, 0, 0, 0, 0, time.UTC)
+	columns := []string{"attempts", "window_started_at", "created_at"}
+	v := Verification{UserID: "u1", Channel: VerificationChannelEmail, Contact: "foo@bar.com", CodeHash: "hash", ExpiresAt: now.Add(time.Minute), CreatedAt: now}
+
+	t.Run("attempts kept in window", func(t *testing.T) {
+		repo, mock := newMockRepository(t)
+		mock.ExpectBegin()
+		mock.ExpectQuery(regexp.QuoteMeta(lockVerificationQuery)).
+			WithArgs(stubTenant, "u1", VerificationChannelEmail).
+			WillReturnRows(sqlmock.NewRows(columns).AddRow(3, now.Add(-time.Minute), now.Add(-time.Minute)))
+		mock.ExpectExec(regexp.QuoteMeta(saveVerificationQuery)).
+			WithArgs(stubTenant, "u1", VerificationChannelEmail, "foo@bar.com", "hash", 3, now.Add(-time.Minute), now.Add(time.Minute), now).
+			WillReturnResult(sqlmock.NewResult(0, 2))
+		mock.ExpectCommit()
+
+		assert.Nil(t, repo.SaveVerification(tenantCtx, v, time.Minute, time.Hour))
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("first code", func(t *testing.T) {
+		repo, mock := newMockRepository(t)
+		mock.ExpectBegin()
+		mock.ExpectQuery(regexp.QuoteMeta(lockVerificationQuery)).
+			WithArgs(stubTenant, "u1", VerificationChannelEmail).
+			WillReturnRows(sqlmock.NewRows(columns))
+		mock.ExpectExec(regexp.QuoteMeta(saveVerificationQuery)).
+			WithArgs(stubTenant, "u1", VerificationChannelEmail, "foo@bar.com", "hash", 0, now, now.Add(time.Minute), now).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		assert.Nil(t, repo.SaveVerification(tenantCtx, v, time.Minute, time.Hour))
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("cooldown", func(t *testing.T) {
+		repo, mock := newMockRepository(t)
+		mock.ExpectBegin()
+		mock.ExpectQuery(regexp.QuoteMeta(lockVerificationQuery)).
+			WithArgs(stubTenant, "u1", VerificationChannelEmail).
+			WillReturnRows(sqlmock.NewRows(columns).AddRow(0, now.Add(-time.Second), now.Add(-time.Second)))
+		mock.ExpectRollback()
+
+		err := repo.SaveVerification(tenantCtx, v, time.Minute, time.Hour)
+		assert.ErrorIs(t, err, ErrVerificationCooldown)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+}
+
+func Test_Repository_AttemptVerification(t *testing.T) {
+	now := time.Date(2024, 01, 01, 0, 0, 0, 0, time.UTC)
+	columns := []string{"user_id", "channel", "contact", "code_hash", "attempts", "window_started_at", "expires_at", "created_at"}
+
+	t.Run("counted", func(t *testing.T) {
+		repo, mock := newMockRepository(t)
+		mock.ExpectBegin()
+		mock.ExpectExec(regexp.QuoteMeta(attemptVerificationQuery)).
+			WithArgs(stubTenant, "u1", VerificationChannelEmail, 5, now).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectQuery(regexp.QuoteMeta(getVerificationQuery)).
+			WithArgs(stubTenant, "u1", VerificationChannelEmail).
+			WillReturnRows(sqlmock.NewRows(columns).AddRow("u1", "email", "foo@bar.com", "hash", 1, now, now.Add(time.Minute), now))
+		mock.ExpectCommit()
+
+		v, err := repo.AttemptVerification(tenantCtx, "u1", VerificationChannelEmail, 5, now)
+		assert.Nil(t, err)
+		assert.Equal(t, Verification{
+			UserID:          "u1",
+			Channel:         VerificationChannelEmail,
+			Contact:         "foo@bar.com",
+			CodeHash:        "hash",
+			Attempts:        1,
+			WindowStartedAt: now,
+			ExpiresAt:       now.Add(time.Minute),
+			CreatedAt:       now,
+		}, v)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("expired or max attempts", func(t *testing.T) {
+		repo, mock := newMockRepository(t)
+		mock.ExpectBegin()
+		mock.ExpectExec(regexp.QuoteMeta(attemptVerificationQuery)).
+			WithArgs(stubTenant, "u1", VerificationChannelEmail, 5, now).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectRollback()
+
+		_, err := repo.AttemptVerification(tenantCtx, "u1", VerificationChannelEmail, 5, now)
+		assert.ErrorIs(t, err, ErrNotFound)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+}
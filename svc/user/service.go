@@ -1,33 +1,158 @@
 package user
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"sync"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/pkg/errors"
 	"github.com/tuingking/supersvc/entity"
+	"github.com/tuingking/supersvc/pkg/jsonmerge"
 	"github.com/tuingking/supersvc/pkg/logger"
+	"github.com/tuingking/supersvc/pkg/notify"
+	"github.com/tuingking/supersvc/pkg/outbox"
+	"github.com/tuingking/supersvc/pkg/password"
+	"github.com/tuingking/supersvc/pkg/tenant"
 )
 
+//go:generate go run github.com/vektra/mockery/v2@v2.53.3 --name=Service --output=mocks --outpkg=mocks
+
 type Service interface {
 	GetUser(ctx context.Context, p GetUserParam) ([]User, entity.Pagination, error)
+
+	// GetUserByID return the survivor when the user is merged, see MergeUser
+	GetUserByID(ctx context.Context, id string, opts ...FindOption) (User, error)
 	CreateUser(ctx context.Context, v User) (User, error)
+
+	// UpdateUser, PatchUser and DeleteUser require the current version of the user,
+	// ErrVersionConflict is returned when the user has been changed.
+	UpdateUser(ctx context.Context, id string, version int64, v UpdateUser) (User, error)
+	PatchUser(ctx context.Context, id string, version int64, patch []byte) (User, error)
+	DeleteUser(ctx context.Context, id string, version int64) error
+
+	// ChangeStatus move user to the given status, see transitions for allowed status
+	ChangeStatus(ctx context.Context, id string, to Status, reason string) (User, error)
+
+	// SuspendUser suspend user until the given time, nil until means suspended until lifted manually.
+	// suspending suspended user reschedule the suspension.
+	SuspendUser(ctx context.Context, id string, until *time.Time, reason string) (User, error)
+
+	// CancelSuspension reactivate suspended user before the suspension is expired
+	CancelSuspension(ctx context.Context, id string) (User, error)
+
+	// LiftExpiredSuspension reactivate user of the tenant whose suspension is expired, return number of user reactivated
+	LiftExpiredSuspension(ctx context.Context) (int, error)
+
+	// ExpiredSuspensionTenants return tenant having user whose suspension is expired, ctx doesn't need tenant
+	ExpiredSuspensionTenants(ctx context.Context) ([]string, error)
+
+	// ImportUsers create users read from src in batch, see ImportReport
+	ImportUsers(ctx context.Context, src ImportReader, dryRun bool) (ImportReport, error)
+
+	// ExportUsers stream users matching p to dst, see ExportWriter
+	ExportUsers(ctx context.Context, p GetUserParam, dst ExportWriter) (int, error)
+
+	RestoreUser(ctx context.Context, id string) (User, error)
+	PurgeUser(ctx context.Context, id string) error
+
+	// GetUserHistory return audit log of the user, newest first unless sorted otherwise
+	GetUserHistory(ctx context.Context, id string, p GetAuditLogParam) ([]AuditLog, entity.Pagination, error)
+
+	// EraseUser anonymize name, phone and email of the user, including soft deleted user, and redact them from the audit log.
+	// the user is kept for referential integrity, erasing erased user return it unchanged.
+	EraseUser(ctx context.Context, id string, reason string) (User, error)
+
+	// ExportUserData return the user with its whole audit log, including soft deleted user
+	ExportUserData(ctx context.Context, id string) (UserData, error)
+
+	// FindDuplicateUsers return group of user with the same phone and similar name
+	FindDuplicateUsers(ctx context.Context) ([]DuplicateGroup, error)
+
+	// MergeUser merge duplicate into survivor and return the survivor.
+	// the duplicate is soft deleted and its id is resolved to the survivor by GetUserByID.
+	MergeUser(ctx context.Context, survivorID, duplicateID string, reason string) (User, error)
+
+	// Authenticate return the active user of the email and password, the user is locked after too many failed attempt.
+	// ErrInvalidCredential is returned for unknown email and wrong password alike.
+	// password hash made with old AuthOption.Password is upgraded.
+	Authenticate(ctx context.Context, email, password string) (User, error)
+
+	// VerifyAccess return the user of access token issued at issuedAt, the token is checked on every request.
+	// ErrAccessRevoked is returned when the user is no longer active or the password is changed after issuedAt.
+	VerifyAccess(ctx context.Context, id string, issuedAt time.Time) (User, error)
+
+	// ChangePassword set new password of the user after verifying the current one
+	ChangePassword(ctx context.Context, id, current, password string) error
+
+	// RequestPasswordReset deliver single use token to set password without the current one to the email with the Notifier.
+	// unknown email is not an error, so the caller cannot tell which email is registered.
+	// user without password set the first password this way.
+	RequestPasswordReset(ctx context.Context, email string) error
+	ResetPassword(ctx context.Context, token, password string) error
+
+	// SendVerification deliver one-time code to email or phone of the user with the Notifier, previous code is replaced
+	SendVerification(ctx context.Context, id string, channel VerificationChannel) (VerificationSent, error)
+
+	// VerifyContact mark email or phone of the user as verified when the code match.
+	// the code is invalidated after VerificationOption.MaxAttempts.
+	VerifyContact(ctx context.Context, id string, channel VerificationChannel, code string) (User, error)
 }
 
 type service struct {
-	opt  Option
-	repo Repository
+	opt    Option
+	repo   Repository
+	hasher password.Hasher
+
+	// notifier deliver verification code and password reset token
+	notifier notify.Notifier
+
+	// dummyHash is verified when the user has no password, see verifyDummy
+	dummyHash string
+	dummyOnce sync.Once
 }
 
 type Option struct {
 	Repository RepositoryOption
+	Sweeper    SweeperOption
+	Import     ImportOption
+	Duplicate  DuplicateOption
+	Auth       AuthOption
+
+	Verification VerificationOption
+
+	// Cache is used when the cache is enabled, see NewCachedRepository
+	Cache CacheOption
+
+	// DefaultRegion is ISO 3166-1 alpha-2 region used to parse phone without country code, e.g: ID.
+	// when empty, phone should have country code.
+	DefaultRegion string
+}
+
+// ServiceOption set optional dependency of the service
+type ServiceOption func(*service)
+
+// WithNotifier deliver verification code and password reset token with n.
+// without notifier, sending verification code and password reset token fail with notify.ErrNotConfigured
+func WithNotifier(n notify.Notifier) ServiceOption {
+	return func(s *service) {
+		s.notifier = n
+	}
 }
 
-func NewService(opt Option, repo Repository) Service {
-	return &service{
-		opt:  opt,
-		repo: repo,
+func NewService(opt Option, repo Repository, opts ...ServiceOption) Service {
+	s := &service{
+		opt:      opt,
+		repo:     repo,
+		hasher:   password.NewHasher(opt.Auth.Password),
+		notifier: notify.NotifierFunc(func(context.Context, notify.Message) error { return notify.ErrNotConfigured }),
+	}
+	for _, fn := range opts {
+		fn(s)
 	}
+	return s
 }
 
 func (s *service) GetUser(ctx context.Context, p GetUserParam) ([]User, entity.Pagination, error) {
@@ -37,11 +162,12 @@ func (s *service) GetUser(ctx context.Context, p GetUserParam) ([]User, entity.P
 func (s *service) CreateUser(ctx context.Context, v User) (User, error) {
 	log := logger.Get(ctx)
 
-	// create user id
-	v.ID = uuid.New().String()
-	v.CreatedAt = time.Now()
+	if err := s.newUser(&v); err != nil {
+		return v, err
+	}
 
-	if err := s.repo.Create(ctx, v); err != nil {
+	create := func(ctx context.Context) error { return s.repo.Create(ctx, v) }
+	if err := s.mutate(ctx, create, mutation{action: AuditActionCreate, after: v}); err != nil {
 		log.Err(err).Msg("failed: create user")
 		return v, err
 	}
@@ -50,3 +176,397 @@ func (s *service) CreateUser(ctx context.Context, v User) (User, error) {
 
 	return v, nil
 }
+
+func (s *service) GetUserByID(ctx context.Context, id string, opts ...FindOption) (User, error) {
+	usr, err := s.repo.FindByID(ctx, id, opts...)
+	if errors.Is(err, ErrNotFound) {
+		// merged user is soft deleted
+		if merged, mergedErr := s.repo.FindByID(ctx, id, WithDeleted()); mergedErr == nil && merged.MergedInto != "" {
+			usr, err = merged, nil
+		}
+	}
+	if err == nil && usr.MergedInto != "" {
+		return s.repo.FindByID(ctx, usr.MergedInto, opts...)
+	}
+	return usr, err
+}
+
+func (s *service) UpdateUser(ctx context.Context, id string, version int64, v UpdateUser) (User, error) {
+	log := logger.Get(ctx)
+
+	usr, err := s.repo.FindByID(ctx, id)
+	if err != nil {
+		return usr, err
+	}
+	if usr.Version != version {
+		return usr, ErrVersionConflict
+	}
+
+	if err := usr.Status.CanTransition(v.Status, ""); err != nil {
+		return usr, err
+	}
+
+	before := usr
+	usr.Name = v.Name
+	usr.Phone = v.Phone
+	usr.Email = v.Email
+	usr.setStatus(v.Status)
+
+	if err := normalizeUser(&usr, s.opt.DefaultRegion); err != nil {
+		return usr, err
+	}
+
+	if err := s.update(ctx, AuditActionUpdate, before, &usr, ""); err != nil {
+		log.Err(err).Msg("failed: update user")
+		return usr, err
+	}
+
+	log.Debug().Str("user_id", usr.ID).Msg("user updated")
+
+	return usr, nil
+}
+
+// patchRequiredFields cannot be removed from the user by null in merge patch
+var patchRequiredFields = []string{"name", "email"}
+
+// checkPatchRequired return ErrInvalidPatch when patch set required field to null,
+// merge patch remove the field so it would otherwise be reported as empty by validation
+func checkPatchRequired(patch []byte) error {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(patch, &fields); err != nil {
+		// patch which is not an object replace the whole user, it is rejected by decode
+		return nil
+	}
+	for _, name := range patchRequiredFields {
+		if v, ok := fields[name]; ok && string(v) == "null" {
+			return errors.Wrap(ErrInvalidPatch, name+" is required and cannot be null")
+		}
+	}
+	return nil
+}
+
+// PatchUser apply JSON merge patch (RFC 7396) to the user.
+// id, created_at, deleted_at, version, suspension, verification, erased_at and merged_into cannot be changed,
+// use SuspendUser for suspension, EraseUser for erasure and MergeUser for merge.
+func (s *service) PatchUser(ctx context.Context, id string, version int64, patch []byte) (User, error) {
+	log := logger.Get(ctx)
+
+	usr, err := s.repo.FindByID(ctx, id)
+	if err != nil {
+		return usr, err
+	}
+	if usr.Version != version {
+		return usr, ErrVersionConflict
+	}
+
+	doc, err := json.Marshal(usr)
+	if err != nil {
+		return usr, err
+	}
+
+	merged, err := jsonmerge.Merge(doc, patch)
+	if err != nil {
+		return usr, errors.Wrap(ErrInvalidPatch, err.Error())
+	}
+	if err := checkPatchRequired(patch); err != nil {
+		return usr, err
+	}
+
+	var patched User
+	dec := json.NewDecoder(bytes.NewReader(merged))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&patched); err != nil {
+		return usr, errors.Wrap(ErrInvalidPatch, err.Error())
+	}
+	patched.ID, patched.CreatedAt, patched.DeletedAt = usr.ID, usr.CreatedAt, usr.DeletedAt
+	patched.SuspendedUntil, patched.SuspensionReason = usr.SuspendedUntil, usr.SuspensionReason
+	patched.Version = usr.Version
+	patched.EmailVerifiedAt, patched.PhoneVerifiedAt = usr.EmailVerifiedAt, usr.PhoneVerifiedAt
+	patched.ErasedAt, patched.MergedInto = usr.ErasedAt, usr.MergedInto
+	patched.setStatus(patched.Status)
+
+	if err := normalizeUser(&patched, s.opt.DefaultRegion); err != nil {
+		return usr, err
+	}
+
+	if err := usr.Status.CanTransition(patched.Status, ""); err != nil {
+		return usr, err
+	}
+
+	if err := s.update(ctx, AuditActionUpdate, usr, &patched, ""); err != nil {
+		log.Err(err).Msg("failed: patch user")
+		return usr, err
+	}
+
+	log.Debug().Str("user_id", patched.ID).Msg("user patched")
+
+	return patched, nil
+}
+
+func (s *service) DeleteUser(ctx context.Context, id string, version int64) error {
+	log := logger.Get(ctx)
+
+	usr, err := s.repo.FindByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	if usr.Version != version {
+		return ErrVersionConflict
+	}
+
+	deleted := usr
+	now := time.Now()
+	deleted.DeletedAt = &now
+	deleted.Version++
+
+	del := func(ctx context.Context) error { return s.repo.Delete(ctx, id, version) }
+	if err := s.mutate(ctx, del, mutation{action: AuditActionDelete, before: usr, after: deleted}); err != nil {
+		return err
+	}
+
+	log.Debug().Str("user_id", id).Msg("user deleted")
+
+	return nil
+}
+
+func (s *service) ChangeStatus(ctx context.Context, id string, to Status, reason string) (User, error) {
+	log := logger.Get(ctx)
+
+	usr, err := s.repo.FindByID(ctx, id)
+	if err != nil {
+		return usr, err
+	}
+
+	if err := usr.Status.CanTransition(to, reason); err != nil {
+		return usr, err
+	}
+
+	before := usr
+	usr.setStatus(to)
+	if to == UserStatusSuspend && before.Status != UserStatusSuspend {
+		usr.SuspensionReason = reason
+	}
+	if err := s.update(ctx, AuditActionStatusChange, before, &usr, reason); err != nil {
+		log.Err(err).Msg("failed: change user status")
+		return usr, err
+	}
+
+	log.Info().Str("user_id", id).Stringer("from", before.Status).Stringer("to", to).Str("reason", reason).Msg("user status changed")
+
+	return usr, nil
+}
+
+func (s *service) SuspendUser(ctx context.Context, id string, until *time.Time, reason string) (User, error) {
+	log := logger.Get(ctx)
+
+	if until != nil && !until.After(time.Now()) {
+		return User{}, errors.Wrapf(ErrInvalidSuspension, "suspended until %s is in the past", until.Format(time.RFC3339))
+	}
+
+	usr, err := s.repo.FindByID(ctx, id)
+	if err != nil {
+		return usr, err
+	}
+
+	if err := usr.Status.CanTransition(UserStatusSuspend, reason); err != nil {
+		return usr, err
+	}
+
+	before := usr
+	usr.setStatus(UserStatusSuspend)
+	usr.SuspendedUntil = until
+	usr.SuspensionReason = reason
+	if err := s.update(ctx, AuditActionStatusChange, before, &usr, reason); err != nil {
+		log.Err(err).Msg("failed: suspend user")
+		return usr, err
+	}
+
+	log.Info().Str("user_id", id).Any("until", until).Str("reason", reason).Msg("user suspended")
+
+	return usr, nil
+}
+
+func (s *service) CancelSuspension(ctx context.Context, id string) (User, error) {
+	log := logger.Get(ctx)
+
+	usr, err := s.repo.FindByID(ctx, id)
+	if err != nil {
+		return usr, err
+	}
+
+	if usr.Status != UserStatusSuspend {
+		return usr, ErrNotSuspended
+	}
+
+	before := usr
+	usr.setStatus(UserStatusActive)
+	if err := s.update(ctx, AuditActionStatusChange, before, &usr, ""); err != nil {
+		log.Err(err).Msg("failed: cancel suspension")
+		return usr, err
+	}
+
+	log.Info().Str("user_id", id).Msg("user suspension canceled")
+
+	return usr, nil
+}
+
+func (s *service) LiftExpiredSuspension(ctx context.Context) (int, error) {
+	log := logger.Get(ctx)
+
+	var users []User
+	err := s.repo.Tx(ctx, func(ctx context.Context) error {
+		var err error
+		users, err = s.repo.LiftExpiredSuspension(ctx, time.Now(), s.opt.Sweeper.batchSize())
+		if err != nil {
+			return err
+		}
+
+		ms := make([]mutation, len(users))
+		for i, before := range users {
+			after := before
+			after.setStatus(UserStatusActive)
+			after.Version++
+			ms[i] = mutation{action: AuditActionStatusChange, before: before, after: after, reason: "suspension expired"}
+		}
+		return s.record(ctx, ms...)
+	})
+	if err != nil {
+		log.Err(err).Msg("failed: lift expired suspension")
+		return 0, err
+	}
+
+	for _, usr := range users {
+		log.Info().Str("user_id", usr.ID).Msg("user suspension expired")
+	}
+
+	return len(users), nil
+}
+
+func (s *service) ExpiredSuspensionTenants(ctx context.Context) ([]string, error) {
+	log := logger.Get(ctx)
+
+	tenants, err := s.repo.FindExpiredSuspensionTenants(ctx, time.Now())
+	if err != nil {
+		log.Err(err).Msg("failed: find expired suspension tenants")
+		return nil, err
+	}
+	return tenants, nil
+}
+
+func (s *service) RestoreUser(ctx context.Context, id string) (User, error) {
+	log := logger.Get(ctx)
+
+	usr, err := s.repo.FindByID(ctx, id, WithDeleted())
+	if err != nil {
+		return usr, err
+	}
+
+	restored := usr
+	restored.DeletedAt = nil
+	restored.Version++
+
+	restore := func(ctx context.Context) error { return s.repo.Restore(ctx, id) }
+	if err := s.mutate(ctx, restore, mutation{action: AuditActionRestore, before: usr, after: restored}); err != nil {
+		return User{}, err
+	}
+
+	log.Debug().Str("user_id", id).Msg("user restored")
+
+	return s.repo.FindByID(ctx, id)
+}
+
+// PurgeUser permanently delete user, user should be deleted first
+func (s *service) PurgeUser(ctx context.Context, id string) error {
+	log := logger.Get(ctx)
+
+	usr, err := s.repo.FindByID(ctx, id, WithDeleted())
+	if err != nil {
+		return err
+	}
+
+	purge := func(ctx context.Context) error {
+		if err := s.repo.Purge(ctx, id); err != nil {
+			return err
+		}
+		if err := s.repo.DeleteCredential(ctx, id); err != nil {
+			return err
+		}
+		return s.deleteVerification(ctx, id)
+	}
+	if err := s.mutate(ctx, purge, mutation{action: AuditActionPurge, before: usr}); err != nil {
+		return err
+	}
+
+	log.Info().Str("user_id", id).Msg("user purged")
+
+	return nil
+}
+
+// newUser validate and normalize user to be created, then assign id, created_at and version
+func (s *service) newUser(v *User) error {
+	if !isInitialStatus(v.Status) {
+		return errors.Wrapf(ErrInvalidStatus, "user cannot be created as %s", v.Status)
+	}
+
+	if err := normalizeUser(v, s.opt.DefaultRegion); err != nil {
+		return err
+	}
+
+	// create user id
+	v.ID = uuid.New().String()
+	v.CreatedAt = time.Now()
+	v.Version = 1
+
+	// contact is verified by VerifyContact only
+	v.EmailVerifiedAt, v.PhoneVerifiedAt = nil, nil
+
+	return nil
+}
+
+// update store the user and record the change from before, then increment the version.
+// usr.Version should be the current version
+func (s *service) update(ctx context.Context, action AuditAction, before User, usr *User, reason string) error {
+	usr.clearStaleVerification(before)
+	after := *usr
+	after.Version++
+
+	update := func(ctx context.Context) error { return s.repo.Update(ctx, *usr) }
+	if err := s.mutate(ctx, update, mutation{action: action, before: before, after: after, reason: reason}); err != nil {
+		return err
+	}
+	usr.Version++
+	return nil
+}
+
+// mutate run fn and record the mutations in the same transaction
+func (s *service) mutate(ctx context.Context, fn func(ctx context.Context) error, ms ...mutation) error {
+	return s.repo.Tx(ctx, func(ctx context.Context) error {
+		if err := fn(ctx); err != nil {
+			return err
+		}
+		return s.record(ctx, ms...)
+	})
+}
+
+// record store audit log and domain event of the mutations, ctx should carry the transaction of the mutations
+func (s *service) record(ctx context.Context, ms ...mutation) error {
+	// tenant is enforced by the repository, it is only copied to the event here
+	tenantID, _ := tenant.FromContext(ctx)
+
+	logs := make([]AuditLog, 0, len(ms))
+	var events []outbox.Message
+	for _, m := range ms {
+		logs = append(logs, newAuditLog(ctx, m))
+
+		msgs, err := m.events(tenantID)
+		if err != nil {
+			return err
+		}
+		events = append(events, msgs...)
+	}
+
+	if err := s.repo.CreateAuditLog(ctx, logs...); err != nil {
+		return err
+	}
+	return s.repo.CreateEvent(ctx, events...)
+}
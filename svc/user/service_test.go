@@ -0,0 +1,472 @@
+package user
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tuingking/supersvc/pkg/outbox"
+	"github.com/tuingking/supersvc/pkg/tenant"
+)
+
+// testRepository is the memory repository with accessor for the rows, used by service test
+type testRepository struct {
+	*memoryRepository
+	outbox *outbox.MemoryStore
+
+	// batches is number of CreateBatch call
+	batches int
+}
+
+// newTestRepository return memory repository of stubTenant, users are stored as is like fixture rows
+func newTestRepository(users ...User) *testRepository {
+	events := outbox.NewMemoryStore()
+	r := &testRepository{memoryRepository: NewMemoryRepository(events).(*memoryRepository), outbox: events}
+	rows := r.rows()
+	for _, u := range users {
+		rows.users[u.ID] = u
+	}
+	return r
+}
+
+func (r *testRepository) CreateBatch(ctx context.Context, users []User) error {
+	r.batches++
+	return r.memoryRepository.CreateBatch(ctx, users)
+}
+
+// rows return rows of stubTenant, call it again after a rolled back Tx because the rows are replaced
+func (r *testRepository) rows() *memoryState {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	s, _ := r.state(tenantCtx)
+	return s
+}
+
+// stored return v as it is read back from the repository, time is rounded like timestamp(6) column
+func stored(v User) User {
+	v.CreatedAt = memoryTime(v.CreatedAt)
+	v.DeletedAt, v.SuspendedUntil, v.ErasedAt = memoryTimePtr(v.DeletedAt), memoryTimePtr(v.SuspendedUntil), memoryTimePtr(v.ErasedAt)
+	v.EmailVerifiedAt, v.PhoneVerifiedAt = memoryTimePtr(v.EmailVerifiedAt), memoryTimePtr(v.PhoneVerifiedAt)
+	return v
+}
+
+// events return event of committed transaction
+func (r *testRepository) events() []outbox.Message {
+	return r.outbox.Pending()
+}
+
+const stubTenant = "t1"
+
+// tenantCtx carry the tenant which is required by every repository method
+var tenantCtx = tenant.WithID(context.Background(), stubTenant)
+
+var stubUser = User{
+	ID:        "u1",
+	Name:      "foo",
+	Phone:     "+6281234567890",
+	Email:     "foo@bar.com",
+	Status:    UserStatusActive,
+	CreatedAt: time.Date(2024, 01, 01, 0, 0, 0, 0, time.UTC),
+	Version:   1,
+}
+
+func Test_Service_UpdateUser(t *testing.T) {
+	t.Run("replace all field", func(t *testing.T) {
+		repo := newTestRepository(stubUser)
+		svc := NewService(Option{}, repo)
+
+		usr, err := svc.UpdateUser(tenantCtx, "u1", 1, UpdateUser{Name: "bar", Email: "bar@foo.com"})
+		assert.Nil(t, err)
+
+		exp := stubUser
+		exp.Name, exp.Phone, exp.Email, exp.Status, exp.Version = "bar", "", "bar@foo.com", UserStatusInActive, 2
+		assert.Equal(t, exp, usr)
+		assert.Equal(t, exp, repo.rows().users["u1"])
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		svc := NewService(Option{}, newTestRepository())
+
+		_, err := svc.UpdateUser(tenantCtx, "u1", 1, UpdateUser{Name: "bar"})
+		assert.ErrorIs(t, err, ErrNotFound)
+	})
+
+	t.Run("version changed", func(t *testing.T) {
+		repo := newTestRepository(stubUser)
+		svc := NewService(Option{}, repo)
+
+		_, err := svc.UpdateUser(tenantCtx, "u1", 2, UpdateUser{Name: "bar", Email: "bar@foo.com"})
+		assert.ErrorIs(t, err, ErrVersionConflict)
+		assert.Equal(t, stubUser, repo.rows().users["u1"])
+	})
+}
+
+func Test_Service_PatchUser(t *testing.T) {
+	testCase := []struct {
+		desc   string
+		patch  string
+		exp    func(u User) User
+		expErr error
+	}{
+		{
+			desc:  "partial update",
+			patch: `{"name":"bar","status":2}`,
+			exp: func(u User) User {
+				u.Name, u.Status = "bar", UserStatusBanned
+				return u
+			},
+		},
+		{
+			desc:  "status by name",
+			patch: `{"status":"suspended"}`,
+			exp: func(u User) User {
+				u.Status = UserStatusSuspend
+				return u
+			},
+		},
+		{
+			desc:  "null reset field",
+			patch: `{"phone":null}`,
+			exp: func(u User) User {
+				u.Phone = ""
+				return u
+			},
+		},
+		{
+			desc:  "id, created_at and version cannot be changed",
+			patch: `{"id":"u2","created_at":"2020-01-01T00:00:00Z","version":9,"name":"bar"}`,
+			exp: func(u User) User {
+				u.Name = "bar"
+				return u
+			},
+		},
+		{
+			desc:   "null name",
+			patch:  `{"name":null}`,
+			expErr: ErrInvalidPatch,
+		},
+		{
+			desc:   "null email",
+			patch:  `{"phone":"","email": null}`,
+			expErr: ErrInvalidPatch,
+		},
+		{
+			desc:   "unknown field",
+			patch:  `{"role":"admin"}`,
+			expErr: ErrInvalidPatch,
+		},
+		{
+			desc:   "invalid status",
+			patch:  `{"status":"deleted"}`,
+			expErr: ErrInvalidPatch,
+		},
+		{
+			desc:   "invalid type",
+			patch:  `{"name":1}`,
+			expErr: ErrInvalidPatch,
+		},
+		{
+			desc:   "invalid json",
+			patch:  `{"name":`,
+			expErr: ErrInvalidPatch,
+		},
+	}
+
+	for _, tc := range testCase {
+		t.Run(tc.desc, func(t *testing.T) {
+			repo := newTestRepository(stubUser)
+			svc := NewService(Option{}, repo)
+
+			usr, err := svc.PatchUser(tenantCtx, "u1", 1, []byte(tc.patch))
+			if tc.expErr != nil {
+				assert.ErrorIs(t, err, tc.expErr)
+				assert.Equal(t, stubUser, repo.rows().users["u1"])
+				return
+			}
+
+			exp := tc.exp(stubUser)
+			exp.Version = 2
+			assert.Nil(t, err)
+			assert.Equal(t, exp, usr)
+			assert.Equal(t, exp, repo.rows().users["u1"])
+		})
+	}
+
+	t.Run("not found", func(t *testing.T) {
+		svc := NewService(Option{}, newTestRepository())
+
+		_, err := svc.PatchUser(tenantCtx, "u1", 1, []byte(`{}`))
+		assert.ErrorIs(t, err, ErrNotFound)
+	})
+
+	t.Run("erased_at and merged_into cannot be changed", func(t *testing.T) {
+		repo := newTestRepository(stubUser)
+		svc := NewService(Option{}, repo)
+
+		usr, err := svc.PatchUser(tenantCtx, "u1", 1, []byte(`{"erased_at":"2020-01-01T00:00:00Z","merged_into":"u9"}`))
+		assert.Nil(t, err)
+		assert.Nil(t, usr.ErasedAt)
+		assert.Empty(t, usr.MergedInto)
+		assert.Equal(t, usr, repo.rows().users["u1"])
+		for _, auditLog := range repo.rows().auditLogs {
+			for _, change := range auditLog.Changes {
+				assert.NotContains(t, []string{"erased_at", "merged_into"}, change.Field)
+			}
+		}
+	})
+
+	t.Run("version changed", func(t *testing.T) {
+		repo := newTestRepository(stubUser)
+		svc := NewService(Option{}, repo)
+
+		_, err := svc.PatchUser(tenantCtx, "u1", 2, []byte(`{"name":"bar"}`))
+		assert.ErrorIs(t, err, ErrVersionConflict)
+		assert.Equal(t, stubUser, repo.rows().users["u1"])
+	})
+}
+
+func Test_Service_DeleteUser(t *testing.T) {
+	repo := newTestRepository(stubUser)
+	svc := NewService(Option{}, repo)
+
+	assert.ErrorIs(t, svc.DeleteUser(tenantCtx, "u1", 2), ErrVersionConflict)
+	assert.Nil(t, svc.DeleteUser(tenantCtx, "u1", 1))
+	assert.ErrorIs(t, svc.DeleteUser(tenantCtx, "u1", 2), ErrNotFound)
+
+	_, err := svc.GetUserByID(tenantCtx, "u1")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	usr, err := svc.GetUserByID(tenantCtx, "u1", WithDeleted())
+	assert.Nil(t, err)
+	assert.NotNil(t, usr.DeletedAt)
+}
+
+func Test_Service_RestoreUser(t *testing.T) {
+	repo := newTestRepository(stubUser)
+	svc := NewService(Option{}, repo)
+
+	_, err := svc.RestoreUser(tenantCtx, "u1")
+	assert.ErrorIs(t, err, ErrNotFound, "restore not deleted user")
+
+	assert.Nil(t, svc.DeleteUser(tenantCtx, "u1", 1))
+	usr, err := svc.RestoreUser(tenantCtx, "u1")
+	assert.Nil(t, err)
+
+	exp := stubUser
+	exp.Version = 3
+	assert.Equal(t, exp, usr)
+}
+
+func Test_Service_PurgeUser(t *testing.T) {
+	repo := newTestRepository(stubUser)
+	svc := NewService(Option{}, repo)
+
+	assert.ErrorIs(t, svc.PurgeUser(tenantCtx, "u1"), ErrNotFound, "purge not deleted user")
+
+	assert.Nil(t, svc.DeleteUser(tenantCtx, "u1", 1))
+	assert.Nil(t, svc.PurgeUser(tenantCtx, "u1"))
+	assert.Empty(t, repo.rows().users)
+}
+
+func Test_Service_CreateUser(t *testing.T) {
+	svc := NewService(Option{}, newTestRepository())
+
+	usr, err := svc.CreateUser(tenantCtx, User{Name: "foo", Email: "foo@bar.com", Status: UserStatusActive})
+	assert.Nil(t, err)
+	assert.NotEmpty(t, usr.ID)
+
+	_, err = svc.CreateUser(tenantCtx, User{Name: "foo", Email: "foo@bar.com", Status: UserStatusBanned})
+	assert.ErrorIs(t, err, ErrInvalidStatus)
+
+	_, err = svc.CreateUser(tenantCtx, User{Name: "foo", Email: "foo@bar.com", Status: Status(99)})
+	assert.ErrorIs(t, err, ErrInvalidStatus)
+
+	t.Run("normalize", func(t *testing.T) {
+		svc := NewService(Option{DefaultRegion: "ID"}, newTestRepository())
+
+		usr, err := svc.CreateUser(tenantCtx, User{Name: " foo ", Phone: "0812-3456-7890", Email: "Foo@Example.com "})
+		assert.Nil(t, err)
+		assert.Equal(t, "foo", usr.Name)
+		assert.Equal(t, "+6281234567890", usr.Phone)
+		assert.Equal(t, "foo@example.com", usr.Email)
+	})
+
+	t.Run("field error", func(t *testing.T) {
+		svc := NewService(Option{DefaultRegion: "ID"}, newTestRepository())
+
+		_, err := svc.CreateUser(tenantCtx, User{Name: " ", Phone: "12", Email: "foo"})
+		assert.ErrorIs(t, err, ErrValidation)
+
+		var validationErr *ValidationError
+		assert.ErrorAs(t, err, &validationErr)
+		assert.Equal(t, []FieldError{
+			{Field: "name", Message: "is required"},
+			{Field: "email", Message: "invalid email address"},
+			{Field: "phone", Message: "invalid phone number"},
+		}, validationErr.Fields)
+	})
+}
+
+func Test_Service_ChangeStatus(t *testing.T) {
+	testCase := []struct {
+		desc   string
+		from   Status
+		to     Status
+		reason string
+		expErr *TransitionError
+	}{
+		{desc: "active to suspended", from: UserStatusActive, to: UserStatusSuspend},
+		{desc: "same status", from: UserStatusBanned, to: UserStatusBanned},
+		{desc: "banned to active with reason", from: UserStatusBanned, to: UserStatusActive, reason: "appeal accepted"},
+		{
+			desc: "banned to active without reason",
+			from: UserStatusBanned,
+			to:   UserStatusActive,
+			expErr: &TransitionError{
+				From:           UserStatusBanned,
+				To:             UserStatusActive,
+				Allowed:        []Status{UserStatusActive},
+				ReasonRequired: true,
+			},
+		},
+		{
+			desc: "inactive to suspended",
+			from: UserStatusInActive,
+			to:   UserStatusSuspend,
+			expErr: &TransitionError{
+				From:    UserStatusInActive,
+				To:      UserStatusSuspend,
+				Allowed: []Status{UserStatusActive, UserStatusBanned},
+			},
+		},
+	}
+
+	for _, tc := range testCase {
+		t.Run(tc.desc, func(t *testing.T) {
+			u := stubUser
+			u.Status = tc.from
+			repo := newTestRepository(u)
+			svc := NewService(Option{}, repo)
+
+			usr, err := svc.ChangeStatus(tenantCtx, "u1", tc.to, tc.reason)
+			if tc.expErr != nil {
+				assert.ErrorIs(t, err, ErrInvalidTransition)
+				var transitionErr *TransitionError
+				assert.ErrorAs(t, err, &transitionErr)
+				assert.Equal(t, tc.expErr, transitionErr)
+				assert.Equal(t, tc.from, repo.rows().users["u1"].Status)
+				return
+			}
+
+			assert.Nil(t, err)
+			assert.Equal(t, tc.to, usr.Status)
+			assert.Equal(t, tc.to, repo.rows().users["u1"].Status)
+		})
+	}
+
+	t.Run("not found", func(t *testing.T) {
+		svc := NewService(Option{}, newTestRepository())
+
+		_, err := svc.ChangeStatus(tenantCtx, "u1", UserStatusActive, "")
+		assert.ErrorIs(t, err, ErrNotFound)
+	})
+}
+
+func Test_Service_SuspendUser(t *testing.T) {
+	ctx := tenantCtx
+	until := time.Now().Add(time.Hour)
+
+	t.Run("suspend and reschedule", func(t *testing.T) {
+		repo := newTestRepository(stubUser)
+		svc := NewService(Option{}, repo)
+
+		usr, err := svc.SuspendUser(ctx, "u1", &until, "spam")
+		assert.Nil(t, err)
+		assert.Equal(t, UserStatusSuspend, usr.Status)
+		assert.Equal(t, &until, usr.SuspendedUntil)
+		assert.Equal(t, "spam", usr.SuspensionReason)
+
+		later := until.Add(time.Hour)
+		usr, err = svc.SuspendUser(ctx, "u1", &later, "spam again")
+		assert.Nil(t, err)
+		assert.Equal(t, memoryTimePtr(&later), repo.rows().users["u1"].SuspendedUntil)
+		assert.Equal(t, "spam again", repo.rows().users["u1"].SuspensionReason)
+	})
+
+	t.Run("until in the past", func(t *testing.T) {
+		svc := NewService(Option{}, newTestRepository(stubUser))
+
+		past := time.Now().Add(-time.Hour)
+		_, err := svc.SuspendUser(ctx, "u1", &past, "spam")
+		assert.ErrorIs(t, err, ErrInvalidSuspension)
+	})
+
+	t.Run("transition not allowed", func(t *testing.T) {
+		u := stubUser
+		u.Status = UserStatusInActive
+		svc := NewService(Option{}, newTestRepository(u))
+
+		_, err := svc.SuspendUser(ctx, "u1", &until, "spam")
+		assert.ErrorIs(t, err, ErrInvalidTransition)
+	})
+
+	t.Run("leaving suspended status clear suspension", func(t *testing.T) {
+		repo := newTestRepository(stubUser)
+		svc := NewService(Option{}, repo)
+
+		_, err := svc.SuspendUser(ctx, "u1", &until, "spam")
+		assert.Nil(t, err)
+
+		usr, err := svc.ChangeStatus(ctx, "u1", UserStatusBanned, "")
+		assert.Nil(t, err)
+		assert.Nil(t, usr.SuspendedUntil)
+		assert.Empty(t, usr.SuspensionReason)
+	})
+}
+
+func Test_Service_CancelSuspension(t *testing.T) {
+	ctx := tenantCtx
+	repo := newTestRepository(stubUser)
+	svc := NewService(Option{}, repo)
+
+	_, err := svc.CancelSuspension(ctx, "u1")
+	assert.ErrorIs(t, err, ErrNotSuspended)
+
+	_, err = svc.SuspendUser(ctx, "u1", nil, "spam")
+	assert.Nil(t, err)
+
+	usr, err := svc.CancelSuspension(ctx, "u1")
+	assert.Nil(t, err)
+
+	exp := stubUser
+	exp.Version = 3
+	assert.Equal(t, exp, usr)
+	assert.Equal(t, exp, repo.rows().users["u1"])
+}
+
+func Test_Service_LiftExpiredSuspension(t *testing.T) {
+	expired := time.Now().Add(-time.Minute)
+	notExpired := time.Now().Add(time.Hour)
+
+	u1 := stubUser
+	u1.Status, u1.SuspendedUntil, u1.SuspensionReason = UserStatusSuspend, &expired, "spam"
+	u2 := stubUser
+	u2.ID, u2.Status, u2.SuspendedUntil = "u2", UserStatusSuspend, &notExpired
+	u3 := stubUser
+	u3.ID, u3.Status = "u3", UserStatusSuspend
+
+	repo := newTestRepository(u1, u2, u3)
+	svc := NewService(Option{}, repo)
+
+	n, err := svc.LiftExpiredSuspension(tenantCtx)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, n)
+
+	exp := stubUser
+	exp.Version = 2
+	assert.Equal(t, exp, repo.rows().users["u1"])
+	assert.Equal(t, u2, repo.rows().users["u2"])
+	assert.Equal(t, u3, repo.rows().users["u3"])
+}
@@ -0,0 +1,82 @@
+package user
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// userSortFields is field the user can be sorted by, it is the column name used in ORDER BY.
+// the compare func is used by repository which doesn't sort in the database, see memoryRepository.
+var userSortFields = map[string]func(a, b User) int{
+	"id":              func(a, b User) int { return strings.Compare(a.ID, b.ID) },
+	"name":            func(a, b User) int { return compareText(a.Name, b.Name) },
+	"phone":           func(a, b User) int { return compareText(a.Phone, b.Phone) },
+	"email":           func(a, b User) int { return compareText(a.Email, b.Email) },
+	"status":          func(a, b User) int { return compareInt(int64(a.Status), int64(b.Status)) },
+	"created_at":      func(a, b User) int { return compareTime(&a.CreatedAt, &b.CreatedAt) },
+	"deleted_at":      func(a, b User) int { return compareTime(a.DeletedAt, b.DeletedAt) },
+	"suspended_until": func(a, b User) int { return compareTime(a.SuspendedUntil, b.SuspendedUntil) },
+	"version":         func(a, b User) int { return compareInt(a.Version, b.Version) },
+}
+
+// auditLogSortFields is field the audit log can be sorted by
+var auditLogSortFields = map[string]func(a, b AuditLog) int{
+	"id":         func(a, b AuditLog) int { return strings.Compare(a.ID, b.ID) },
+	"action":     func(a, b AuditLog) int { return compareText(string(a.Action), string(b.Action)) },
+	"actor":      func(a, b AuditLog) int { return compareText(a.Actor, b.Actor) },
+	"created_at": func(a, b AuditLog) int { return compareTime(&a.CreatedAt, &b.CreatedAt) },
+}
+
+// validateSortBy return ErrInvalidSortBy when sortBy has field which is not in fields,
+// sortBy is written as is in ORDER BY so it must be checked before the query is built.
+func validateSortBy[T any](sortBy []string, fields map[string]func(a, b T) int) error {
+	for _, v := range sortBy {
+		if _, ok := fields[strings.TrimPrefix(v, "-")]; !ok {
+			return fmt.Errorf("%w: %q", ErrInvalidSortBy, v)
+		}
+	}
+	return nil
+}
+
+// compareSortBy compare a and b by every field of validated sortBy, field prefixed with - is descending
+func compareSortBy[T any](a, b T, sortBy []string, fields map[string]func(a, b T) int) int {
+	for _, v := range sortBy {
+		field, desc := strings.TrimPrefix(v, "-"), strings.HasPrefix(v, "-")
+		if c := fields[field](a, b); c != 0 {
+			if desc {
+				return -c
+			}
+			return c
+		}
+	}
+	return 0
+}
+
+// compareText compare case insensitively like the column collation
+func compareText(a, b string) int {
+	return strings.Compare(strings.ToLower(a), strings.ToLower(b))
+}
+
+func compareInt(a, b int64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// compareTime order nil first like NULL in mysql
+func compareTime(a, b *time.Time) int {
+	switch {
+	case a == nil && b == nil:
+		return 0
+	case a == nil:
+		return -1
+	case b == nil:
+		return 1
+	}
+	return a.Compare(*b)
+}
@@ -1,7 +1,66 @@
 package user
 
+// every query is scoped by tenant_id, tenant of the ctx is the first arg of the where clause
 const (
-	getUserQuery    = `SELECT id, name, phone, email, status, created_at FROM user`
-	countUserQuery  = `SELECT COUNT(1) FROM user`
-	createUserQuery = `INSERT INTO user(id, name, phone, email, status, created_at) VALUES (?, ?, ?, ?, ?, ?)`
+	getUserQuery           = `SELECT id, name, phone, email, status, created_at, deleted_at, suspended_until, suspension_reason, version, erased_at, merged_into, email_verified_at, phone_verified_at FROM user`
+	getUserByIDQuery       = getUserQuery + ` WHERE tenant_id = ? AND id = ?`
+	getActiveUserByIDQuery = getUserByIDQuery + ` AND deleted_at IS NULL`
+	countUserQuery         = `SELECT COUNT(1) FROM user`
+	createUserQuery        = insertUserQuery + insertUserValues
+	updateUserQuery        = `UPDATE user SET name = ?, phone = ?, email = ?, status = ?, suspended_until = ?, suspension_reason = ?, email_verified_at = ?, phone_verified_at = ?, version = version + 1 WHERE tenant_id = ? AND id = ? AND version = ? AND deleted_at IS NULL`
+	softDeleteUserQuery    = `UPDATE user SET deleted_at = ?, version = version + 1 WHERE tenant_id = ? AND id = ? AND version = ? AND deleted_at IS NULL`
+	restoreUserQuery       = `UPDATE user SET deleted_at = NULL, version = version + 1 WHERE tenant_id = ? AND id = ? AND deleted_at IS NOT NULL AND merged_into = ''`
+	purgeUserQuery         = `DELETE FROM user WHERE tenant_id = ? AND id = ? AND deleted_at IS NOT NULL AND merged_into = ''`
+	eraseUserQuery         = `UPDATE user SET name = ?, phone = ?, email = ?, erased_at = ?, email_verified_at = NULL, phone_verified_at = NULL, version = version + 1 WHERE tenant_id = ? AND id = ? AND version = ? AND erased_at IS NULL`
+	tenantWhereClause      = `tenant_id = ?`
+
+	// user sharing phone is grouped in the database, so only candidate of duplicate is read, see user_phone_ix
+	findPhoneDuplicateQuery = getUserQuery + ` WHERE tenant_id = ? AND deleted_at IS NULL AND erased_at IS NULL AND phone IN (SELECT phone FROM user WHERE tenant_id = ? AND phone <> '' AND deleted_at IS NULL AND erased_at IS NULL GROUP BY tenant_id, phone HAVING COUNT(*) > 1) ORDER BY phone, created_at, id`
+
+	// merged user is soft deleted, user merged into it before is moved to the survivor so merged id is resolved in one step
+	mergeUserQuery        = `UPDATE user SET deleted_at = ?, merged_into = ?, version = version + 1 WHERE tenant_id = ? AND id = ? AND version = ? AND deleted_at IS NULL`
+	moveMergedUserQuery   = `UPDATE user SET merged_into = ? WHERE tenant_id = ? AND merged_into = ?`
+	notDeletedWhereClause = `deleted_at IS NULL`
+
+	// multi rows insert is insertUserQuery followed by comma separated insertUserValues
+	insertUserQuery        = `INSERT INTO user(tenant_id, id, name, phone, email, status, created_at, version) VALUES `
+	insertUserValues       = `(?, ?, ?, ?, ?, ?, ?, ?)`
+	findExistingEmailQuery = `SELECT email FROM user WHERE tenant_id = ? AND email IN (?)`
+
+	// lock expired suspension, locked row is skipped so multiple sweeper don't pick the same user
+	lockExpiredSuspensionQuery = getUserQuery + ` WHERE tenant_id = ? AND status = ? AND suspended_until <= ? AND deleted_at IS NULL ORDER BY suspended_until LIMIT ? FOR UPDATE SKIP LOCKED`
+	liftSuspensionQuery        = `UPDATE user SET status = ?, suspended_until = NULL, suspension_reason = '', version = version + 1 WHERE tenant_id = ? AND id = ?`
+
+	// the only query across tenant, the sweeper lift expired suspension of every returned tenant
+	findExpiredSuspensionTenantsQuery = `SELECT DISTINCT tenant_id FROM user WHERE status = ? AND suspended_until <= ? AND deleted_at IS NULL`
+
+	// multi rows insert is insertAuditLogQuery followed by comma separated insertAuditLogValues
+	insertAuditLogQuery  = `INSERT INTO user_audit_log(tenant_id, id, user_id, action, actor, request_id, reason, changes, created_at) VALUES `
+	insertAuditLogValues = `(?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	getAuditLogQuery     = `SELECT id, user_id, action, actor, request_id, reason, changes, created_at FROM user_audit_log`
+	countAuditLogQuery   = `SELECT COUNT(1) FROM user_audit_log`
+	auditLogUserClause   = `user_id = ?`
+
+	// credential, failed login is counted in locked row so concurrent login doesn't lose a failure
+	getUserByEmailQuery          = getUserQuery + ` WHERE tenant_id = ? AND email = ? AND deleted_at IS NULL`
+	getCredentialQuery           = `SELECT user_id, password_hash, failed_attempts, locked_until, password_changed_at FROM user_credential WHERE tenant_id = ? AND user_id = ?`
+	lockCredentialQuery          = getCredentialQuery + ` FOR UPDATE`
+	saveCredentialQuery          = `INSERT INTO user_credential(tenant_id, user_id, password_hash, failed_attempts, locked_until, password_changed_at) VALUES (?, ?, ?, 0, NULL, ?) ON DUPLICATE KEY UPDATE password_hash = VALUES(password_hash), failed_attempts = 0, locked_until = NULL, password_changed_at = VALUES(password_changed_at)`
+	upgradePasswordHashQuery     = `UPDATE user_credential SET password_hash = ? WHERE tenant_id = ? AND user_id = ? AND password_hash = ?`
+	updateLoginFailureQuery      = `UPDATE user_credential SET failed_attempts = ?, locked_until = ? WHERE tenant_id = ? AND user_id = ?`
+	deleteCredentialQuery        = `DELETE FROM user_credential WHERE tenant_id = ? AND user_id = ?`
+	createPasswordResetQuery     = `INSERT INTO user_password_reset(tenant_id, token_hash, user_id, expires_at, created_at) VALUES (?, ?, ?, ?, ?)`
+	lockPasswordResetQuery       = `SELECT user_id FROM user_password_reset WHERE tenant_id = ? AND token_hash = ? AND expires_at > ? FOR UPDATE`
+	deleteUserPasswordResetQuery = `DELETE FROM user_password_reset WHERE tenant_id = ? AND user_id = ?`
+
+	// verification attempt is counted only while the code is valid, 0 affected row means the code cannot be used
+	lockVerificationQuery    = `SELECT attempts, window_started_at, created_at FROM user_verification WHERE tenant_id = ? AND user_id = ? AND channel = ? FOR UPDATE`
+	saveVerificationQuery    = `INSERT INTO user_verification(tenant_id, user_id, channel, contact, code_hash, attempts, window_started_at, expires_at, created_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?) ON DUPLICATE KEY UPDATE contact = VALUES(contact), code_hash = VALUES(code_hash), attempts = VALUES(attempts), window_started_at = VALUES(window_started_at), expires_at = VALUES(expires_at), created_at = VALUES(created_at)`
+	attemptVerificationQuery = `UPDATE user_verification SET attempts = attempts + 1 WHERE tenant_id = ? AND user_id = ? AND channel = ? AND attempts < ? AND expires_at > ?`
+	getVerificationQuery     = `SELECT user_id, channel, contact, code_hash, attempts, window_started_at, expires_at, created_at FROM user_verification WHERE tenant_id = ? AND user_id = ? AND channel = ?`
+	deleteVerificationQuery  = `DELETE FROM user_verification WHERE tenant_id = ? AND user_id = ? AND channel = ?`
+
+	// redact personal data in audit log of erased user
+	lockAuditLogChangesQuery   = `SELECT id, changes FROM user_audit_log WHERE tenant_id = ? AND user_id = ? FOR UPDATE`
+	updateAuditLogChangesQuery = `UPDATE user_audit_log SET changes = ? WHERE tenant_id = ? AND id = ?`
 )
@@ -0,0 +1,152 @@
+package user
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Status is user status, stored as int and represented by name in JSON and query param
+type Status int
+
+const (
+	UserStatusInActive Status = iota
+	UserStatusActive
+	UserStatusBanned
+	UserStatusSuspend
+)
+
+var statusNames = map[Status]string{
+	UserStatusInActive: "inactive",
+	UserStatusActive:   "active",
+	UserStatusBanned:   "banned",
+	UserStatusSuspend:  "suspended",
+}
+
+// initialStatuses is status allowed when user is created
+var initialStatuses = []Status{UserStatusInActive, UserStatusActive}
+
+type transition struct {
+	// requireReason transition need admin reason
+	requireReason bool
+}
+
+// transitions is user status state machine, from -> to -> rule.
+// transition to the same status is always allowed (no-op).
+var transitions = map[Status]map[Status]transition{
+	UserStatusInActive: {
+		UserStatusActive: {},
+		UserStatusBanned: {},
+	},
+	UserStatusActive: {
+		UserStatusInActive: {},
+		UserStatusSuspend:  {},
+		UserStatusBanned:   {},
+	},
+	UserStatusSuspend: {
+		UserStatusActive: {},
+		UserStatusBanned: {},
+	},
+	UserStatusBanned: {
+		UserStatusActive: {requireReason: true},
+	},
+}
+
+func ParseStatus(s string) (Status, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	for status, name := range statusNames {
+		if name == s {
+			return status, nil
+		}
+	}
+
+	// backward compatibility, status used to be an int
+	if n, err := strconv.Atoi(s); err == nil && Status(n).IsValid() {
+		return Status(n), nil
+	}
+
+	return 0, fmt.Errorf("invalid user status %q", s)
+}
+
+func (s Status) IsValid() bool {
+	_, ok := statusNames[s]
+	return ok
+}
+
+func (s Status) String() string {
+	if name, ok := statusNames[s]; ok {
+		return name
+	}
+	return strconv.Itoa(int(s))
+}
+
+func (s Status) MarshalText() ([]byte, error) {
+	if !s.IsValid() {
+		return nil, fmt.Errorf("invalid user status %d", int(s))
+	}
+	return []byte(s.String()), nil
+}
+
+func (s *Status) UnmarshalText(text []byte) error {
+	status, err := ParseStatus(string(text))
+	if err != nil {
+		return err
+	}
+	*s = status
+	return nil
+}
+
+// UnmarshalJSON accept status name or legacy int
+func (s *Status) UnmarshalJSON(data []byte) error {
+	var name string
+	if err := json.Unmarshal(data, &name); err == nil {
+		return s.UnmarshalText([]byte(name))
+	}
+
+	var n int
+	if err := json.Unmarshal(data, &n); err != nil {
+		return fmt.Errorf("invalid user status %s", data)
+	}
+	return s.UnmarshalText([]byte(strconv.Itoa(n)))
+}
+
+// NextStatuses return status allowed as the next status
+func (s Status) NextStatuses() []Status {
+	var next []Status
+	for _, to := range []Status{UserStatusInActive, UserStatusActive, UserStatusBanned, UserStatusSuspend} {
+		if _, ok := transitions[s][to]; ok {
+			next = append(next, to)
+		}
+	}
+	return next
+}
+
+// CanTransition validate status change, reason is required for some transition.
+// *TransitionError is returned when the transition is not allowed.
+func (s Status) CanTransition(to Status, reason string) error {
+	if s == to {
+		return nil
+	}
+
+	t, ok := transitions[s][to]
+	if !ok || (t.requireReason && strings.TrimSpace(reason) == "") {
+		return &TransitionError{
+			From:           s,
+			To:             to,
+			Allowed:        s.NextStatuses(),
+			ReasonRequired: ok && t.requireReason,
+		}
+	}
+
+	return nil
+}
+
+func isInitialStatus(s Status) bool {
+	for _, status := range initialStatuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,113 @@
+package user
+
+import (
+	"context"
+	"time"
+
+	"github.com/tuingking/supersvc/pkg/ctxkey"
+	"github.com/tuingking/supersvc/pkg/logger"
+	"github.com/tuingking/supersvc/pkg/tenant"
+)
+
+const (
+	defaultSweepInterval  = time.Minute
+	defaultSweepBatchSize = 100
+)
+
+// SweeperOption configure background job which lift expired suspension
+type SweeperOption struct {
+	// Interval between sweep, default to 1 minute
+	Interval time.Duration
+
+	// BatchSize is max user reactivated in single transaction, default to 100
+	BatchSize int
+}
+
+func (o SweeperOption) interval() time.Duration {
+	if o.Interval <= 0 {
+		return defaultSweepInterval
+	}
+	return o.Interval
+}
+
+func (o SweeperOption) batchSize() int {
+	if o.BatchSize <= 0 {
+		return defaultSweepBatchSize
+	}
+	return o.BatchSize
+}
+
+// Sweeper periodically reactivate user whose suspension is expired, tenant by tenant.
+// expired user is locked when lifted, so it is safe to run sweeper in every replica.
+type Sweeper interface {
+	// Run block until ctx is canceled
+	Run(ctx context.Context)
+}
+
+type sweeper struct {
+	opt SweeperOption
+	svc Service
+}
+
+func NewSweeper(opt SweeperOption, svc Service) Sweeper {
+	return &sweeper{
+		opt: opt,
+		svc: svc,
+	}
+}
+
+func (s *sweeper) Run(ctx context.Context) {
+	log := logger.Get(ctx)
+
+	// audit log of lifted suspension
+	ctx = context.WithValue(ctx, ctxkey.XActor, ActorSweeper)
+
+	ticker := time.NewTicker(s.opt.interval())
+	defer ticker.Stop()
+
+	log.Info().Dur("interval", s.opt.interval()).Msg("suspension sweeper started")
+	for {
+		select {
+		case <-ctx.Done():
+			log.Info().Msg("suspension sweeper stopped")
+			return
+		case <-ticker.C:
+			s.sweep(ctx)
+		}
+	}
+}
+
+// sweep lift expired suspension of every tenant, failure of a tenant doesn't stop the others
+func (s *sweeper) sweep(ctx context.Context) {
+	log := logger.Get(ctx)
+
+	tenants, err := s.svc.ExpiredSuspensionTenants(ctx)
+	if err != nil {
+		log.Err(err).Msg("failed: sweep expired suspension")
+		return
+	}
+
+	for _, tenantID := range tenants {
+		if ctx.Err() != nil {
+			return
+		}
+		s.sweepTenant(ctx, tenantID)
+	}
+}
+
+// sweepTenant lift expired suspension of the tenant batch by batch until no full batch is left
+func (s *sweeper) sweepTenant(ctx context.Context, tenantID string) {
+	log := logger.Get(ctx)
+
+	ctx = tenant.WithID(ctx, tenantID)
+	for ctx.Err() == nil {
+		n, err := s.svc.LiftExpiredSuspension(ctx)
+		if err != nil {
+			log.Err(err).Str("tenant_id", tenantID).Msg("failed: sweep expired suspension")
+			return
+		}
+		if n < s.opt.batchSize() {
+			return
+		}
+	}
+}
@@ -0,0 +1,29 @@
+package user
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Sweeper_Sweep(t *testing.T) {
+	expired := time.Now().Add(-time.Minute)
+
+	var users []User
+	for _, id := range []string{"u1", "u2", "u3"} {
+		u := stubUser
+		u.ID, u.Status, u.SuspendedUntil = id, UserStatusSuspend, &expired
+		users = append(users, u)
+	}
+
+	repo := newTestRepository(users...)
+	opt := SweeperOption{BatchSize: 2}
+	s := NewSweeper(opt, NewService(Option{Sweeper: opt}, repo)).(*sweeper)
+
+	s.sweep(tenantCtx)
+	for id, usr := range repo.rows().users {
+		assert.Equal(t, UserStatusActive, usr.Status, id)
+		assert.Nil(t, usr.SuspendedUntil, id)
+	}
+}
@@ -0,0 +1,17 @@
+package user
+
+import (
+	"context"
+
+	"github.com/tuingking/supersvc/pkg/tenant"
+)
+
+// ctxTenant return tenant of ctx, ErrInvalidTenant is returned when ctx has no valid tenant.
+// repository call it first in every method, so no query is run without tenant.
+func ctxTenant(ctx context.Context) (string, error) {
+	id, err := tenant.FromContext(ctx)
+	if err != nil {
+		return "", ErrInvalidTenant
+	}
+	return id, nil
+}
@@ -0,0 +1,113 @@
+package user
+
+import (
+	"errors"
+	"fmt"
+	"net/mail"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/nyaruka/phonenumbers"
+)
+
+// maxFieldLength follow varchar(250) column of user table
+const maxFieldLength = 250
+
+// FieldError is validation error of single user field
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// ValidationError is returned when one or more user field is invalid
+type ValidationError struct {
+	Fields []FieldError
+}
+
+func (e *ValidationError) Error() string {
+	msgs := make([]string, 0, len(e.Fields))
+	for _, f := range e.Fields {
+		msgs = append(msgs, f.Field+": "+f.Message)
+	}
+	return fmt.Sprintf("%s: %s", ErrValidation, strings.Join(msgs, "; "))
+}
+
+func (e *ValidationError) Is(target error) bool {
+	return target == ErrValidation
+}
+
+func (e *ValidationError) ErrorCode() int {
+	return ErrValidation.Code
+}
+
+func (e *ValidationError) add(field, msg string) {
+	e.Fields = append(e.Fields, FieldError{Field: field, Message: msg})
+}
+
+// normalizeUser trim name, lowercase email and format phone as E.164.
+// phone without country code is parsed using region, e.g: ID.
+// *ValidationError is returned when any field is invalid.
+func normalizeUser(u *User, region string) error {
+	var verr ValidationError
+
+	u.Name = strings.TrimSpace(u.Name)
+	switch {
+	case u.Name == "":
+		verr.add("name", "is required")
+	case utf8.RuneCountInString(u.Name) > maxFieldLength:
+		verr.add("name", fmt.Sprintf("must not exceed %d characters", maxFieldLength))
+	}
+
+	email, err := normalizeEmail(u.Email)
+	if err != nil {
+		verr.add("email", err.Error())
+	}
+	u.Email = email
+
+	phone, err := normalizePhone(u.Phone, region)
+	if err != nil {
+		verr.add("phone", err.Error())
+	}
+	u.Phone = phone
+
+	if len(verr.Fields) > 0 {
+		return &verr
+	}
+	return nil
+}
+
+func normalizeEmail(email string) (string, error) {
+	email = strings.ToLower(strings.TrimSpace(email))
+	if email == "" {
+		return email, errors.New("is required")
+	}
+	if len(email) > maxFieldLength {
+		return email, fmt.Errorf("must not exceed %d characters", maxFieldLength)
+	}
+
+	// reject display name, e.g: "Foo <foo@example.com>"
+	addr, err := mail.ParseAddress(email)
+	if err != nil || addr.Address != email {
+		return email, errors.New("invalid email address")
+	}
+
+	return email, nil
+}
+
+// normalizePhone format phone as E.164, empty phone is allowed
+func normalizePhone(phone, region string) (string, error) {
+	phone = strings.TrimSpace(phone)
+	if phone == "" {
+		return phone, nil
+	}
+
+	num, err := phonenumbers.Parse(phone, strings.ToUpper(region))
+	if err != nil || !phonenumbers.IsValidNumber(num) {
+		if region == "" && !strings.HasPrefix(phone, "+") {
+			return phone, errors.New("invalid phone number, country code is required")
+		}
+		return phone, errors.New("invalid phone number")
+	}
+
+	return phonenumbers.Format(num, phonenumbers.E164), nil
+}
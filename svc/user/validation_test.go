@@ -0,0 +1,68 @@
+package user
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_NormalizeEmail(t *testing.T) {
+	testCase := []struct {
+		in     string
+		exp    string
+		expErr bool
+	}{
+		{in: "foo@example.com", exp: "foo@example.com"},
+		{in: " Foo@Example.COM ", exp: "foo@example.com"},
+		{in: "", expErr: true},
+		{in: "foo", expErr: true},
+		{in: "foo@", expErr: true},
+		{in: "Foo <foo@example.com>", expErr: true},
+	}
+
+	for _, tc := range testCase {
+		t.Run(tc.in, func(t *testing.T) {
+			got, err := normalizeEmail(tc.in)
+			if tc.expErr {
+				assert.NotNil(t, err)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, tc.exp, got)
+		})
+	}
+}
+
+func Test_NormalizePhone(t *testing.T) {
+	testCase := []struct {
+		in     string
+		region string
+		exp    string
+		expErr bool
+	}{
+		{in: "", region: "ID", exp: ""},
+		{in: "081234567890", region: "ID", exp: "+6281234567890"},
+		{in: "0812-3456-7890", region: "ID", exp: "+6281234567890"},
+		{in: "(0812) 3456 7890", region: "ID", exp: "+6281234567890"},
+		{in: "+62 812 3456 7890", region: "ID", exp: "+6281234567890"},
+		{in: "6281234567890", region: "ID", exp: "+6281234567890"},
+		{in: "+1 (650) 253-0000", region: "ID", exp: "+16502530000"},
+		{in: "(650) 253-0000", region: "US", exp: "+16502530000"},
+		{in: "+6281234567890", region: "", exp: "+6281234567890"},
+		{in: "081234567890", region: "", expErr: true},
+		{in: "12", region: "ID", expErr: true},
+		{in: "phone", region: "ID", expErr: true},
+	}
+
+	for _, tc := range testCase {
+		t.Run(tc.region+"/"+tc.in, func(t *testing.T) {
+			got, err := normalizePhone(tc.in, tc.region)
+			if tc.expErr {
+				assert.NotNil(t, err)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, tc.exp, got)
+		})
+	}
+}
@@ -0,0 +1,276 @@
+package user
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/tuingking/supersvc/pkg/logger"
+	"github.com/tuingking/supersvc/pkg/notify"
+)
+
+const (
+	defaultVerificationCodeLength  = 6
+	defaultVerificationCodeTTL     = 10 * time.Minute
+	defaultVerificationMaxAttempts = 5
+	defaultVerificationCooldown    = time.Minute
+	defaultVerificationWindow      = time.Hour
+)
+
+type VerificationOption struct {
+	// CodeLength is number of digit of the code, default to 6
+	CodeLength int
+
+	// CodeTTL is how long the code is valid, default to 10 minute
+	CodeTTL time.Duration
+
+	// MaxAttempts is number of code entered in AttemptWindow before the code is invalidated, default to 5.
+	// the count is kept when new code is sent, so resend doesn't give more guesses
+	MaxAttempts int
+
+	// AttemptWindow is how long attempts are counted since the first code is sent, default to 1 hour
+	AttemptWindow time.Duration
+
+	// ResendCooldown is min duration between code sent to the same user and channel, default to 1 minute
+	ResendCooldown time.Duration
+}
+
+func (o VerificationOption) codeLength() int {
+	if o.CodeLength <= 0 {
+		return defaultVerificationCodeLength
+	}
+	return o.CodeLength
+}
+
+func (o VerificationOption) codeTTL() time.Duration {
+	if o.CodeTTL <= 0 {
+		return defaultVerificationCodeTTL
+	}
+	return o.CodeTTL
+}
+
+func (o VerificationOption) maxAttempts() int {
+	if o.MaxAttempts <= 0 {
+		return defaultVerificationMaxAttempts
+	}
+	return o.MaxAttempts
+}
+
+func (o VerificationOption) attemptWindow() time.Duration {
+	if o.AttemptWindow <= 0 {
+		return defaultVerificationWindow
+	}
+	return o.AttemptWindow
+}
+
+func (o VerificationOption) resendCooldown() time.Duration {
+	if o.ResendCooldown <= 0 {
+		return defaultVerificationCooldown
+	}
+	return o.ResendCooldown
+}
+
+// VerificationChannel is contact of the user which is verified
+type VerificationChannel string
+
+const (
+	VerificationChannelEmail VerificationChannel = "email"
+	VerificationChannelPhone VerificationChannel = "phone"
+)
+
+var verificationChannels = []VerificationChannel{VerificationChannelEmail, VerificationChannelPhone}
+
+// Verification is pending one-time code sent to contact of the user, only hash of the code is stored.
+// there is at most one pending code per user and channel, sending new code replace the previous one.
+type Verification struct {
+	UserID  string
+	Channel VerificationChannel
+
+	// Contact is the email or phone the code is sent to, the code is rejected when the contact has changed since
+	Contact  string
+	CodeHash string
+
+	// Attempts is counted since WindowStartedAt, it is carried over to the new code while the window is open
+	Attempts        int
+	WindowStartedAt time.Time
+	ExpiresAt       time.Time
+	CreatedAt       time.Time
+}
+
+// renew return v which replace prev, prev is nil when no code is pending.
+// ErrVerificationCooldown is returned when prev is created less than cooldown before v.
+// attempts of prev is kept while its window is open, so resend doesn't reset the attempt budget.
+func (v Verification) renew(prev *Verification, cooldown, window time.Duration) (Verification, error) {
+	v.Attempts, v.WindowStartedAt = 0, v.CreatedAt
+	if prev == nil {
+		return v, nil
+	}
+	if v.CreatedAt.Before(prev.CreatedAt.Add(cooldown)) {
+		return v, ErrVerificationCooldown
+	}
+	if v.CreatedAt.Before(prev.WindowStartedAt.Add(window)) {
+		v.Attempts, v.WindowStartedAt = prev.Attempts, prev.WindowStartedAt
+	}
+	return v, nil
+}
+
+// VerificationSent is returned when the code is sent, the code itself is only delivered to the contact
+type VerificationSent struct {
+	Channel   VerificationChannel `json:"channel"`
+	ExpiresAt time.Time           `json:"expires_at"`
+}
+
+// contact return the contact of the channel and when it is verified
+func (u *User) contact(channel VerificationChannel) (string, *time.Time) {
+	if channel == VerificationChannelPhone {
+		return u.Phone, u.PhoneVerifiedAt
+	}
+	return u.Email, u.EmailVerifiedAt
+}
+
+func (u *User) setVerified(channel VerificationChannel, at time.Time) {
+	if channel == VerificationChannelPhone {
+		u.PhoneVerifiedAt = &at
+		return
+	}
+	u.EmailVerifiedAt = &at
+}
+
+// verificationCode return random numeric code of n digit
+func verificationCode(n int) (string, error) {
+	max := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(n)), nil)
+	v, err := rand.Int(rand.Reader, max)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%0*d", n, v), nil
+}
+
+func hashVerificationCode(code string) string {
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
+
+func verificationMessage(channel VerificationChannel, contact, code string, ttl time.Duration) notify.Message {
+	body := fmt.Sprintf("Your verification code is %s. It expires in %s.", code, ttl)
+	if channel == VerificationChannelPhone {
+		return notify.Message{Channel: notify.ChannelSMS, To: contact, Body: body}
+	}
+	return notify.Message{Channel: notify.ChannelEmail, To: contact, Subject: "Verify your email", Body: body}
+}
+
+func (s *service) SendVerification(ctx context.Context, id string, channel VerificationChannel) (VerificationSent, error) {
+	log := logger.Get(ctx)
+
+	usr, err := s.repo.FindByID(ctx, id)
+	if err != nil {
+		return VerificationSent{}, err
+	}
+
+	contact, verifiedAt := usr.contact(channel)
+	if contact == "" || usr.ErasedAt != nil {
+		return VerificationSent{}, errors.Wrapf(ErrNoContact, "user has no %s", channel)
+	}
+	if verifiedAt != nil {
+		return VerificationSent{}, ErrContactVerified
+	}
+
+	code, err := verificationCode(s.opt.Verification.codeLength())
+	if err != nil {
+		log.Err(err).Msg("failed: generate verification code")
+		return VerificationSent{}, err
+	}
+
+	now := time.Now()
+	v := Verification{
+		UserID:    id,
+		Channel:   channel,
+		Contact:   contact,
+		CodeHash:  hashVerificationCode(code),
+		ExpiresAt: now.Add(s.opt.Verification.codeTTL()),
+		CreatedAt: now,
+	}
+	opt := s.opt.Verification
+	err = s.repo.SaveVerification(ctx, v, opt.resendCooldown(), opt.attemptWindow())
+	if errors.Is(err, ErrVerificationCooldown) {
+		return VerificationSent{}, err
+	}
+	if err != nil {
+		log.Err(err).Msg("failed: save verification")
+		return VerificationSent{}, err
+	}
+
+	// the code is saved first, so it is valid by the time it is delivered
+	if err := s.notifier.Notify(ctx, verificationMessage(channel, contact, code, s.opt.Verification.codeTTL())); err != nil {
+		log.Err(err).Str("channel", string(channel)).Msg("failed: send verification code")
+		return VerificationSent{}, err
+	}
+
+	log.Info().Str("user_id", id).Str("channel", string(channel)).Msg("verification code sent")
+
+	return VerificationSent{Channel: channel, ExpiresAt: v.ExpiresAt}, nil
+}
+
+func (s *service) VerifyContact(ctx context.Context, id string, channel VerificationChannel, code string) (User, error) {
+	log := logger.Get(ctx)
+
+	usr, err := s.repo.FindByID(ctx, id)
+	if err != nil {
+		return usr, err
+	}
+
+	contact, verifiedAt := usr.contact(channel)
+	if verifiedAt != nil {
+		return usr, ErrContactVerified
+	}
+
+	// the attempt is counted before the code is compared, so concurrent guess cannot exceed the limit
+	now := time.Now()
+	v, err := s.repo.AttemptVerification(ctx, id, channel, s.opt.Verification.maxAttempts(), now)
+	if errors.Is(err, ErrNotFound) {
+		return usr, ErrInvalidVerificationCode
+	}
+	if err != nil {
+		return usr, err
+	}
+	if code == "" || subtle.ConstantTimeCompare([]byte(hashVerificationCode(code)), []byte(v.CodeHash)) != 1 || v.Contact != contact {
+		return usr, ErrInvalidVerificationCode
+	}
+
+	before := usr
+	usr.setVerified(channel, now)
+
+	after := usr
+	after.Version++
+
+	verify := func(ctx context.Context) error {
+		if err := s.repo.Update(ctx, usr); err != nil {
+			return err
+		}
+		return s.repo.DeleteVerification(ctx, id, channel)
+	}
+	if err := s.mutate(ctx, verify, mutation{action: AuditActionVerify, before: before, after: after}); err != nil {
+		log.Err(err).Msg("failed: verify contact")
+		return before, err
+	}
+
+	log.Info().Str("user_id", id).Str("channel", string(channel)).Msg("contact verified")
+
+	return after, nil
+}
+
+// deleteVerification delete pending code of every channel of the user
+func (s *service) deleteVerification(ctx context.Context, id string) error {
+	for _, channel := range verificationChannels {
+		if err := s.repo.DeleteVerification(ctx, id, channel); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,246 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tuingking/supersvc/pkg/notify"
+)
+
+var verificationCodeRegexp = regexp.MustCompile(`\d{6}`)
+
+// stubNotifier keep sent message, code return the code of the last message
+type stubNotifier struct {
+	msgs []notify.Message
+	err  error
+}
+
+func (n *stubNotifier) Notify(ctx context.Context, msg notify.Message) error {
+	if n.err != nil {
+		return n.err
+	}
+	n.msgs = append(n.msgs, msg)
+	return nil
+}
+
+func (n *stubNotifier) code() string {
+	if len(n.msgs) == 0 {
+		return ""
+	}
+	return verificationCodeRegexp.FindString(n.msgs[len(n.msgs)-1].Body)
+}
+
+func Test_Service_VerifyContact(t *testing.T) {
+	ctx := tenantCtx
+
+	t.Run("email", func(t *testing.T) {
+		repo, notifier := newTestRepository(stubUser), &stubNotifier{}
+		svc := NewService(Option{}, repo, WithNotifier(notifier))
+
+		sent, err := svc.SendVerification(ctx, stubUser.ID, VerificationChannelEmail)
+		assert.Nil(t, err)
+		assert.Equal(t, VerificationChannelEmail, sent.Channel)
+		assert.WithinDuration(t, time.Now().Add(defaultVerificationCodeTTL), sent.ExpiresAt, time.Minute)
+
+		assert.Len(t, notifier.msgs, 1)
+		assert.Equal(t, notify.ChannelEmail, notifier.msgs[0].Channel)
+		assert.Equal(t, stubUser.Email, notifier.msgs[0].To)
+		code := notifier.code()
+		assert.Len(t, code, defaultVerificationCodeLength)
+
+		_, err = svc.VerifyContact(ctx, stubUser.ID, VerificationChannelEmail, "")
+		assert.ErrorIs(t, err, ErrInvalidVerificationCode)
+
+		usr, err := svc.VerifyContact(ctx, stubUser.ID, VerificationChannelEmail, code)
+		assert.Nil(t, err)
+		assert.NotNil(t, usr.EmailVerifiedAt)
+		assert.Nil(t, usr.PhoneVerifiedAt)
+		assert.Equal(t, stubUser.Version+1, usr.Version)
+		assert.Equal(t, stored(usr), repo.rows().users[stubUser.ID])
+		assert.Empty(t, repo.rows().verifications)
+
+		assert.Len(t, repo.rows().auditLogs, 1)
+		assert.Equal(t, AuditActionVerify, repo.rows().auditLogs[0].Action)
+		assert.Equal(t, "email_verified_at", repo.rows().auditLogs[0].Changes[0].Field)
+
+		_, err = svc.SendVerification(ctx, stubUser.ID, VerificationChannelEmail)
+		assert.ErrorIs(t, err, ErrContactVerified)
+		_, err = svc.VerifyContact(ctx, stubUser.ID, VerificationChannelEmail, code)
+		assert.ErrorIs(t, err, ErrContactVerified)
+	})
+
+	t.Run("phone", func(t *testing.T) {
+		repo, notifier := newTestRepository(stubUser), &stubNotifier{}
+		svc := NewService(Option{Verification: VerificationOption{CodeLength: 8}}, repo, WithNotifier(notifier))
+
+		_, err := svc.SendVerification(ctx, stubUser.ID, VerificationChannelPhone)
+		assert.Nil(t, err)
+		assert.Equal(t, notify.ChannelSMS, notifier.msgs[0].Channel)
+		assert.Equal(t, stubUser.Phone, notifier.msgs[0].To)
+
+		code := regexp.MustCompile(`\d{8}`).FindString(notifier.msgs[0].Body)
+		usr, err := svc.VerifyContact(ctx, stubUser.ID, VerificationChannelPhone, code)
+		assert.Nil(t, err)
+		assert.NotNil(t, usr.PhoneVerifiedAt)
+		assert.Nil(t, usr.EmailVerifiedAt)
+	})
+
+	t.Run("new code replace previous", func(t *testing.T) {
+		notifier := &stubNotifier{}
+		svc := NewService(Option{Verification: VerificationOption{ResendCooldown: time.Nanosecond}}, newTestRepository(stubUser), WithNotifier(notifier))
+
+		_, err := svc.SendVerification(ctx, stubUser.ID, VerificationChannelEmail)
+		assert.Nil(t, err)
+		previous := notifier.code()
+		time.Sleep(time.Millisecond)
+		_, err = svc.SendVerification(ctx, stubUser.ID, VerificationChannelEmail)
+		assert.Nil(t, err)
+
+		if previous != notifier.code() {
+			_, err = svc.VerifyContact(ctx, stubUser.ID, VerificationChannelEmail, previous)
+			assert.ErrorIs(t, err, ErrInvalidVerificationCode)
+		}
+		_, err = svc.VerifyContact(ctx, stubUser.ID, VerificationChannelEmail, notifier.code())
+		assert.Nil(t, err)
+	})
+
+	t.Run("max attempts", func(t *testing.T) {
+		notifier := &stubNotifier{}
+		svc := NewService(Option{Verification: VerificationOption{MaxAttempts: 2}}, newTestRepository(stubUser), WithNotifier(notifier))
+
+		_, err := svc.SendVerification(ctx, stubUser.ID, VerificationChannelEmail)
+		assert.Nil(t, err)
+
+		for i := 0; i < 2; i++ {
+			_, err = svc.VerifyContact(ctx, stubUser.ID, VerificationChannelEmail, "wrong")
+			assert.ErrorIs(t, err, ErrInvalidVerificationCode)
+		}
+		_, err = svc.VerifyContact(ctx, stubUser.ID, VerificationChannelEmail, notifier.code())
+		assert.ErrorIs(t, err, ErrInvalidVerificationCode)
+	})
+
+	t.Run("resend cooldown", func(t *testing.T) {
+		notifier := &stubNotifier{}
+		svc := NewService(Option{}, newTestRepository(stubUser), WithNotifier(notifier))
+
+		_, err := svc.SendVerification(ctx, stubUser.ID, VerificationChannelEmail)
+		assert.Nil(t, err)
+		code := notifier.code()
+		_, err = svc.SendVerification(ctx, stubUser.ID, VerificationChannelEmail)
+		assert.ErrorIs(t, err, ErrVerificationCooldown)
+		assert.Len(t, notifier.msgs, 1)
+
+		_, err = svc.VerifyContact(ctx, stubUser.ID, VerificationChannelEmail, code)
+		assert.Nil(t, err, "pending code is kept")
+	})
+
+	t.Run("resend keep attempts", func(t *testing.T) {
+		notifier := &stubNotifier{}
+		svc := NewService(Option{Verification: VerificationOption{MaxAttempts: 2, ResendCooldown: time.Nanosecond}}, newTestRepository(stubUser), WithNotifier(notifier))
+
+		_, err := svc.SendVerification(ctx, stubUser.ID, VerificationChannelEmail)
+		assert.Nil(t, err)
+		_, err = svc.VerifyContact(ctx, stubUser.ID, VerificationChannelEmail, "wrong")
+		assert.ErrorIs(t, err, ErrInvalidVerificationCode)
+
+		time.Sleep(time.Millisecond)
+		_, err = svc.SendVerification(ctx, stubUser.ID, VerificationChannelEmail)
+		assert.Nil(t, err)
+		_, err = svc.VerifyContact(ctx, stubUser.ID, VerificationChannelEmail, "wrong")
+		assert.ErrorIs(t, err, ErrInvalidVerificationCode)
+
+		time.Sleep(time.Millisecond)
+		_, err = svc.SendVerification(ctx, stubUser.ID, VerificationChannelEmail)
+		assert.Nil(t, err)
+		_, err = svc.VerifyContact(ctx, stubUser.ID, VerificationChannelEmail, notifier.code())
+		assert.ErrorIs(t, err, ErrInvalidVerificationCode, "attempt budget is spent before resend")
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		notifier := &stubNotifier{}
+		svc := NewService(Option{Verification: VerificationOption{CodeTTL: time.Nanosecond}}, newTestRepository(stubUser), WithNotifier(notifier))
+
+		_, err := svc.SendVerification(ctx, stubUser.ID, VerificationChannelEmail)
+		assert.Nil(t, err)
+
+		time.Sleep(time.Millisecond)
+		_, err = svc.VerifyContact(ctx, stubUser.ID, VerificationChannelEmail, notifier.code())
+		assert.ErrorIs(t, err, ErrInvalidVerificationCode)
+	})
+
+	t.Run("contact changed", func(t *testing.T) {
+		repo, notifier := newTestRepository(stubUser), &stubNotifier{}
+		svc := NewService(Option{}, repo, WithNotifier(notifier))
+
+		_, err := svc.SendVerification(ctx, stubUser.ID, VerificationChannelEmail)
+		assert.Nil(t, err)
+
+		usr, err := svc.UpdateUser(ctx, stubUser.ID, stubUser.Version, UpdateUser{Name: "foo", Phone: stubUser.Phone, Email: "bar@foo.com", Status: UserStatusActive})
+		assert.Nil(t, err)
+
+		_, err = svc.VerifyContact(ctx, usr.ID, VerificationChannelEmail, notifier.code())
+		assert.ErrorIs(t, err, ErrInvalidVerificationCode)
+	})
+
+	t.Run("no contact", func(t *testing.T) {
+		noPhone := stubUser
+		noPhone.Phone = ""
+		svc := NewService(Option{}, newTestRepository(noPhone), WithNotifier(&stubNotifier{}))
+
+		_, err := svc.SendVerification(ctx, stubUser.ID, VerificationChannelPhone)
+		assert.ErrorIs(t, err, ErrNoContact)
+	})
+
+	t.Run("notifier failed", func(t *testing.T) {
+		errNotify := errors.New("provider unavailable")
+		svc := NewService(Option{}, newTestRepository(stubUser), WithNotifier(&stubNotifier{err: errNotify}))
+
+		_, err := svc.SendVerification(ctx, stubUser.ID, VerificationChannelEmail)
+		assert.ErrorIs(t, err, errNotify)
+	})
+
+	t.Run("no notifier", func(t *testing.T) {
+		svc := NewService(Option{}, newTestRepository(stubUser))
+
+		_, err := svc.SendVerification(ctx, stubUser.ID, VerificationChannelEmail)
+		assert.ErrorIs(t, err, notify.ErrNotConfigured)
+	})
+}
+
+func Test_Service_ClearStaleVerification(t *testing.T) {
+	ctx := tenantCtx
+	verifiedAt := time.Date(2024, 01, 02, 0, 0, 0, 0, time.UTC)
+	verified := stubUser
+	verified.EmailVerifiedAt, verified.PhoneVerifiedAt = &verifiedAt, &verifiedAt
+
+	t.Run("update", func(t *testing.T) {
+		svc := NewService(Option{}, newTestRepository(verified))
+
+		usr, err := svc.UpdateUser(ctx, stubUser.ID, stubUser.Version, UpdateUser{Name: "bar", Phone: stubUser.Phone, Email: "bar@foo.com", Status: UserStatusActive})
+		assert.Nil(t, err)
+		assert.Nil(t, usr.EmailVerifiedAt)
+		assert.Equal(t, &verifiedAt, usr.PhoneVerifiedAt)
+	})
+
+	t.Run("patch", func(t *testing.T) {
+		svc := NewService(Option{}, newTestRepository(verified))
+
+		usr, err := svc.PatchUser(ctx, stubUser.ID, stubUser.Version, []byte(`{"phone":"+6281234567891","email_verified_at":null}`))
+		assert.Nil(t, err)
+		assert.Equal(t, &verifiedAt, usr.EmailVerifiedAt, "verification cannot be patched")
+		assert.Nil(t, usr.PhoneVerifiedAt)
+	})
+}
+
+func Test_Service_CreateUser_NotVerified(t *testing.T) {
+	verifiedAt := time.Date(2024, 01, 02, 0, 0, 0, 0, time.UTC)
+	svc := NewService(Option{}, newTestRepository())
+
+	usr, err := svc.CreateUser(tenantCtx, User{Name: "foo", Email: "foo@bar.com", EmailVerifiedAt: &verifiedAt})
+	assert.Nil(t, err)
+	assert.Nil(t, usr.EmailVerifiedAt)
+}